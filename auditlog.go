@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ///////////////////
+// AUDIT LOG
+// ///////////////////
+
+// --log-file keeps a JSON-lines record of every row a run handled, for
+// quality management systems that need to show what was machine translated.
+// Records are written as rows finish, so a crashed run still leaves its
+// trail, and the file is appended to, so earlier runs are kept.
+
+// auditRecord is one line of the log file.
+type auditRecord struct {
+	Time       string `json:"time"`
+	Run        string `json:"run"` // start of the run, tells runs in one file apart
+	File       string `json:"file"`
+	Sheet      string `json:"sheet"`
+	Row        int    `json:"row"`
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Tokens     int64  `json:"tokens"`
+	LatencyMS  int64  `json:"latency_ms"`
+	Model      string `json:"model"`
+}
+
+// auditLog is the --log-file of a run.
+type auditLog struct {
+	mu    sync.Mutex
+	file  *os.File
+	enc   *json.Encoder
+	run   string
+	model string
+	err   error // first write error; later records are dropped
+}
+
+// openAuditLog opens path for appending.
+func openAuditLog(path, model string) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open log file: %w", err)
+	}
+	return &auditLog{file: file, enc: json.NewEncoder(file), run: time.Now().Format(time.RFC3339), model: model}, nil
+}
+
+// forFile returns the writer the jobs of one file record their rows with.
+func (l *auditLog) forFile(fileName string) *fileAudit {
+	if l == nil {
+		return nil
+	}
+	return &fileAudit{log: l, fileName: fileName}
+}
+
+// Close closes the file and returns the first error writing it.
+func (l *auditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	err := l.file.Close()
+	if l.err != nil {
+		return l.err
+	}
+	return err
+}
+
+// fileAudit records the rows of one file. A nil *fileAudit records nothing.
+type fileAudit struct {
+	log      *auditLog
+	fileName string
+}
+
+func (a *fileAudit) record(e reportEntry) {
+	if a == nil {
+		return
+	}
+	l := a.log
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	l.err = l.enc.Encode(auditRecord{
+		Time:       e.at.Format(time.RFC3339),
+		Run:        l.run,
+		File:       a.fileName,
+		Sheet:      e.sheet,
+		Row:        e.row,
+		SourceLang: e.sourceLang,
+		TargetLang: e.targetLang,
+		Source:     e.source,
+		Target:     e.translation,
+		Status:     string(e.status),
+		Detail:     e.detail,
+		Tokens:     e.tokens,
+		LatencyMS:  e.latency.Milliseconds(),
+		Model:      l.model,
+	})
+}
+
+// tokenCounter adds up the tokens providers report for the requests of one
+// row, and the requests that were retried. It travels in the context, like
+// promptHints.
+type tokenCounter struct {
+	n       atomic.Int64
+	retries atomic.Int64
+}
+
+type tokenCounterKey struct{}
+
+func withTokenCounter(ctx context.Context, c *tokenCounter) context.Context {
+	return context.WithValue(ctx, tokenCounterKey{}, c)
+}
+
+// countTokens adds n to the counter in ctx, if there is one. Batch requests
+// carry none: their tokens belong to no single row.
+func countTokens(ctx context.Context, n int) {
+	if c, ok := ctx.Value(tokenCounterKey{}).(*tokenCounter); ok {
+		c.n.Add(int64(n))
+	}
+}
+
+// countRetry counts a retried request in the counter in ctx, if there is
+// one.
+func countRetry(ctx context.Context) {
+	if c, ok := ctx.Value(tokenCounterKey{}).(*tokenCounter); ok {
+		c.retries.Add(1)
+	}
+}
+
+// reportFromLog rebuilds the report of one run from the records of a log
+// file: of the run started at run, or of the last run in the file if run is
+// empty.
+func reportFromLog(r io.Reader, run string) (*runReport, error) {
+	var records []auditRecord
+	decoder := json.NewDecoder(r)
+	for {
+		var rec auditRecord
+		if err := decoder.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read log file: %w", err)
+		}
+		records = append(records, rec)
+	}
+	if run == "" && len(records) > 0 {
+		run = records[len(records)-1].Run
+	}
+
+	report := &runReport{}
+	files := make(map[string]*fileReport)
+	for _, rec := range records {
+		if rec.Run != run {
+			continue
+		}
+		report.model = rec.Model
+		fr, ok := files[rec.File]
+		if !ok {
+			fr = report.addFile(rec.File)
+			files[rec.File] = fr
+		}
+		fr.entries = append(fr.entries, reportEntry{
+			sheet:       rec.Sheet,
+			row:         rec.Row,
+			sourceLang:  rec.SourceLang,
+			targetLang:  rec.TargetLang,
+			source:      rec.Source,
+			translation: rec.Target,
+			status:      rowStatus(rec.Status),
+			detail:      rec.Detail,
+			tokens:      rec.Tokens,
+			latency:     time.Duration(rec.LatencyMS) * time.Millisecond,
+		})
+	}
+	if len(report.files) == 0 {
+		if run == "" {
+			return nil, errors.New("the log file has no records")
+		}
+		return nil, fmt.Errorf("the log file has no records of run %s", run)
+	}
+	return report, nil
+}
+
+// runReportCommand writes the --report of a past run from its --log-file.
+func runReportCommand(args []string) error {
+	usage := "usage: report [--run TIME] LOG.jsonl OUT.html|OUT.md"
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	run := fs.String("run", "", "Start of the run to report, as in the run field of the log (default: the last run in the file).")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New(usage)
+	}
+	logPath, path := fs.Arg(0), fs.Arg(1)
+
+	file, err := os.Open(logPath)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+	defer file.Close()
+	report, err := reportFromLog(file, *run)
+	if err != nil {
+		return err
+	}
+	if err := report.write(path); err != nil {
+		return err
+	}
+	fmt.Printf("Saved report to %s (%s)\n", path, strings.Join(report.counts(), ", "))
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tokenTranslator upper-cases texts and reports one token per character.
+type tokenTranslator struct{}
+
+func (tokenTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	countTokens(ctx, len(text))
+	return strings.ToUpper(text), nil
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Pump running", ""},
+		{"Text", ""},
+		{"Pump running", ""},
+	}
+	for run := 0; run < 2; run++ {
+		audit, err := openAuditLog(path, "openai/test-model")
+		if err != nil {
+			t.Fatal(err)
+		}
+		job := newTestJob(t, rows)
+		job.audit = audit.forFile("export.xlsx")
+		iterateAndTranslate(context.Background(), discardSender{}, tokenTranslator{}, job)
+		if err := audit.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []auditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 6 {
+		t.Fatalf("%d records; expected 3 rows in each of 2 runs", len(records))
+	}
+
+	byRow := make(map[int]auditRecord)
+	for _, r := range records[:3] {
+		byRow[r.Row] = r
+	}
+	translated := byRow[2]
+	if translated.File != "export.xlsx" || translated.Sheet == "" || translated.SourceLang != "de-DE" || translated.TargetLang != "en-US" {
+		t.Errorf("record for row 2 = %+v", translated)
+	}
+	if translated.Target != "PUMP RUNNING" || translated.Status != string(rowTranslated) || translated.Tokens != int64(len("Pump running")) || translated.Model != "openai/test-model" {
+		t.Errorf("record for row 2 = %+v", translated)
+	}
+	if skipped := byRow[3]; skipped.Status != string(rowSkipped) || skipped.Tokens != 0 || skipped.Detail == "" {
+		t.Errorf("record for row 3 = %+v", skipped)
+	}
+	if reused := byRow[4]; reused.Status != string(rowReused) || reused.Tokens != 0 {
+		t.Errorf("record for row 4 = %+v", reused)
+	}
+	if records[0].Run == "" || records[0].Time == "" {
+		t.Errorf("record without run or time: %+v", records[0])
+	}
+}
+
+func TestReportFromLog(t *testing.T) {
+	log := strings.Join([]string{
+		`{"run": "2026-03-01T08:00:00Z", "file": "old.xlsx", "sheet": "Sheet1", "row": 2, "source": "Alt", "target": "OLD", "status": "translated", "model": "openai/gpt-4o"}`,
+		`{"run": "2026-03-02T08:00:00Z", "file": "a.xlsx", "sheet": "Sheet1", "row": 3, "source": "Motor", "target": "MOTOR", "status": "translated", "tokens": 5, "latency_ms": 120, "model": "openai/gpt-4o-mini"}`,
+		`{"run": "2026-03-02T08:00:00Z", "file": "b.xlsx", "sheet": "Sheet1", "row": 2, "source": "##Tag##", "target": "##Tag##", "status": "placeholder copied", "model": "openai/gpt-4o-mini"}`,
+		`{"run": "2026-03-02T08:00:00Z", "file": "a.xlsx", "sheet": "Sheet1", "row": 2, "source": "Pumpe", "status": "error", "detail": "timeout", "model": "openai/gpt-4o-mini"}`,
+	}, "\n")
+
+	report, err := reportFromLog(strings.NewReader(log), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.model != "openai/gpt-4o-mini" || len(report.files) != 2 || report.files[0].fileName != "a.xlsx" || report.files[1].fileName != "b.xlsx" {
+		t.Fatalf("report of the last run: model %s, %d files", report.model, len(report.files))
+	}
+	entries := report.files[0].sorted()
+	if len(entries) != 2 || entries[0].status != rowFailed || entries[1].translation != "MOTOR" || entries[1].latency != 120*time.Millisecond {
+		t.Errorf("entries of a.xlsx = %+v", entries)
+	}
+
+	if report, err = reportFromLog(strings.NewReader(log), "2026-03-01T08:00:00Z"); err != nil || len(report.files) != 1 || report.files[0].fileName != "old.xlsx" {
+		t.Errorf("report of the first run: %v", err)
+	}
+	if _, err := reportFromLog(strings.NewReader(log), "2026-03-05T08:00:00Z"); err == nil {
+		t.Error("no error for an unknown run")
+	}
+	if _, err := reportFromLog(strings.NewReader("{not json"), ""); err == nil {
+		t.Error("no error for a broken log file")
+	}
+}
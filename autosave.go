@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// AUTO-SAVE
+// ///////////////////
+
+// autosaver writes the in-progress workbook to a sidecar file every few
+// translated rows, so a crash loses at most that many rows of output. Like
+// the checkpoint it is only used from the goroutine that writes the
+// workbook. A nil *autosaver does nothing.
+type autosaver struct {
+	f       *excelize.File
+	path    string
+	every   int
+	pending int
+}
+
+const autosaveSuffix = ".autosave.xlsx"
+
+// autosavePath is the sidecar file next to the input file. It is always an
+// XLSX workbook, whatever the input format.
+func autosavePath(fileName string) string {
+	return fileName + autosaveSuffix
+}
+
+// skipPrefixes are the file name prefixes of earlier outputs, set by
+// --skip-prefix.
+var skipPrefixes = []string{"translated-"}
+
+// isSidecarFile reports whether name was written by a run rather than being
+// an input file.
+func isSidecarFile(name string) bool {
+	name = filepath.Base(name)
+	if strings.HasSuffix(name, autosaveSuffix) || strings.HasPrefix(name, "partial-") || strings.Contains(name, backupInfix) {
+		return true
+	}
+	for _, prefix := range skipPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// newAutosaver returns nil if every is below 1, which disables auto-save.
+func newAutosaver(f *excelize.File, path string, every int) *autosaver {
+	if every < 1 {
+		return nil
+	}
+	return &autosaver{f: f, path: path, every: every}
+}
+
+// rowWritten counts a translated row and saves once enough have accumulated.
+func (a *autosaver) rowWritten() error {
+	if a == nil {
+		return nil
+	}
+	a.pending++
+	if a.pending < a.every {
+		return nil
+	}
+	return a.save()
+}
+
+// save writes the workbook atomically, so a crash mid-write keeps the
+// previous auto-save intact.
+func (a *autosaver) save() error {
+	if a == nil || a.pending == 0 {
+		return nil
+	}
+	tmp := a.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("could not auto-save: %w", err)
+	}
+	if _, err := a.f.WriteTo(file); err != nil {
+		file.Close()
+		return fmt.Errorf("could not auto-save: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("could not auto-save: %w", err)
+	}
+	if err := os.Rename(tmp, a.path); err != nil {
+		return fmt.Errorf("could not auto-save: %w", err)
+	}
+	a.pending = 0
+	return nil
+}
+
+// remove deletes the auto-save once the real output has been written.
+func (a *autosaver) remove() error {
+	if a == nil {
+		return nil
+	}
+	if err := os.Remove(a.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
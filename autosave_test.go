@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestAutosave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plant.xlsx"+autosaveSuffix)
+	f := excelize.NewFile()
+	a := newAutosaver(f, path, 2)
+
+	f.SetCellValue("Sheet1", "B2", "Pump")
+	if err := a.rowWritten(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatalf("auto-saved after 1 row, expected to wait for 2")
+	}
+	f.SetCellValue("Sheet1", "B3", "Valve")
+	if err := a.rowWritten(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatalf("auto-save not readable: %v", err)
+	}
+	got, _ := saved.GetCellValue("Sheet1", "B3")
+	saved.Close()
+	if got != "Valve" {
+		t.Errorf("auto-saved B3 = %q, expected %q", got, "Valve")
+	}
+
+	if err := a.remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("auto-save still exists after remove")
+	}
+
+	off := newAutosaver(f, path, 0)
+	if off != nil {
+		t.Fatalf("--autosave-every 0 should disable auto-save")
+	}
+	if err := off.rowWritten(); err != nil {
+		t.Error(err)
+	}
+}
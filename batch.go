@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ///////////////////
+// BATCHED REQUESTS
+// ///////////////////
+
+// batchTranslator is optionally implemented by a Translator that can
+// translate several texts in one request. The result has one entry per input
+// text, in the same order.
+type batchTranslator interface {
+	TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error)
+}
+
+func cacheKey(text, sourceLang, targetLang string) string {
+	return sourceLang + "\x00" + targetLang + "\x00" + text
+}
+
+// dedupTranslator makes sure each distinct text is only sent once per row
+// context: concurrent callers asking for the same text wait for the first
+// call, later callers get the stored result. Errors are passed to the waiting
+// callers but not stored, so a later call tries the text again.
+type dedupTranslator struct {
+	Translator
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+type dedupEntry struct {
+	done   chan struct{}
+	result string
+	err    error
+}
+
+func newDedupTranslator(inner Translator) *dedupTranslator {
+	return &dedupTranslator{Translator: inner, entries: make(map[string]*dedupEntry)}
+}
+
+func (t *dedupTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	// A stricter second attempt must reach the provider, not the stored
+	// reply that prompted it.
+	if promptHintsFrom(ctx).retry() {
+		return t.Translator.Translate(ctx, text, sourceLang, targetLang)
+	}
+	key := cacheKey(text, sourceLang, targetLang) + "\x00" + promptHintsFrom(ctx).context
+	t.mu.Lock()
+	if e, ok := t.entries[key]; ok {
+		t.mu.Unlock()
+		<-e.done
+		return e.result, e.err
+	}
+	e := &dedupEntry{done: make(chan struct{})}
+	t.entries[key] = e
+	t.mu.Unlock()
+
+	e.result, e.err = t.Translator.Translate(ctx, text, sourceLang, targetLang)
+	if e.err != nil {
+		t.mu.Lock()
+		delete(t.entries, key)
+		t.mu.Unlock()
+	}
+	close(e.done)
+	return e.result, e.err
+}
+
+// prefetchTranslator serves translations that were fetched ahead of time in
+// batches and falls back to the wrapped Translator for everything else.
+type prefetchTranslator struct {
+	Translator
+	mu      sync.Mutex
+	results map[string]string
+}
+
+func newPrefetchTranslator(inner Translator) *prefetchTranslator {
+	return &prefetchTranslator{Translator: inner, results: make(map[string]string)}
+}
+
+func (t *prefetchTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	t.mu.Lock()
+	result, ok := t.results[cacheKey(text, sourceLang, targetLang)]
+	t.mu.Unlock()
+	// Prefetched results were made without context or retry hints.
+	if hints := promptHintsFrom(ctx); ok && hints.context == "" && !hints.retry() {
+		return result, nil
+	}
+	return t.Translator.Translate(ctx, text, sourceLang, targetLang)
+}
+
+// prefetch translates texts in batches of batchSize using up to workers
+// concurrent requests. hint attaches the prompt hints for a batch. A failed
+// batch is only logged: its texts are simply translated one by one later.
+func (t *prefetchTranslator) prefetch(ctx context.Context, p msgSender, bt batchTranslator, texts []string, sourceLang, targetLang string, batchSize, workers int, hint func(context.Context, ...string) context.Context) {
+	var batches [][]string
+	seen := make(map[string]bool)
+	var current []string
+	for _, text := range texts {
+		if seen[text] {
+			continue
+		}
+		seen[text] = true
+		current = append(current, text)
+		if len(current) == batchSize {
+			batches = append(batches, current)
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+
+	queue := make(chan []string)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range queue {
+				p.Send(logMsg(fmt.Sprintf("Translating: batch of %d texts", len(batch))))
+				results, err := bt.TranslateBatch(hint(ctx, batch...), batch, sourceLang, targetLang)
+				if err == nil && len(results) != len(batch) {
+					err = fmt.Errorf("expected %d translations, got %d", len(batch), len(results))
+				}
+				if err != nil {
+					p.Send(logMsg(fmt.Sprintf("Batch failed, falling back to single rows: %v", err)))
+					continue
+				}
+				t.mu.Lock()
+				for i, text := range batch {
+					t.results[cacheKey(text, sourceLang, targetLang)] = results[i]
+				}
+				t.mu.Unlock()
+			}
+		}()
+	}
+	for _, batch := range batches {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- batch
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// pendingTexts lists the texts of batchTexts the translation memory cannot
+// serve.
+func (j *translationJob) pendingTexts(jobs []*rowJob) []string {
+	var texts []string
+	for _, text := range batchTexts(jobs, j.protected) {
+		if _, _, ok := j.memoryMatch(text); !ok {
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
+
+// batchTexts lists the texts the planned jobs will send to the translator,
+// in row order and with their markup and protected terms already swapped
+// for markers.
+func batchTexts(jobs []*rowJob, protected *protectedTerms) []string {
+	var texts []string
+	add := func(text string) {
+		masked, _ := protectTags(text, protected)
+		texts = append(texts, masked)
+	}
+	for _, rj := range jobs {
+		switch {
+		case rj.resumed, rj.context != "":
+			// Batches carry no per-text context, so rows with one are
+			// sent on their own.
+			continue
+		case rj.chunked:
+			// Chunks are sent with the text before them as context.
+			continue
+		case rj.sourceLang != "":
+			// Batches are in the language of the column.
+			continue
+		case rj.segments != nil:
+			for idx, segment := range rj.segments {
+				if trimmed := strings.TrimSpace(segment); idx%2 == 0 && trimmed != "" {
+					add(trimmed)
+				}
+			}
+		case rj.prev == nil:
+			add(rj.source)
+		case !rj.identical:
+			if _, err := strconv.Atoi(rj.suffix); err != nil {
+				add(rj.suffix)
+			}
+		}
+	}
+	return texts
+}
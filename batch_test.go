@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// batchUpperTranslator is an upperTranslator that also supports batches.
+type batchUpperTranslator struct {
+	upperTranslator
+	batches [][]string
+}
+
+func (b *batchUpperTranslator) TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	b.mu.Lock()
+	b.batches = append(b.batches, texts)
+	b.mu.Unlock()
+	results := make([]string, len(texts))
+	for i, text := range texts {
+		results[i] = strings.ToUpper(text)
+	}
+	return results, nil
+}
+
+func TestBatchedTranslation(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Pump running", ""},
+		{"Valve open", ""},
+		{"Motor fault", ""},
+		{"Tank full", ""},
+	}
+	job := newTestJob(t, rows)
+	job.batchSize = 2
+	translator := &batchUpperTranslator{}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	for i, row := range rows[1:] {
+		got, _ := job.f.GetCellValue(job.sheetName, "B"+string(rune('2'+i)))
+		if want := strings.ToUpper(row[0]); got != want {
+			t.Errorf("row %d = %q; expected %q", i+2, got, want)
+		}
+	}
+	if len(translator.calls) != 0 {
+		t.Errorf("single-text requests made: %v; expected none", translator.calls)
+	}
+	if len(translator.batches) != 2 {
+		t.Errorf("got %d batches (%v); expected 2", len(translator.batches), translator.batches)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ///////////////////
+// BATCH API
+// ///////////////////
+
+// With --batch-api the texts of a sheet are not sent one request at a time
+// but uploaded as one OpenAI Batch job, which costs half as much and is
+// finished within 24 hours. The replies are merged into a
+// prefetchTranslator, so the rows then go through the normal pipeline with
+// all its checks. Texts the job did not answer are sent as ordinary
+// requests.
+
+// batchJobTranslator is a provider that can run many requests as one
+// asynchronous job. hint attaches the prompt hints for a text. The result
+// maps each answered text to its translation.
+type batchJobTranslator interface {
+	translateJob(ctx context.Context, p msgSender, texts []string, sourceLang, targetLang string, hint func(context.Context, ...string) context.Context) (map[string]string, error)
+}
+
+const (
+	batchAPIMaxRequests  = 50000 // most requests OpenAI accepts in one job
+	batchAPIPollInterval = 30 * time.Second
+	batchAPIDiscount     = 0.5 // share of the list price a job costs
+)
+
+// prefetchJob translates texts with a batch job and keeps the results. A
+// failed job is only logged: its texts are translated one by one later.
+func (t *prefetchTranslator) prefetchJob(ctx context.Context, p msgSender, bj batchJobTranslator, texts []string, sourceLang, targetLang string, hint func(context.Context, ...string) context.Context) {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		if !seen[text] {
+			seen[text] = true
+			unique = append(unique, text)
+		}
+	}
+	if len(unique) == 0 {
+		return
+	}
+	results, err := bj.translateJob(ctx, p, unique, sourceLang, targetLang, hint)
+	t.mu.Lock()
+	for text, result := range results {
+		t.results[cacheKey(text, sourceLang, targetLang)] = result
+	}
+	t.mu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		p.Send(logMsg(fmt.Sprintf("WARNING: batch job failed: %v", err)))
+	}
+	if missing := len(unique) - len(results); missing > 0 {
+		p.Send(logMsg(fmt.Sprintf("WARNING: %d texts were not translated by the batch job and are sent as regular requests at the full price", missing)))
+	}
+}
+
+// translateJob runs texts as OpenAI Batch jobs of at most
+// batchAPIMaxRequests requests each.
+func (t *openaiTranslator) translateJob(ctx context.Context, p msgSender, texts []string, sourceLang, targetLang string, hint func(context.Context, ...string) context.Context) (map[string]string, error) {
+	results := make(map[string]string)
+	// A job is paid for in full once submitted, so it only gets the texts
+	// the budget covers.
+	if n := t.budget.affordable(texts, batchAPIDiscount); n < len(texts) {
+		p.Send(logMsg(fmt.Sprintf("WARNING: the budget covers about %d of %d texts; only those go into the batch job", n, len(texts))))
+		texts = texts[:n]
+	}
+	for start := 0; start < len(texts); start += batchAPIMaxRequests {
+		chunk := texts[start:min(start+batchAPIMaxRequests, len(texts))]
+		if err := t.runJob(ctx, p, chunk, sourceLang, targetLang, hint, results); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// runJob uploads one request per text, waits for the job and adds the
+// translations it returned to results.
+func (t *openaiTranslator) runJob(ctx context.Context, p msgSender, texts []string, sourceLang, targetLang string, hint func(context.Context, ...string) context.Context, results map[string]string) error {
+	upload := openai.UploadBatchFileRequest{FileName: "translations.jsonl"}
+	for i, text := range texts {
+		req, err := t.chatRequest(hint(ctx, text), text, sourceLang, targetLang)
+		if err != nil {
+			return err
+		}
+		// The custom ID is the index into texts.
+		upload.AddChatCompletion(strconv.Itoa(i), req)
+	}
+	resp, err := t.client.CreateBatchWithUploadFile(ctx, openai.CreateBatchWithUploadFileRequest{
+		Endpoint:               openai.BatchEndpointChatCompletions,
+		CompletionWindow:       "24h",
+		UploadBatchFileRequest: upload,
+	})
+	if err != nil {
+		return fmt.Errorf("could not submit the batch job: %w", err)
+	}
+	batch := resp.Batch
+	p.Send(logMsg(fmt.Sprintf("Submitted batch job %s with %d texts, waiting for it to finish (up to 24 hours)", batch.ID, len(texts))))
+
+	poll := t.poll
+	if poll <= 0 {
+		poll = batchAPIPollInterval
+	}
+	done := 0
+	for !batchFinished(batch.Status) {
+		if err := sleepContext(ctx, poll); err != nil {
+			t.cancelJob(p, batch.ID)
+			return err
+		}
+		resp, err := t.client.RetrieveBatch(ctx, batch.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				t.cancelJob(p, batch.ID)
+				return ctx.Err()
+			}
+			p.Send(logMsg(fmt.Sprintf("Could not check batch job %s, trying again: %v", batch.ID, err)))
+			continue
+		}
+		batch = resp.Batch
+		if n := batch.RequestCounts.Completed + batch.RequestCounts.Failed; n != done {
+			done = n
+			p.Send(logMsg(fmt.Sprintf("Batch job %s: %d of %d texts done", batch.ID, done, len(texts))))
+		}
+	}
+
+	if batch.Status == "failed" {
+		return fmt.Errorf("batch job %s failed%s", batch.ID, batchErrors(batch))
+	}
+	if batch.Status != "completed" {
+		p.Send(logMsg(fmt.Sprintf("WARNING: batch job %s %s before all texts were done", batch.ID, batch.Status)))
+	}
+	if batch.OutputFileID == nil || *batch.OutputFileID == "" {
+		return nil
+	}
+	content, err := t.client.GetFileContent(ctx, *batch.OutputFileID)
+	if err != nil {
+		return fmt.Errorf("could not download the results of batch job %s: %w", batch.ID, err)
+	}
+	defer content.Close()
+
+	translated, failed := 0, 0
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var line batchOutputLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		i, err := strconv.Atoi(line.CustomID)
+		if err != nil || i < 0 || i >= len(texts) {
+			continue
+		}
+		if line.Response == nil || line.Response.StatusCode != 200 {
+			failed++
+			continue
+		}
+		usage := line.Response.Body.Usage
+		t.budget.prepaid(usage.PromptTokens, usage.CompletionTokens, 0, batchAPIDiscount)
+		result, err := t.reply(line.Response.Body)
+		if err != nil {
+			failed++
+			continue
+		}
+		results[texts[i]] = result
+		translated++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read the results of batch job %s: %w", batch.ID, err)
+	}
+	p.Send(logMsg(fmt.Sprintf("Batch job %s: %d of %d texts translated, %d failed", batch.ID, translated, len(texts), failed)))
+	return nil
+}
+
+// batchOutputLine is a line of a batch job's output file.
+type batchOutputLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int                           `json:"status_code"`
+		Body       openai.ChatCompletionResponse `json:"body"`
+	} `json:"response"`
+}
+
+// cancelJob stops a job whose results are no longer wanted, so that it is
+// not billed. It runs after ctx is cancelled and so uses its own.
+func (t *openaiTranslator) cancelJob(p msgSender, id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := t.client.CancelBatch(ctx, id); err != nil {
+		p.Send(logMsg(fmt.Sprintf("WARNING: could not cancel batch job %s: %v", id, err)))
+		return
+	}
+	p.Send(logMsg(fmt.Sprintf("Cancelled batch job %s", id)))
+}
+
+// batchFinished reports whether a job in status will not change any more.
+func batchFinished(status string) bool {
+	switch status {
+	case "completed", "failed", "expired", "cancelled":
+		return true
+	}
+	return false
+}
+
+// batchErrors formats the errors of a failed job for the log.
+func batchErrors(batch openai.Batch) string {
+	if batch.Errors == nil || len(batch.Errors.Data) == 0 {
+		return ""
+	}
+	var msgs []string
+	for _, e := range batch.Errors.Data {
+		msgs = append(msgs, e.Message)
+	}
+	return ": " + strings.Join(msgs, "; ")
+}
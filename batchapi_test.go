@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeBatchServer answers the OpenAI file and batch endpoints. The job
+// translates texts to upper case, except texts containing "Broken", whose
+// requests fail. Regular chat requests are counted and answered "SINGLE".
+type fakeBatchServer struct {
+	mu     sync.Mutex
+	input  []string // lines of the uploaded file
+	polls  int
+	single int
+}
+
+func (s *fakeBatchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/files":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			s.input = append(s.input, scanner.Text())
+		}
+		fmt.Fprint(w, `{"id": "file-in", "object": "file", "purpose": "batch"}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/batches":
+		fmt.Fprint(w, `{"id": "batch_1", "status": "validating"}`)
+	case r.URL.Path == "/v1/batches/batch_1":
+		s.polls++
+		if s.polls == 1 {
+			fmt.Fprintf(w, `{"id": "batch_1", "status": "in_progress", "request_counts": {"total": %d}}`, len(s.input))
+			return
+		}
+		fmt.Fprintf(w, `{"id": "batch_1", "status": "completed", "output_file_id": "file-out", "request_counts": {"total": %d, "completed": %d}}`, len(s.input), len(s.input))
+	case r.URL.Path == "/v1/files/file-out/content":
+		for _, line := range s.input {
+			var req struct {
+				CustomID string `json:"custom_id"`
+				Body     struct {
+					Messages []struct {
+						Content string `json:"content"`
+					} `json:"messages"`
+				} `json:"body"`
+			}
+			json.Unmarshal([]byte(line), &req)
+			prompt := req.Body.Messages[0].Content
+			text := prompt[strings.LastIndex(prompt, "The text to translate is: ")+len("The text to translate is: "):]
+			if strings.Contains(text, "Broken") {
+				fmt.Fprintf(w, `{"custom_id": %q, "response": {"status_code": 500, "body": {}}}`+"\n", req.CustomID)
+				continue
+			}
+			reply, _ := json.Marshal(map[string]string{"translation": strings.ToUpper(text)})
+			body, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]string{"role": "assistant", "content": string(reply)}}}})
+			fmt.Fprintf(w, `{"custom_id": %q, "response": {"status_code": 200, "body": %s}}`+"\n", req.CustomID, body)
+		}
+	case r.URL.Path == "/v1/chat/completions":
+		s.single++
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "{\"translation\": \"SINGLE\"}"}}]}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestBatchAPI(t *testing.T) {
+	fake := &fakeBatchServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Broken valve", ""},
+		{"Motor fault", ""},
+		{"Pump on", ""},
+	}
+	job := newTestJob(t, rows)
+	job.batchAPI = true
+	translator := newOpenAITranslator("OpenAI", providerConfig{apiKey: "sk-test", baseURL: server.URL + "/v1"}, "gpt-4o-mini")
+	translator.poll = 1
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	expected := map[string]string{
+		"B2": "MOTOR FAULT",
+		"B3": "SINGLE",
+		"B4": "MOTOR FAULT",
+		"B5": "PUMP ON",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if len(fake.input) != 3 {
+		t.Errorf("job has %d requests; expected one per distinct text", len(fake.input))
+	}
+	if fake.single != 1 {
+		t.Errorf("%d regular requests; expected 1 for the text the job failed", fake.single)
+	}
+}
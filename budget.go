@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ///////////////////
+// BUDGET
+// ///////////////////
+
+// budget stops the run once --max-tokens or --max-cost is used up.
+// Providers report what each response used, and the run is then cancelled
+// as on Ctrl+C: the rows done so far are saved as a partial translation that
+// --resume can finish. Requests in flight are cancelled as well, so the
+// limit is overshot by at most the responses that arrive at that moment.
+type budget struct {
+	maxTokens int64   // 0 = no limit
+	maxCost   float64 // USD; 0 = no limit
+	price     modelPrice
+	count     func(string) int // counts the tokens of a text, see tokensFor
+	stop      func()           // cancels the run; set before it starts
+
+	mu       sync.Mutex
+	tokens   int64
+	cost     float64
+	exceeded bool
+}
+
+// newBudget returns nil without limits. A cost limit needs a known price for
+// the model.
+func newBudget(maxTokens int64, maxCost float64, provider, model string) (*budget, error) {
+	if maxTokens == 0 && maxCost == 0 {
+		return nil, nil
+	}
+	b := &budget{maxTokens: maxTokens, maxCost: maxCost, count: tokensFor(provider, model)}
+	if maxCost > 0 {
+		price, ok := priceOf(provider, model)
+		if !ok {
+			return nil, fmt.Errorf("--max-cost: no price is known for %s; use --max-tokens instead", modelLabel(provider, model))
+		}
+		b.price = price
+	}
+	return b, nil
+}
+
+// priceOf looks up the list price of a model. Models that cost nothing,
+// such as local ones, have none.
+func priceOf(provider, model string) (modelPrice, bool) {
+	mp, ok := modelPriceFor(provider, model)
+	if !ok || mp.input == 0 && mp.output == 0 && mp.perChar == 0 {
+		return modelPrice{}, false
+	}
+	return mp, true
+}
+
+// spend adds what a response used: its tokens, or the characters for a
+// provider that bills by character. discount scales the list price, e.g.
+// batchAPIDiscount. Once the budget is used up, the run is stopped.
+func (b *budget) spend(inputTokens, outputTokens, chars int, discount float64) {
+	if b == nil {
+		return
+	}
+	b.prepaid(inputTokens, outputTokens, chars, discount)
+	b.mu.Lock()
+	over := !b.exceeded && (b.maxTokens > 0 && b.tokens >= b.maxTokens || b.maxCost > 0 && b.cost >= b.maxCost)
+	if over {
+		b.exceeded = true
+	}
+	b.mu.Unlock()
+	if over && b.stop != nil {
+		b.stop()
+	}
+}
+
+// prepaid adds what a response used without stopping the run, for results
+// that are paid for already and should still be written, such as those of a
+// batch job. The next spend stops the run if the budget is used up.
+func (b *budget) prepaid(inputTokens, outputTokens, chars int, discount float64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += int64(inputTokens + outputTokens)
+	b.cost += b.costOf(inputTokens, outputTokens, chars, discount)
+}
+
+// costOf prices tokens and characters at discount times the list price.
+func (b *budget) costOf(inputTokens, outputTokens, chars int, discount float64) float64 {
+	return discount * (float64(inputTokens)/1e6*b.price.input + float64(outputTokens)/1e6*b.price.output + float64(chars)/1e6*b.price.perChar)
+}
+
+// affordable returns how many of texts, from the start, the rest of the
+// budget covers by the estimate of --dry-run. It is used before work that is
+// paid for up front, such as a batch job.
+func (b *budget) affordable(texts []string, discount float64) int {
+	if b == nil {
+		return len(texts)
+	}
+	b.mu.Lock()
+	tokens, cost := b.tokens, b.cost
+	b.mu.Unlock()
+	for i, text := range texts {
+		input := b.count(text) + promptOverheadTokens
+		output := estimateOutputTokens(b.count(text))
+		tokens += int64(input + output)
+		cost += b.costOf(input, output, utf8.RuneCountInString(text), discount)
+		if b.maxTokens > 0 && tokens > b.maxTokens || b.maxCost > 0 && cost > b.maxCost {
+			return i
+		}
+	}
+	return len(texts)
+}
+
+// reached reports whether the budget stopped the run.
+func (b *budget) reached() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
+
+// usage describes the spending against the limits, e.g. "$1.02 of $1.00".
+func (b *budget) usage() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var parts []string
+	if b.maxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d tokens", b.tokens, b.maxTokens))
+	}
+	if b.maxCost > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f of $%.2f", b.cost, b.maxCost))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// limits describes the limits for the run summary.
+func (b *budget) limits() string {
+	var parts []string
+	if b.maxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d tokens", b.maxTokens))
+	}
+	if b.maxCost > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f", b.maxCost))
+	}
+	return strings.Join(parts, ", ")
+}
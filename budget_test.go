@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBudget(t *testing.T) {
+	testCases := []struct {
+		provider, model string
+		ok              bool
+	}{
+		{"openai", "", true},
+		{"openai", "gpt-4.1", true},
+		{"openai", "my-finetune", false},
+		{"deepl", "", true},
+		{"ollama", "", false},
+	}
+	for _, tc := range testCases {
+		if _, err := newBudget(0, 5, tc.provider, tc.model); (err == nil) != tc.ok {
+			t.Errorf("newBudget(--max-cost, %s %q) error = %v; expected ok %t", tc.provider, tc.model, err, tc.ok)
+		}
+	}
+	if b, err := newBudget(0, 0, "openai", ""); b != nil || err != nil {
+		t.Errorf("newBudget() without limits = %v, %v; expected nil", b, err)
+	}
+
+	// gpt-4o-mini: $0.15 per million input and $0.60 per million output
+	// tokens.
+	b, _ := newBudget(0, 1, "openai", "")
+	stopped := 0
+	b.stop = func() { stopped++ }
+	b.prepaid(4_000_000, 0, 0, 0.5)
+	if b.reached() || stopped != 0 {
+		t.Error("prepaid results stopped the run")
+	}
+	if n := b.affordable([]string{"Motor fault", "Pump on"}, 1); n != 2 {
+		t.Errorf("affordable() = %d; expected both texts", n)
+	}
+	b.spend(1_000_000, 1_000_000, 0, 1)
+	if !b.reached() || stopped != 1 {
+		t.Fatalf("budget not reached at $%.2f", b.cost)
+	}
+	b.spend(10, 10, 0, 1)
+	if stopped != 1 {
+		t.Error("the run was stopped twice")
+	}
+	if got := b.usage(); got != "$1.05 of $1.00" {
+		t.Errorf("usage() = %q", got)
+	}
+	if n := b.affordable([]string{"Motor fault"}, 1); n != 0 {
+		t.Errorf("affordable() = %d after the budget is used up; expected 0", n)
+	}
+}
+
+func TestMaxTokens(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "{\"translation\": \"done\"}"}}], "usage": {"prompt_tokens": 8, "completion_tokens": 2, "total_tokens": 10}}`)
+	}))
+	defer server.Close()
+
+	rows := [][]string{{"de-DE", "en-US"}}
+	for i := 1; i <= 6; i++ {
+		rows = append(rows, []string{fmt.Sprintf("Motor %d fault", i), ""})
+	}
+	job := newTestJob(t, rows)
+	job.workers = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b, _ := newBudget(25, 0, "openai", "")
+	b.stop = cancel
+	translator := newOpenAITranslator("OpenAI", providerConfig{apiKey: "sk-test", baseURL: server.URL + "/v1", budget: b}, "gpt-4o-mini")
+	iterateAndTranslate(ctx, discardSender{}, translator, job)
+
+	if !b.reached() || requests.Load() != 3 {
+		t.Fatalf("%d requests, budget reached %t; expected the run to stop after 3", requests.Load(), b.reached())
+	}
+	for i := 2; i <= 7; i++ {
+		got, _ := job.f.GetCellValue(job.sheetName, fmt.Sprintf("B%d", i))
+		if want := map[bool]string{true: "done", false: ""}[i <= 4]; got != want {
+			t.Errorf("B%d = %q; expected %q", i, got, want)
+		}
+	}
+	if got := b.usage(); got != "30 of 25 tokens" {
+		t.Errorf("usage() = %q", got)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ///////////////////
+// CASE RULES
+// ///////////////////
+
+// Alarm banners are often written in capitals and screen headings in Title
+// Case, and models tend to answer both in sentence case. Translations are
+// brought back into the case of their source: ALL CAPS stays ALL CAPS, and
+// Title Case stays Title Case where the languages use it. Placeholders and
+// markup are left alone. --no-case-rules turns this off.
+
+// titleSmallWords stay lower case inside a Title Case text.
+var titleSmallWords = func() map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(`a an and as at but by for from in into nor of on or per the to via vs with
+		à au aux d de des du en et l la le les par pour sur un une
+		al con del e el la las los o para por un una y
+		da dei del della di e gli i il in lo per su
+		do dos em na no o os
+		een en het in met op te van voor`) {
+		words[w] = true
+	}
+	return words
+}()
+
+// wordRegex matches a word, with apostrophes and hyphens inside it.
+var wordRegex = regexp.MustCompile(`\p{L}[\p{L}\p{M}\d'’-]*`)
+
+// textOutsidePlaceholders returns text with its placeholders and markup
+// blanked out.
+func textOutsidePlaceholders(text string) string {
+	return placeholderTokenRegex.ReplaceAllString(text, " ")
+}
+
+// isAllCaps reports whether text has at least two letters with case and
+// none of them in lower case.
+func isAllCaps(text string) bool {
+	cased := 0
+	for _, r := range textOutsidePlaceholders(text) {
+		switch {
+		case unicode.IsLower(r):
+			return false
+		case unicode.IsUpper(r):
+			cased++
+		}
+	}
+	return cased >= 2
+}
+
+// isTitleCase reports whether text has at least two words and every one of
+// them that is not a small word inside the title starts with a capital.
+func isTitleCase(text string) bool {
+	words := wordRegex.FindAllString(textOutsidePlaceholders(text), -1)
+	if len(words) < 2 || isAllCaps(text) {
+		return false
+	}
+	capitals := 0
+	for i, w := range words {
+		first, _ := utf8.DecodeRuneInString(w)
+		switch {
+		case unicode.IsUpper(first):
+			capitals++
+		case i > 0 && titleSmallWords[w]:
+		default:
+			return false
+		}
+	}
+	return capitals >= 2
+}
+
+// titleCaseLanguage reports whether capitals in lang can mark a title. In
+// German they mark nouns, so a German source that looks like Title Case
+// usually is not, and a German translation must not be made one.
+func titleCaseLanguage(lang string) bool {
+	return headerLanguage(lang) != "de"
+}
+
+// applyCase gives translation the case of source: ALL CAPS, or Title Case
+// between languages that use it.
+func applyCase(source, translation, sourceLang, targetLang string) string {
+	switch {
+	case isAllCaps(source):
+		return mapOutsidePlaceholders(translation, strings.ToUpper)
+	case titleCaseLanguage(sourceLang) && titleCaseLanguage(targetLang) && isTitleCase(source):
+		first := true
+		return mapOutsidePlaceholders(translation, func(s string) string {
+			return wordRegex.ReplaceAllStringFunc(s, func(w string) string {
+				small := !first && titleSmallWords[strings.ToLower(w)]
+				first = false
+				if small {
+					return w
+				}
+				r, size := utf8.DecodeRuneInString(w)
+				return string(unicode.ToTitle(r)) + w[size:]
+			})
+		})
+	}
+	return translation
+}
+
+// mapOutsidePlaceholders applies f to the parts of text between its
+// placeholders and markup, in order.
+func mapOutsidePlaceholders(text string, f func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range placeholderTokenRegex.FindAllStringIndex(text, -1) {
+		b.WriteString(f(text[last:loc[0]]))
+		b.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(f(text[last:]))
+	return b.String()
+}
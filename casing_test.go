@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestApplyCase(t *testing.T) {
+	tests := []struct {
+		source, translation, sourceLang, targetLang, want string
+	}{
+		{"MOTORSCHUTZ AUSGELÖST", "Motor protection tripped", "de-DE", "en-US", "MOTOR PROTECTION TRIPPED"},
+		{"DRUCK @1%5.2f@ BAR", "Pressure @1%5.2f@ bar", "de-DE", "en-US", "PRESSURE @1%5.2f@ BAR"},
+		{"ALARM <b>{0}</b>", "Alarme <b>{0}</b>", "en-US", "fr-FR", "ALARME <b>{0}</b>"},
+		{"Motor Speed Settings", "Réglages de la vitesse du moteur", "en-US", "fr-FR", "Réglages de la Vitesse du Moteur"},
+		{"Start of the Pump", "avvio della pompa", "en-US", "it-IT", "Avvio della Pompa"},
+		{"Motor Speed Settings", "Motordrehzahl-Einstellungen", "en-US", "de-DE", "Motordrehzahl-Einstellungen"},
+		{"Motor Überlast", "Motor overload", "de-DE", "en-US", "Motor overload"}, // German nouns
+		{"Motor overload", "Surcharge moteur", "en-US", "fr-FR", "Surcharge moteur"},
+		{"OK", "OK", "en-US", "fr-FR", "OK"},
+		{"#1#", "#1#", "en-US", "fr-FR", "#1#"},
+	}
+	for _, tt := range tests {
+		if got := applyCase(tt.source, tt.translation, tt.sourceLang, tt.targetLang); got != tt.want {
+			t.Errorf("applyCase(%q, %q) = %q, want %q", tt.source, tt.translation, got, tt.want)
+		}
+	}
+}
+
+func TestIsTitleCase(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Motor Speed Settings", true},
+		{"Start of the Pump", true},
+		{"Motor speed settings", false},
+		{"MOTOR SPEED", false},
+		{"Settings", false},
+		{"Valve {0} Open", true},
+	}
+	for _, tt := range tests {
+		if got := isTitleCase(tt.text); got != tt.want {
+			t.Errorf("isTitleCase(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// lowerTranslator answers in lower case, like a model that ignores the
+// case of the source.
+type lowerTranslator struct{}
+
+func (lowerTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	return map[string]string{"PUMPE AUS": "pump off", "Pumpe aus": "pump off"}[text], nil
+}
+
+func TestKeepCase(t *testing.T) {
+	for _, keep := range []bool{true, false} {
+		job := newTestJob(t, [][]string{{"de-DE", "en-US"}, {"PUMPE AUS", ""}, {"Pumpe aus", ""}})
+		job.keepCase = keep
+		iterateAndTranslate(context.Background(), discardSender{}, lowerTranslator{}, job)
+		want := "pump off"
+		if keep {
+			want = "PUMP OFF"
+		}
+		if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != want {
+			t.Errorf("keepCase %v: B2 = %q, want %q", keep, got, want)
+		}
+		if got, _ := job.f.GetCellValue(job.sheetName, "B3"); got != "pump off" {
+			t.Errorf("keepCase %v: B3 = %q", keep, got)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ///////////////////
+// RECORD AND REPLAY
+// ///////////////////
+
+// --record FILE writes every request sent to the provider, with its
+// response, to a cassette file. --replay FILE answers the same requests from
+// the file instead, without a network or API key, so a run can be repeated
+// exactly: to debug an odd translation, or to check that a code change
+// leaves the output alone. Requests are matched by method, path and body;
+// a request that was not recorded, e.g. after a prompt change, fails.
+
+// cassetteEntry is one recorded request and its response, a line of the
+// cassette file. API keys are sent in headers, which are not recorded.
+type cassetteEntry struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Request  string      `json:"request,omitempty"`
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header,omitempty"`
+	Response string      `json:"response"`
+}
+
+// key identifies the request of e. The host is left out, so the free and
+// pro endpoints of DeepL, or another --base-url, still match.
+func (e cassetteEntry) key() string {
+	path := e.URL
+	if u, err := url.Parse(e.URL); err == nil {
+		path = u.RequestURI()
+	}
+	return e.Method + " " + path + "\n" + e.Request
+}
+
+// errNotRecorded is returned in replay mode for a request the cassette has
+// no response to.
+var errNotRecorded = errors.New("no recorded response")
+
+// cassette records to or replays from a cassette file. All providers of a
+// run share it.
+type cassette struct {
+	path   string
+	replay bool
+
+	mu      sync.Mutex
+	file    *os.File                   // when recording
+	entries map[string][]cassetteEntry // when replaying: by key, in recorded order
+	served  map[string]int             // responses served per key
+}
+
+// newRecorder starts the cassette file at path, replacing an older one.
+func newRecorder(path string) (*cassette, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cassette: %w", err)
+	}
+	return &cassette{path: path, file: file}, nil
+}
+
+// loadCassette reads the cassette file at path for replay.
+func loadCassette(path string) (*cassette, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open cassette: %w", err)
+	}
+	defer file.Close()
+	c := &cassette{path: path, replay: true, entries: make(map[string][]cassetteEntry), served: make(map[string]int)}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 256<<20)
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e cassetteEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", path, n, err)
+		}
+		c.entries[e.key()] = append(c.entries[e.key()], e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read cassette: %w", err)
+	}
+	return c, nil
+}
+
+// record appends e to the cassette file.
+func (c *cassette) record(e cassetteEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.file.Write(append(line, '\n'))
+	return err
+}
+
+// play returns the recorded response to the request of e. Identical
+// requests get the recorded responses in turn, the last one again once they
+// run out, as polling a batch job needs.
+func (c *cassette) play(req *http.Request, e cassetteEntry) (*http.Response, error) {
+	key := e.key()
+	c.mu.Lock()
+	recorded := c.entries[key]
+	i := min(c.served[key], len(recorded)-1)
+	c.served[key]++
+	c.mu.Unlock()
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("%w in %s for %s %s; the request differs from the recorded run", errNotRecorded, c.path, req.Method, req.URL.Path)
+	}
+	r := recorded[i]
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(r.Response)),
+		ContentLength: int64(len(r.Response)),
+		Request:       req,
+	}, nil
+}
+
+// transport wraps next so that requests go through c.
+func (c *cassette) transport(next http.RoundTripper) http.RoundTripper {
+	return &cassetteTransport{cassette: c, next: next}
+}
+
+type cassetteTransport struct {
+	cassette *cassette
+	next     http.RoundTripper
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	e := cassetteEntry{Method: req.Method, URL: req.URL.String(), Request: string(body)}
+	if t.cassette.replay {
+		return t.cassette.play(req, e)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	e.Status = resp.StatusCode
+	e.Header = resp.Header.Clone()
+	e.Header.Del("Set-Cookie")
+	e.Response = string(data)
+	if err := t.cassette.record(e); err != nil {
+		return nil, fmt.Errorf("could not record to %s: %w", t.cassette.path, err)
+	}
+	return resp, nil
+}
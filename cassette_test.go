@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		translation := "Pumpe"
+		if strings.Contains(req.Messages[0].Content, "Valve") {
+			// Identical requests get different answers, as a model may give.
+			translation = []string{"Ventil", "Klappe"}[min(n, 3)-2]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{
+				"message": map[string]string{"role": "assistant", "content": `{"translation": "` + translation + `"}`},
+			}},
+		})
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "run.cassette")
+	translate := func(c *cassette, key string, texts ...string) []string {
+		t.Helper()
+		client := &http.Client{Transport: c.transport(http.DefaultTransport)}
+		translator := newOpenAITranslator("test server", providerConfig{apiKey: key, baseURL: server.URL, client: client}, "test-model")
+		var results []string
+		for _, text := range texts {
+			result, err := translator.Translate(context.Background(), text, "en-US", "de-DE")
+			if err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, result)
+		}
+		return results
+	}
+
+	recorder, err := newRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := translate(recorder, "secret", "Pump", "Valve", "Valve")
+	recorder.file.Close()
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "secret") {
+		t.Errorf("the cassette contains the API key:\n%s", data)
+	}
+	if want := []string{"Pumpe", "Ventil", "Klappe"}; strings.Join(recorded, ",") != strings.Join(want, ",") {
+		t.Fatalf("recorded %q, want %q", recorded, want)
+	}
+
+	player, err := loadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := translate(player, "", "Valve", "Pump", "Valve", "Valve")
+	if want := []string{"Ventil", "Pumpe", "Klappe", "Klappe"}; strings.Join(replayed, ",") != strings.Join(want, ",") {
+		t.Errorf("replayed %q, want %q", replayed, want)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("server got %d requests, want the 3 recorded", hits.Load())
+	}
+
+	client := &http.Client{Transport: player.transport(http.DefaultTransport)}
+	translator := newOpenAITranslator("test server", providerConfig{baseURL: server.URL, client: client}, "test-model")
+	if _, err := translator.Translate(context.Background(), "Motor", "en-US", "de-DE"); !errors.Is(err, errNotRecorded) {
+		t.Errorf("unrecorded request: err = %v, want errNotRecorded", err)
+	}
+}
+
+func TestCassetteReplayWithWorkers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		text := req.Messages[len(req.Messages)-1].Content
+		if strings.Contains(text, "langsam") {
+			// Finishes after the rows below it.
+			time.Sleep(50 * time.Millisecond)
+		}
+		reply, _ := json.Marshal(map[string]string{"translation": strings.ToUpper(text)})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{
+				"message": map[string]string{"role": "assistant", "content": string(reply)},
+			}},
+		})
+	}))
+	defer server.Close()
+
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Förderband läuft langsam", ""},
+		{"Förderband steht", ""},
+		{"Förderband gestoppt", ""},
+		{"Förderband defekt", ""},
+		{"Förderband leer", ""},
+	}
+	run := func(c *cassette) []string {
+		t.Helper()
+		client := &http.Client{Transport: c.transport(http.DefaultTransport)}
+		translator := newOpenAITranslator("test server", providerConfig{apiKey: "secret", baseURL: server.URL, client: client}, "test-model")
+		job := newTestJob(t, rows)
+		job.workers = 2
+		job.session = newSessionTerms(job.sourceLang)
+		job.session.inOrder = true
+		sender := &recordingSender{}
+		if stats := iterateAndTranslate(context.Background(), sender, translator, job); stats.errors > 0 {
+			t.Fatalf("%d rows failed: %v", stats.errors, sender.msgs)
+		}
+		var results []string
+		for i := 1; i < len(rows); i++ {
+			results = append(results, job.cellValue(i))
+		}
+		return results
+	}
+
+	path := filepath.Join(t.TempDir(), "run.cassette")
+	recorder, err := newRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := run(recorder)
+	recorder.file.Close()
+
+	player, err := loadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed := run(player); strings.Join(replayed, ",") != strings.Join(recorded, ",") {
+		t.Errorf("replayed %q, want the recorded %q", replayed, recorded)
+	}
+}
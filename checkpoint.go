@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// ///////////////////
+// CHECKPOINTS
+// ///////////////////
+
+// checkpointState is what gets written to the sidecar file: enough to verify
+// that a resumed run uses the same settings, plus every translated cell.
+type checkpointState struct {
+	File      string                    `json:"file"`
+	Mode      string                    `json:"mode"`
+	UpdatedAt string                    `json:"updated_at"`
+	Sheets    map[string]*sheetProgress `json:"sheets"`
+}
+
+// sheetProgress is the checkpoint of one sheet. Column numbers are 1-based.
+type sheetProgress struct {
+	SourceCol  int                    `json:"source_col"`
+	TargetCols []int                  `json:"target_cols"`
+	Rows       map[int]map[int]string `json:"rows"` // target column -> 1-based sheet row -> translated text
+}
+
+// checkpoint records translated rows and writes them to disk every few rows.
+// It is only used from the goroutine that writes the workbook.
+type checkpoint struct {
+	path    string
+	every   int
+	state   checkpointState
+	pending int
+}
+
+// checkpointPath is the sidecar file next to the input workbook.
+func checkpointPath(fileName string) string {
+	return fileName + ".checkpoint.json"
+}
+
+func newCheckpoint(path string, every int, state checkpointState) *checkpoint {
+	if state.Sheets == nil {
+		state.Sheets = make(map[string]*sheetProgress)
+	}
+	for _, s := range state.Sheets {
+		if s.Rows == nil {
+			s.Rows = make(map[int]map[int]string)
+		}
+	}
+	return &checkpoint{path: path, every: every, state: state}
+}
+
+// loadCheckpoint reads a checkpoint file. It returns nil and no error if the
+// file does not exist.
+func loadCheckpoint(path string) (*checkpointState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read checkpoint: %w", err)
+	}
+	var state checkpointState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("could not parse checkpoint %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// matches reports whether a checkpoint was written for the same sheets,
+// columns and mode as the current run.
+func (s *checkpointState) matches(other checkpointState) error {
+	if s.Mode != other.Mode {
+		return fmt.Errorf("checkpoint is for %s mode, not %s", s.Mode, other.Mode)
+	}
+	if len(s.Sheets) != len(other.Sheets) {
+		return fmt.Errorf("checkpoint is for %d sheets, not %d", len(s.Sheets), len(other.Sheets))
+	}
+	for name, o := range other.Sheets {
+		sp := s.Sheets[name]
+		switch {
+		case sp == nil:
+			return fmt.Errorf("checkpoint does not cover sheet %q", name)
+		case sp.SourceCol != o.SourceCol || !slices.Equal(sp.TargetCols, o.TargetCols):
+			return fmt.Errorf("checkpoint is for columns %d -> %v of sheet %q, not %d -> %v", sp.SourceCol, sp.TargetCols, name, o.SourceCol, o.TargetCols)
+		}
+	}
+	return nil
+}
+
+// record notes a translated row (0-based indexes) and saves the checkpoint
+// once enough rows have accumulated.
+func (c *checkpoint) record(sheet string, targetIndex, rowIndex int, value string) error {
+	if c == nil {
+		return nil
+	}
+	sp := c.state.Sheets[sheet]
+	if sp == nil {
+		sp = &sheetProgress{Rows: make(map[int]map[int]string)}
+		c.state.Sheets[sheet] = sp
+	}
+	rows := sp.Rows[targetIndex+1]
+	if rows == nil {
+		rows = make(map[int]string)
+		sp.Rows[targetIndex+1] = rows
+	}
+	rows[rowIndex+1] = value
+	c.pending++
+	if c.pending < c.every {
+		return nil
+	}
+	return c.flush()
+}
+
+// flush writes the checkpoint atomically so a crash mid-write never leaves a
+// corrupt file behind.
+func (c *checkpoint) flush() error {
+	if c == nil || c.pending == 0 {
+		return nil
+	}
+	c.state.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("could not write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("could not write checkpoint: %w", err)
+	}
+	c.pending = 0
+	return nil
+}
+
+// remove deletes the checkpoint after the output was saved successfully.
+func (c *checkpoint) remove() error {
+	if c == nil {
+		return nil
+	}
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.xlsx.checkpoint.json")
+	state := checkpointState{
+		File:   "export.xlsx",
+		Mode:   "full",
+		Sheets: map[string]*sheetProgress{"Texts": {SourceCol: 5, TargetCols: []int{6, 7}}},
+	}
+	cp := newCheckpoint(path, 2, state)
+
+	if err := cp.record("Texts", 5, 1, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := loadCheckpoint(path); saved != nil {
+		t.Fatalf("checkpoint written before %d rows", cp.every)
+	}
+	if err := cp.record("Texts", 6, 2, "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := loadCheckpoint(path)
+	if err != nil || saved == nil {
+		t.Fatalf("loadCheckpoint = %v, %v", saved, err)
+	}
+	if rows := saved.Sheets["Texts"].Rows; rows[6][2] != "first" || rows[7][3] != "second" {
+		t.Errorf("rows = %v; expected row 2 of column 6 and row 3 of column 7", rows)
+	}
+	if err := saved.matches(state); err != nil {
+		t.Errorf("matches same state: %v", err)
+	}
+	other := state
+	other.Sheets = map[string]*sheetProgress{"Texts": {SourceCol: 5, TargetCols: []int{6}}}
+	if err := saved.matches(other); err == nil {
+		t.Errorf("matches should reject different target columns")
+	}
+	other.Sheets = map[string]*sheetProgress{"Alarms": {SourceCol: 5, TargetCols: []int{6, 7}}}
+	if err := saved.matches(other); err == nil {
+		t.Errorf("matches should reject a different sheet")
+	}
+
+	if err := cp.remove(); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := loadCheckpoint(path); saved != nil {
+		t.Errorf("checkpoint still present after remove")
+	}
+}
+
+func TestResumeSkipsCheckpointedRows(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Discrete_alarm_66", ""},
+		{"Discrete_alarm_67", ""},
+		{"Pump running", ""},
+	}
+	job := newTestJob(t, rows)
+	job.resumed = map[int]string{2: "Diskreter_Alarm_66"}
+	translator := &upperTranslator{}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	expected := map[string]string{
+		"B2": "Diskreter_Alarm_66",
+		"B3": "Diskreter_Alarm_67",
+		"B4": "PUMP RUNNING",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if len(translator.calls) != 1 {
+		t.Errorf("translator calls = %v; expected only the unfinished row", translator.calls)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// LONG TEXT CHUNKS
+// ///////////////////
+
+// A model replies with a few thousand tokens at most, so the translation of
+// a very long cell came back cut off. Texts over chunkTokens are split into
+// chunks at sentence ends, or between words where a sentence is longer
+// still, and the chunks translated one by one. Each chunk gets the end of
+// the one before it as context, so it reads on from there, and the
+// translations are joined with the original whitespace.
+
+// chunkTokens is the most estimated tokens of a text sent in one request.
+// Translations come out longer than their source, and the reply must fit
+// the output limit of small models too.
+const chunkTokens = 1000
+
+// chunkOverlap is how many characters of the preceding chunk go along with
+// a chunk as context.
+const chunkOverlap = 300
+
+// errCutShort is returned for replies the model stopped at its output
+// limit, instead of writing what arrived.
+var errCutShort = errors.New("the reply was cut short at the model's output limit")
+
+// chunkText splits text into alternating segments of chunks of at most limit
+// estimated tokens and the whitespace between them, like
+// tiatrans.SplitSentences. A single word longer than limit stays whole.
+func chunkText(text string, limit int) []string {
+	var units []string // alternating pieces and separators
+	sentences := tiatrans.SplitSentences(text)
+	for i, s := range sentences {
+		if i%2 == 1 || estimateTokens(s) <= limit {
+			units = append(units, s)
+			continue
+		}
+		units = append(units, splitWords(s)...)
+	}
+
+	segments := []string{units[0]}
+	for i := 1; i+1 < len(units); i += 2 {
+		last := len(segments) - 1
+		if merged := segments[last] + units[i] + units[i+1]; estimateTokens(merged) <= limit {
+			segments[last] = merged
+		} else {
+			segments = append(segments, units[i], units[i+1])
+		}
+	}
+	return segments
+}
+
+// splitWords splits text into alternating words and the whitespace between
+// them.
+func splitWords(text string) []string {
+	var segments []string
+	start := 0
+	inSpace := false
+	for i, r := range text {
+		if space := unicode.IsSpace(r); space != inSpace && i > 0 {
+			segments = append(segments, text[start:i])
+			start = i
+		}
+		inSpace = unicode.IsSpace(r)
+	}
+	segments = append(segments, text[start:])
+	if unicode.IsSpace([]rune(text)[0]) {
+		segments = append([]string{""}, segments...)
+	}
+	if len(segments)%2 == 0 {
+		segments = append(segments, "")
+	}
+	return segments
+}
+
+// withPrecedingText attaches the end of previous, the chunk before, to the
+// context hint of ctx.
+func withPrecedingText(ctx context.Context, previous string) context.Context {
+	tail := previous
+	if utf8.RuneCountInString(tail) > chunkOverlap {
+		runes := []rune(tail)
+		tail = string(runes[len(runes)-chunkOverlap:])
+		if i := strings.IndexFunc(tail, unicode.IsSpace); i >= 0 {
+			tail = tail[i+1:]
+		}
+		tail = "…" + tail
+	}
+	hints := promptHintsFrom(ctx)
+	if hints.context != "" {
+		hints.context += "; "
+	}
+	hints.context += "the text continues from: " + tail
+	return withPromptHints(ctx, hints)
+}
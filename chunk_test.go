@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"sentences", strings.Repeat("Check the relief valve of the pump. ", 40) + "Then restart."},
+		{"paragraphs", strings.Repeat("Open the valve.\n\nClose the drain.\n", 30)},
+		{"one long sentence", strings.Repeat("pump valve motor ", 60) + "end"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := chunkText(tt.text, 50)
+			if got := strings.Join(segments, ""); got != tt.text {
+				t.Fatalf("chunks do not join back to the text: %q", got)
+			}
+			if len(segments) < 3 || len(segments)%2 == 0 {
+				t.Fatalf("got %d segments", len(segments))
+			}
+			for i, s := range segments {
+				if i%2 == 1 {
+					if strings.TrimSpace(s) != "" {
+						t.Errorf("separator %d is %q", i, s)
+					}
+					continue
+				}
+				if n := estimateTokens(s); n > 50 {
+					t.Errorf("chunk %d has %d tokens: %q", i, n, s)
+				}
+			}
+		})
+	}
+	if got := chunkText("Short text.", 50); len(got) != 1 {
+		t.Errorf("short text split into %q", got)
+	}
+}
+
+// contextRecorder upper-cases and records the context hint of each text.
+type contextRecorder struct {
+	mu       sync.Mutex
+	contexts map[string]string
+}
+
+func (c *contextRecorder) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	c.mu.Lock()
+	c.contexts[text] = promptHintsFrom(ctx).context
+	c.mu.Unlock()
+	return strings.ToUpper(text), nil
+}
+
+func TestTranslateLongTextInChunks(t *testing.T) {
+	paragraph := strings.Repeat("Check the relief valve and the drain of the pump. ", 30)
+	long := strings.TrimSpace(paragraph + "\n" + paragraph + "\n" + paragraph)
+	job := newTestJob(t, [][]string{{"de-DE", "en-US"}, {long, ""}})
+	translator := &contextRecorder{contexts: make(map[string]string)}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != strings.ToUpper(long) {
+		t.Errorf("B2 = %q", got)
+	}
+	if len(translator.contexts) < 2 {
+		t.Fatalf("text sent in %d requests, expected chunks", len(translator.contexts))
+	}
+	continued := 0
+	for text, context := range translator.contexts {
+		if estimateTokens(text) > chunkTokens {
+			t.Errorf("chunk of %d tokens sent", estimateTokens(text))
+		}
+		if strings.HasPrefix(context, "the text continues from: …") {
+			continued++
+		}
+	}
+	if continued != len(translator.contexts)-1 {
+		t.Errorf("%d of %d chunks have the text before them as context", continued, len(translator.contexts))
+	}
+}
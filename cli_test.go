@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveColumns(t *testing.T) {
+	headers := []string{"Text list", "ID", "Reference", "Comment", "de-DE*", "en-US", "fr-FR"}
+
+	testCases := []struct {
+		specs    string
+		expected []int
+		wantErr  bool
+	}{
+		{"en-US", []int{5}, false},
+		{"en-US,fr-FR", []int{5, 6}, false},
+		{"7, en-US", []int{6, 5}, false},
+		{"en-US,6,", []int{5}, false},
+		{"en-US,it-IT", nil, true},
+		{" , ", nil, true},
+	}
+
+	for _, tc := range testCases {
+		result, err := resolveColumns(headers, tc.specs)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("resolveColumns(%q) error = %v; wantErr %t", tc.specs, err, tc.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("resolveColumns(%q) = %v; expected %v", tc.specs, result, tc.expected)
+		}
+	}
+}
+
+func TestResolveLanguage(t *testing.T) {
+	rows := [][]string{
+		{"ID", "de-DE", "en-US", "en-GB", "fr-FR", "de-DE*", "fr-FR"},
+		{"1", "Pumpe", "Pump", "Pump", "", "Pumpe", "Pompe"},
+		{"2", "Ventil", "Valve", "", "", "", "Vanne"},
+	}
+
+	testCases := []struct {
+		code     string
+		source   bool
+		expected int
+		wantNote bool
+		wantErr  bool
+	}{
+		{"en-US", false, 2, false, false},
+		{"EN_us", false, 2, false, false},
+		{"en-gb", true, 3, false, false},
+		{"en", false, -1, false, true},    // en-US and en-GB
+		{"it-IT", false, -1, false, true}, // no such column
+		{"de-DE", true, 5, true, false},   // the reference column wins
+		{"de", true, 5, true, false},
+		{"de-DE", false, 1, true, false},
+		{"fr-FR", true, 6, true, false}, // the fuller one
+		{"fr-FR", false, 4, true, false},
+	}
+
+	for _, tc := range testCases {
+		col, note, err := resolveLanguage(rows, tc.code, tc.source)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("resolveLanguage(%q, %t) error = %v; wantErr %t", tc.code, tc.source, err, tc.wantErr)
+			continue
+		}
+		if col != tc.expected {
+			t.Errorf("resolveLanguage(%q, %t) = %d; expected %d", tc.code, tc.source, col, tc.expected)
+		}
+		if (note != "") != tc.wantNote {
+			t.Errorf("resolveLanguage(%q, %t) note = %q; wantNote %t", tc.code, tc.source, note, tc.wantNote)
+		}
+	}
+}
+
+func TestMetadataColumns(t *testing.T) {
+	testCases := []struct {
+		name     string
+		headers  []string
+		fileType FileType
+		metaCols int
+		expected int
+	}{
+		{"TIA Portal", []string{"Text list", "ID", "Reference", "Comment", "de-DE*", "en-US"}, FileTypeTIA, -1, 4},
+		{"WinCC Unified", []string{"ID", "Text list", "de-DE", "en-US"}, FileTypeTIA, -1, 2},
+		{"no metadata", []string{"de_DE", "en-US", "ID"}, FileTypeTIA, -1, 0},
+		{"no language header", []string{"ID", "German", "English"}, FileTypeTIA, -1, 4},
+		{"override", []string{"Text list", "ID", "Reference", "Comment", "de-DE*", "en-US"}, FileTypeTIA, 3, 3},
+		{"Rockwell", []string{"Server", "Component Type", "Component Name", "Description", "REF", "en-US"}, FileTypeRockwell, -1, 5},
+		{"PO", []string{poLineHeader, "Context", "Source", "Target"}, FileTypePO, -1, 2},
+	}
+
+	for _, tc := range testCases {
+		if got := metadataColumns(tc.headers, tc.fileType, tc.metaCols); got != tc.expected {
+			t.Errorf("%s: metadataColumns() = %d; expected %d", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestPlainPrinterProgress(t *testing.T) {
+	var out strings.Builder
+	pp := &plainPrinter{out: &out}
+	pp.Send(fileInfoMsg{fileName: "a.xlsx"})
+	for i := 1; i <= 40; i++ {
+		pp.Send(progressMsg(float64(i) / 40))
+	}
+	pp.Send(fileInfoMsg{fileName: "b.xlsx"})
+	pp.Send(progressMsg(0.55))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 11 {
+		t.Fatalf("%d lines; expected one per tenth of a.xlsx and one for b.xlsx:\n%s", len(lines), out.String())
+	}
+	if lines[0] != "Progress: 10% of a.xlsx" || lines[9] != "Progress: 100% of a.xlsx" || lines[10] != "Progress: 50% of b.xlsx" {
+		t.Errorf("unexpected lines:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "\x1b") {
+		t.Error("plain output contains escape sequences")
+	}
+
+	// With time passing, the lines carry the rate and time left.
+	out.Reset()
+	clock := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+	pp = &plainPrinter{out: &out, now: func() time.Time { return clock }}
+	pp.Send(fileInfoMsg{fileName: "c.xlsx", totalRows: 1000})
+	for done := 50; done <= 200; done += 50 {
+		clock = clock.Add(30 * time.Second)
+		pp.Send(progressMsg(float64(done) / 1000))
+	}
+	if got := strings.TrimSpace(out.String()); got != "Progress: 10% of c.xlsx (100 rows/min, about 9m left)\nProgress: 20% of c.xlsx (100 rows/min, about 8m left)" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	testCases := []struct {
+		args  []string
+		files []string
+		mode  string
+	}{
+		{[]string{`C:\exports\plant 1.xlsx`}, []string{`C:\exports\plant 1.xlsx`}, ""},
+		{[]string{"--mode", "quick", "a.xlsx", "b.csv"}, []string{"a.xlsx", "b.csv"}, "quick"},
+		{[]string{"a.xlsx", "--mode", "quick", `\\server\share\b.xlsx`}, []string{"a.xlsx", `\\server\share\b.xlsx`}, "quick"},
+		{[]string{"--mode=full"}, nil, "full"},
+	}
+	for _, tc := range testCases {
+		fs := flag.NewFlagSet("translate", flag.ContinueOnError)
+		mode := fs.String("mode", "", "")
+		files := parseInterspersed(fs, tc.args)
+		if !reflect.DeepEqual(files, tc.files) || *mode != tc.mode {
+			t.Errorf("parseInterspersed(%q) = %q, mode %q; expected %q, mode %q", tc.args, files, *mode, tc.files, tc.mode)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// ///////////////////
+// COMMANDS
+// ///////////////////
+
+// The tool is run as "translator COMMAND [FLAGS] [ARGS]". Each command parses
+// its own flags with a flag.FlagSet; translate keeps the global flag set.
+// Without a command, or when the first argument is a flag or a file to
+// translate, the run is a translate run, so existing scripts keep working
+// and files can be dropped onto the executable.
+
+// command is a subcommand of the tool.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"translate", "Translate workbooks (the default when no command is given).", runTranslateCommand},
+	{"open", "Repeat or resume the job saved in a " + projectExt + " project file.", runOpenCommand},
+	{"estimate", "Count the texts and estimate tokens, cost and time without calling the API.", runEstimateCommand},
+	{"compare", "Translate a sample with two providers or models side by side and compare them.", runCompareCommand},
+	{"score", "Score translations against human reference translations with chrF and BLEU.", runScoreCommand},
+	{"validate", "Check a translated workbook before re-importing it into TIA Portal.", runValidateCommand},
+	{"report", "Write a Markdown or HTML report from a --log-file audit log.", runReportCommand},
+	{"tm", "Export or import the translation memory as TMX.", runTMCommand},
+	{"glossary", "Check a translated workbook against a glossary, or extract candidate terms from one.", runGlossaryCommand},
+	{"xliff", "Export a sheet to XLIFF or import a translated XLIFF file.", runXLIFFCommand},
+	{"key", "Store or delete an API key in the system keychain.", runKeyCommand},
+	{"serve", "Translate uploaded workbooks from a page in the web browser.", runServeCommand},
+}
+
+// findCommand returns the command called name, or nil.
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// runCommand runs the command named by args[0], or translate if args do
+// not start with one, and returns the exit code. translate exits itself.
+func runCommand(args []string) int {
+	name := "translate"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") && !isInputArg(args[0]) {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printCommands(os.Stdout)
+		return exitClean
+	}
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printCommands(os.Stderr)
+		return exitAborted
+	}
+	if err := cmd.run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitErrors
+	}
+	return exitClean
+}
+
+// isInputArg reports whether arg names a file to translate rather than a
+// command: an existing file, or a name or pattern with an input extension.
+func isInputArg(arg string) bool {
+	if findCommand(arg) != nil {
+		return false
+	}
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+		return true
+	}
+	return slices.Contains(inputExtensions, strings.ToLower(filepath.Ext(strings.Trim(arg, `"`))))
+}
+
+// printCommands writes the list of commands for "translator help".
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "usage: translator [COMMAND] [FLAGS] [ARGS]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"translator COMMAND -h\" for the flags of a command.")
+}
+
+func runTranslateCommand(args []string) error {
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: translator [translate] [FLAGS]")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+	translate(args)
+	return nil
+}
+
+// runEstimateCommand is translate --dry-run.
+func runEstimateCommand(args []string) error {
+	return runTranslateCommand(append([]string{"--dry-run"}, args...))
+}
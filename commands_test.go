@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestRunCommand(t *testing.T) {
+	testCases := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"help"}, exitClean},
+		{[]string{"translations"}, exitAborted},
+		{[]string{"validate"}, exitErrors},
+		{[]string{"glossary", "fix"}, exitErrors},
+		{[]string{"report", "run.jsonl"}, exitErrors},
+	}
+	for _, tc := range testCases {
+		if code := runCommand(tc.args); code != tc.code {
+			t.Errorf("runCommand(%q) = %d; expected %d", tc.args, code, tc.code)
+		}
+	}
+	for _, cmd := range commands {
+		if cmd.run == nil || cmd.summary == "" {
+			t.Errorf("command %s has no run function or summary", cmd.name)
+		}
+	}
+}
+
+func TestIsInputArg(t *testing.T) {
+	testCases := []struct {
+		arg      string
+		expected bool
+	}{
+		{"validate", false},
+		{"translations", false},
+		{`C:\exports\plant 1.xlsx`, true},
+		{`\\server\share\Project\TextExport.XLSX`, true},
+		{`"D:\exports\texts.csv"`, true},
+		{`exports\*.xlsx`, true},
+		{"commands_test.go", true}, // an existing file
+	}
+	for _, tc := range testCases {
+		if got := isInputArg(tc.arg); got != tc.expected {
+			t.Errorf("isInputArg(%q) = %t; expected %t", tc.arg, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// COMPARING MODELS
+// ///////////////////
+
+// "translator compare" translates a sample of a sheet with two providers or
+// models, side by side, so their quality can be judged on a few dozen texts
+// before a whole project is sent to one of them. Both sides go through the
+// same placeholder, markup and refusal handling as a translate run. The
+// results are written to a workbook of their own, with how similar the two
+// translations are, how close each comes to the translation already in the
+// target column, if there is one, and what the QA checks found.
+
+// compareSide is one of the two translators being compared.
+type compareSide struct {
+	label      string // provider/model
+	translator Translator
+}
+
+// comparison is one sampled row.
+type comparison struct {
+	row       int // 1-based sheet row
+	source    string
+	reference string // translation already in the target column
+	results   [2]string
+	errs      [2]error
+	flags     [2][]string
+	similar   float64    // of the two results, 0-1
+	closeness [2]float64 // of each result to the reference, 0-1
+}
+
+// compareTotals sums up one side.
+type compareTotals struct {
+	translated int
+	errors     int
+	flagged    int
+	references int     // rows with a reference
+	closeness  float64 // sum of the similarities to the references
+	tokens     int64
+	elapsed    time.Duration
+}
+
+// textSimilarity is the cosine of the trigram vectors of a and b, as the
+// fuzzy translation memory uses it: 1 for texts with the same characters.
+func textSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	vectors, _ := ngramEmbedder{}.embed(context.Background(), []string{a, b})
+	return similarity(vectors[0], vectors[1])
+}
+
+// sampleRows picks up to n rows of rows[1:] to compare: distinct source
+// texts that a run would send to the translator, evenly spread over the
+// sheet. It returns 0-based row indexes.
+func sampleRows(rows [][]string, src, n int) []int {
+	seen := make(map[string]bool)
+	var candidates []int
+	for i := 1; i < len(rows); i++ {
+		if src >= len(rows[i]) {
+			continue
+		}
+		text := strings.TrimSpace(rows[i][src])
+		if text == "" || seen[text] || tiatrans.IsPlaceholder(text) || tiatrans.IsVisualSeparator(text) || tiatrans.HasEmbeddedRefs(text) {
+			continue
+		}
+		if r := (*tiatrans.RuleSet)(nil).Decide(text); r != nil {
+			continue
+		}
+		seen[text] = true
+		candidates = append(candidates, i)
+	}
+	if n <= 0 || len(candidates) <= n {
+		return candidates
+	}
+	sample := make([]int, n)
+	for i := range sample {
+		sample[i] = candidates[i*len(candidates)/n]
+	}
+	return sample
+}
+
+// compareRows translates the sampled rows with both sides. job supplies the
+// languages and the glossary for the prompt and the QA checks.
+func compareRows(ctx context.Context, p msgSender, sides [2]compareSide, job *translationJob, rows [][]string, sample []int, src, tgt int) ([]comparison, [2]compareTotals) {
+	var totals [2]compareTotals
+	var results []comparison
+	for _, i := range sample {
+		if ctx.Err() != nil {
+			break
+		}
+		c := comparison{row: i + 1, source: rows[i][src]}
+		if tgt < len(rows[i]) {
+			c.reference = strings.TrimSpace(rows[i][tgt])
+		}
+		for s, side := range sides {
+			var tokens tokenCounter
+			start := time.Now()
+			result, err := job.translate(withTokenCounter(ctx, &tokens), p, side.translator, c.source, 0)
+			t := &totals[s]
+			t.elapsed += time.Since(start)
+			t.tokens += tokens.n.Load()
+			if err != nil {
+				c.errs[s] = err
+				t.errors++
+				continue
+			}
+			c.results[s] = result
+			c.flags[s] = job.qaFlags(c.source, result)
+			t.translated++
+			if len(c.flags[s]) > 0 {
+				t.flagged++
+			}
+			if c.reference != "" {
+				c.closeness[s] = textSimilarity(result, c.reference)
+				t.references++
+				t.closeness += c.closeness[s]
+			}
+		}
+		if c.errs[0] == nil && c.errs[1] == nil {
+			c.similar = textSimilarity(c.results[0], c.results[1])
+		}
+		results = append(results, c)
+	}
+	return results, totals
+}
+
+// writeComparison writes the compared rows and the summary to a new
+// workbook.
+func writeComparison(path string, sides [2]compareSide, results []comparison, totals [2]compareTotals) error {
+	f := excelize.NewFile()
+	defer f.Close()
+	const sheet = "Compare"
+	f.SetSheetName(f.GetSheetName(0), sheet)
+	header := []any{"Row", "Source", "Reference", sides[0].label, sides[1].label, "Similarity", "Closeness " + sides[0].label, "Closeness " + sides[1].label, "QA " + sides[0].label, "QA " + sides[1].label}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+	percent := func(v float64) string { return fmt.Sprintf("%.0f%%", v*100) }
+	for i, c := range results {
+		values := []any{strconv.Itoa(c.row), c.source, c.reference, "", "", "", "", "", "", ""}
+		for s := range sides {
+			if c.errs[s] != nil {
+				values[3+s] = "ERROR: " + c.errs[s].Error()
+				continue
+			}
+			values[3+s] = c.results[s]
+			if c.reference != "" {
+				values[6+s] = percent(c.closeness[s])
+			}
+			values[8+s] = strings.Join(c.flags[s], "; ")
+		}
+		if c.errs[0] == nil && c.errs[1] == nil {
+			values[5] = percent(c.similar)
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+			return err
+		}
+	}
+	if err := f.SetColWidth(sheet, "B", "E", 40); err != nil {
+		return err
+	}
+
+	const summary = "Summary"
+	if _, err := f.NewSheet(summary); err != nil {
+		return err
+	}
+	for i, line := range compareSummary(sides, results, totals) {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(summary, cell, &line); err != nil {
+			return err
+		}
+	}
+	if err := f.SetColWidth(summary, "A", "C", 30); err != nil {
+		return err
+	}
+	return f.SaveAs(path)
+}
+
+// compareSummary is the table printed at the end and put on the summary
+// sheet: a row per figure, a column per side.
+func compareSummary(sides [2]compareSide, results []comparison, totals [2]compareTotals) [][]any {
+	identical, both := 0, 0
+	var similar float64
+	for _, c := range results {
+		if c.errs[0] != nil || c.errs[1] != nil {
+			continue
+		}
+		both++
+		similar += c.similar
+		if c.results[0] == c.results[1] {
+			identical++
+		}
+	}
+	table := [][]any{
+		{"", sides[0].label, sides[1].label},
+	}
+	side := func(name string, value func(t compareTotals) string) {
+		table = append(table, []any{name, value(totals[0]), value(totals[1])})
+	}
+	side("Translated", func(t compareTotals) string { return strconv.Itoa(t.translated) })
+	side("Errors", func(t compareTotals) string { return strconv.Itoa(t.errors) })
+	side("Rows with QA findings", func(t compareTotals) string { return strconv.Itoa(t.flagged) })
+	if totals[0].references > 0 || totals[1].references > 0 {
+		side("Closeness to the reference", func(t compareTotals) string {
+			if t.references == 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%.0f%%", t.closeness/float64(t.references)*100)
+		})
+	}
+	side("Tokens", func(t compareTotals) string { return strconv.FormatInt(t.tokens, 10) })
+	side("Time", func(t compareTotals) string { return t.elapsed.Round(100 * time.Millisecond).String() })
+	if both > 0 {
+		table = append(table,
+			[]any{"Identical translations", fmt.Sprintf("%d of %d", identical, both)},
+			[]any{"Average similarity", fmt.Sprintf("%.0f%%", similar/float64(both)*100)})
+	}
+	return table
+}
+
+// printComparison writes the summary table to w.
+func printComparison(w io.Writer, table [][]any) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range table {
+		for i, v := range row {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, v)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// parseSide splits a --a or --b value, PROVIDER or PROVIDER/MODEL.
+func parseSide(spec string) (provider, model string, err error) {
+	provider, model, _ = strings.Cut(spec, "/")
+	if _, ok := providers[provider]; !ok {
+		return "", "", fmt.Errorf("unknown provider %q (available: %s)", provider, strings.Join(providerNames(), ", "))
+	}
+	return provider, model, nil
+}
+
+// runCompareCommand implements "compare".
+func runCompareCommand(args []string) error {
+	usage := "usage: compare --a PROVIDER[/MODEL] --b PROVIDER[/MODEL] --source-col COL --target-col COL [--sample N] WORKBOOK"
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	a := fs.String("a", "", "First provider and model, e.g. openai/gpt-4o-mini.")
+	b := fs.String("b", "", "Second provider and model, e.g. ollama/llama3.1 or deepl.")
+	sheet := fs.String("sheet", "", "Sheet to sample (default: first sheet).")
+	sourceCol := fs.String("source-col", "", "Source language column, as a 1-based column number or header name.")
+	targetCol := fs.String("target-col", "", "Target language column, as a 1-based column number or header name; translations already in it are the reference.")
+	sampleSize := fs.Int("sample", 50, "Number of distinct texts to translate with both (0 = all).")
+	glossaryPath := fs.String("glossary", "", "Glossary for the prompts and the QA check, as for translate.")
+	output := fs.String("output", "", "Workbook the comparison is written to (default: compare-WORKBOOK.xlsx next to it).")
+	proxy := fs.String("proxy", "", "HTTP(S) proxy for all API requests, as for translate.")
+	caCert := fs.String("ca-cert", "", "PEM file with extra CA certificates, as for translate.")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *a == "" || *b == "" || *sourceCol == "" || *targetCol == "" {
+		return errors.New(usage)
+	}
+	fileName := fs.Arg(0)
+
+	f, _, _, _, err := openInput(options{}, fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sheetName := f.GetSheetName(0)
+	if *sheet != "" {
+		sheetName = *sheet
+	}
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return fmt.Errorf("Error getting rows: %v", err)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("Sheet %q is empty", sheetName)
+	}
+	src, err := tiatrans.ResolveColumn(rows[0], *sourceCol)
+	if err != nil {
+		return fmt.Errorf("--source-col: %v", err)
+	}
+	tgt, err := tiatrans.ResolveColumn(rows[0], *targetCol)
+	if err != nil {
+		return fmt.Errorf("--target-col: %v", err)
+	}
+	job := &translationJob{sheetName: sheetName, sourceLang: rows[0][src], targetLang: rows[0][tgt]}
+	if *glossaryPath != "" {
+		if job.glossary, err = loadGlossary(*glossaryPath, job.sourceLang, job.targetLang); err != nil {
+			return err
+		}
+	}
+
+	var sides [2]compareSide
+	for i, spec := range []string{*a, *b} {
+		provider, model, err := parseSide(spec)
+		if err != nil {
+			return err
+		}
+		opts := options{provider: provider, model: model, proxy: *proxy, caCert: *caCert, requestTimeout: 2 * time.Minute}
+		translator, _, err := newRunTranslator(opts, nil, isTerminal(os.Stdin))
+		if err != nil {
+			return fmt.Errorf("%s: %v", spec, err)
+		}
+		policy := retryPolicy{retries: 5, baseDelay: defaultRetryBaseDelay, maxDelay: defaultRetryMaxDelay, timeout: opts.requestTimeout}
+		sides[i] = compareSide{label: modelLabel(provider, model), translator: &retryTranslator{Translator: translator, policy: policy}}
+	}
+	if sides[0].label == sides[1].label {
+		return fmt.Errorf("--a and --b are both %s", sides[0].label)
+	}
+
+	sample := sampleRows(rows, src, *sampleSize)
+	if len(sample) == 0 {
+		return fmt.Errorf("Sheet %q has no texts to translate in column %s", sheetName, job.sourceLang)
+	}
+	fmt.Printf("Translating %d texts from %s to %s with %s and %s\n", len(sample), job.sourceLang, job.targetLang, sides[0].label, sides[1].label)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	results, totals := compareRows(ctx, discardSender{}, sides, job, rows, sample, src, tgt)
+
+	path := *output
+	if path == "" {
+		path = outputBaseName(fileName, "compare-") + ".xlsx"
+	}
+	if err := writeComparison(path, sides, results, totals); err != nil {
+		return fmt.Errorf("Error saving the comparison: %w", err)
+	}
+	printComparison(os.Stdout, compareSummary(sides, results, totals))
+	fmt.Printf("Saved the comparison of %d texts to %s\n", len(results), filepath.Clean(path))
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// dictTranslator translates from a fixed list and fails everything else.
+type dictTranslator map[string]string
+
+func (d dictTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	if result, ok := d[text]; ok {
+		return result, nil
+	}
+	return "", errors.New("unknown text")
+}
+
+func TestSampleRows(t *testing.T) {
+	rows := [][]string{{"de-DE", "en-US"}}
+	for _, text := range []string{"Pumpe", "Pumpe", "", "100", "Ventil", "Motor", "Lüfter", "Tank"} {
+		rows = append(rows, []string{text, ""})
+	}
+	if got, want := sampleRows(rows, 0, 0), []int{1, 5, 6, 7, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("all rows: %v; expected %v", got, want)
+	}
+	if got, want := sampleRows(rows, 0, 2), []int{1, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sample of 2: %v; expected %v", got, want)
+	}
+}
+
+func TestCompareRows(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Pumpe läuft", "Pump running"},
+		{"Ventil {0} offen", ""},
+		{"Motor", ""},
+	}
+	sides := [2]compareSide{
+		{"openai/gpt-4o", dictTranslator{"Pumpe läuft": "Pump running", "Ventil {0} offen": "Valve {0} open", "Motor": "Motor"}},
+		{"ollama/llama3.1", dictTranslator{"Pumpe läuft": "Pump is running", "Ventil {0} offen": "Valve open"}},
+	}
+	job := &translationJob{sourceLang: "de-DE", targetLang: "en-US"}
+	results, totals := compareRows(context.Background(), discardSender{}, sides, job, rows, sampleRows(rows, 0, 0), 0, 1)
+
+	if len(results) != 3 {
+		t.Fatalf("%d results; expected 3", len(results))
+	}
+	if results[0].closeness[0] != 1 || results[0].closeness[1] >= 1 || results[0].similar >= 1 {
+		t.Errorf("row 2: closeness %v, similarity %v", results[0].closeness, results[0].similar)
+	}
+	if len(results[1].flags[1]) != 1 || !strings.Contains(results[1].flags[1][0], "placeholders") {
+		t.Errorf("row 3 flags %q; expected the lost placeholder", results[1].flags[1])
+	}
+	if results[2].errs[1] == nil {
+		t.Error("row 4 did not fail for the second side")
+	}
+	if totals[0].translated != 3 || totals[1].translated != 2 || totals[1].errors != 1 || totals[1].flagged != 1 || totals[0].references != 1 {
+		t.Errorf("totals %+v", totals)
+	}
+
+	path := filepath.Join(t.TempDir(), "compare.xlsx")
+	if err := writeComparison(path, sides, results, totals); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	compared, _ := f.GetRows("Compare")
+	if len(compared) != 4 || compared[1][3] != "Pump running" || compared[1][6] != "100%" || compared[3][4] != "ERROR: unknown text" {
+		t.Errorf("compare sheet %q", compared)
+	}
+	summary, _ := f.GetRows("Summary")
+	var identical []string
+	for _, row := range summary {
+		if row[0] == "Identical translations" {
+			identical = row
+		}
+	}
+	if !reflect.DeepEqual(identical, []string{"Identical translations", "0 of 2"}) {
+		t.Errorf("summary %q", summary)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// CONSISTENCY
+// ///////////////////
+
+// cellRef is a target cell of a job.
+type cellRef struct {
+	job *translationJob
+	row int // 0-based row index in the sheet
+}
+
+// variant is one of the translations a source text got.
+type variant struct {
+	translation string
+	cells       []cellRef
+}
+
+// inconsistency is a source text translated differently in different rows
+// of the same file and target language.
+type inconsistency struct {
+	source     string
+	targetLang string
+	variants   []*variant // most used first; ties keep their row order
+}
+
+// findInconsistencies compares the target cells of all jobs of a file as
+// they are now. Rows with a context only count as the same text if the
+// context matches too, and rows left out by --rows/--match are ignored.
+func findInconsistencies(jobs []translationJob) []inconsistency {
+	type group struct {
+		source, targetLang string
+		variants           []*variant
+	}
+	groups := make(map[string]*group)
+	var order []*group
+	for k := range jobs {
+		job := &jobs[k]
+		for i := 1; i < len(job.rows); i++ {
+			row := job.rows[i]
+			if len(row) <= job.sourceIndex {
+				continue
+			}
+			source := strings.TrimSpace(row[job.sourceIndex])
+			if source == "" || !job.filter.allows(i+1, source) {
+				continue
+			}
+			translation := job.cellValue(i)
+			if !tiatrans.HasTranslation(translation) {
+				continue
+			}
+			key := job.targetLang + "\x00" + source
+			if job.contextCol > 0 && len(row) >= job.contextCol {
+				key += "\x00" + strings.TrimSpace(row[job.contextCol-1])
+			}
+			g, ok := groups[key]
+			if !ok {
+				g = &group{source: source, targetLang: job.targetLang}
+				groups[key] = g
+				order = append(order, g)
+			}
+			var v *variant
+			for _, existing := range g.variants {
+				if existing.translation == translation {
+					v = existing
+					break
+				}
+			}
+			if v == nil {
+				v = &variant{translation: translation}
+				g.variants = append(g.variants, v)
+			}
+			v.cells = append(v.cells, cellRef{job: job, row: i})
+		}
+	}
+
+	var found []inconsistency
+	for _, g := range order {
+		if len(g.variants) < 2 {
+			continue
+		}
+		slices.SortStableFunc(g.variants, func(a, b *variant) int {
+			return len(b.cells) - len(a.cells)
+		})
+		found = append(found, inconsistency{source: g.source, targetLang: g.targetLang, variants: g.variants})
+	}
+	return found
+}
+
+// describe formats an inconsistency for the log.
+func (in inconsistency) describe() string {
+	parts := make([]string, len(in.variants))
+	for i, v := range in.variants {
+		rows := make([]string, len(v.cells))
+		for j, c := range v.cells {
+			rows[j] = strconv.Itoa(c.row + 1)
+			if c.job.sheetName != in.variants[0].cells[0].job.sheetName {
+				rows[j] = c.job.sheetName + "!" + rows[j]
+			}
+		}
+		label := "row"
+		if len(rows) > 1 {
+			label = "rows"
+		}
+		parts[i] = fmt.Sprintf("%q in %s %s", v.translation, label, strings.Join(rows, ", "))
+	}
+	return fmt.Sprintf("%q -> %s: %s", in.source, in.targetLang, strings.Join(parts, "; "))
+}
+
+// checkConsistency logs the source texts of task that were translated in
+// more than one way. With fix, every cell gets the most used translation,
+// except cells kept with --overwrite=ask; translations still waiting for
+// review are updated too.
+func checkConsistency(p msgSender, task *fileTask, fix bool) {
+	found := findInconsistencies(task.jobs)
+	if len(found) == 0 {
+		return
+	}
+	p.Send(logMsg(fmt.Sprintf("%d source texts in %s were translated inconsistently", len(found), task.fileName)))
+	unified := 0
+	for _, in := range found {
+		p.Send(logMsg("Inconsistent: " + in.describe()))
+		if !fix {
+			continue
+		}
+		preferred := in.variants[0].translation
+		for _, v := range in.variants[1:] {
+			for _, c := range v.cells {
+				if c.job.keep[c.row] {
+					continue
+				}
+				c.job.setCell(c.row, preferred)
+				task.review.unify(c.job.sheetName, c.job.targetIndex, c.row, preferred)
+				unified++
+			}
+		}
+	}
+	if fix {
+		p.Send(logMsg(fmt.Sprintf("Unified %d cells to their most used translation", unified)))
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestCheckConsistency(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Start", "Start"},
+		{"Start", "Begin"},
+		{"Stopp", "Stop"},
+		{"Start", "Start"},
+		{"Stopp", "Stop"},
+		{"Start", "Launch"},
+		{"Ventil", ""},
+	}
+	job := newTestJob(t, rows)
+	job.keep = map[int]bool{6: true}
+	task := &fileTask{fileName: "plant.xlsx", f: job.f, jobs: []translationJob{job}}
+
+	found := findInconsistencies(task.jobs)
+	if len(found) != 1 {
+		t.Fatalf("found %d inconsistencies, want 1", len(found))
+	}
+	want := `"Start" -> en-US: "Start" in rows 2, 5; "Begin" in row 3; "Launch" in row 7`
+	if got := found[0].describe(); got != want {
+		t.Errorf("describe() = %s\nwant %s", got, want)
+	}
+
+	checkConsistency(discardSender{}, task, true)
+	expected := map[string]string{
+		"B2": "Start",
+		"B3": "Start",
+		"B4": "Stop",
+		"B7": "Launch", // kept with --overwrite=ask
+		"B8": "",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q, want %q", cell, got, want)
+		}
+	}
+}
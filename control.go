@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ///////////////////
+// RUN CONTROL
+// ///////////////////
+
+// runControl carries the TUI's keys to the running jobs: space pauses and
+// resumes the API calls, s skips the row started last and r retries the
+// last row that failed. A nil *runControl never pauses and skips nothing.
+type runControl struct {
+	mu       sync.Mutex
+	paused   bool
+	resumed  chan struct{} // closed when a pause ends
+	inflight []*inflightRow
+	retry    chan struct{}
+}
+
+// inflightRow is a row a worker is translating.
+type inflightRow struct {
+	source  string
+	cancel  context.CancelFunc
+	skipped bool
+}
+
+// errSkippedByUser is the error of a row skipped with s.
+var errSkippedByUser = errors.New("skipped by user")
+
+func newRunControl() *runControl {
+	return &runControl{retry: make(chan struct{}, 1)}
+}
+
+// togglePause pauses or resumes and reports whether it is paused now.
+// Requests already sent finish; new ones wait.
+func (c *runControl) togglePause() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		close(c.resumed)
+	} else {
+		c.resumed = make(chan struct{})
+	}
+	c.paused = !c.paused
+	return c.paused
+}
+
+// wait blocks while the run is paused.
+func (c *runControl) wait(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	paused, resumed := c.paused, c.resumed
+	c.mu.Unlock()
+	if !paused {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// begin registers a row a worker starts on and returns the context its
+// requests must use, which skip cancels.
+func (c *runControl) begin(ctx context.Context, source string) (context.Context, *inflightRow) {
+	if c == nil {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	row := &inflightRow{source: source, cancel: cancel}
+	c.mu.Lock()
+	c.inflight = append(c.inflight, row)
+	c.mu.Unlock()
+	return ctx, row
+}
+
+// end unregisters row and reports whether it was skipped.
+func (c *runControl) end(row *inflightRow) bool {
+	if c == nil || row == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, r := range c.inflight {
+		if r == row {
+			c.inflight = append(c.inflight[:i], c.inflight[i+1:]...)
+			break
+		}
+	}
+	row.cancel()
+	return row.skipped
+}
+
+// skip cancels the row started last and returns its source text. ok is
+// false if no row is being translated.
+func (c *runControl) skip() (source string, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.inflight) - 1; i >= 0; i-- {
+		if row := c.inflight[i]; !row.skipped {
+			row.skipped = true
+			row.cancel()
+			return row.source, true
+		}
+	}
+	return "", false
+}
+
+// requestRetry asks the job running now to retry its last failed row.
+func (c *runControl) requestRetry() {
+	select {
+	case c.retry <- struct{}{}:
+	default:
+	}
+}
+
+// retryRequests delivers the r presses; nil without a control.
+func (c *runControl) retryRequests() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.retry
+}
+
+// pausableTranslator holds back requests while the run is paused.
+type pausableTranslator struct {
+	Translator
+	control *runControl
+}
+
+func (t *pausableTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	if err := t.control.wait(ctx); err != nil {
+		return "", err
+	}
+	return t.Translator.Translate(ctx, text, sourceLang, targetLang)
+}
+
+// pausableBatchTranslator does the same for batch requests.
+type pausableBatchTranslator struct {
+	batchTranslator
+	control *runControl
+}
+
+func (t *pausableBatchTranslator) TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	if err := t.control.wait(ctx); err != nil {
+		return nil, err
+	}
+	return t.batchTranslator.TranslateBatch(ctx, texts, sourceLang, targetLang)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// gatedTranslator upper-cases texts. Texts containing "Slow" wait until
+// their context ends, texts containing "Gate" until gate is closed, and
+// texts containing "Flaky" fail on their first request.
+type gatedTranslator struct {
+	mu      sync.Mutex
+	seen    map[string]int
+	started chan string
+	gate    chan struct{}
+}
+
+func newGatedTranslator() *gatedTranslator {
+	return &gatedTranslator{seen: make(map[string]int), started: make(chan string, 10), gate: make(chan struct{})}
+}
+
+func (g *gatedTranslator) count(text string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.seen[text]
+}
+
+func (g *gatedTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	g.mu.Lock()
+	g.seen[text]++
+	n := g.seen[text]
+	g.mu.Unlock()
+	g.started <- text
+	switch {
+	case strings.Contains(text, "Slow"):
+		<-ctx.Done()
+		return "", ctx.Err()
+	case strings.Contains(text, "Gate"):
+		<-g.gate
+	case strings.Contains(text, "Flaky") && n == 1:
+		return "", errors.New("connection reset")
+	}
+	return strings.ToUpper(text), nil
+}
+
+func TestSkipRow(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Slow text", ""},
+		{"Pump running", ""},
+	}
+	job := newTestJob(t, rows)
+	job.workers = 1
+	job.report = &fileReport{}
+	job.control = newRunControl()
+	translator := newGatedTranslator()
+	done := make(chan struct{})
+	go func() {
+		iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+		close(done)
+	}()
+
+	if text := <-translator.started; text != "Slow text" {
+		t.Fatalf("first request for %q", text)
+	}
+	if source, ok := job.control.skip(); !ok || source != "Slow text" {
+		t.Fatalf("skip() = %q, %t", source, ok)
+	}
+	<-done
+
+	if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != "" {
+		t.Errorf("B2 = %q; expected the skipped row left alone", got)
+	}
+	if got, _ := job.f.GetCellValue(job.sheetName, "B3"); got != "PUMP RUNNING" {
+		t.Errorf("B3 = %q", got)
+	}
+	if n := translator.count("Slow text"); n != 1 {
+		t.Errorf("skipped row sent %d times; expected no retry", n)
+	}
+	entries := job.report.sorted()
+	if entries[0].status != rowSkipped || entries[0].detail != errSkippedByUser.Error() {
+		t.Errorf("report entry = %+v", entries[0])
+	}
+	if _, ok := job.control.skip(); ok {
+		t.Error("skip() found a row after the run")
+	}
+}
+
+func TestPause(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Pump running", ""},
+	}
+	job := newTestJob(t, rows)
+	job.control = newRunControl()
+	job.control.togglePause()
+	translator := newGatedTranslator()
+	done := make(chan struct{})
+	go func() {
+		iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+		close(done)
+	}()
+
+	select {
+	case text := <-translator.started:
+		t.Fatalf("%q sent while paused", text)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if job.control.togglePause() {
+		t.Fatal("still paused after the second toggle")
+	}
+	<-done
+	if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != "PUMP RUNNING" {
+		t.Errorf("B2 = %q", got)
+	}
+}
+
+func TestRetryKey(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Flaky pump", ""},
+		{"Gate valve", ""},
+	}
+	job := newTestJob(t, rows)
+	job.workers = 1
+	job.control = newRunControl()
+	translator := newGatedTranslator()
+	done := make(chan struct{})
+	go func() {
+		iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+		close(done)
+	}()
+
+	// The worker is held on "Gate valve" while the failed row is retried.
+	for text := range translator.started {
+		if text == "Gate valve" {
+			break
+		}
+	}
+	job.control.requestRetry()
+	for translator.count("Flaky pump") < 2 {
+		<-translator.started
+	}
+	close(translator.gate)
+	<-done
+
+	if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != "FLAKY PUMP" {
+		t.Errorf("B2 = %q", got)
+	}
+	if n := translator.count("Flaky pump"); n != 2 {
+		t.Errorf("failed row sent %d times; expected 2, the retry pass has nothing left", n)
+	}
+}
+
+func TestQuitKey(t *testing.T) {
+	q := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}}
+	j := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}}
+	quits := func(cmd tea.Cmd) bool {
+		if cmd == nil {
+			return false
+		}
+		_, ok := cmd().(tea.QuitMsg)
+		return ok
+	}
+
+	var m tea.Model = model{}
+	for i, key := range []tea.KeyMsg{q, j, q} {
+		var cmd tea.Cmd
+		m, cmd = m.Update(key)
+		if quits(cmd) {
+			t.Fatalf("key %d (%s) quit the run", i, key)
+		}
+	}
+	if _, cmd := m.Update(q); !quits(cmd) {
+		t.Error("q pressed twice did not quit")
+	}
+	if _, cmd := (model{done: true}).Update(q); !quits(cmd) {
+		t.Error("q after the run did not quit at once")
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// ///////////////////
+// CSV INPUT
+// ///////////////////
+
+// csvFormat is how a CSV input file was written, so the translated copy can
+// be written the same way.
+type csvFormat struct {
+	delimiter rune
+	encoding  encoding.Encoding
+	bom       bool
+}
+
+// isCSVFile reports whether fileName should be read as CSV.
+func isCSVFile(fileName string) bool {
+	return strings.EqualFold(filepath.Ext(fileName), ".csv")
+}
+
+// parseDelimiter turns the --csv-delimiter value into a rune; 0 means
+// detect it from the header line.
+func parseDelimiter(spec string) (rune, error) {
+	switch spec {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	}
+	if utf8.RuneCountInString(spec) != 1 {
+		return 0, fmt.Errorf("delimiter must be a single character, \"comma\", \"semicolon\" or \"tab\", got %q", spec)
+	}
+	r, _ := utf8.DecodeRuneInString(spec)
+	if r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("invalid delimiter %q", spec)
+	}
+	return r, nil
+}
+
+// lookupEncoding returns the encoding for a --csv-encoding name such as
+// "utf-8", "utf-16le" or "windows-1252", or nil for "auto".
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	if name == "" || strings.EqualFold(name, "auto") {
+		return nil, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	return enc, nil
+}
+
+// detectEncoding guesses the encoding from a byte order mark, falling back to
+// Windows-1252 for anything that is not valid UTF-8, which is what older
+// WinCC flexible exports use.
+func detectEncoding(data []byte) encoding.Encoding {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return unicode.UTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	case utf8.Valid(data):
+		return unicode.UTF8
+	}
+	return charmap.Windows1252
+}
+
+// detectDelimiter picks whichever of ; , and tab occurs most often in the
+// header line, defaulting to a comma.
+func detectDelimiter(text string) rune {
+	header, _, _ := strings.Cut(text, "\n")
+	best, bestCount := ',', 0
+	for _, r := range []rune{',', ';', '\t'} {
+		if n := strings.Count(header, string(r)); n > bestCount {
+			best, bestCount = r, n
+		}
+	}
+	return best
+}
+
+// decodeCSV parses CSV data. A zero delimiter or nil encoding is detected.
+func decodeCSV(data []byte, delimiter rune, enc encoding.Encoding) ([][]string, csvFormat, error) {
+	if enc == nil {
+		enc = detectEncoding(data)
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, csvFormat{}, fmt.Errorf("could not decode CSV: %w", err)
+	}
+	text := string(decoded)
+	format := csvFormat{delimiter: delimiter, encoding: enc}
+	if strings.HasPrefix(text, "\ufeff") {
+		text = strings.TrimPrefix(text, "\ufeff")
+		format.bom = true
+	}
+	if format.delimiter == 0 {
+		format.delimiter = detectDelimiter(text)
+	}
+
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = format.delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, csvFormat{}, fmt.Errorf("could not parse CSV: %w", err)
+	}
+	return rows, format, nil
+}
+
+// openCSV reads a CSV file into a single-sheet workbook so the rest of the
+// tool can treat it like any other input.
+func openCSV(fileName string, delimiter rune, enc encoding.Encoding) (*excelize.File, *csvFormat, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error opening file: %v", err)
+	}
+	rows, format, err := decodeCSV(data, delimiter, enc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		values := make([]interface{}, len(row))
+		for j, v := range row {
+			values[j] = v
+		}
+		if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	return f, &format, nil
+}
+
+// csvOutputFormat is the format of --csv output and of the list of failed
+// rows: --csv-delimiter and --csv-encoding if given, otherwise
+// comma-separated UTF-8, with a byte order mark if --csv-bom is set. Excel
+// in many European locales expects semicolons, and only recognizes UTF-8
+// with the byte order mark.
+func csvOutputFormat(opts options) csvFormat {
+	format := csvFormat{delimiter: opts.csvDelimiter, encoding: opts.csvEncoding, bom: opts.csvBOM}
+	if format.delimiter == 0 {
+		format.delimiter = ','
+	}
+	if format.encoding == nil {
+		format.encoding = unicode.UTF8
+	}
+	return format
+}
+
+// writeCSV writes a sheet in the given format.
+func writeCSV(f *excelize.File, sheetName, newFileName string, format csvFormat) error {
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return fmt.Errorf("failed to get rows from sheet: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if format.bom {
+		buf.WriteString("\ufeff")
+	}
+	writer := csv.NewWriter(&buf)
+	writer.Comma = format.delimiter
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+
+	data, err := format.encoding.NewEncoder().Bytes(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("could not encode CSV: %w", err)
+	}
+	return os.WriteFile(newFileName, data, 0o644)
+}
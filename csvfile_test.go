@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestDecodeCSV(t *testing.T) {
+	utf16, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String("de-DE\ten-US\r\nStörung\t\r\n")
+	latin1, _ := charmap.Windows1252.NewEncoder().String("de-DE;en-US\nMotorstörung;\"a;b\"\n")
+
+	testCases := []struct {
+		name     string
+		data     string
+		expected [][]string
+		delim    rune
+		bom      bool
+	}{
+		{"utf-8 comma", "de-DE,en-US\nPumpe,Pump\n", [][]string{{"de-DE", "en-US"}, {"Pumpe", "Pump"}}, ',', false},
+		{"utf-8 bom semicolon", "\ufeffde-DE;en-US\nPumpe;\n", [][]string{{"de-DE", "en-US"}, {"Pumpe", ""}}, ';', true},
+		{"windows-1252", latin1, [][]string{{"de-DE", "en-US"}, {"Motorstörung", "a;b"}}, ';', false},
+		{"utf-16 tab", utf16, [][]string{{"de-DE", "en-US"}, {"Störung", ""}}, '\t', true},
+	}
+
+	for _, tc := range testCases {
+		rows, format, err := decodeCSV([]byte(tc.data), 0, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(rows, tc.expected) {
+			t.Errorf("%s: rows = %q; expected %q", tc.name, rows, tc.expected)
+		}
+		if format.delimiter != tc.delim || format.bom != tc.bom {
+			t.Errorf("%s: delimiter %q bom %t; expected %q %t", tc.name, format.delimiter, format.bom, tc.delim, tc.bom)
+		}
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "texts.csv")
+	data, _ := charmap.Windows1252.NewEncoder().String("ID;de-DE;en-US\r\n1;Störung;\r\n")
+	if err := os.WriteFile(in, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, format, err := openCSV(in, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet := f.GetSheetName(0)
+	f.SetCellValue(sheet, "C2", "Fault")
+
+	out := filepath.Join(dir, "out.csv")
+	if err := writeCSV(f, sheet, out, *format); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(out)
+	expected, _ := charmap.Windows1252.NewEncoder().String("ID;de-DE;en-US\n1;Störung;Fault\n")
+	if string(got) != expected {
+		t.Errorf("output = %q; expected %q", got, expected)
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	testCases := []struct {
+		spec     string
+		expected rune
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{";", ';', false},
+		{"tab", '\t', false},
+		{`\t`, '\t', false},
+		{"comma", ',', false},
+		{"semicolon", ';', false},
+		{"|", '|', false},
+		{";;", 0, true},
+		{`"`, 0, true},
+	}
+	for _, tc := range testCases {
+		got, err := parseDelimiter(tc.spec)
+		if (err != nil) != tc.wantErr || got != tc.expected {
+			t.Errorf("parseDelimiter(%q) = %q, %v; expected %q, wantErr %t", tc.spec, got, err, tc.expected, tc.wantErr)
+		}
+	}
+}
+
+func TestCSVOutputFormat(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	f.SetSheetRow("Sheet1", "A1", &[]string{"de-DE", "en-US"})
+	f.SetSheetRow("Sheet1", "A2", &[]string{"Störung", "Fault"})
+
+	testCases := []struct {
+		name     string
+		opts     options
+		expected string
+	}{
+		{"default", options{}, "de-DE,en-US\nStörung,Fault\n"},
+		{"bom", options{csvDelimiter: ';', csvBOM: true}, "\ufeffde-DE;en-US\nStörung;Fault\n"},
+		{"windows-1252", options{csvDelimiter: '\t', csvEncoding: charmap.Windows1252}, "de-DE\ten-US\nSt\xf6rung\tFault\n"},
+	}
+	for _, tc := range testCases {
+		out := filepath.Join(t.TempDir(), "out.csv")
+		if err := writeCSV(f, "Sheet1", out, csvOutputFormat(tc.opts)); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(out); string(got) != tc.expected {
+			t.Errorf("%s: output = %q; expected %q", tc.name, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ///////////////////
+// DEEPL PROVIDER
+// ///////////////////
+
+const (
+	deeplFreeURL = "https://api-free.deepl.com"
+	deeplProURL  = "https://api.deepl.com"
+)
+
+func init() {
+	registerProvider("deepl", providerSpec{
+		factory: func(cfg providerConfig) (Translator, error) {
+			t := newDeepLTranslator(cfg.apiKey)
+			if cfg.client != nil {
+				t.client = cfg.client
+			}
+			t.budget = cfg.budget
+			// The prefer_ variants fall back to the default for languages
+			// without a formal/informal distinction instead of failing.
+			switch cfg.tone {
+			case "formal":
+				t.formality = "prefer_more"
+			case "informal":
+				t.formality = "prefer_less"
+			}
+			return t, nil
+		},
+		keyEnv:  "DEEPL_API_KEY",
+		keyFile: "deepl-key.txt",
+		keyName: "DeepL",
+	})
+}
+
+type deeplTranslator struct {
+	apiKey    string
+	baseURL   string
+	client    *http.Client
+	budget    *budget
+	formality string // "prefer_more", "prefer_less" or "" for the default
+}
+
+// newDeepLTranslator picks the free or pro endpoint from the key: DeepL free
+// keys end in ":fx".
+func newDeepLTranslator(apiKey string) *deeplTranslator {
+	baseURL := deeplProURL
+	if strings.HasSuffix(apiKey, ":fx") {
+		baseURL = deeplFreeURL
+	}
+	return &deeplTranslator{apiKey: apiKey, baseURL: baseURL, client: http.DefaultClient}
+}
+
+type deeplRequest struct {
+	Text       []string `json:"text"`
+	SourceLang string   `json:"source_lang,omitempty"`
+	TargetLang string   `json:"target_lang"`
+	Context    string   `json:"context,omitempty"`
+	Formality  string   `json:"formality,omitempty"`
+}
+
+type deeplResponse struct {
+	Translations []struct {
+		DetectedSourceLanguage string `json:"detected_source_language"`
+		Text                   string `json:"text"`
+	} `json:"translations"`
+}
+
+func (t *deeplTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	results, err := t.TranslateBatch(ctx, []string{text}, sourceLang, targetLang)
+	if err != nil {
+		return "", err
+	}
+	return results[0], nil
+}
+
+// deeplMaxTexts is the most texts DeepL accepts in one request.
+const deeplMaxTexts = 50
+
+// TranslateBatch uses DeepL's native support for several texts per request.
+func (t *deeplTranslator) TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	if len(texts) > deeplMaxTexts {
+		return nil, fmt.Errorf("DeepL accepts at most %d texts per request, got %d", deeplMaxTexts, len(texts))
+	}
+	body, err := json.Marshal(deeplRequest{
+		Text:       texts,
+		SourceLang: deeplLangCode(sourceLang, false),
+		TargetLang: deeplLangCode(targetLang, true),
+		Context:    promptHintsFrom(ctx).context,
+		Formality:  t.formality,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp deeplResponse
+	if err := t.do(ctx, http.MethodPost, "/v2/translate", body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Translations) != len(texts) {
+		return nil, fmt.Errorf("DeepL returned %d translations for %d texts", len(resp.Translations), len(texts))
+	}
+	results := make([]string, len(texts))
+	chars := 0
+	for i, tr := range resp.Translations {
+		results[i] = tr.Text
+		chars += utf8.RuneCountInString(texts[i])
+	}
+	// DeepL bills the characters of the source text.
+	t.budget.spend(0, 0, chars, 1)
+	return results, nil
+}
+
+// Validate checks the key against the usage endpoint, which costs nothing.
+func (t *deeplTranslator) Validate(ctx context.Context) error {
+	return t.do(ctx, http.MethodGet, "/v2/usage", nil, nil)
+}
+
+func (t *deeplTranslator) do(ctx context.Context, method, path string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, t.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+t.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not connect to DeepL: %w%s", err, networkHint(err))
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("the provided DeepL API key is invalid or has expired")
+	case resp.StatusCode == 456:
+		return fmt.Errorf("DeepL quota exceeded")
+	case resp.StatusCode != http.StatusOK:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{
+			code:       resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			err:        fmt.Errorf("DeepL returned %s: %s", resp.Status, strings.TrimSpace(string(msg))),
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+var langCodeRegex = regexp.MustCompile(`^([A-Za-z]{2,3})(?:[-_]([A-Za-z]{2,4}))?`)
+
+// deeplLangCode converts a column header such as "de-DE" or "en-US*" into a
+// DeepL language code. Source languages are bare ("DE"); targets keep the
+// regional variant where DeepL distinguishes one ("EN-US", "PT-BR").
+func deeplLangCode(header string, target bool) string {
+	m := langCodeRegex.FindStringSubmatch(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(header), "*")))
+	if m == nil {
+		return strings.ToUpper(header)
+	}
+	lang := strings.ToUpper(m[1])
+	region := strings.ToUpper(m[2])
+	if !target {
+		return lang
+	}
+	switch lang {
+	case "EN":
+		if region == "GB" {
+			return "EN-GB"
+		}
+		return "EN-US"
+	case "PT":
+		if region == "BR" {
+			return "PT-BR"
+		}
+		return "PT-PT"
+	case "ZH":
+		if region == "TW" || region == "HK" || region == "HANT" {
+			return "ZH-HANT"
+		}
+		return "ZH-HANS"
+	}
+	return lang
+}
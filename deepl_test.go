@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDeepLLangCode(t *testing.T) {
+	testCases := []struct {
+		header   string
+		target   bool
+		expected string
+	}{
+		{"de-DE", false, "DE"},
+		{"de-DE*", false, "DE"},
+		{"en-US", true, "EN-US"},
+		{"en-GB", true, "EN-GB"},
+		{"en", true, "EN-US"},
+		{"en-US", false, "EN"},
+		{"pt-BR", true, "PT-BR"},
+		{"pt-PT", true, "PT-PT"},
+		{"zh-CN", true, "ZH-HANS"},
+		{"zh-TW", true, "ZH-HANT"},
+		{"fr-FR", true, "FR"},
+		{" it_IT ", true, "IT"},
+	}
+
+	for _, tc := range testCases {
+		result := deeplLangCode(tc.header, tc.target)
+		if result != tc.expected {
+			t.Errorf("deeplLangCode(%q, %t) = %q; expected %q", tc.header, tc.target, result, tc.expected)
+		}
+	}
+}
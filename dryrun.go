@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ///////////////////
+// DRY RUN
+// ///////////////////
+
+// modelPrice is what a model costs and roughly how long one request takes.
+// Prices are list prices in USD and only meant for a quote; check the
+// provider's pricing page before relying on them.
+type modelPrice struct {
+	provider string
+	model    string
+	input    float64 // per million input tokens
+	output   float64 // per million output tokens
+	perChar  float64 // per million characters, for character-billed APIs
+	latency  time.Duration
+}
+
+var modelPrices = []modelPrice{
+	{provider: "openai", model: "gpt-4o-mini", input: 0.15, output: 0.60, latency: 1200 * time.Millisecond},
+	{provider: "openai", model: "gpt-4.1-nano", input: 0.10, output: 0.40, latency: 1000 * time.Millisecond},
+	{provider: "openai", model: "gpt-4.1-mini", input: 0.40, output: 1.60, latency: 1500 * time.Millisecond},
+	{provider: "openai", model: "gpt-4.1", input: 2.00, output: 8.00, latency: 2500 * time.Millisecond},
+	{provider: "openai", model: "gpt-4o", input: 2.50, output: 10.00, latency: 2500 * time.Millisecond},
+	{provider: "deepl", model: "DeepL API Pro", perChar: 25.00, latency: 400 * time.Millisecond},
+	{provider: "ollama", model: "local model", latency: 4 * time.Second},
+}
+
+// modelPriceFor finds the entry of modelPrices for the selected provider
+// and model. OpenAI has one per model, the other providers one each.
+func modelPriceFor(provider, model string) (modelPrice, bool) {
+	if provider == "openai" && model == "" {
+		model = openai.GPT4oMini
+	}
+	for _, mp := range modelPrices {
+		if mp.provider == provider && (provider != "openai" || mp.model == model) {
+			return mp, true
+		}
+	}
+	return modelPrice{}, false
+}
+
+// promptOverheadTokens approximates the instructions sent around each text
+// (see openaiTranslator).
+const promptOverheadTokens = 70
+
+// estimateOutputTokens approximates the tokens of the translations of texts
+// with inputTokens tokens. Translations come out about as long as their
+// source, with some growth for languages like German.
+func estimateOutputTokens(inputTokens int) int {
+	return int(math.Ceil(float64(inputTokens) * 1.2))
+}
+
+// tokensFor returns how to count the tokens of a text for a model: with
+// the model's own BPE tokenizer for the OpenAI models tiktoken knows, and
+// with estimateTokens for any other. The tokenizers' vocabularies are built
+// in, so counting needs no network.
+func tokensFor(provider, model string) func(string) int {
+	if provider != "openai" {
+		return estimateTokens
+	}
+	if model == "" {
+		model = openai.GPT4oMini
+	}
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+	enc, ok := tokenizers[model]
+	if !ok {
+		// Loading a vocabulary takes a moment, so each is loaded once.
+		enc, _ = tiktoken.EncodingForModel(model)
+		tokenizers[model] = enc
+	}
+	if enc == nil {
+		return estimateTokens
+	}
+	return func(text string) int { return len(enc.EncodeOrdinary(text)) }
+}
+
+var (
+	tokenizersMu sync.Mutex
+	tokenizers   = make(map[string]*tiktoken.Tiktoken) // by model; nil = none known
+)
+
+func init() {
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// estimateTokens approximates the token count of text where no tokenizer is
+// known: about four characters per token for Latin script, and one token per
+// character for scripts such as CJK that tokenizers split much finer.
+func estimateTokens(text string) int {
+	latin, other := 0, 0
+	for _, r := range text {
+		if r < 0x250 {
+			latin++
+		} else {
+			other++
+		}
+	}
+	return (latin+3)/4 + other
+}
+
+// runEstimate is what a run would send to the API.
+type runEstimate struct {
+	texts        int // distinct texts sent
+	chars        int
+	inputTokens  int              // text only, without prompt overhead
+	count        func(string) int // counts the tokens of a text; see tokensFor
+	tmHits       int
+	reused       int
+	requestCount int
+	files        int // files that are run, some of them at once with --parallel-files
+
+	// What happens to the data rows of the sheet.
+	rows      int
+	planned   int // rows that go to the translator, including reused ones
+	copied    int
+	preserved int
+	previous  int // unchanged since --previous
+}
+
+// merge adds o to e.
+func (e *runEstimate) merge(o runEstimate) {
+	e.texts += o.texts
+	e.chars += o.chars
+	e.inputTokens += o.inputTokens
+	e.tmHits += o.tmHits
+	e.reused += o.reused
+	e.requestCount += o.requestCount
+	e.files += o.files
+	e.rows += o.rows
+	e.planned += o.planned
+	e.copied += o.copied
+	e.preserved += o.preserved
+	e.previous += o.previous
+}
+
+// skipped counts the rows that are neither translated, copied nor kept,
+// such as empty or unselected ones.
+func (e runEstimate) skipped() int {
+	return e.rows - e.planned - e.copied - e.preserved - e.previous
+}
+
+// tokens estimates the input tokens, including the prompt sent with every
+// request, and the output tokens.
+func (e runEstimate) tokens() (input, output int) {
+	return e.inputTokens + e.requestCount*promptOverheadTokens, estimateOutputTokens(e.inputTokens)
+}
+
+// quote estimates what e costs with mp and how long it takes. Requests run
+// on opts.workers in parallel in each of the files run at once, but no
+// faster than --rpm/--tpm allow.
+func (e runEstimate) quote(mp modelPrice, opts options) (cost float64, duration string) {
+	input, output := e.tokens()
+	cost = float64(input)/1e6*mp.input + float64(output)/1e6*mp.output + float64(e.chars)/1e6*mp.perChar
+	if opts.batchAPI && mp.provider == "openai" {
+		return cost * batchAPIDiscount, "up to 24h"
+	}
+	workers := max(opts.workers, 1) * max(min(opts.parallelFiles, e.files), 1)
+	d := time.Duration(e.requestCount) * mp.latency / time.Duration(workers)
+	if opts.rpm > 0 {
+		d = max(d, minutesToDuration(float64(e.requestCount)/float64(opts.rpm)))
+	}
+	if opts.tpm > 0 {
+		d = max(d, minutesToDuration(float64(input+output)/float64(opts.tpm)))
+	}
+	return cost, d.Round(time.Second).String()
+}
+
+// add counts one text the translator would be asked for.
+func (e *runEstimate) add(text string, sent map[string]bool, job *translationJob) {
+	if sent[text] {
+		e.reused++
+		return
+	}
+	sent[text] = true
+	if _, ok := job.memoryLookup(text); ok {
+		e.tmHits++
+		return
+	}
+	e.texts++
+	e.chars += utf8.RuneCountInString(text)
+	e.inputTokens += e.count(text)
+}
+
+// estimateJob plans a job like iterateAndTranslate does and counts the texts
+// that would need the translator, and their tokens with count. The workbook
+// is left as it is.
+func estimateJob(job translationJob, count func(string) int) runEstimate {
+	est := runEstimate{count: count}
+	var stats stats
+	job.planOnly = true
+	job.report, job.audit = nil, nil
+	jobs := planRows(discardSender{}, &job, &stats, func() {})
+	est.rows = max(len(job.rows)-1, 0)
+	est.planned = len(jobs)
+	est.copied = stats.copied
+	est.preserved = stats.preserved
+	est.previous = stats.reused // planning only reuses the previous run
+	sent := make(map[string]bool)
+	for _, rj := range jobs {
+		switch {
+		case rj.resumed:
+			est.reused++
+		case rj.segments != nil:
+			for i, seg := range rj.segments {
+				if i%2 == 0 && strings.TrimSpace(seg) != "" {
+					est.add(strings.TrimSpace(seg), sent, &job)
+				}
+			}
+		case rj.prev != nil && rj.identical:
+			est.reused++
+		case rj.prev != nil:
+			if _, err := strconv.Atoi(rj.suffix); err == nil {
+				est.reused++
+			} else {
+				est.add(rj.suffix, sent, &job)
+			}
+		default:
+			est.add(rj.source, sent, &job)
+		}
+	}
+	est.requestCount = est.texts
+	if job.batchSize > 1 {
+		est.requestCount = (est.texts + job.batchSize - 1) / job.batchSize
+	}
+	return est
+}
+
+// analysisLines sums up what a run will do for the confirmation screen:
+// where the rows go, how many texts are sent, and the estimate for the
+// selected model.
+func analysisLines(tasks []*fileTask, opts options) []string {
+	total := runEstimate{files: len(tasks)}
+	count := tokensFor(opts.provider, opts.model)
+	for _, task := range tasks {
+		for _, job := range task.jobs {
+			total.merge(estimateJob(job, count))
+		}
+	}
+	rows := fmt.Sprintf("%d to translate, %d copied, %d kept, %d skipped", total.planned, total.copied, total.preserved, total.skipped())
+	if total.previous > 0 {
+		rows += fmt.Sprintf(", %d unchanged since the previous run", total.previous)
+	}
+	lines := []string{
+		fmt.Sprintf("Rows:       %d (%s)", total.rows, rows),
+		fmt.Sprintf("Texts:      %d unique to send, %d reused, %d from translation memory", total.texts, total.reused, total.tmHits),
+	}
+	input, output := total.tokens()
+	size := fmt.Sprintf("~%d tokens", input+output)
+	if opts.provider == "deepl" {
+		size = fmt.Sprintf("%d characters", total.chars)
+	}
+	mp, ok := modelPriceFor(opts.provider, opts.model)
+	if !ok {
+		return append(lines, fmt.Sprintf("Estimate:   %s, no price known for %s", size, modelLabel(opts.provider, opts.model)))
+	}
+	cost, duration := total.quote(mp, opts)
+	price := fmt.Sprintf("$%.2f", cost)
+	if cost > 0 && cost < 0.01 {
+		price = "under $0.01"
+	}
+	return append(lines, fmt.Sprintf("Estimate:   %s, %s, %s", size, price, duration))
+}
+
+// discardSender drops progress messages, e.g. during a dry run.
+type discardSender struct{}
+
+func (discardSender) Send(msg tea.Msg) {}
+
+// printDryRun writes the estimate for all tasks and a cost table per model.
+func printDryRun(w io.Writer, tasks []*fileTask, opts options) {
+	fmt.Fprintln(w, "Dry run: no requests are sent.")
+	fmt.Fprintln(w)
+
+	total := runEstimate{files: len(tasks)}
+	count := tokensFor(opts.provider, opts.model)
+	for _, task := range tasks {
+		for _, job := range task.jobs {
+			est := estimateJob(job, count)
+			unchanged := ""
+			if est.previous > 0 {
+				unchanged = fmt.Sprintf(", %d unchanged since the previous run", est.previous)
+			}
+			fmt.Fprintf(w, "%s [%s] %s -> %s: %d texts to translate (%d from translation memory, %d reused%s)\n",
+				task.fileName, job.sheetName, job.sourceLang, job.targetLang, est.texts, est.tmHits, est.reused, unchanged)
+			total.merge(est)
+		}
+	}
+
+	overhead := total.requestCount * promptOverheadTokens
+	inputTokens, outputTokens := total.tokens()
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Total: %d texts, %d characters, %d requests\n", total.texts, total.chars, total.requestCount)
+	fmt.Fprintf(w, "Estimated tokens: ~%d input (incl. ~%d prompt), ~%d output\n", inputTokens, overhead, outputTokens)
+	fmt.Fprintln(w)
+
+	selected, _ := modelPriceFor(opts.provider, opts.model)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tModel\tEst. cost (USD)\tEst. time")
+	for _, mp := range modelPrices {
+		cost, est := total.quote(mp, opts)
+		marker := ""
+		if mp == selected {
+			marker = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s (%s)\t%.4f\t%s\n", marker, mp.model, mp.provider, cost, est)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "* selected provider. Token counts and prices are estimates.")
+	if opts.batchAPI {
+		fmt.Fprintln(w, "OpenAI prices include the Batch API discount.")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected int
+	}{
+		{"", 0},
+		{"Pump", 1},
+		{"Motor fault", 3},
+		{"Störung", 2},
+		{"电机故障", 4},
+	}
+	for _, tc := range testCases {
+		if got := estimateTokens(tc.text); got != tc.expected {
+			t.Errorf("estimateTokens(%q) = %d; expected %d", tc.text, got, tc.expected)
+		}
+	}
+}
+
+func TestTokensFor(t *testing.T) {
+	testCases := []struct {
+		provider, model, text string
+		expected              int
+	}{
+		{"openai", "", "Motor fault", 2},
+		{"openai", "gpt-4.1-nano", "Discrete_alarm_66", 4},
+		{"openai", "my-finetune", "Motor fault", 3}, // no tokenizer known, estimated
+		{"deepl", "", "Motor fault", 3},
+	}
+	for _, tc := range testCases {
+		if got := tokensFor(tc.provider, tc.model)(tc.text); got != tc.expected {
+			t.Errorf("tokensFor(%q, %q)(%q) = %d; expected %d", tc.provider, tc.model, tc.text, got, tc.expected)
+		}
+	}
+}
+
+func TestEstimateJob(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Motor fault", ""},
+		{"Discrete_alarm_66", ""},
+		{"Discrete_alarm_67", ""},
+		{"42", ""},
+		{"Pump running", ""},
+	}
+	job := newTestJob(t, rows)
+	job.resumed = map[int]string{7: "Pump is running"}
+
+	est := estimateJob(job, estimateTokens)
+	if est.texts != 2 || est.reused != 3 {
+		t.Errorf("estimate = %+v; expected 2 texts and 3 reused", est)
+	}
+	if want := len("Motor fault") + len("Discrete_alarm_66"); est.chars != want {
+		t.Errorf("chars = %d; expected %d", est.chars, want)
+	}
+
+	var buf bytes.Buffer
+	printDryRun(&buf, []*fileTask{{fileName: "export.xlsx", jobs: []translationJob{job}}}, options{provider: "openai", workers: 4})
+	out := buf.String()
+	if !strings.Contains(out, "2 texts to translate") || !strings.Contains(out, "*  gpt-4o-mini") {
+		t.Errorf("unexpected dry run output:\n%s", out)
+	}
+}
+
+func TestAnalysisLines(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Motor fault", ""},
+		{"Discrete_alarm_66", ""},
+		{"Discrete_alarm_67", ""},
+		{"42", ""},
+		{"", ""},
+	}
+	job := newTestJob(t, rows)
+	task := &fileTask{fileName: "export.xlsx", jobs: []translationJob{job}}
+	lines := analysisLines([]*fileTask{task}, options{provider: "openai", workers: 4})
+	expected := []string{
+		"Rows:       6 (4 to translate, 1 copied, 0 kept, 1 skipped)",
+		"Texts:      2 unique to send, 2 reused, 0 from translation memory",
+		"Estimate:   ~154 tokens, under $0.01, 1s", // 6 text tokens by gpt-4o-mini's tokenizer
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("analysisLines() =\n%s\nexpected\n%s", strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+	}
+	lines = analysisLines([]*fileTask{task}, options{provider: "openai", model: "my-finetune", workers: 4})
+	if !strings.Contains(lines[2], "no price known for openai/my-finetune") {
+		t.Errorf("estimate for an unknown model = %q", lines[2])
+	}
+	if got, _ := job.f.GetCellValue(job.sheetName, "B6"); got != "" {
+		t.Errorf("the analysis copied %q into the workbook", got)
+	}
+}
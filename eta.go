@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ///////////////////
+// THROUGHPUT AND ETA
+// ///////////////////
+
+// rateWindow is how far back the row rate looks. Skipped and copied rows
+// are done in a burst when a sheet starts; a rolling window lets the
+// estimate settle on the pace of the translated rows soon after.
+const rateWindow = time.Minute
+
+// minRateSpan is the least time the samples must cover before a rate is
+// shown at all.
+const minRateSpan = 5 * time.Second
+
+type rateSample struct {
+	at   time.Time
+	rows int
+}
+
+// throughput tracks how many rows of the current file are done over time.
+type throughput struct {
+	samples []rateSample
+}
+
+// add records that rows rows are done at time at. Samples older than the
+// window are dropped, except the newest of them, which anchors the window.
+func (t *throughput) add(at time.Time, rows int) {
+	t.samples = append(t.samples, rateSample{at, rows})
+	cut := 0
+	for cut+1 < len(t.samples) && at.Sub(t.samples[cut+1].at) >= rateWindow {
+		cut++
+	}
+	t.samples = t.samples[cut:]
+}
+
+// perMinute returns the rows done per minute over the window. ok is false
+// until the samples span minRateSpan.
+func (t *throughput) perMinute() (rate float64, ok bool) {
+	if len(t.samples) < 2 {
+		return 0, false
+	}
+	first, last := t.samples[0], t.samples[len(t.samples)-1]
+	span := last.at.Sub(first.at)
+	if span < minRateSpan {
+		return 0, false
+	}
+	return float64(last.rows-first.rows) / span.Minutes(), true
+}
+
+// eta estimates how long the remaining rows will take at the current rate.
+func (t *throughput) eta(remaining int) (time.Duration, bool) {
+	rate, ok := t.perMinute()
+	if !ok || rate <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(remaining) / rate * float64(time.Minute)), true
+}
+
+// status renders the rate and the time left for remaining rows, e.g.
+// "120 rows/min, about 12m left", or "" while there is no estimate yet.
+func (t *throughput) status(remaining int) string {
+	rate, ok := t.perMinute()
+	if !ok {
+		return ""
+	}
+	eta, ok := t.eta(remaining)
+	if !ok {
+		return fmt.Sprintf("%.0f rows/min", rate)
+	}
+	return fmt.Sprintf("%.0f rows/min, about %s left", rate, formatETA(eta))
+}
+
+// formatETA rounds d to what is worth reading: "2h 5m", "12m" or "<1m".
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Minute)
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
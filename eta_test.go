@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThroughput(t *testing.T) {
+	start := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+	var rate throughput
+	rate.add(start, 0)
+	rate.add(start.Add(2*time.Second), 500) // skipped rows, done in a burst
+	if _, ok := rate.perMinute(); ok {
+		t.Error("rate given before the samples span minRateSpan")
+	}
+
+	// Then 100 rows per minute for five minutes.
+	for i := 1; i <= 10; i++ {
+		rate.add(start.Add(2*time.Second+time.Duration(i)*30*time.Second), 500+i*50)
+	}
+	perMinute, ok := rate.perMinute()
+	if !ok || perMinute < 99 || perMinute > 101 {
+		t.Errorf("perMinute() = %.1f, %t; expected 100 once the burst left the window", perMinute, ok)
+	}
+	if eta, ok := rate.eta(1500); !ok || eta != 15*time.Minute {
+		t.Errorf("eta(1500) = %v, %t; expected 15m", eta, ok)
+	}
+	if got := rate.status(1500); got != "100 rows/min, about 15m left" {
+		t.Errorf("status() = %q", got)
+	}
+
+	var stalled throughput
+	stalled.add(start, 10)
+	stalled.add(start.Add(time.Minute), 10)
+	if _, ok := stalled.eta(100); ok {
+		t.Error("eta given for a stalled file")
+	}
+	if got := stalled.status(100); got != "0 rows/min" {
+		t.Errorf("status() of a stalled file = %q", got)
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	testCases := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{20 * time.Second, "<1m"},
+		{90 * time.Second, "2m"},
+		{59 * time.Minute, "59m"},
+		{3*time.Hour + 4*time.Minute + 40*time.Second, "3h 5m"},
+	}
+	for _, tc := range testCases {
+		if got := formatETA(tc.d); got != tc.expected {
+			t.Errorf("formatETA(%v) = %q; expected %q", tc.d, got, tc.expected)
+		}
+	}
+}
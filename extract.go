@@ -0,0 +1,332 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// TERM EXTRACTION
+// ///////////////////
+
+// "glossary extract" bootstraps a glossary for a new project. It counts the
+// words and word groups of up to three words in the distinct source texts
+// and lists those in enough texts as candidate terms. With a target column
+// that already holds translations, it proposes the rendering each term
+// gets there: the translation of a text that is just the term, or else the
+// word group of the translations that occurs together with the term most
+// consistently (the Dice coefficient). The CSV is meant to be reviewed,
+// and can then be passed to --glossary as it is.
+
+// extractMaxWords is the longest word group that is a candidate term.
+const extractMaxWords = 3
+
+// stopWords are function words that do not start or end a term.
+var stopWords = func() map[string]map[string]bool {
+	lists := map[string]string{
+		"de": "der die das den dem des ein eine einer eines einem einen und oder nicht ist sind wird werden wurde zu zum zur im in am an auf aus bei mit von vom für nach über unter vor bis als auch noch kein keine bitte",
+		"en": "the a an and or not is are was be been to of in on at by for from with as into no please this that it",
+		"fr": "le la les un une des du de et ou ne pas est sont à au aux en pour par sur avec dans ce cette",
+		"es": "el la los las un una unos unas y o no es son de del al a en por para con sin que se",
+		"it": "il lo la i gli le un una uno e o non è sono di del della dei delle a al alla in per con su da che",
+	}
+	words := make(map[string]map[string]bool)
+	for lang, list := range lists {
+		words[lang] = make(map[string]bool)
+		for _, w := range strings.Fields(list) {
+			words[lang][w] = true
+		}
+	}
+	return words
+}()
+
+// extractedTerm is a candidate glossary entry.
+type extractedTerm struct {
+	source    string  // as first written in the texts
+	texts     int     // distinct source texts that contain it
+	target    string  // proposed translation; "" = none found
+	agreement float64 // how consistently target goes with source, 0-1
+}
+
+// termNgrams returns the lower-case word groups of text, keyed to the
+// spelling they first have, once each. A group only spans words separated
+// by spaces, not by numbers, placeholders or punctuation, and groups that
+// start or end with a stop word of lang are left out.
+func termNgrams(text, lang string) map[string]string {
+	stop := stopWords[headerLanguage(lang)]
+	text = textOutsidePlaceholders(text)
+	grams := make(map[string]string)
+	var words []string
+	end := 0
+	for _, loc := range wordRegex.FindAllStringIndex(text, -1) {
+		if strings.TrimSpace(text[end:loc[0]]) != "" {
+			addTermNgrams(grams, words, stop)
+			words = nil
+		}
+		words = append(words, text[loc[0]:loc[1]])
+		end = loc[1]
+	}
+	addTermNgrams(grams, words, stop)
+	return grams
+}
+
+// addTermNgrams adds the word groups of a run of words to grams.
+func addTermNgrams(grams map[string]string, words []string, stop map[string]bool) {
+	for i := range words {
+		for n := 1; n <= extractMaxWords && i+n <= len(words); n++ {
+			first, last := strings.ToLower(words[i]), strings.ToLower(words[i+n-1])
+			if stop[first] || stop[last] || (n == 1 && len([]rune(first)) < 3) {
+				continue
+			}
+			gram := strings.Join(words[i:i+n], " ")
+			if key := strings.ToLower(gram); grams[key] == "" {
+				grams[key] = gram
+			}
+		}
+	}
+}
+
+// extractTerms finds the terms in at least minTexts of the distinct source
+// texts, most frequent first. translations maps source texts to their
+// current translation and may be nil.
+func extractTerms(sources []string, translations map[string]string, sourceLang, targetLang string, minTexts int) []extractedTerm {
+	spelling := make(map[string]string)
+	inTexts := make(map[string][]int) // term -> indices of the texts it is in
+	for i, text := range sources {
+		for key, gram := range termNgrams(text, sourceLang) {
+			if _, ok := spelling[key]; !ok {
+				spelling[key] = gram
+			}
+			inTexts[key] = append(inTexts[key], i)
+		}
+	}
+
+	candidates := make(map[string]int)
+	for key, texts := range inTexts {
+		if len(texts) >= minTexts {
+			candidates[key] = len(texts)
+		}
+	}
+	var terms []extractedTerm
+	for key, count := range candidates {
+		if !subsumed(key, count, candidates) {
+			terms = append(terms, extractedTerm{source: spelling[key], texts: count})
+		}
+	}
+	slices.SortFunc(terms, func(a, b extractedTerm) int {
+		return cmp.Or(cmp.Compare(b.texts, a.texts), strings.Compare(strings.ToLower(a.source), strings.ToLower(b.source)))
+	})
+
+	if len(translations) > 0 {
+		proposer := newTermProposer(sources, translations, targetLang)
+		for i := range terms {
+			terms[i].target, terms[i].agreement = proposer.propose(terms[i].source, inTexts[strings.ToLower(terms[i].source)])
+		}
+	}
+	return terms
+}
+
+// subsumed reports whether term only occurs as part of a longer candidate,
+// e.g. "Schutztür" if every text with it says "Schutztür offen".
+func subsumed(term string, count int, candidates map[string]int) bool {
+	for other, n := range candidates {
+		if n == count && other != term && strings.Contains(" "+other+" ", " "+term+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// termProposer proposes translations of terms from the translated texts.
+type termProposer struct {
+	sources     []string
+	targets     []map[string]string // word groups of each text's translation; nil = untranslated
+	gramTexts   map[string]int      // translations each target word group is in
+	translated  []string
+	exactSource map[string]string // lower-case source text -> translation
+}
+
+func newTermProposer(sources []string, translations map[string]string, targetLang string) *termProposer {
+	p := &termProposer{
+		sources:     sources,
+		targets:     make([]map[string]string, len(sources)),
+		gramTexts:   make(map[string]int),
+		translated:  make([]string, len(sources)),
+		exactSource: make(map[string]string),
+	}
+	for i, source := range sources {
+		translation := strings.TrimSpace(translations[source])
+		if translation == "" {
+			continue
+		}
+		p.translated[i] = translation
+		p.targets[i] = termNgrams(translation, targetLang)
+		for key := range p.targets[i] {
+			p.gramTexts[key]++
+		}
+		p.exactSource[strings.ToLower(strings.TrimSpace(source))] = translation
+	}
+	return p
+}
+
+// propose returns the translation of term, which is in the texts with the
+// given indices, and how consistently it is used, or "" if none of the
+// translations agree well enough.
+func (p *termProposer) propose(term string, texts []int) (string, float64) {
+	if translation, ok := p.exactSource[strings.ToLower(term)]; ok {
+		return translation, 1
+	}
+	together := make(map[string]int)
+	withTerm := 0
+	for _, i := range texts {
+		if p.targets[i] == nil {
+			continue
+		}
+		withTerm++
+		for key := range p.targets[i] {
+			together[key]++
+		}
+	}
+	best, bestScore := "", 0.0
+	for key, n := range together {
+		score := 2 * float64(n) / float64(withTerm+p.gramTexts[key])
+		// On a tie the longer group wins, as "emergency stop" over "stop"
+		// when they always go together, then the alphabetically first, so
+		// the result does not depend on map order.
+		if score > bestScore || score == bestScore && (len(key) > len(best) || len(key) == len(best) && key < best) {
+			best, bestScore = key, score
+		}
+	}
+	if bestScore < 0.5 {
+		return "", bestScore
+	}
+	for _, i := range texts {
+		if gram, ok := p.targets[i][best]; ok {
+			return gram, bestScore
+		}
+	}
+	return best, bestScore
+}
+
+// writeExtractedTerms writes terms as a CSV glossary with a "source,target"
+// header; the extra columns are ignored when it is loaded.
+func writeExtractedTerms(path string, terms []extractedTerm) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not write glossary: %w", err)
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	w.Write([]string{"source", "target", "texts", "agreement"})
+	for _, term := range terms {
+		agreement := ""
+		if term.target != "" {
+			agreement = strconv.FormatFloat(term.agreement, 'f', 2, 64)
+		}
+		w.Write([]string{term.source, term.target, strconv.Itoa(term.texts), agreement})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("could not write glossary: %w", err)
+	}
+	return nil
+}
+
+// runGlossaryExtract implements "glossary extract".
+func runGlossaryExtract(args []string) error {
+	usage := "usage: glossary extract [--sheet NAME] --source-col COL [--target-col COL] [--min-texts N] [--top N] [--output FILE.csv] WORKBOOK"
+	fs := flag.NewFlagSet("glossary extract", flag.ExitOnError)
+	sheet := fs.String("sheet", "", "Sheet to scan (default: first sheet).")
+	sourceCol := fs.String("source-col", "", "Source language column, as a 1-based column number or header name.")
+	targetCol := fs.String("target-col", "", "Column with existing translations to propose renderings from (optional).")
+	minTexts := fs.Int("min-texts", 3, "Fewest distinct source texts a term must occur in.")
+	top := fs.Int("top", 200, "Most terms to list, most frequent first (0 = all).")
+	output := fs.String("output", "", "CSV file the candidates are written to (default: glossary-WORKBOOK.csv next to it).")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *sourceCol == "" || *minTexts < 1 || *top < 0 {
+		return errors.New(usage)
+	}
+	fileName := fs.Arg(0)
+
+	f, _, _, _, err := openInput(options{}, fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sheetName := f.GetSheetName(0)
+	if *sheet != "" {
+		sheetName = *sheet
+	}
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return fmt.Errorf("Error getting rows: %v", err)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("Sheet %q is empty", sheetName)
+	}
+	src, err := tiatrans.ResolveColumn(rows[0], *sourceCol)
+	if err != nil {
+		return fmt.Errorf("--source-col: %v", err)
+	}
+	tgt := -1
+	if *targetCol != "" {
+		if tgt, err = tiatrans.ResolveColumn(rows[0], *targetCol); err != nil {
+			return fmt.Errorf("--target-col: %v", err)
+		}
+	}
+
+	var sources []string
+	translations := make(map[string]string)
+	for _, row := range rows[1:] {
+		if src >= len(row) || strings.TrimSpace(row[src]) == "" {
+			continue
+		}
+		text := strings.TrimSpace(row[src])
+		if _, seen := translations[text]; !seen {
+			sources = append(sources, text)
+			translations[text] = ""
+		}
+		if tgt >= 0 && tgt < len(row) && translations[text] == "" {
+			translations[text] = strings.TrimSpace(row[tgt])
+		}
+	}
+	targetLang := ""
+	if tgt >= 0 {
+		targetLang = rows[0][tgt]
+	} else {
+		translations = nil
+	}
+
+	terms := extractTerms(sources, translations, rows[0][src], targetLang, *minTexts)
+	if *top > 0 && len(terms) > *top {
+		terms = terms[:*top]
+	}
+	path := *output
+	if path == "" {
+		path = outputBaseName(fileName, "glossary-") + ".csv"
+	}
+	if err := writeExtractedTerms(path, terms); err != nil {
+		return err
+	}
+	proposed := 0
+	for _, term := range terms {
+		if term.target != "" {
+			proposed++
+		}
+	}
+	fmt.Printf("%d candidate terms from %d texts written to %s", len(terms), len(sources), path)
+	if tgt >= 0 {
+		fmt.Printf(", %d with a proposed translation", proposed)
+	}
+	fmt.Println()
+	return nil
+}
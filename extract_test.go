@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExtractTerms(t *testing.T) {
+	sources := []string{
+		"Störung Pumpe 1",
+		"Störung Pumpe 2",
+		"Störung Ventil 3",
+		"Not-Halt betätigt",
+		"Not-Halt quittieren",
+		"Not-Halt an Schutztür offen",
+		"Schutztür offen",
+		"Schutztür offen @1%d@",
+		"Störung",
+	}
+	translations := map[string]string{
+		"Störung Pumpe 1":             "Fault pump 1",
+		"Störung Pumpe 2":             "Fault pump 2",
+		"Störung Ventil 3":            "Fault valve 3",
+		"Not-Halt betätigt":           "Emergency stop pressed",
+		"Not-Halt quittieren":         "Acknowledge emergency stop",
+		"Not-Halt an Schutztür offen": "Emergency stop at safety door open",
+		"Schutztür offen":             "Safety door open",
+		"Schutztür offen @1%d@":       "Safety door open @1%d@",
+		"Störung":                     "Fault",
+	}
+	terms := extractTerms(sources, translations, "de-DE", "en-US", 3)
+	got := make(map[string]extractedTerm)
+	var order []string
+	for _, term := range terms {
+		got[term.source] = term
+		order = append(order, term.source)
+	}
+	want := map[string]string{"Störung": "Fault", "Not-Halt": "Emergency stop", "Schutztür offen": "Safety door open"}
+	if len(got) != len(want) {
+		t.Fatalf("terms = %q, want %d of them", order, len(want))
+	}
+	for source, target := range want {
+		if got[source].target != target {
+			t.Errorf("%q -> %q, want %q", source, got[source].target, target)
+		}
+	}
+	if order[0] != "Störung" || got["Störung"].texts != 4 || got["Störung"].agreement != 1 {
+		t.Errorf("Störung = %+v first in %q, want it first in 4 texts with the exact translation", got["Störung"], order)
+	}
+
+	// Without translations only the terms are listed.
+	for _, term := range extractTerms(sources, nil, "de-DE", "", 3) {
+		if term.target != "" {
+			t.Errorf("%q -> %q without a target column", term.source, term.target)
+		}
+	}
+}
+
+func TestTermNgrams(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Druck der Pumpe", []string{"druck", "druck der pumpe", "pumpe"}},
+		{"Pumpe 1 Störung", []string{"pumpe", "störung"}},
+		{"Ventil @2%d@ zu", []string{"ventil"}},
+	}
+	for _, tt := range tests {
+		grams := termNgrams(tt.text, "de-DE")
+		if len(grams) != len(tt.want) {
+			t.Errorf("termNgrams(%q) = %v, want %q", tt.text, grams, tt.want)
+			continue
+		}
+		for _, key := range tt.want {
+			if _, ok := grams[key]; !ok {
+				t.Errorf("termNgrams(%q) = %v, missing %q", tt.text, grams, key)
+			}
+		}
+	}
+}
+
+func TestRunGlossaryExtract(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "export.xlsx")
+	f := excelize.NewFile()
+	rows := [][]string{{"de-DE", "en-US"}, {"Überlast Motor 1", "Overload motor 1"}, {"Überlast Motor 2", "Overload motor 2"}, {"Überlast Lüfter", "Overload fan"}}
+	for i, row := range rows {
+		f.SetSheetRow("Sheet1", "A"+string(rune('1'+i)), &row)
+	}
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "terms.csv")
+	if err := runGlossaryCommand([]string{"extract", "--source-col", "de-DE", "--target-col", "en-US", "--min-texts", "2", "--output", output, path}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Überlast,Overload,3,1.00") || !strings.Contains(string(data), "Überlast Motor,Overload motor,2,1.00") {
+		t.Errorf("extracted glossary:\n%s", data)
+	}
+	// The candidates load as a glossary as they are.
+	g, err := loadGlossary(output, "de-DE", "en-US")
+	if err != nil || len(g.terms) != 2 {
+		t.Errorf("loading the extracted glossary: %v, %d terms", err, len(g.terms))
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// FAILED ROWS
+// ///////////////////
+
+// Rows that fail are held back until the rest of their sheet is done and
+// then tried once more, one at a time; a rate limit or a short outage has
+// usually passed by then. What still fails is listed on an errors sheet in
+// the output workbook, so it does not scroll out of the log unnoticed.
+
+// errorsSheet is the sheet the rows that failed are listed on.
+const errorsSheet = "Errors"
+
+// failedRow is a row that still failed after the retry pass.
+type failedRow struct {
+	sheet      string
+	row        int // 1-based sheet row
+	targetLang string
+	source     string
+	err        string
+}
+
+// failureList collects the failed rows of a file. Like the checkpoint it is
+// only appended to from the goroutine that writes the workbook. A nil
+// *failureList collects nothing.
+type failureList struct {
+	rows []failedRow
+}
+
+func (l *failureList) add(job *translationJob, rj *rowJob, note string) {
+	if l == nil {
+		return
+	}
+	l.rows = append(l.rows, failedRow{sheet: job.sheetName, row: rj.index + 1, targetLang: job.targetLang, source: rj.source, err: note})
+}
+
+func (l *failureList) empty() bool {
+	return l == nil || len(l.rows) == 0
+}
+
+// errorsHeader heads the errors sheet; validate recognises the sheet by it.
+var errorsHeader = []string{"Sheet", "Row", "Target", "Source", "Error"}
+
+// writeSheet lists the failed rows on a new sheet of f and returns its name:
+// errorsSheet, or "Errors 2" and so on if the workbook already has one.
+func (l *failureList) writeSheet(f *excelize.File) (string, error) {
+	name := freeSheetName(f, errorsSheet)
+	if _, err := f.NewSheet(name); err != nil {
+		return "", err
+	}
+	header := make([]any, len(errorsHeader))
+	for i, h := range errorsHeader {
+		header[i] = h
+	}
+	if err := f.SetSheetRow(name, "A1", &header); err != nil {
+		return "", err
+	}
+	for i, r := range l.rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		values := []any{r.sheet, strconv.Itoa(r.row), r.targetLang, r.source, r.err}
+		if err := f.SetSheetRow(name, cell, &values); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// freeSheetName returns name, or "name 2" and so on if f already has a
+// sheet of that name.
+func freeSheetName(f *excelize.File, name string) string {
+	free := name
+	for i := 2; ; i++ {
+		if idx, _ := f.GetSheetIndex(free); idx == -1 {
+			return free
+		}
+		free = fmt.Sprintf("%s %d", name, i)
+	}
+}
+
+// isErrorsSheet reports whether a sheet with the given rows is an errors
+// sheet written by writeSheet.
+func isErrorsSheet(rows [][]string) bool {
+	return len(rows) > 0 && slices.Equal(rows[0], errorsHeader)
+}
+
+// reset clears what the last attempt left on rj. Tokens, latency and
+// retries add up over the attempts.
+func (rj *rowJob) reset() {
+	rj.status = ""
+	rj.result = ""
+	rj.write = false
+	rj.err = nil
+	rj.translated = 0
+	rj.reused = 0
+	rj.errors = 0
+	rj.flags = nil
+	rj.doubtful = false
+}
+
+// retryFailed gives every row in failed one more attempt, in row order so a
+// family's first member is done before the members reusing it.
+func retryFailed(ctx context.Context, p msgSender, translator Translator, job *translationJob, failed []*rowJob) {
+	p.Send(logMsg(fmt.Sprintf("Retrying %d failed rows of sheet %s", len(failed), job.sheetName)))
+	recovered := 0
+	for _, rj := range failed {
+		if ctx.Err() != nil {
+			return
+		}
+		rj.reset()
+		rj.retries++
+		runRow(ctx, p, translator, job, rj)
+		if rj.status != rowFailed {
+			recovered++
+		}
+	}
+	p.Send(logMsg(fmt.Sprintf("Retry pass: %d of %d failed rows recovered", recovered, len(failed))))
+}
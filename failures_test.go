@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// flakyTranslator fails texts containing "Flaky" on their first request and
+// texts containing "Broken" every time.
+type flakyTranslator struct {
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+func (f *flakyTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	f.mu.Lock()
+	f.seen[text]++
+	n := f.seen[text]
+	f.mu.Unlock()
+	if strings.Contains(text, "Broken") || strings.Contains(text, "Flaky") && n == 1 {
+		return "", errors.New("connection reset")
+	}
+	return strings.ToUpper(text), nil
+}
+
+func TestRetryPass(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Flaky pump", ""},
+		{"Broken valve", "old"},
+		{"Motor fault", ""},
+		{"Flaky pump", ""},
+	}
+	job := newTestJob(t, rows)
+	job.report = &fileReport{}
+	job.failures = &failureList{}
+	translator := &flakyTranslator{seen: make(map[string]int)}
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	expected := map[string]string{
+		"B2": "FLAKY PUMP",
+		"B3": "old",
+		"B4": "MOTOR FAULT",
+		"B5": "FLAKY PUMP",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if n := translator.seen["Broken valve"]; n != 2 {
+		t.Errorf("failing text sent %d times; expected 2", n)
+	}
+	if len(job.failures.rows) != 1 || job.failures.rows[0].row != 3 || job.failures.rows[0].err != "connection reset" {
+		t.Fatalf("failures = %+v; expected row 3 only", job.failures.rows)
+	}
+	for _, entry := range job.report.sorted() {
+		if entry.row != 3 && entry.status == rowFailed {
+			t.Errorf("row %d reported as failed after a successful retry", entry.row)
+		}
+	}
+
+	name, err := job.failures.writeSheet(job.f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := job.f.GetRows(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isErrorsSheet(sheet) || len(sheet) != 2 || strings.Join(sheet[1], "|") != job.sheetName+"|3|en-US|Broken valve|connection reset" {
+		t.Errorf("errors sheet = %q", sheet)
+	}
+	problems, err := validateWorkbook(job.f, nil, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], name+": lists 1 rows") {
+		t.Errorf("validate found %q; expected the errors sheet", problems)
+	}
+
+	// A second errors sheet does not replace the first.
+	if again, _ := job.failures.writeSheet(job.f); again != errorsSheet+" 2" {
+		t.Errorf("second errors sheet named %q", again)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// UNTRANSLATED ROWS
+// ///////////////////
+
+// With --untranslated copy, a row that ends up without a translation, because
+// it failed, the model refused it or a rule skipped it, gets its source text
+// in the target cell. TIA Portal then shows the source on the HMI instead of
+// an empty string. In XLSX output the copies get a red fill, so nobody takes
+// them for translations.
+
+// The --untranslated policies.
+const (
+	untranslatedLeave = "leave" // leave the target cell as it is
+	untranslatedCopy  = "copy"  // copy the source text into an empty target cell
+)
+
+// fallbackColor is the fill of cells holding a copy of their source: a light
+// red that stands out from the yellow of --highlight and the orange of
+// doubtful cells.
+const fallbackColor = "F4CCCC"
+
+// fallbackCell is a target cell the source text was copied into.
+type fallbackCell struct {
+	sheet string
+	row   int // 1-based sheet row
+	col   int // 1-based target column
+	value string
+}
+
+// fallbackList collects the cells of a file that got their source text. Like
+// the failure list it is only appended to from the goroutine that writes the
+// workbook. A nil *fallbackList copies nothing.
+type fallbackList struct {
+	cells []fallbackCell
+}
+
+// copySource writes the source text of a row into its target cell, if the
+// run copies untranslated rows and the cell holds no translation. It reports
+// whether it did.
+func (j *translationJob) copySource(rowIndex int) bool {
+	if j.fallback == nil || j.planOnly || tiatrans.HasTranslation(j.cellValue(rowIndex)) {
+		return false
+	}
+	value := j.rows[rowIndex][j.sourceIndex]
+	if strings.TrimSpace(value) == "" {
+		return false
+	}
+	j.setCell(rowIndex, value)
+	j.fallback.cells = append(j.fallback.cells, fallbackCell{sheet: j.sheetName, row: rowIndex + 1, col: j.targetIndex + 1, value: value})
+	return true
+}
+
+// withCopied adds to the detail of a report entry that the source was copied.
+func withCopied(detail string, copied bool) string {
+	switch {
+	case !copied:
+		return detail
+	case detail == "":
+		return "source copied"
+	}
+	return detail + "; source copied"
+}
+
+// mark fills the cells that still hold the copy of their source with
+// fallbackColor. Like markDoubtful it runs just before saving.
+func (l *fallbackList) mark(f *excelize.File) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	styles := make(map[int]int)
+	n := 0
+	for _, c := range l.cells {
+		cell, _ := excelize.CoordinatesToCellName(c.col, c.row)
+		value, err := f.GetCellValue(c.sheet, cell)
+		if err != nil {
+			return n, err
+		}
+		if value != c.value {
+			continue
+		}
+		if err := fillCell(f, c.sheet, cell, fallbackColor, styles); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
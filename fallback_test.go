@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUntranslatedCopy(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Broken valve", ""},
+		{"Broken pump", "old"},
+		{"----------", ""},
+		{"Motor fault", ""},
+	}
+	job := newTestJob(t, rows)
+	job.report = &fileReport{}
+	job.failures = &failureList{}
+	job.fallback = &fallbackList{}
+	iterateAndTranslate(context.Background(), discardSender{}, &flakyTranslator{seen: make(map[string]int)}, job)
+
+	expected := map[string]string{
+		"B2": "Broken valve",
+		"B3": "old",
+		"B4": "----------",
+		"B5": "MOTOR FAULT",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if len(job.failures.rows) != 2 || job.failures.rows[0].err != "connection reset" {
+		t.Errorf("failures = %+v; expected rows 2 and 3 with the error only", job.failures.rows)
+	}
+	details := make(map[int]string)
+	for _, entry := range job.report.sorted() {
+		details[entry.row] = entry.detail
+	}
+	if details[2] != "connection reset; source copied" || details[3] != "connection reset" {
+		t.Errorf("details of the failed rows = %q, %q", details[2], details[3])
+	}
+	if details[4] != "separator -_=*. 80%; source copied" {
+		t.Errorf("detail of the separator = %q", details[4])
+	}
+
+	// A reviewer replaced the copy in row 4 before saving, so only row 2 is
+	// marked.
+	job.f.SetCellValue(job.sheetName, "B4", "———")
+	n, err := job.fallback.mark(job.f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("marked %d cells; expected 1", n)
+	}
+	for cell, want := range map[string]bool{"B2": true, "B3": false, "B4": false, "B5": false} {
+		styleID, err := job.f.GetCellStyle(job.sheetName, cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		style, err := job.f.GetStyle(styleID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filled := len(style.Fill.Color) == 1 && style.Fill.Color[0] == fallbackColor
+		if filled != want {
+			t.Errorf("%s filled = %t; expected %t", cell, filled, want)
+		}
+	}
+}
+
+func TestUntranslatedLeave(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Broken valve", ""},
+		{"----------", ""},
+	}
+	job := newTestJob(t, rows)
+	iterateAndTranslate(context.Background(), discardSender{}, &flakyTranslator{seen: make(map[string]int)}, job)
+	for _, cell := range []string{"B2", "B3"} {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != "" {
+			t.Errorf("%s = %q; expected it left empty", cell, got)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ///////////////////
+// ROW FILTERS
+// ///////////////////
+
+// rowRange is an inclusive range of 1-based sheet rows; 0 leaves a side open.
+type rowRange struct {
+	from, to int
+}
+
+// rowFilter limits a run to part of a sheet (--rows, --match, --exclude).
+// A nil filter lets every row through.
+type rowFilter struct {
+	ranges  []rowRange
+	match   *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// parseRowRanges parses a list like "100-500", "2-10,40-" or "7".
+func parseRowRanges(spec string) ([]rowRange, error) {
+	var ranges []rowRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var r rowRange
+		from, to, isRange := strings.Cut(part, "-")
+		var err error
+		if from = strings.TrimSpace(from); from != "" {
+			if r.from, err = strconv.Atoi(from); err != nil || r.from < 1 {
+				return nil, fmt.Errorf("invalid row %q", from)
+			}
+		}
+		if !isRange {
+			r.to = r.from
+		} else if to = strings.TrimSpace(to); to != "" {
+			if r.to, err = strconv.Atoi(to); err != nil || r.to < 1 {
+				return nil, fmt.Errorf("invalid row %q", to)
+			}
+		}
+		if r.from == 0 && r.to == 0 {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+		if r.to != 0 && r.from > r.to {
+			return nil, fmt.Errorf("range %q ends before it starts", part)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// newRowFilter builds a filter from the command line values. It returns nil
+// when none of them is set.
+func newRowFilter(rows, match, exclude string) (*rowFilter, error) {
+	if rows == "" && match == "" && exclude == "" {
+		return nil, nil
+	}
+	filter := &rowFilter{}
+	var err error
+	if filter.ranges, err = parseRowRanges(rows); err != nil {
+		return nil, fmt.Errorf("--rows: %v", err)
+	}
+	if match != "" {
+		if filter.match, err = regexp.Compile(match); err != nil {
+			return nil, fmt.Errorf("--match: %v", err)
+		}
+	}
+	if exclude != "" {
+		if filter.exclude, err = regexp.Compile(exclude); err != nil {
+			return nil, fmt.Errorf("--exclude: %v", err)
+		}
+	}
+	return filter, nil
+}
+
+// allows reports whether a row (1-based) with the given source text is part
+// of the run.
+func (f *rowFilter) allows(row int, source string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.ranges) > 0 {
+		in := false
+		for _, r := range f.ranges {
+			if row >= r.from && (r.to == 0 || row <= r.to) {
+				in = true
+				break
+			}
+		}
+		if !in {
+			return false
+		}
+	}
+	if f.match != nil && !f.match.MatchString(source) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(source) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRowRanges(t *testing.T) {
+	testCases := []struct {
+		spec     string
+		expected []rowRange
+		wantErr  bool
+	}{
+		{"100-500", []rowRange{{100, 500}}, false},
+		{"7", []rowRange{{7, 7}}, false},
+		{"2-10, 40-", []rowRange{{2, 10}, {40, 0}}, false},
+		{"-20", []rowRange{{0, 20}}, false},
+		{"", nil, false},
+		{"500-100", nil, true},
+		{"a-b", nil, true},
+		{"-", nil, true},
+		{"0-5", nil, true},
+	}
+	for _, tc := range testCases {
+		got, err := parseRowRanges(tc.spec)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseRowRanges(%q) error = %v; wantErr %t", tc.spec, err, tc.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("parseRowRanges(%q) = %v; expected %v", tc.spec, got, tc.expected)
+		}
+	}
+}
+
+func TestRowFilterAllows(t *testing.T) {
+	filter, err := newRowFilter("10-20,50-", `^Alarm`, `(?i)spare`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		row      int
+		source   string
+		expected bool
+	}{
+		{10, "Alarm motor", true},
+		{20, "Alarm pump", true},
+		{21, "Alarm pump", false},
+		{60, "Alarm valve", true},
+		{15, "Warning motor", false},
+		{15, "Alarm SPARE 3", false},
+	}
+	for _, tc := range testCases {
+		if got := filter.allows(tc.row, tc.source); got != tc.expected {
+			t.Errorf("allows(%d, %q) = %t; expected %t", tc.row, tc.source, got, tc.expected)
+		}
+	}
+
+	var none *rowFilter
+	if !none.allows(1, "anything") {
+		t.Errorf("nil filter should allow every row")
+	}
+	if _, err := newRowFilter("", "(", ""); err == nil {
+		t.Errorf("newRowFilter should reject an invalid regular expression")
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ///////////////////
+// FUZZY MEMORY
+// ///////////////////
+
+// Most alarm texts differ from one already in the translation memory only in
+// their tag numbers. Such texts reuse the stored translation with the numbers
+// swapped. Other texts that come close to a stored one, as measured by the
+// cosine similarity of their embeddings, are translated by the model with the
+// stored pair as a reference, so the wording stays consistent.
+
+// embedder turns texts into unit vectors whose dot product tells how alike
+// the texts are.
+type embedder interface {
+	// name keys the vectors cached in the translation memory.
+	name() string
+	embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// embedChunk is the most texts sent in one embeddings request.
+const embedChunk = 100
+
+// ngramEmbedder embeds texts locally by hashing their character trigrams.
+// It knows nothing of meaning, but needs no API and catches what matters
+// most here: texts that share most of their characters.
+type ngramEmbedder struct{}
+
+const ngramDims = 512
+
+func (ngramEmbedder) name() string { return "local-trigram" }
+
+func (ngramEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		// Numbers count as the same digit, they are what differs most.
+		runes := []rune(" " + strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return '0'
+			}
+			return unicode.ToLower(r)
+		}, text) + " ")
+		v := make([]float32, ngramDims)
+		for j := 0; j+3 <= len(runes); j++ {
+			h := fnv.New32a()
+			h.Write([]byte(string(runes[j : j+3])))
+			v[h.Sum32()%ngramDims]++
+		}
+		vectors[i] = normalize(v)
+	}
+	return vectors, nil
+}
+
+// apiEmbedder uses the embeddings endpoint of OpenAI or a server that
+// mimics it, such as Ollama.
+type apiEmbedder struct {
+	client *openai.Client
+	model  string
+}
+
+// Default embedding models per provider.
+const (
+	defaultOpenAIEmbeddingModel = string(openai.SmallEmbedding3)
+	defaultOllamaEmbeddingModel = "nomic-embed-text"
+)
+
+// newAPIEmbedder builds an embedder for the openai or ollama provider.
+func newAPIEmbedder(provider, apiKey, baseURL, model string, client *http.Client) (*apiEmbedder, error) {
+	var defaultModel string
+	switch provider {
+	case "openai":
+		defaultModel = defaultOpenAIEmbeddingModel
+	case "ollama":
+		defaultModel = defaultOllamaEmbeddingModel
+		if baseURL == "" {
+			baseURL = defaultOllamaURL
+		}
+	default:
+		return nil, fmt.Errorf("--embeddings api needs the openai or ollama provider, use --embeddings local with %s", provider)
+	}
+	config := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		config.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	if client != nil {
+		config.HTTPClient = client
+	}
+	if model == "" {
+		model = defaultModel
+	}
+	return &apiEmbedder{client: openai.NewClientWithConfig(config), model: model}, nil
+}
+
+func (e *apiEmbedder) name() string { return "api/" + e.model }
+
+func (e *apiEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{Input: texts, Model: openai.EmbeddingModel(e.model)})
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings: got %d vectors for %d texts", len(resp.Data), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embeddings: vector index %d out of range", d.Index)
+		}
+		vectors[d.Index] = normalize(d.Embedding)
+	}
+	return vectors, nil
+}
+
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+func similarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+var numberRegex = regexp.MustCompile(`\d+`)
+
+// numberShape is text with every number blanked out.
+func numberShape(text string) string {
+	return numberRegex.ReplaceAllString(text, "\x00")
+}
+
+// renumber carries the numbers of text over into the translation of a stored
+// source that differs from text only in its numbers. ok is false if the
+// translation does not hold exactly the stored source's numbers, e.g.
+// because the model spelled one out.
+func renumber(text string, stored tmEntry) (string, bool) {
+	from := numberRegex.FindAllString(stored.source, -1)
+	to := numberRegex.FindAllString(text, -1)
+	if len(from) == 0 || len(from) != len(to) {
+		return "", false
+	}
+	mapping := make(map[string]string)
+	for i, n := range from {
+		if m, ok := mapping[n]; ok && m != to[i] {
+			return "", false // the same number becomes two different ones
+		}
+		mapping[n] = to[i]
+	}
+	found := numberRegex.FindAllString(stored.translation, -1)
+	if len(found) != len(from) {
+		return "", false
+	}
+	for _, n := range found {
+		if _, ok := mapping[n]; !ok {
+			return "", false
+		}
+	}
+	return numberRegex.ReplaceAllStringFunc(stored.translation, func(n string) string { return mapping[n] }), true
+}
+
+// fuzzyIndex holds the memory entries of one language pair.
+type fuzzyIndex struct {
+	entries []tmEntry
+	byShape map[string][]int // numberShape of a source -> entries
+	vectors [][]float32      // embeddings of the sources; nil until needed
+	err     error            // embedding the entries failed; similarity is off
+	stale   bool             // the memory was written to since the index was loaded
+}
+
+// fuzzyMemory finds close matches in the translation memory. Entries stored
+// while a job runs are indexed when the next job is prepared, so that what a
+// row matches does not depend on which rows finished first; exact lookups
+// find them right away. Once embedded, an index is not changed but
+// replaced, so it can be searched without the lock. A nil *fuzzyMemory
+// finds nothing.
+type fuzzyMemory struct {
+	tm        *translationMemory
+	embedder  embedder
+	threshold float64 // least similarity for a reference
+
+	mu      sync.Mutex
+	indexes map[string]*fuzzyIndex // "sourceLang\x00targetLang" -> index
+	vectors map[string][]float32   // texts embedded in this run
+}
+
+func newFuzzyMemory(tm *translationMemory, e embedder, threshold float64) *fuzzyMemory {
+	if tm == nil || threshold <= 0 {
+		return nil
+	}
+	return &fuzzyMemory{tm: tm, embedder: e, threshold: threshold, indexes: make(map[string]*fuzzyIndex), vectors: make(map[string][]float32)}
+}
+
+// fuzzyKey keys the index of a language pair.
+func fuzzyKey(sourceLang, targetLang string) string {
+	return normalizeLang(sourceLang) + "\x00" + normalizeLang(targetLang)
+}
+
+// index loads the entries of a language pair the first time it is needed,
+// and again with reload once the memory was written to. The caller holds
+// fm.mu.
+func (fm *fuzzyMemory) index(sourceLang, targetLang string, reload bool) (*fuzzyIndex, error) {
+	key := fuzzyKey(sourceLang, targetLang)
+	if idx, ok := fm.indexes[key]; ok && !(reload && idx.stale) {
+		return idx, nil
+	}
+	entries, err := fm.tm.entries(sourceLang, targetLang)
+	if err != nil {
+		return nil, err
+	}
+	idx := &fuzzyIndex{entries: entries, byShape: make(map[string][]int)}
+	for i, e := range entries {
+		shape := numberShape(e.source)
+		idx.byShape[shape] = append(idx.byShape[shape], i)
+	}
+	fm.indexes[key] = idx
+	return idx, nil
+}
+
+// embedEntries embeds the sources of idx, reusing vectors cached in the
+// translation memory or embedded earlier in the run and caching new ones.
+// The caller holds fm.mu.
+func (fm *fuzzyMemory) embedEntries(ctx context.Context, p msgSender, idx *fuzzyIndex) {
+	if idx.vectors != nil || idx.err != nil {
+		return
+	}
+	cached, err := fm.tm.loadEmbeddings(fm.embedder.name())
+	if err != nil {
+		idx.err = err
+		p.Send(logMsg(fmt.Sprintf("Fuzzy memory: %v", err)))
+		return
+	}
+	var missing, known []string
+	var knownVectors [][]float32
+	for _, e := range idx.entries {
+		if _, ok := cached[e.source]; ok {
+			continue
+		}
+		if v := fm.vectors[e.source]; v != nil {
+			cached[e.source] = v
+			known, knownVectors = append(known, e.source), append(knownVectors, v)
+			continue
+		}
+		missing = append(missing, e.source)
+	}
+	if len(known) > 0 {
+		if err := fm.tm.storeEmbeddings(fm.embedder.name(), known, knownVectors); err != nil {
+			p.Send(logMsg(fmt.Sprintf("Fuzzy memory: could not cache embeddings: %v", err)))
+		}
+	}
+	if len(missing) > 0 {
+		p.Send(logMsg(fmt.Sprintf("Fuzzy memory: embedding %d translation memory entries", len(missing))))
+		fresh, err := embedAll(ctx, fm.embedder, missing)
+		if err != nil {
+			idx.err = err
+			p.Send(logMsg(fmt.Sprintf("Fuzzy memory: %v; only number variants are matched", err)))
+			return
+		}
+		for i, text := range missing {
+			cached[text] = fresh[i]
+		}
+		if err := fm.tm.storeEmbeddings(fm.embedder.name(), missing, fresh); err != nil {
+			p.Send(logMsg(fmt.Sprintf("Fuzzy memory: could not cache embeddings: %v", err)))
+		}
+	}
+	idx.vectors = make([][]float32, len(idx.entries))
+	for i, e := range idx.entries {
+		idx.vectors[i] = cached[e.source]
+	}
+}
+
+func embedAll(ctx context.Context, e embedder, texts []string) ([][]float32, error) {
+	var vectors [][]float32
+	for start := 0; start < len(texts); start += embedChunk {
+		chunk, err := e.embed(ctx, texts[start:min(start+embedChunk, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, chunk...)
+	}
+	return vectors, nil
+}
+
+// prepare embeds the memory of a language pair and the texts of a job up
+// front, in as few requests as possible. Entries stored since the index was
+// loaded are added to it here.
+func (fm *fuzzyMemory) prepare(ctx context.Context, p msgSender, sourceLang, targetLang string, texts []string) {
+	if fm == nil {
+		return
+	}
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	idx, err := fm.index(sourceLang, targetLang, true)
+	if err != nil {
+		p.Send(logMsg(fmt.Sprintf("Fuzzy memory: %v", err)))
+		return
+	}
+	if len(idx.entries) == 0 {
+		return
+	}
+	fm.embedEntries(ctx, p, idx)
+	var missing []string
+	for _, text := range texts {
+		if _, ok := fm.vectors[text]; !ok {
+			missing = append(missing, text)
+			fm.vectors[text] = nil // also dedupes
+		}
+	}
+	vectors, err := embedAll(ctx, fm.embedder, missing)
+	if err != nil {
+		p.Send(logMsg(fmt.Sprintf("Fuzzy memory: %v", err)))
+		for _, text := range missing {
+			delete(fm.vectors, text)
+		}
+		return
+	}
+	for i, text := range missing {
+		fm.vectors[text] = vectors[i]
+	}
+}
+
+// renumbered returns the stored translation of a text that differs from
+// text only in its numbers, with text's numbers, and the stored source.
+func (fm *fuzzyMemory) renumbered(text, sourceLang, targetLang string) (translation, source string, ok bool) {
+	if fm == nil {
+		return "", "", false
+	}
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	idx, err := fm.index(sourceLang, targetLang, false)
+	if err != nil {
+		return "", "", false
+	}
+	for _, i := range idx.byShape[numberShape(text)] {
+		if translation, ok := renumber(text, idx.entries[i]); ok {
+			return translation, idx.entries[i].source, true
+		}
+	}
+	return "", "", false
+}
+
+// reference returns the stored entry most similar to text, if it reaches
+// the threshold. Texts prepare did not embed are embedded on the spot,
+// without the lock, so workers do not wait on each other's requests.
+func (fm *fuzzyMemory) reference(ctx context.Context, text, sourceLang, targetLang string) (tmEntry, bool) {
+	if fm == nil {
+		return tmEntry{}, false
+	}
+	fm.mu.Lock()
+	var entries []tmEntry
+	var stored [][]float32
+	if idx, err := fm.index(sourceLang, targetLang, false); err == nil {
+		entries, stored = idx.entries, idx.vectors
+	}
+	v, ok := fm.vectors[text]
+	fm.mu.Unlock()
+	if stored == nil {
+		return tmEntry{}, false
+	}
+	if !ok {
+		vectors, err := fm.embedder.embed(ctx, []string{text})
+		if err != nil {
+			return tmEntry{}, false
+		}
+		v = vectors[0]
+		fm.mu.Lock()
+		fm.vectors[text] = v
+		fm.mu.Unlock()
+	}
+	if v == nil {
+		return tmEntry{}, false
+	}
+	best, bestScore := -1, fm.threshold
+	for i, ev := range stored {
+		if score := similarity(v, ev); score >= bestScore && entries[i].source != text {
+			best, bestScore = i, score
+		}
+	}
+	if best == -1 {
+		return tmEntry{}, false
+	}
+	return entries[best], true
+}
+
+// stored marks the index of a language pair for a reload, after a
+// translation was stored in the memory.
+func (fm *fuzzyMemory) stored(sourceLang, targetLang string) {
+	if fm == nil {
+		return
+	}
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if idx, ok := fm.indexes[fuzzyKey(sourceLang, targetLang)]; ok {
+		idx.stale = true
+	}
+}
+
+// referenceInstructions shows the model similar texts translated before.
+func referenceInstructions(refs []tmEntry) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(refs))
+	for i, r := range refs {
+		pairs[i] = fmt.Sprintf("%q -> %q", r.source, r.translation)
+	}
+	return fmt.Sprintf(" Similar texts were translated like this before; keep the wording consistent with them: %s.", strings.Join(pairs, "; "))
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRenumber(t *testing.T) {
+	testCases := []struct {
+		text     string
+		stored   tmEntry
+		expected string
+		ok       bool
+	}{
+		{"Motor 12 overload", tmEntry{source: "Motor 7 overload", translation: "Motor 7 Überlast"}, "Motor 12 Überlast", true},
+		{"M12 to M13", tmEntry{source: "M1 to M2", translation: "M2 von M1"}, "M13 von M12", true},
+		{"Tank 3 level 3", tmEntry{source: "Tank 1 level 1", translation: "Behälter 1 Füllstand 1"}, "Behälter 3 Füllstand 3", true},
+		{"Tank 3 level 4", tmEntry{source: "Tank 1 level 1", translation: "Behälter 1 Füllstand 1"}, "", false},
+		{"Motor 12 overload", tmEntry{source: "Motor 7 overload", translation: "Siebter Motor überlastet"}, "", false},
+		{"Motor overload", tmEntry{source: "Motor overload", translation: "Motorüberlast"}, "", false},
+	}
+
+	for _, tc := range testCases {
+		result, ok := renumber(tc.text, tc.stored)
+		if ok != tc.ok || result != tc.expected {
+			t.Errorf("renumber(%q, %q) = %q, %t; expected %q, %t", tc.text, tc.stored.source, result, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+func TestNgramSimilarity(t *testing.T) {
+	vectors, err := ngramEmbedder{}.embed(context.Background(), []string{
+		"Motor 12 overload in conveyor section",
+		"Motor 13 overload in conveyor section B",
+		"Pump running",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := similarity(vectors[0], vectors[1]); s < 0.85 {
+		t.Errorf("similar texts score %.2f; expected at least 0.85", s)
+	}
+	if s := similarity(vectors[0], vectors[2]); s > 0.3 {
+		t.Errorf("unrelated texts score %.2f; expected at most 0.3", s)
+	}
+}
+
+// referenceTranslator records the references sent along with each text.
+type referenceTranslator struct {
+	upperTranslator
+	mu         sync.Mutex
+	references map[string][]tmEntry
+}
+
+func (r *referenceTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	r.mu.Lock()
+	r.references[text] = promptHintsFrom(ctx).references
+	r.mu.Unlock()
+	return r.upperTranslator.Translate(ctx, text, sourceLang, targetLang)
+}
+
+func TestFuzzyMemory(t *testing.T) {
+	tm, err := openTM(filepath.Join(t.TempDir(), "tm.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tm.Close()
+	for source, translation := range map[string]string{
+		"Motor 7 overload": "Motor 7 Überlast",
+		"Conveyor belt section A stopped by operator": "Förderband Abschnitt A vom Bediener gestoppt",
+	} {
+		if err := tm.store(source, "en-US", "de-DE", translation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows := [][]string{
+		{"en-US", "de-DE"},
+		{"Motor 12 overload", ""},
+		{"Conveyor belt section B stopped by operator", ""},
+		{"Pump running", ""},
+	}
+	job := newTestJob(t, rows)
+	job.sourceLang, job.targetLang = "en-US", "de-DE"
+	job.tm = tm
+	job.fuzzy = newFuzzyMemory(tm, ngramEmbedder{}, 0.8)
+	translator := &referenceTranslator{references: make(map[string][]tmEntry)}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != "Motor 12 Überlast" {
+		t.Errorf("B2 = %q; expected the stored translation with the new number", got)
+	}
+	refs := translator.references["Conveyor belt section B stopped by operator"]
+	if len(refs) != 1 || !strings.HasPrefix(refs[0].translation, "Förderband") {
+		t.Errorf("references = %v; expected the stored conveyor text", refs)
+	}
+	if refs := translator.references["Pump running"]; len(refs) != 0 {
+		t.Errorf("references for an unrelated text = %v; expected none", refs)
+	}
+	if len(translator.calls) != 2 {
+		t.Errorf("translator called %d times (%v); expected 2", len(translator.calls), translator.calls)
+	}
+
+	cached, err := tm.loadEmbeddings(ngramEmbedder{}.name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 2 || len(cached["Motor 7 overload"]) != ngramDims {
+		t.Errorf("%d embeddings cached; expected the 2 memory entries", len(cached))
+	}
+}
+
+func TestFuzzyMemoryStoredEntries(t *testing.T) {
+	tm, err := openTM(filepath.Join(t.TempDir(), "tm.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tm.Close()
+	if err := tm.store("Pump running", "en-US", "de-DE", "Pumpe läuft"); err != nil {
+		t.Fatal(err)
+	}
+	job := newTestJob(t, nil)
+	job.sourceLang, job.targetLang = "en-US", "de-DE"
+	job.tm = tm
+	job.fuzzy = newFuzzyMemory(tm, ngramEmbedder{}, 0.8)
+	ctx := context.Background()
+	job.fuzzy.prepare(ctx, discardSender{}, "en-US", "de-DE", nil)
+
+	job.memoryStore(discardSender{}, "Motor 7 overload", "Motor 7 Überlast")
+	if _, _, ok := job.fuzzy.renumbered("Motor 12 overload", "en-US", "de-DE"); ok {
+		t.Error("an entry stored while the job runs was matched before the next job")
+	}
+	job.fuzzy.prepare(ctx, discardSender{}, "en-US", "de-DE", []string{"Motor 12 overload"})
+	if got, _, ok := job.fuzzy.renumbered("Motor 12 overload", "en-US", "de-DE"); got != "Motor 12 Überlast" || !ok {
+		t.Errorf("renumbered = %q, %t; expected the stored entry with the new number", got, ok)
+	}
+	if ref, ok := job.fuzzy.reference(ctx, "Motor 12 overload", "en-US", "de-DE"); !ok || ref.source != "Motor 7 overload" {
+		t.Errorf("reference = %v, %t; expected the stored entry", ref, ok)
+	}
+}
+
+// blockingEmbedder holds up embedding "slow" texts until release is closed.
+type blockingEmbedder struct {
+	ngramEmbedder
+	started, release chan struct{}
+}
+
+func (b blockingEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if strings.HasPrefix(texts[0], "slow") {
+		close(b.started)
+		<-b.release
+	}
+	return b.ngramEmbedder.embed(ctx, texts)
+}
+
+func TestFuzzyReferenceEmbedsWithoutLock(t *testing.T) {
+	tm, err := openTM(filepath.Join(t.TempDir(), "tm.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tm.Close()
+	if err := tm.store("Motor 7 overload in conveyor section", "en-US", "de-DE", "Motor 7 Überlast im Förderabschnitt"); err != nil {
+		t.Fatal(err)
+	}
+	e := blockingEmbedder{started: make(chan struct{}), release: make(chan struct{})}
+	fm := newFuzzyMemory(tm, e, 0.8)
+	ctx := context.Background()
+	fm.prepare(ctx, discardSender{}, "en-US", "de-DE", []string{"Motor 12 overload in conveyor section"})
+
+	slow := make(chan struct{})
+	go func() {
+		defer close(slow)
+		fm.reference(ctx, "slow text", "en-US", "de-DE")
+	}()
+	<-e.started
+	found := make(chan bool)
+	go func() {
+		_, ok := fm.reference(ctx, "Motor 12 overload in conveyor section", "en-US", "de-DE")
+		found <- ok
+	}()
+	select {
+	case ok := <-found:
+		if !ok {
+			t.Error("no reference for a prepared text")
+		}
+	case <-time.After(time.Second):
+		t.Error("reference waited for another text's embedding")
+	}
+	close(e.release)
+	<-slow
+}
+
+func TestReferenceInstructions(t *testing.T) {
+	if got := referenceInstructions(nil); got != "" {
+		t.Errorf("referenceInstructions(nil) = %q; expected none", got)
+	}
+	got := referenceInstructions([]tmEntry{{source: "Pump on", translation: "Pumpe ein"}})
+	if !strings.Contains(got, `"Pump on" -> "Pumpe ein"`) {
+		t.Errorf("referenceInstructions() = %q", got)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// GLOSSARY
+// ///////////////////
+
+// glossaryTerm is a mandatory rendering of a source term.
+type glossaryTerm struct {
+	source string
+	target string
+	re     *regexp.Regexp // finds source as a whole word, ignoring case
+}
+
+type glossary struct {
+	terms []glossaryTerm
+}
+
+// loadGlossary reads a CSV glossary. Two layouts are accepted:
+//   - plain "source,target" pairs, optionally under a "source,target" header
+//   - a header row of language codes (e.g. "de-DE,en-US,fr-FR"), in which
+//     case the columns matching sourceLang and targetLang are used
+//
+// Lines starting with "#" are comments. Both comma and semicolon work as
+// delimiter.
+func loadGlossary(path, sourceLang, targetLang string) (*glossary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open glossary: %w", err)
+	}
+	defer file.Close()
+	return parseGlossary(file, sourceLang, targetLang)
+}
+
+func parseGlossary(r io.Reader, sourceLang, targetLang string) (*glossary, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	if firstLine, _, _ := strings.Cut(text, "\n"); strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse glossary: %w", err)
+	}
+	if len(records) == 0 {
+		return &glossary{}, nil
+	}
+
+	srcCol, dstCol := 0, 1
+	header := records[0]
+	if isLanguageHeader(header) {
+		srcCol, dstCol = -1, -1
+		for i, h := range header {
+			switch normalizeLang(h) {
+			case normalizeLang(sourceLang):
+				srcCol = i
+			case normalizeLang(targetLang):
+				dstCol = i
+			}
+		}
+		if srcCol == -1 || dstCol == -1 {
+			return nil, fmt.Errorf("glossary has no columns for %s and %s", sourceLang, targetLang)
+		}
+		records = records[1:]
+	} else if len(header) >= 2 && strings.EqualFold(strings.TrimSpace(header[0]), "source") {
+		records = records[1:]
+	}
+
+	g := &glossary{}
+	for _, rec := range records {
+		if len(rec) <= srcCol || len(rec) <= dstCol {
+			continue
+		}
+		source := strings.TrimSpace(rec[srcCol])
+		target := strings.TrimSpace(rec[dstCol])
+		if source == "" || target == "" {
+			continue
+		}
+		g.terms = append(g.terms, glossaryTerm{source: source, target: target, re: termRegex(source)})
+	}
+	return g, nil
+}
+
+func isLanguageHeader(header []string) bool {
+	if len(header) < 2 {
+		return false
+	}
+	for _, h := range header {
+		code := strings.TrimSuffix(strings.TrimSpace(h), "*")
+		if m := langCodeRegex.FindString(code); m == "" || m != code {
+			return false
+		}
+	}
+	return true
+}
+
+// termRegex matches term case-insensitively, as a whole word where the term
+// starts or ends with a letter or digit.
+func termRegex(term string) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(term)
+	if r, _ := utf8.DecodeRuneInString(term); isWordRune(r) {
+		pattern = `(?:^|[^\pL\pN_])` + pattern
+	}
+	if r, _ := utf8.DecodeLastRuneInString(term); isWordRune(r) {
+		pattern = pattern + `(?:$|[^\pL\pN_])`
+	}
+	return regexp.MustCompile(`(?i)` + pattern)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// matches returns the terms whose source term occurs in text.
+func (g *glossary) matches(text string) []glossaryTerm {
+	if g == nil {
+		return nil
+	}
+	var found []glossaryTerm
+	for _, term := range g.terms {
+		if term.re.MatchString(text) {
+			found = append(found, term)
+		}
+	}
+	return found
+}
+
+// violations returns the terms that occur in source but whose required
+// rendering is missing from translation.
+func (g *glossary) violations(source, translation string) []glossaryTerm {
+	var missing []glossaryTerm
+	lower := strings.ToLower(translation)
+	for _, term := range g.matches(source) {
+		if !strings.Contains(lower, strings.ToLower(term.target)) {
+			missing = append(missing, term)
+		}
+	}
+	return missing
+}
+
+// glossaryInstructions renders terms as a prompt instruction.
+func glossaryInstructions(terms []glossaryTerm) string {
+	if len(terms) == 0 {
+		return ""
+	}
+	return " Always use this terminology: " + glossaryPairs(terms) + "."
+}
+
+// glossaryPairs lists terms as "'source' -> 'target'" pairs separated by "; ".
+func glossaryPairs(terms []glossaryTerm) string {
+	pairs := make([]string, len(terms))
+	for i, term := range terms {
+		pairs[i] = fmt.Sprintf("'%s' -> '%s'", term.source, term.target)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// runGlossaryCommand checks the translations in a workbook against a
+// glossary, e.g. after they were edited by hand, or extracts candidate
+// terms from it.
+func runGlossaryCommand(args []string) error {
+	usage := "usage: glossary check [--sheet NAME] --source-col COL --target-col COL GLOSSARY.csv WORKBOOK\n       glossary extract [--sheet NAME] --source-col COL [--target-col COL] WORKBOOK"
+	if len(args) > 0 && args[0] == "extract" {
+		return runGlossaryExtract(args[1:])
+	}
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New(usage)
+	}
+
+	fs := flag.NewFlagSet("glossary check", flag.ExitOnError)
+	sheet := fs.String("sheet", "", "Sheet to check (default: first sheet).")
+	sourceCol := fs.String("source-col", "", "Source language column, as a 1-based column number or header name.")
+	targetCol := fs.String("target-col", "", "Target language column, as a 1-based column number or header name.")
+	fs.Parse(args[1:])
+	if fs.NArg() != 2 || *sourceCol == "" || *targetCol == "" {
+		return errors.New(usage)
+	}
+	glossaryPath, fileName := fs.Arg(0), fs.Arg(1)
+
+	f, err := excelize.OpenFile(fileName)
+	if err != nil {
+		return fmt.Errorf("Error opening file: %v", err)
+	}
+	defer f.Close()
+	sheetName := f.GetSheetName(0)
+	if *sheet != "" {
+		sheetName = *sheet
+	}
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return fmt.Errorf("Error getting rows: %v", err)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("Sheet %q is empty", sheetName)
+	}
+	src, err := tiatrans.ResolveColumn(rows[0], *sourceCol)
+	if err != nil {
+		return fmt.Errorf("--source-col: %v", err)
+	}
+	tgt, err := tiatrans.ResolveColumn(rows[0], *targetCol)
+	if err != nil {
+		return fmt.Errorf("--target-col: %v", err)
+	}
+	// A glossary with language columns is matched by the headers.
+	g, err := loadGlossary(glossaryPath, rows[0][src], rows[0][tgt])
+	if err != nil {
+		return err
+	}
+
+	checked, problems := 0, 0
+	for i, row := range rows[1:] {
+		if src >= len(row) || tgt >= len(row) || strings.TrimSpace(row[tgt]) == "" {
+			continue
+		}
+		checked++
+		if missing := g.violations(row[src], row[tgt]); len(missing) > 0 {
+			problems++
+			fmt.Printf("Row %d: %s not used in %q\n", i+2, glossaryPairs(missing), row[tgt])
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d of %d translations do not follow the glossary", problems, checked)
+	}
+	fmt.Printf("All %d translations in sheet %s follow the glossary\n", checked, sheetName)
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestParseGlossary(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "Plain pairs",
+			input:    "Not-Halt,Emergency stop\nStörung,Fault\n",
+			expected: map[string]string{"Not-Halt": "Emergency stop", "Störung": "Fault"},
+		},
+		{
+			name:     "Source/target header and comments",
+			input:    "source,target\n# safety terms\nNot-Halt,Emergency stop\n",
+			expected: map[string]string{"Not-Halt": "Emergency stop"},
+		},
+		{
+			name:     "Semicolon with BOM",
+			input:    "\ufeffFreigabe;Enable\n",
+			expected: map[string]string{"Freigabe": "Enable"},
+		},
+		{
+			name:     "Language header picks columns",
+			input:    "fr-FR,de-DE,en-US\nArrêt d'urgence,Not-Halt,Emergency stop\n",
+			expected: map[string]string{"Not-Halt": "Emergency stop"},
+		},
+		{
+			name:    "Language header without target",
+			input:   "de-DE,fr-FR\nNot-Halt,Arrêt d'urgence\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := parseGlossary(strings.NewReader(tc.input), "de-DE*", "en-US")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseGlossary error = %v; wantErr %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if len(g.terms) != len(tc.expected) {
+				t.Fatalf("got %d terms; expected %d", len(g.terms), len(tc.expected))
+			}
+			for _, term := range g.terms {
+				if tc.expected[term.source] != term.target {
+					t.Errorf("term %q -> %q; expected %q", term.source, term.target, tc.expected[term.source])
+				}
+			}
+		})
+	}
+}
+
+func TestGlossaryViolations(t *testing.T) {
+	g, err := parseGlossary(strings.NewReader("Not-Halt,Emergency stop\nStörung,Fault\n%Q0.5,%Q0.5\n"), "de-DE", "en-US")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		source      string
+		translation string
+		expected    int
+	}{
+		{"Not-Halt betätigt", "Emergency stop pressed", 0},
+		{"Not-Halt betätigt", "E-stop pressed", 1},
+		{"not-halt betätigt", "E-stop pressed", 1},
+		{"Motorstörung", "Motor failure", 0}, // compound word, not a whole-word match
+		{"Störung Not-Halt", "Fault emergency STOP", 0},
+		{"Störung Not-Halt", "Error E-stop", 2},
+		{"Ausgang %Q0.5", "Output Q0.5", 1},
+	}
+
+	for _, tc := range testCases {
+		result := g.violations(tc.source, tc.translation)
+		if len(result) != tc.expected {
+			t.Errorf("violations(%q, %q) = %d; expected %d", tc.source, tc.translation, len(result), tc.expected)
+		}
+	}
+}
+
+func TestRunGlossaryCommand(t *testing.T) {
+	dir := t.TempDir()
+	glossaryPath := filepath.Join(dir, "terms.csv")
+	if err := os.WriteFile(glossaryPath, []byte("de-DE,en-US\nPumpe,pump\nVentil,valve\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	workbook := func(rows ...[]string) string {
+		path := filepath.Join(dir, fmt.Sprintf("book%d.xlsx", len(rows)))
+		f := excelize.NewFile()
+		for i, row := range rows {
+			f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", i+1), &row)
+		}
+		if err := f.SaveAs(path); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := workbook([]string{"de-DE", "en-US"}, []string{"Pumpe an", "Pump on"}, []string{"Ventil", ""})
+	bad := workbook([]string{"de-DE", "en-US"}, []string{"Pumpe an", "Pump on"}, []string{"Ventil zu", "Gate closed"}, []string{"Motor", "Motor"})
+
+	args := []string{"check", "--source-col", "de-DE", "--target-col", "en-US", glossaryPath}
+	if err := runGlossaryCommand(append(args, good)); err != nil {
+		t.Errorf("check of a workbook that follows the glossary: %v", err)
+	}
+	if err := runGlossaryCommand(append(args, bad)); err == nil || !strings.Contains(err.Error(), "1 of 3") {
+		t.Errorf("check of a workbook with a wrong term: %v; expected 1 of 3 translations reported", err)
+	}
+	if err := runGlossaryCommand([]string{"check", glossaryPath, good}); err == nil {
+		t.Error("no error without --source-col and --target-col")
+	}
+}
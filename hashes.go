@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// ROW HASHES
+// ///////////////////
+
+// With --hashes, a run records a hash of every row's source text and
+// translation in a sidecar file next to the input. When the project is
+// exported again to the same file, translations already in the export are
+// checked against it: one recorded for the same source text is still good,
+// and one whose source text is not recorded belongs to an older text and is
+// translated again. Rows are matched by their texts, not their position,
+// so it does not matter if the export orders them differently.
+
+// hashState is what gets written to the sidecar file.
+type hashState struct {
+	File      string                  `json:"file"`
+	UpdatedAt string                  `json:"updated_at"`
+	Columns   map[string]columnHashes `json:"columns"` // see columnKey
+}
+
+// columnHashes maps the hash of each source text of a target column to the
+// hashes of its translations; a text can have several, e.g. per context.
+type columnHashes map[string][]string
+
+// hashVerdict is what the sidecar says about a translation in the input.
+type hashVerdict int
+
+const (
+	hashUnknown   hashVerdict = iota // source not recorded: a new or changed text
+	hashUnchanged                    // source and translation as recorded
+	hashEdited                       // source as recorded, translation edited since
+)
+
+// rowHashes is the sidecar file of one input file.
+type rowHashes struct {
+	path  string
+	state hashState
+}
+
+// hashesPath is the sidecar file next to the input workbook.
+func hashesPath(fileName string) string {
+	return fileName + ".hashes.json"
+}
+
+// loadHashes reads a sidecar file; a missing one is empty.
+func loadHashes(path string) (*rowHashes, error) {
+	h := &rowHashes{path: path}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("could not read hashes: %w", err)
+	default:
+		if err := json.Unmarshal(data, &h.state); err != nil {
+			return nil, fmt.Errorf("could not parse hashes %s: %w", path, err)
+		}
+	}
+	if h.state.Columns == nil {
+		h.state.Columns = make(map[string]columnHashes)
+	}
+	return h, nil
+}
+
+func columnKey(sheet, sourceLang, targetLang string) string {
+	return sheet + ": " + sourceLang + " > " + targetLang
+}
+
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// column returns the hashes recorded for a target column, or nil if there
+// are none yet.
+func (h *rowHashes) column(sheet, sourceLang, targetLang string) columnHashes {
+	if h == nil {
+		return nil
+	}
+	return h.state.Columns[columnKey(sheet, sourceLang, targetLang)]
+}
+
+func (c columnHashes) check(source, translation string) hashVerdict {
+	translations, ok := c[textHash(source)]
+	switch {
+	case !ok:
+		return hashUnknown
+	case slices.Contains(translations, textHash(translation)):
+		return hashUnchanged
+	}
+	return hashEdited
+}
+
+func (c columnHashes) add(source, translation string) {
+	key, hash := textHash(source), textHash(translation)
+	if !slices.Contains(c[key], hash) {
+		c[key] = append(c[key], hash)
+	}
+}
+
+// update records the target columns of a task as they were saved. A cell
+// that may still hold the translation of an older text is left out: rows
+// that failed, that a reviewer rejected or that were not selected.
+func (h *rowHashes) update(task *fileTask) {
+	if h == nil {
+		return
+	}
+	stale := make(map[string]bool) // sheet, target language and 1-based row
+	staleKey := func(sheet, targetLang string, row int) string {
+		return fmt.Sprintf("%s\x00%s\x00%d", sheet, targetLang, row)
+	}
+	if task.failures != nil {
+		for _, r := range task.failures.rows {
+			stale[staleKey(r.sheet, r.targetLang, r.row)] = true
+		}
+	}
+	if task.review != nil {
+		for _, it := range task.review.items {
+			if it.decision == reviewRejected {
+				stale[staleKey(it.job.sheetName, it.job.targetLang, it.row+1)] = true
+			}
+		}
+	}
+
+	h.state.File = filepath.Base(task.fileName)
+	for _, job := range task.jobs {
+		c := make(columnHashes)
+		for i, row := range job.rows {
+			if i == 0 || len(row) <= job.sourceIndex {
+				continue
+			}
+			source := strings.TrimSpace(row[job.sourceIndex])
+			translation := strings.TrimSpace(job.cellValue(i))
+			if source == "" || !tiatrans.HasTranslation(translation) ||
+				!job.filter.allows(i+1, source) || stale[staleKey(job.sheetName, job.targetLang, i+1)] {
+				continue
+			}
+			c.add(source, translation)
+		}
+		h.state.Columns[columnKey(job.sheetName, job.sourceLang, job.targetLang)] = c
+	}
+}
+
+// save writes the sidecar file atomically.
+func (h *rowHashes) save() error {
+	if h == nil {
+		return nil
+	}
+	h.state.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.MarshalIndent(h.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("could not write hashes: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return fmt.Errorf("could not write hashes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestHashes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "TextExport.xlsx")
+	writeSheet(t, path, [][]string{
+		{"ID", "de-DE", "en-US"},
+		{"1", "Pumpe läuft", ""},
+		{"2", "Ventil offen", ""},
+		{"3", "Motor aus", ""},
+	})
+	opts := options{workers: 2, checkpointEvery: 25, mode: "quick", sourceCol: "de-DE", targetCol: "en-US", hashes: true}
+	run := func() *upperTranslator {
+		t.Helper()
+		task, err := prepareFile(opts, path, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		translator := &upperTranslator{}
+		result := &runResult{}
+		runTasks(context.Background(), discardSender{}, translator, []*fileTask{task}, opts, result)
+		if _, code := result.summary(); code != exitClean {
+			t.Fatalf("exit code %d", code)
+		}
+		slices.Sort(translator.calls)
+		return translator
+	}
+	if calls := run().calls; len(calls) != 3 {
+		t.Fatalf("first run sent %q", calls)
+	}
+
+	// The translations come back in a new export, in another order, with
+	// one source text changed and one translation edited by hand.
+	writeSheet(t, path, [][]string{
+		{"ID", "de-DE", "en-US"},
+		{"3", "Motor aus", "MOTOR AUS"},
+		{"2", "Ventil geschlossen", "VENTIL OFFEN"},
+		{"1", "Pumpe läuft", "Pump running"},
+		{"4", "Lüfter an", ""},
+	})
+	if calls, want := run().calls, []string{"Lüfter an", "Ventil geschlossen"}; !slices.Equal(calls, want) {
+		t.Errorf("second run sent %q; expected %q", calls, want)
+	}
+	out, err := excelize.OpenFile(filepath.Join(dir, "translated-TextExport.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	for cell, want := range map[string]string{"C2": "MOTOR AUS", "C3": "VENTIL GESCHLOSSEN", "C4": "Pump running", "C5": "LÜFTER AN"} {
+		if got, _ := out.GetCellValue("Sheet1", cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+
+	// The sidecar now has the texts of the second run.
+	h, err := loadHashes(hashesPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := h.column("Sheet1", "de-DE", "en-US")
+	testCases := []struct {
+		source, translation string
+		expected            hashVerdict
+	}{
+		{"Ventil geschlossen", "VENTIL GESCHLOSSEN", hashUnchanged},
+		{"Pumpe läuft", "Pump running", hashUnchanged},
+		{"Pumpe läuft", "PUMPE LÄUFT", hashEdited},
+		{"Ventil offen", "VENTIL OFFEN", hashUnknown},
+	}
+	for _, tc := range testCases {
+		if got := c.check(tc.source, tc.translation); got != tc.expected {
+			t.Errorf("check(%q, %q) = %d; expected %d", tc.source, tc.translation, got, tc.expected)
+		}
+	}
+}
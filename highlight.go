@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// HIGHLIGHTING
+// ///////////////////
+
+// highlightColor is the fill of cells the run changed: a light yellow that
+// keeps black text readable.
+const highlightColor = "FFF2CC"
+
+// highlighter marks the target cells a run changed, so reviewers can spot
+// them in Excel. It only affects XLSX output. A nil *highlighter does
+// nothing.
+type highlighter struct {
+	comments bool        // also attach the previous value as a cell comment
+	styles   map[int]int // original style ID -> the same style with the fill
+}
+
+// newHighlighter returns nil unless fill or comments is set. Comments come
+// with the fill.
+func newHighlighter(fill, comments bool) *highlighter {
+	if !fill && !comments {
+		return nil
+	}
+	return &highlighter{comments: comments, styles: make(map[int]int)}
+}
+
+// apply compares every target cell with its value before the run and marks
+// those that differ. It runs just before saving, so cells rejected during
+// review or reset by a later step are not marked.
+func (h *highlighter) apply(task *fileTask) (int, error) {
+	if h == nil {
+		return 0, nil
+	}
+	commented := make(map[string]map[string]bool)
+	changed := 0
+	for _, job := range task.jobs {
+		if _, ok := commented[job.sheetName]; !ok && h.comments {
+			existing, err := task.f.GetComments(job.sheetName)
+			if err != nil {
+				return changed, fmt.Errorf("could not read comments of %s: %w", job.sheetName, err)
+			}
+			commented[job.sheetName] = make(map[string]bool)
+			for _, c := range existing {
+				commented[job.sheetName][c.Cell] = true
+			}
+		}
+		for i := 1; i < len(job.rows); i++ {
+			var original string
+			if len(job.rows[i]) > job.targetIndex {
+				original = job.rows[i][job.targetIndex]
+			}
+			cell, _ := excelize.CoordinatesToCellName(job.targetIndex+1, i+1)
+			value, err := task.f.GetCellValue(job.sheetName, cell)
+			if err != nil {
+				return changed, err
+			}
+			if value == original {
+				continue
+			}
+			if err := fillCell(task.f, job.sheetName, cell, highlightColor, h.styles); err != nil {
+				return changed, err
+			}
+			// Never stack a second comment on a cell that already has one.
+			if h.comments && original != "" && !commented[job.sheetName][cell] {
+				err := task.f.AddComment(job.sheetName, excelize.Comment{
+					Author: "translator",
+					Cell:   cell,
+					Text:   "Before translation: " + original,
+				})
+				if err != nil {
+					return changed, fmt.Errorf("could not comment %s: %w", cell, err)
+				}
+				commented[job.sheetName][cell] = true
+			}
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// fillCell adds a fill of color to the cell's own style, so its font,
+// borders and number format stay as they were. styles caches the filled
+// copy of each style.
+func fillCell(f *excelize.File, sheet, cell, color string, styles map[int]int) error {
+	styleID, err := f.GetCellStyle(sheet, cell)
+	if err != nil {
+		return err
+	}
+	highlighted, ok := styles[styleID]
+	if !ok {
+		style, err := f.GetStyle(styleID)
+		if err != nil {
+			return err
+		}
+		style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{color}}
+		if highlighted, err = f.NewStyle(style); err != nil {
+			return err
+		}
+		styles[styleID] = highlighted
+	}
+	return f.SetCellStyle(sheet, cell, cell, highlighted)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestHighlightChangedCells(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Pump running", "Pump on"},
+		{"Valve open", "Valve open"},
+	}
+	job := newTestJob(t, rows)
+	bold, err := job.f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.f.SetCellStyle(job.sheetName, "B3", "B3", bold)
+	job.setCell(1, "MOTOR FAULT")
+	job.setCell(2, "PUMP RUNNING")
+	task := &fileTask{f: job.f, jobs: []translationJob{job}}
+
+	changed, err := newHighlighter(false, true).apply(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 2 {
+		t.Errorf("%d cells highlighted, expected 2", changed)
+	}
+
+	tests := []struct {
+		cell   string
+		filled bool
+		bold   bool
+	}{
+		{"B2", true, false},
+		{"B3", true, true},
+		{"B4", false, false},
+	}
+	for _, tt := range tests {
+		id, err := job.f.GetCellStyle(job.sheetName, tt.cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		style, err := job.f.GetStyle(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filled := len(style.Fill.Color) == 1 && style.Fill.Color[0] == highlightColor
+		if filled != tt.filled {
+			t.Errorf("%s filled = %v; expected %v", tt.cell, filled, tt.filled)
+		}
+		if isBold := style.Font != nil && style.Font.Bold; isBold != tt.bold {
+			t.Errorf("%s bold = %v; expected %v", tt.cell, isBold, tt.bold)
+		}
+	}
+
+	// Only B3 had a value before, so only it gets a comment.
+	comments, err := job.f.GetComments(job.sheetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].Cell != "B3" {
+		t.Fatalf("comments = %+v; expected one on B3", comments)
+	}
+	if comments[0].Text != "Before translation: Pump on" {
+		t.Errorf("comment = %q", comments[0].Text)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ///////////////////
+// JSON SUMMARY
+// ///////////////////
+
+// --json-summary writes the outcome of a run as one JSON document for build
+// pipelines and localization orchestrators: the exit code, the counts of the
+// final summary line, the files written and the status of every row. It is
+// written when the run ends, also when it was cancelled.
+
+type jsonSummary struct {
+	Status   string            `json:"status"` // clean, errors or aborted, as the exit code
+	ExitCode int               `json:"exit_code"`
+	Model    string            `json:"model"`
+	Started  string            `json:"started"`
+	Finished string            `json:"finished"`
+	Stats    jsonSummaryStats  `json:"stats"`
+	Files    []jsonSummaryFile `json:"files"`
+}
+
+type jsonSummaryStats struct {
+	Translated int `json:"translated"`
+	Reused     int `json:"reused"`
+	Copied     int `json:"copied"`
+	Skipped    int `json:"skipped"`
+	Preserved  int `json:"preserved"`
+	Flagged    int `json:"flagged"`
+	Errors     int `json:"errors"`
+}
+
+type jsonSummaryFile struct {
+	Input   string           `json:"input"`
+	Status  string           `json:"status"` // saved, partial, not saved or not started
+	Outputs []string         `json:"outputs"`
+	Rows    []jsonSummaryRow `json:"rows"`
+}
+
+type jsonSummaryRow struct {
+	Sheet      string `json:"sheet"`
+	Row        int    `json:"row"`
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Tokens     int64  `json:"tokens"`
+}
+
+var exitStatuses = map[int]string{exitClean: "clean", exitErrors: "errors", exitAborted: "aborted"}
+
+// writeJSONSummary writes the summary of a run over tasks to path. report
+// holds the rows of the files the run got to, in the order of tasks.
+func writeJSONSummary(path string, report *runReport, tasks []*fileTask, result *runResult, started time.Time) error {
+	s, code := result.summary()
+	summary := jsonSummary{
+		Status:   exitStatuses[code],
+		ExitCode: code,
+		Model:    report.model,
+		Started:  started.Format(time.RFC3339),
+		Finished: time.Now().Format(time.RFC3339),
+		Stats: jsonSummaryStats{
+			Translated: s.translated,
+			Reused:     s.reused,
+			Copied:     s.copied,
+			Skipped:    s.skipped,
+			Preserved:  s.preserved,
+			Flagged:    s.flagged,
+			Errors:     s.errors,
+		},
+		Files: []jsonSummaryFile{},
+	}
+	for i, task := range tasks {
+		file := jsonSummaryFile{Input: task.fileName, Status: "not started", Outputs: []string{}, Rows: []jsonSummaryRow{}}
+		if i < len(report.files) && !report.files[i].pending {
+			fr := report.files[i]
+			file.Outputs = append(file.Outputs, fr.outputs...)
+			switch {
+			case fr.partial:
+				file.Status = "partial"
+			case len(fr.outputs) > 0:
+				file.Status = "saved"
+			default:
+				file.Status = "not saved"
+			}
+			for _, e := range fr.sorted() {
+				file.Rows = append(file.Rows, jsonSummaryRow{
+					Sheet:      e.sheet,
+					Row:        e.row,
+					SourceLang: e.sourceLang,
+					TargetLang: e.targetLang,
+					Source:     e.source,
+					Target:     e.translation,
+					Status:     string(e.status),
+					Detail:     e.detail,
+					Tokens:     e.tokens,
+				})
+			}
+		}
+		summary.Files = append(summary.Files, file)
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not write JSON summary: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not write JSON summary: %w", err)
+	}
+	return nil
+}
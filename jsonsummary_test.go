@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestJSONSummary(t *testing.T) {
+	dir := t.TempDir()
+	opts := options{workers: 1, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US", provider: "openai",
+		jsonSummaryPath: filepath.Join(dir, "result.json")}
+
+	var tasks []*fileTask
+	for _, name := range []string{"one.xlsx", "two.xlsx"} {
+		path := filepath.Join(dir, name)
+		f := excelize.NewFile()
+		f.SetSheetRow("Sheet1", "A1", &[]string{"de-DE", "en-US"})
+		f.SetSheetRow("Sheet1", "A2", &[]string{"Motor", ""})
+		f.SetSheetRow("Sheet1", "A3", &[]string{"Broken valve", ""})
+		if err := f.SaveAs(path); err != nil {
+			t.Fatal(err)
+		}
+		task, err := prepareFile(opts, path, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tasks = append(tasks, task)
+	}
+	// Cancelling once the first file is translated, as with Ctrl+C, leaves
+	// the second one alone.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := &runResult{}
+	runTasks(ctx, cancelOnStats{cancel}, &flakyTranslator{seen: make(map[string]int)}, tasks, opts, result)
+
+	data, err := os.ReadFile(opts.jsonSummaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary jsonSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.ExitCode != exitAborted || summary.Status != "aborted" || summary.Model != "openai/gpt-4o-mini" {
+		t.Errorf("summary = %s %d %s; expected aborted %d openai/gpt-4o-mini", summary.Status, summary.ExitCode, summary.Model, exitAborted)
+	}
+	if len(summary.Files) != 2 {
+		t.Fatalf("%d files; expected 2", len(summary.Files))
+	}
+	first, second := summary.Files[0], summary.Files[1]
+	if first.Status != "partial" || len(first.Outputs) != 1 || first.Outputs[0] != filepath.Join(dir, "partial-one.xlsx") {
+		t.Errorf("first file %s %v; expected partial-one.xlsx", first.Status, first.Outputs)
+	}
+	if second.Status != "not started" || len(second.Outputs) != 0 || len(second.Rows) != 0 {
+		t.Errorf("second file %s %v with %d rows; expected not started", second.Status, second.Outputs, len(second.Rows))
+	}
+	expected := []jsonSummaryRow{
+		{Sheet: "Sheet1", Row: 2, SourceLang: "de-DE", TargetLang: "en-US", Source: "Motor", Target: "MOTOR", Status: "translated"},
+		{Sheet: "Sheet1", Row: 3, SourceLang: "de-DE", TargetLang: "en-US", Source: "Broken valve", Status: "error", Detail: "connection reset"},
+	}
+	if len(first.Rows) != len(expected) {
+		t.Fatalf("rows = %+v; expected %+v", first.Rows, expected)
+	}
+	for i, want := range expected {
+		if first.Rows[i] != want {
+			t.Errorf("row %d = %+v; expected %+v", i, first.Rows[i], want)
+		}
+	}
+}
+
+// cancelOnStats cancels the run when a job reports its stats, i.e. when it
+// is done.
+type cancelOnStats struct {
+	cancel context.CancelFunc
+}
+
+func (c cancelOnStats) Send(msg tea.Msg) {
+	if _, ok := msg.(statMsg); ok {
+		c.cancel()
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/zalando/go-keyring"
+)
+
+// ///////////////////
+// KEYCHAIN
+// ///////////////////
+
+// API keys can be kept in the credential store of the operating system
+// (Windows Credential Manager, macOS Keychain, Secret Service on Linux)
+// instead of a plain-text key file next to the executable. They are stored
+// under keychainService with the provider name as the account.
+
+const keychainService = "tia-text-translator"
+
+// keychainKey returns the key stored for provider, or "" if there is none
+// or the system has no credential store.
+func keychainKey(provider string) string {
+	key, err := keyring.Get(keychainService, provider)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
+
+// runKeyCommand implements "key set" and "key delete".
+func runKeyCommand(args []string) error {
+	usage := "usage: key set [--provider NAME] | key delete [--provider NAME]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	fs := flag.NewFlagSet("key "+args[0], flag.ExitOnError)
+	provider := fs.String("provider", "openai", "Provider the key belongs to: "+strings.Join(keyedProviders(), ", ")+".")
+	fs.Parse(args[1:])
+	if fs.NArg() != 0 {
+		return errors.New(usage)
+	}
+	spec, ok := providers[*provider]
+	if !ok || spec.keyEnv == "" {
+		return fmt.Errorf("invalid --provider %q: must be one of %s", *provider, strings.Join(keyedProviders(), ", "))
+	}
+
+	switch args[0] {
+	case "set":
+		key, err := readKey(spec.keyName)
+		if err != nil {
+			return err
+		}
+		if err := keyring.Set(keychainService, *provider, key); err != nil {
+			return fmt.Errorf("could not store the key in the system keychain: %w", err)
+		}
+		fmt.Printf("Stored the %s API key in the system keychain.\n", spec.keyName)
+		if keyPath, err := keyFilePath(spec.keyFile); err == nil {
+			if _, err := os.Stat(keyPath); err == nil {
+				fmt.Printf("%s is no longer needed and can be deleted.\n", keyPath)
+			}
+		}
+	case "delete":
+		if err := keyring.Delete(keychainService, *provider); err != nil {
+			if errors.Is(err, keyring.ErrNotFound) {
+				return fmt.Errorf("no %s API key is stored in the system keychain", spec.keyName)
+			}
+			return fmt.Errorf("could not delete the key from the system keychain: %w", err)
+		}
+		fmt.Printf("Deleted the %s API key from the system keychain.\n", spec.keyName)
+	default:
+		return errors.New(usage)
+	}
+	return nil
+}
+
+// readKey asks for the key, or reads it from stdin when that is not a
+// terminal, e.g. "type api-key.txt | translator key set".
+func readKey(name string) (string, error) {
+	var key string
+	if isTerminal(os.Stdin) {
+		form := huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title(fmt.Sprintf("%s API Key", name)).
+					Description("Stored in the system keychain.").
+					Value(&key).
+					Password(true),
+			),
+		).WithTheme(formTheme)
+		if err := form.Run(); err != nil {
+			return "", fmt.Errorf("could not read the key: %w", err)
+		}
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("could not read the key from stdin: %w", err)
+		}
+		key = line
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", errors.New("API key cannot be empty")
+	}
+	return key, nil
+}
+
+// keyedProviders lists the providers that need an API key.
+func keyedProviders() []string {
+	var names []string
+	for _, name := range providerNames() {
+		if providers[name].keyEnv != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
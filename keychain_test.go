@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+// withStdin runs fn with os.Stdin reading input from a pipe.
+func withStdin(t *testing.T, input string, fn func()) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(input)
+	w.Close()
+	defer r.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+	fn()
+}
+
+func TestKeyCommand(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv("OPENAI_API_KEY", "")
+
+	if _, err := getAPIKey("openai", false); err == nil {
+		t.Fatal("getAPIKey() found a key before one was stored")
+	}
+
+	withStdin(t, "sk-test-123\n", func() {
+		if err := runKeyCommand([]string{"set"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if key, err := getAPIKey("openai", false); err != nil || key != "sk-test-123" {
+		t.Errorf("getAPIKey() = %q, %v; expected the stored key", key, err)
+	}
+	if key := keychainKey("deepl"); key != "" {
+		t.Errorf("deepl key = %q; keys are stored per provider", key)
+	}
+
+	t.Setenv("OPENAI_API_KEY", "sk-from-env")
+	if key, _ := getAPIKey("openai", false); key != "sk-from-env" {
+		t.Errorf("getAPIKey() = %q; the environment variable comes first", key)
+	}
+
+	if err := runKeyCommand([]string{"delete"}); err != nil {
+		t.Fatal(err)
+	}
+	if key := keychainKey("openai"); key != "" {
+		t.Errorf("key still stored after delete: %q", key)
+	}
+	if err := runKeyCommand([]string{"delete"}); err == nil {
+		t.Error("deleting a missing key succeeded")
+	}
+	if err := runKeyCommand([]string{"set", "--provider", "ollama"}); err == nil {
+		t.Error("set accepted a provider without keys")
+	}
+	withStdin(t, "\n", func() {
+		if err := runKeyCommand([]string{"set"}); err == nil {
+			t.Error("set accepted an empty key")
+		}
+	})
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// LANGUAGE DETECTION
+// ///////////////////
+
+// Picking the wrong column of a 12-language export is easy, so the content
+// of each column is checked against its header. HMI texts are short, so the
+// detection leans on words that are frequent in them (articles, "open",
+// "fault", "pressure", ...) and on letters only some languages use. It
+// knows the languages below; anything else is reported as unknown.
+
+var languageNames = map[string]string{
+	"de": "German", "en": "English", "fr": "French", "es": "Spanish", "it": "Italian",
+	"nl": "Dutch", "pt": "Portuguese", "pl": "Polish", "cs": "Czech", "sv": "Swedish",
+	"da": "Danish", "tr": "Turkish", "ru": "Russian", "zh": "Chinese", "ja": "Japanese",
+	"ko": "Korean", "el": "Greek",
+}
+
+var languageWords = map[string]string{
+	"de": "der die das und nicht ist sind ein eine einen für mit von zu auf bei wird werden störung fehler pumpe ventil aus geöffnet geschlossen warnung druck hoch niedrig betrieb handbetrieb automatik quittieren behälter füllstand überlast anlage einschalten ausschalten drehzahl wert",
+	"en": "the and of to is are not for with on off fault error failure pump valve opened closed warning pressure high low running stopped level overload acknowledge speed value enabled disabled please",
+	"fr": "le les et des du un une pour avec sur est sont pas défaut erreur pompe vanne ouvert ouverte fermé fermée avertissement pression haute basse marche arrêt niveau surcharge réservoir acquitter vitesse valeur",
+	"es": "el los las y del un una para con es son no fallo error bomba válvula abierta abierto cerrada cerrado advertencia presión alta baja marcha parada nivel sobrecarga depósito velocidad valor",
+	"it": "il lo gli e di del della un una per con su è non guasto errore pompa valvola aperta aperto chiusa chiuso avviso pressione alta bassa marcia arresto livello sovraccarico serbatoio velocità valore",
+	"nl": "de het een en van voor met op is niet storing fout pomp klep open gesloten waarschuwing druk hoog laag bedrijf niveau overbelasting snelheid waarde",
+	"pt": "o a os as e do da um uma para com em é não falha erro bomba válvula aberta aberto fechada fechado aviso pressão alta baixa nível sobrecarga tanque velocidade valor",
+	"pl": "i w na z do nie jest dla się błąd awaria pompa zawór otwarty zamknięty ostrzeżenie ciśnienie wysokie niskie poziom przeciążenie zbiornik prędkość wartość",
+	"cs": "a v na z do ne je pro se chyba porucha čerpadlo ventil otevřeno zavřeno varování tlak vysoký nízký hladina přetížení nádrž rychlost hodnota",
+	"sv": "och i på av för med är inte en ett fel larm pump ventil öppen stängd varning tryck hög låg nivå överlast hastighet värde",
+	"da": "og i på af for med er ikke en et fejl alarm pumpe ventil åben lukket advarsel tryk høj lav niveau overbelastning hastighed værdi",
+	"tr": "ve bir için ile değil hata arıza pompa valf açık kapalı uyarı basınç yüksek düşük seviye hız değer",
+}
+
+var languageLetters = map[string]string{
+	"de": "ßäöü", "fr": "éèêçàùâî", "es": "ñ¿¡áéíóú", "it": "èàòìé", "pt": "ãõçêáé",
+	"pl": "ąęłżźśćń", "cs": "čřěůšžýá", "sv": "åäö", "da": "åøæ", "tr": "ışğçöü",
+}
+
+// wordLanguages and letterLanguages map a word or letter to the languages
+// that use it; shared ones count for each of them in part.
+var wordLanguages, letterLanguages = indexLanguageHints()
+
+func indexLanguageHints() (map[string][]string, map[rune][]string) {
+	words := make(map[string][]string)
+	for lang, list := range languageWords {
+		for _, w := range strings.Fields(list) {
+			words[w] = append(words[w], lang)
+		}
+	}
+	letters := make(map[rune][]string)
+	for lang, list := range languageLetters {
+		for _, r := range list {
+			letters[r] = append(letters[r], lang)
+		}
+	}
+	return words, letters
+}
+
+// detectLanguage returns the language code most of texts are written in, or
+// "" if they give no clear answer.
+func detectLanguage(texts []string) string {
+	return detectLanguageHits(texts, 3)
+}
+
+// detectTextLanguage returns the language code of a single text, or "" if it
+// is too short to tell. Two frequent words are enough.
+func detectTextLanguage(text string) string {
+	return detectLanguageHits([]string{text}, 2)
+}
+
+// detectLanguageHits detects the language of texts from at least minHits
+// word and letter hits.
+func detectLanguageHits(texts []string, minHits float64) string {
+	scripts := make(map[string]int)
+	latin := 0
+	scores := make(map[string]float64)
+	for _, text := range texts {
+		text = strings.ToLower(text)
+		for _, r := range text {
+			switch {
+			case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+				scripts["ja"]++
+			case unicode.Is(unicode.Hangul, r):
+				scripts["ko"]++
+			case unicode.Is(unicode.Han, r):
+				scripts["zh"]++
+			case unicode.Is(unicode.Cyrillic, r):
+				scripts["ru"]++
+			case unicode.Is(unicode.Greek, r):
+				scripts["el"]++
+			case unicode.Is(unicode.Latin, r):
+				latin++
+				for _, lang := range letterLanguages[r] {
+					scores[lang] += 0.5 / float64(len(letterLanguages[r]))
+				}
+			}
+		}
+		for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) }) {
+			for _, lang := range wordLanguages[word] {
+				scores[lang] += 1 / float64(len(wordLanguages[word]))
+			}
+		}
+	}
+
+	nonLatin := 0
+	for _, n := range scripts {
+		nonLatin += n
+	}
+	if nonLatin > latin {
+		// Japanese mixes kana into Han, so any kana decides.
+		if scripts["ja"] > 0 {
+			return "ja"
+		}
+		best := ""
+		for lang, n := range scripts {
+			if best == "" || n > scripts[best] || (n == scripts[best] && lang < best) {
+				best = lang
+			}
+		}
+		return best
+	}
+
+	var best, second string
+	for lang, score := range scores {
+		switch {
+		case best == "" || score > scores[best] || (score == scores[best] && lang < best):
+			best, second = lang, best
+		case second == "" || score > scores[second]:
+			second = lang
+		}
+	}
+	// A few hits, well ahead of the runner-up.
+	if best == "" || scores[best] < minHits || (second != "" && scores[best] < 2*scores[second]) {
+		return ""
+	}
+	return best
+}
+
+// columnLanguages detects the language of every column from a sample of its
+// texts. Columns without a clear answer get "".
+func columnLanguages(rows [][]string) []string {
+	const sample = 300
+	if len(rows) == 0 {
+		return nil
+	}
+	langs := make([]string, len(rows[0]))
+	for col := range langs {
+		var texts []string
+		for _, row := range rows[1:] {
+			if len(texts) == sample {
+				break
+			}
+			if col >= len(row) {
+				continue
+			}
+			text := strings.TrimSpace(row[col])
+			if !tiatrans.HasTranslation(text) || tiatrans.IsPlaceholder(text) {
+				continue
+			}
+			if _, err := strconv.ParseFloat(text, 64); err == nil {
+				continue
+			}
+			texts = append(texts, text)
+		}
+		langs[col] = detectLanguage(texts)
+	}
+	return langs
+}
+
+// headerLanguage returns the language code of a header such as "de-DE*", or
+// "" if the header is not a language code.
+func headerLanguage(header string) string {
+	header = strings.TrimSuffix(strings.TrimSpace(header), "*")
+	m := langCodeRegex.FindStringSubmatch(header)
+	if m == nil || m[0] != header {
+		return ""
+	}
+	return strings.ToLower(m[1])
+}
+
+// isCultureCode reports whether header is a TIA culture code such as "de-DE"
+// or "en-US*". The region is required: a bare "ID" header would read
+// as Indonesian.
+func isCultureCode(header string) bool {
+	header = strings.TrimSuffix(strings.TrimSpace(header), "*")
+	m := langCodeRegex.FindStringSubmatch(header)
+	return m != nil && m[0] == header && m[2] != ""
+}
+
+// cyrillicLanguages are the header languages a Cyrillic column may have
+// without a warning; the detector calls all of them "ru".
+var cyrillicLanguages = map[string]bool{"ru": true, "uk": true, "bg": true, "be": true, "sr": true, "mk": true, "kk": true}
+
+// languageMismatch describes how the content of column col contradicts its
+// header, or returns "" if it doesn't.
+func languageMismatch(headers, detected []string, col int) string {
+	if col < 0 || col >= len(headers) || col >= len(detected) || detected[col] == "" {
+		return ""
+	}
+	if !contradicts(headerLanguage(headers[col]), detected[col]) {
+		return ""
+	}
+	return fmt.Sprintf("column %d %q looks like %s text", col+1, headers[col], languageNames[detected[col]])
+}
+
+// contradicts reports whether text detected as language got cannot be in
+// language want.
+func contradicts(want, got string) bool {
+	switch {
+	case want == "" || got == "" || want == got:
+		return false
+	case got == "ru" && cyrillicLanguages[want]:
+		return false
+	case languageNames[want] == "" && languageWords[got] != "":
+		// The header's language is unknown to the detector, which may
+		// have mistaken it for a related Latin-script one.
+		return false
+	}
+	return true
+}
+
+// suggestSourceColumn picks the likely source column: the one TIA marks as
+// reference language with "*", or else the fullest column whose content
+// matches its language header. It returns -1 if there is none.
+func suggestSourceColumn(rows [][]string, detected []string) int {
+	if len(rows) == 0 {
+		return -1
+	}
+	headers := rows[0]
+	for i, h := range headers {
+		if strings.HasSuffix(strings.TrimSpace(h), "*") && headerLanguage(h) != "" {
+			return i
+		}
+	}
+	best, bestCount := -1, 0
+	for i, h := range headers {
+		if i >= len(detected) || detected[i] == "" || headerLanguage(h) == "" || languageMismatch(headers, detected, i) != "" {
+			continue
+		}
+		count := 0
+		for _, row := range rows[1:] {
+			if i < len(row) && tiatrans.HasTranslation(strings.TrimSpace(row[i])) {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = i, count
+		}
+	}
+	return best
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		texts []string
+		want  string
+	}{
+		{[]string{"Störung Pumpe 1", "Ventil geöffnet", "Druck zu hoch", "Füllstand Behälter niedrig"}, "de"},
+		{[]string{"Pump 1 fault", "Valve opened", "Pressure too high", "Tank level low"}, "en"},
+		{[]string{"Défaut pompe 1", "Vanne ouverte", "Pression trop haute", "Niveau réservoir bas"}, "fr"},
+		{[]string{"Fallo bomba 1", "Válvula abierta", "Presión demasiado alta", "Nivel del depósito bajo"}, "es"},
+		{[]string{"Guasto pompa 1", "Valvola aperta", "Pressione troppo alta", "Livello serbatoio basso"}, "it"},
+		{[]string{"Błąd pompy 1", "Zawór otwarty", "Ciśnienie za wysokie", "Poziom zbiornika niski"}, "pl"},
+		{[]string{"泵1故障", "阀门打开", "压力过高"}, "zh"},
+		{[]string{"ポンプ1の故障", "バルブが開いています"}, "ja"},
+		{[]string{"Неисправность насоса 1", "Клапан открыт"}, "ru"},
+		{[]string{"Motor 1", "M1", "Tag_07"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.texts); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.texts, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageMismatch(t *testing.T) {
+	rows := [][]string{
+		{"ID", "de-DE*", "en-US", "fr-FR", "uk-UA", "fi-FI"},
+		{"1", "Störung Pumpe", "Pump fault", "Störung Pumpe", "Несправність насоса", "Pumpun vika"},
+		{"2", "Ventil geöffnet", "Valve opened", "Ventil geöffnet", "Клапан відкритий", "Venttiili auki"},
+		{"3", "Druck zu hoch", "Pressure too high", "Druck zu hoch", "Тиск занадто високий", "Paine liian korkea"},
+		{"4", "Füllstand niedrig", "Level low", "Füllstand niedrig", "Низький рівень", "Taso matala"},
+	}
+	detected := columnLanguages(rows)
+	want := []string{
+		"",
+		"",
+		"",
+		`column 4 "fr-FR" looks like German text`,
+		"",
+		"",
+	}
+	for col := range rows[0] {
+		if got := languageMismatch(rows[0], detected, col); got != want[col] {
+			t.Errorf("column %d: languageMismatch = %q, want %q", col+1, got, want[col])
+		}
+	}
+	if got := suggestSourceColumn(rows, detected); got != 1 {
+		t.Errorf("suggestSourceColumn = %d, want 1 (the reference language)", got)
+	}
+
+	// Without the "*" marker, the fullest matching column wins.
+	rows[0][1] = "de-DE"
+	rows[1][2] = ""
+	if got := suggestSourceColumn(rows, columnLanguages(rows)); got != 1 {
+		t.Errorf("suggestSourceColumn without marker = %d, want 1", got)
+	}
+}
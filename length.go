@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// LENGTH LIMITS
+// ///////////////////
+
+// lengthLimits is --max-length: the most characters a target column may hold,
+// usually set by the width of the HMI display field. A nil *lengthLimits
+// sets no limit.
+type lengthLimits struct {
+	all     int            // applies to target columns without their own limit; 0 = none
+	columns map[string]int // column spec (header or 1-based number) -> limit
+}
+
+// parseLengthLimits parses a list like "40", "en-US=40,fr-FR=36" or
+// "40,zh-CN=20". A bare number applies to every other target column.
+func parseLengthLimits(spec string) (*lengthLimits, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	limits := &lengthLimits{columns: make(map[string]int)}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		column, value, hasColumn := strings.Cut(part, "=")
+		if !hasColumn {
+			value = column
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid length %q", value)
+		}
+		if !hasColumn {
+			limits.all = n
+			continue
+		}
+		if column = strings.TrimSpace(column); column == "" {
+			return nil, fmt.Errorf("missing column in %q", part)
+		}
+		limits.columns[column] = n
+	}
+	return limits, nil
+}
+
+// forColumn returns the limit of column col (0-based) of a sheet with the
+// given headers, or 0 if it has none. Columns a sheet doesn't have are
+// ignored, so one spec can serve files with different languages.
+func (l *lengthLimits) forColumn(headers []string, col int) int {
+	if l == nil {
+		return 0
+	}
+	for spec, n := range l.columns {
+		if i, err := tiatrans.ResolveColumn(headers, spec); err == nil && i == col {
+			return n
+		}
+	}
+	return l.all
+}
+
+// lengthFlag flags a translation that is longer than the target column
+// allows; it returns "" if the translation fits.
+func (j *translationJob) lengthFlag(translation string) string {
+	if n := utf8.RuneCountInString(translation); j.maxLength > 0 && n > j.maxLength {
+		return fmt.Sprintf("too long: %d characters, limit %d", n, j.maxLength)
+	}
+	return ""
+}
+
+// lengthInstructions asks for a translation that fits limit characters.
+func lengthInstructions(limit int) string {
+	if limit == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" The translation is shown on an HMI display and must not be longer than %d characters. Shorten it, using common abbreviations where needed, but keep the meaning.", limit)
+}
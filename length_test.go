@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseLengthLimits(t *testing.T) {
+	headers := []string{"de-DE*", "en-US", "fr-FR", "zh-CN"}
+	tests := []struct {
+		spec    string
+		want    []int // limit per column
+		wantErr bool
+	}{
+		{"", []int{0, 0, 0, 0}, false},
+		{"40", []int{40, 40, 40, 40}, false},
+		{"en-US=40,fr-FR=36", []int{0, 40, 36, 0}, false},
+		{"40, zh-cn=20", []int{40, 40, 40, 20}, false},
+		{"3=30", []int{0, 0, 30, 0}, false},
+		{"it-IT=30", []int{0, 0, 0, 0}, false},
+		{"en-US=0", nil, true},
+		{"=40", nil, true},
+		{"wide", nil, true},
+	}
+	for _, tt := range tests {
+		limits, err := parseLengthLimits(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLengthLimits(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		for col, want := range tt.want {
+			if got := limits.forColumn(headers, col); got != want {
+				t.Errorf("parseLengthLimits(%q) column %d = %d, want %d", tt.spec, col+1, got, want)
+			}
+		}
+	}
+}
+
+// verboseTranslator answers with a long text unless asked to keep it short.
+type verboseTranslator struct {
+	mu       sync.Mutex
+	shortens int
+}
+
+func (v *verboseTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	limit := promptHintsFrom(ctx).maxLength
+	if limit == 0 {
+		return "Please note: " + strings.ToUpper(text), nil
+	}
+	v.mu.Lock()
+	v.shortens++
+	v.mu.Unlock()
+	result := strings.ToUpper(text)
+	if len(result) > limit {
+		result = result[:limit+1] // still one too long
+	}
+	return result, nil
+}
+
+func TestMaxLength(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Pump off", ""},
+		{"Tank overpressure", ""},
+	}
+	job := newTestJob(t, rows)
+	job.maxLength = 12
+	job.report = &fileReport{}
+	translator := &verboseTranslator{}
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	expected := []struct {
+		cell, value, detail string
+	}{
+		{"B2", "PUMP OFF", ""},
+		{"B3", "TANK OVERPRES", "too long: 13 characters, limit 12"},
+	}
+	entries := job.report.sorted()
+	for i, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, want.cell); got != want.value {
+			t.Errorf("%s = %q, want %q", want.cell, got, want.value)
+		}
+		if entries[i].detail != want.detail {
+			t.Errorf("%s detail = %q, want %q", want.cell, entries[i].detail, want.detail)
+		}
+	}
+	if translator.shortens != 2 {
+		t.Errorf("asked for %d shorter translations, want 2", translator.shortens)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ///////////////////
+// LOG PANE
+// ///////////////////
+
+// The log pane keeps the whole history of the run. Lines are coloured once
+// when they arrive and the viewport is refreshed at most every
+// logRefreshInterval, so a run of tens of thousands of rows stays cheap to
+// draw. While the user is scrolled up, new lines do not pull the view down.
+
+const logRefreshInterval = 100 * time.Millisecond
+
+// logRefreshMsg asks for a pending refresh of the log pane.
+type logRefreshMsg struct{}
+
+var logStyleMatch = lipgloss.NewStyle().Reverse(true)
+
+// logPane is the state of the log viewport and its search.
+type logPane struct {
+	lines       []string // as logged
+	colored     []string // lines rendered for the viewport
+	dirty       bool     // colored has lines the viewport does not show yet
+	scheduled   bool     // a logRefreshMsg is on its way
+	lastRefresh time.Time
+
+	searching bool // the / prompt is open
+	input     textinput.Model
+	query     string
+	match     int // line of the current match; -1 if none
+}
+
+// appendLog adds a line and returns a command if a refresh has to be
+// scheduled.
+func (m *model) appendLog(line string) tea.Cmd {
+	m.log.lines = append(m.log.lines, line)
+	m.log.colored = append(m.log.colored, m.renderLogLine(line))
+	m.log.dirty = true
+	if !m.ready {
+		return nil
+	}
+	if time.Since(m.log.lastRefresh) >= logRefreshInterval {
+		m.refreshLog()
+		return nil
+	}
+	if m.log.scheduled {
+		return nil
+	}
+	m.log.scheduled = true
+	return tea.Tick(logRefreshInterval, func(time.Time) tea.Msg { return logRefreshMsg{} })
+}
+
+// refreshLog hands the colored lines to the viewport and follows the end of
+// the log unless the user scrolled away from it.
+func (m *model) refreshLog() {
+	follow := m.viewport.AtBottom()
+	m.viewport.SetContent(strings.Join(m.log.colored, "\n"))
+	if follow {
+		m.viewport.GotoBottom()
+	}
+	m.log.dirty = false
+	m.log.lastRefresh = time.Now()
+}
+
+func (m *model) renderLogLine(line string) string {
+	if m.log.query != "" && strings.Contains(strings.ToLower(line), m.log.query) {
+		return logStyleMatch.Render(line)
+	}
+	return colorizeLog(line)
+}
+
+// recolorLog renders every line again, after the query changed.
+func (m *model) recolorLog() {
+	for i, line := range m.log.lines {
+		m.log.colored[i] = m.renderLogLine(line)
+	}
+	m.log.dirty = true
+	if m.ready {
+		m.refreshLog()
+	}
+}
+
+// findLogLine returns the next line after from (or the previous one before
+// it if backward) that contains query, wrapping around; -1 if none does.
+// query must be lower case.
+func findLogLine(lines []string, query string, from int, backward bool) int {
+	n := len(lines)
+	step := 1
+	if backward {
+		step = -1
+	}
+	for i := 1; i <= n; i++ {
+		idx := ((from+step*i)%n + n) % n
+		if strings.Contains(strings.ToLower(lines[idx]), query) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// jumpToMatch moves to the next or previous match and puts it at the top of
+// the pane.
+func (m *model) jumpToMatch(backward bool) {
+	if m.log.query == "" || len(m.log.lines) == 0 {
+		return
+	}
+	from := m.log.match
+	if from < 0 {
+		from = m.viewport.YOffset - 1
+		if backward {
+			from = m.viewport.YOffset + m.viewport.Height
+		}
+	}
+	m.log.match = findLogLine(m.log.lines, m.log.query, from, backward)
+	if m.log.match < 0 {
+		return
+	}
+	if m.log.dirty {
+		m.refreshLog()
+	}
+	m.viewport.SetYOffset(m.log.match)
+}
+
+// openSearch shows the / prompt.
+func (m *model) openSearch() tea.Cmd {
+	m.log.input = textinput.New()
+	m.log.input.Prompt = "/"
+	m.log.input.Placeholder = "search the log"
+	m.log.input.SetValue(m.log.query)
+	m.log.searching = true
+	return m.log.input.Focus()
+}
+
+// updateSearch handles keys while the / prompt is open: enter searches,
+// esc closes the prompt and clears the search.
+func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.log.searching = false
+		m.log.query = strings.ToLower(strings.TrimSpace(m.log.input.Value()))
+		m.log.match = -1
+		m.recolorLog()
+		m.jumpToMatch(false)
+		return m, nil
+	case tea.KeyEsc:
+		m.log.searching = false
+		if m.log.query != "" {
+			m.log.query = ""
+			m.log.match = -1
+			m.recolorLog()
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.log.input, cmd = m.log.input.Update(msg)
+	return m, cmd
+}
+
+// searchStatus describes the search for the footer.
+func (m model) searchStatus() string {
+	if m.log.searching {
+		return m.log.input.View()
+	}
+	if m.log.query == "" {
+		return ""
+	}
+	count := 0
+	for _, line := range m.log.lines {
+		if strings.Contains(strings.ToLower(line), m.log.query) {
+			count++
+		}
+	}
+	if count == 0 {
+		return fmt.Sprintf("/%s: no match  |  esc: clear", m.log.query)
+	}
+	return fmt.Sprintf("/%s: %d lines  |  n/N: next/previous  |  esc: clear", m.log.query, count)
+}
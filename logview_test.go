@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFindLogLine(t *testing.T) {
+	lines := []string{"Translating: a", "WARNING: x", "Translating: b", "warning: y", "Done"}
+	testCases := []struct {
+		from     int
+		backward bool
+		expected int
+	}{
+		{-1, false, 1},
+		{1, false, 3},
+		{3, false, 1}, // wraps around
+		{3, true, 1},
+		{1, true, 3},
+		{5, true, 3},
+	}
+	for _, tc := range testCases {
+		if got := findLogLine(lines, "warning", tc.from, tc.backward); got != tc.expected {
+			t.Errorf("findLogLine(from %d, backward %t) = %d; expected %d", tc.from, tc.backward, got, tc.expected)
+		}
+	}
+	if got := findLogLine(lines, "missing", 0, false); got != -1 {
+		t.Errorf("findLogLine() for a missing text = %d; expected -1", got)
+	}
+}
+
+func TestLogSearch(t *testing.T) {
+	var m tea.Model = model{}
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	for i := 0; i < 5000; i++ {
+		line := fmt.Sprintf("Translating: row %d", i)
+		if i == 20 || i == 4000 {
+			line = fmt.Sprintf("WARNING: sheet Alarms: row %d", i)
+		}
+		m, _ = m.Update(logMsg(line))
+	}
+	if got := len(m.(model).log.lines); got != 5000 {
+		t.Fatalf("log keeps %d lines; expected the whole history", got)
+	}
+
+	keys := []tea.KeyMsg{{Type: tea.KeyRunes, Runes: []rune{'/'}}}
+	for _, r := range "warning" {
+		keys = append(keys, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	keys = append(keys, tea.KeyMsg{Type: tea.KeyEnter})
+	for _, key := range keys {
+		m, _ = m.Update(key)
+	}
+	offsets := []int{m.(model).viewport.YOffset}
+	for _, key := range []rune{'n', 'n', 'N'} {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{key}})
+		offsets = append(offsets, m.(model).viewport.YOffset)
+	}
+	if fmt.Sprint(offsets) != "[20 4000 20 4000]" {
+		t.Errorf("matches shown at lines %v; expected [20 4000 20 4000]", offsets)
+	}
+
+	// Scrolled away from the end, new lines leave the view where it is.
+	m, _ = m.Update(logMsg("Translating: one more"))
+	m, _ = m.Update(logRefreshMsg{})
+	if got := m.(model).viewport.YOffset; got != 4000 {
+		t.Errorf("view moved to %d on a new line while scrolled up", got)
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.(model).log.query != "" {
+		t.Error("esc did not clear the search")
+	}
+}
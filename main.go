@@ -2,17 +2,16 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
-	"flag"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"regexp"
 	"runtime/debug"
-	"strconv"
+	"slices"
 	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
@@ -20,8 +19,6 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
-	openai "github.com/sashabaranov/go-openai"
-	"github.com/xuri/excelize/v2"
 )
 
 // ///////////////////
@@ -92,6 +89,7 @@ var (
 	logStyleCopied      = lipgloss.NewStyle().Foreground(colorMuted)
 	logStyleError       = lipgloss.NewStyle().Foreground(colorError).Bold(true)
 	logStyleSkipped     = lipgloss.NewStyle().Foreground(colorAccent)
+	logStyleFlagged     = lipgloss.NewStyle().Foreground(colorWarning).Bold(true)
 
 	footerStyle = lipgloss.NewStyle().
 			Foreground(colorMuted).
@@ -145,8 +143,27 @@ type stats struct {
 	translated int
 	reused     int
 	copied     int
-	errors     int
 	skipped    int
+	errors     int
+	preserved  int
+	flagged    int
+}
+
+// add sums up the stats of several jobs.
+func (s *stats) add(o stats) {
+	s.translated += o.translated
+	s.reused += o.reused
+	s.copied += o.copied
+	s.skipped += o.skipped
+	s.errors += o.errors
+	s.preserved += o.preserved
+	s.flagged += o.flagged
+}
+
+// String is the one-line summary printed at the end of a run.
+func (s stats) String() string {
+	return fmt.Sprintf("Translated: %d | Reused: %d | Copied: %d | Skipped: %d | Preserved: %d | Flagged: %d | Errors: %d",
+		s.translated, s.reused, s.copied, s.skipped, s.preserved, s.flagged, s.errors)
 }
 
 type FileType int
@@ -154,6 +171,8 @@ type FileType int
 const (
 	FileTypeTIA FileType = iota
 	FileTypeRockwell
+	FileTypeTIAXML
+	FileTypePO
 )
 
 func (ft FileType) String() string {
@@ -162,6 +181,10 @@ func (ft FileType) String() string {
 		return "TIA Portal"
 	case FileTypeRockwell:
 		return "Rockwell FTView"
+	case FileTypeTIAXML:
+		return "TIA Portal XML"
+	case FileTypePO:
+		return "Gettext PO"
 	default:
 		return "Unknown"
 	}
@@ -181,14 +204,23 @@ func detectFileType(headers []string) FileType {
 	if len(headers) > 0 && headers[0] == "Server" {
 		return FileTypeRockwell
 	}
+	// TIA XML: sheet built by openSimatic
+	if len(headers) > 0 && headers[0] == simaticIDHeader {
+		return FileTypeTIAXML
+	}
+	// PO: sheet built by openPO
+	if len(headers) > 0 && headers[0] == poLineHeader {
+		return FileTypePO
+	}
 	// Default to TIA
 	return FileTypeTIA
 }
 
 type model struct {
 	percent     float64
-	logMessages []string
+	log         logPane
 	progressBar progress.Model
+	overallBar  progress.Model
 	viewport    viewport.Model
 	done        bool
 	err         error
@@ -198,25 +230,31 @@ type model struct {
 	mode        string
 	currentRow  int
 	totalRows   int
+	fileIndex   int
+	fileCount   int
+	parallel    int        // files run at once; up to 1 shows one file at a time
+	files       []fileSlot // with parallel files
 	stats       stats
 	width       int
 	height      int
+	review      *reviewModel    // set while a file is being reviewed
+	locked      []lockedFileMsg // output files waiting for a decision, oldest first
+	rate        throughput      // rows of the current file done over time
+	control     *runControl     // reaches the running jobs for pause, skip and retry
+	paused      bool
+	quitArmed   bool // q was pressed once; a second q stops the run
 }
 
 type progressMsg float64
 type logMsg string
 type doneMsg struct{}
-type statMsg struct {
-	translated int
-	reused     int
-	copied     int
-	errors     int
-	skipped    int
-}
+type statMsg stats
 type fileInfoMsg struct {
 	fileName  string
 	mode      string
 	totalRows int
+	fileIndex int
+	fileCount int
 }
 
 func (m model) Init() tea.Cmd {
@@ -226,9 +264,66 @@ func (m model) Init() tea.Cmd {
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "q", "ctrl+c":
+		if len(m.locked) > 0 && msg.String() != "ctrl+c" {
+			return m.updateLocked(msg)
+		}
+		if m.review != nil && msg.String() != "ctrl+c" {
+			return m.updateReview(msg)
+		}
+		if m.log.searching && msg.String() != "ctrl+c" {
+			return m.updateSearch(msg)
+		}
+		// A stray key must not end an expensive run: q asks to be pressed
+		// again, anything else in between cancels it.
+		key := msg.String()
+		armed := m.quitArmed
+		m.quitArmed = false
+		switch key {
+		case "ctrl+c":
 			return m, tea.Quit
+		case "q":
+			if m.done || m.err != nil || armed {
+				return m, tea.Quit
+			}
+			m.quitArmed = true
+			return m.Update(logMsg("Press q again to stop and save what was translated so far"))
+		case " ":
+			if m.done || m.control == nil {
+				return m, nil
+			}
+			m.paused = m.control.togglePause()
+			if m.paused {
+				return m.Update(logMsg("Paused: requests already sent finish, no new ones are sent. Press space to resume."))
+			}
+			return m.Update(logMsg("Resumed"))
+		case "s":
+			if m.done || m.control == nil {
+				return m, nil
+			}
+			if source, ok := m.control.skip(); ok {
+				return m.Update(logMsg(fmt.Sprintf("Skipping: %s", source)))
+			}
+			return m.Update(logMsg("No row is being translated"))
+		case "r":
+			if m.done || m.control == nil {
+				return m, nil
+			}
+			m.control.requestRetry()
+			return m, nil
+		case "/":
+			if !m.ready {
+				return m, nil
+			}
+			return m, m.openSearch()
+		case "n", "N":
+			m.jumpToMatch(key == "N")
+			return m, nil
+		case "esc":
+			if m.log.query != "" {
+				m.log.query = ""
+				m.recolorLog()
+			}
+			return m, nil
 		case "j", "down":
 			if m.ready {
 				m.viewport.ScrollDown(1)
@@ -258,54 +353,94 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		headerHeight := 3
 		progressHeight := 3
 		footerHeight := 2
+		if m.parallel > 1 {
+			progressHeight += m.parallel // a line per running file
+		} else if m.fileCount > 1 {
+			progressHeight++ // overall bar
+		}
 		viewportHeight := msg.Height - headerHeight - progressHeight - footerHeight - 4
 		if viewportHeight < 5 {
 			viewportHeight = 5
 		}
 		m.viewport = viewport.New(msg.Width-4, viewportHeight)
-		m.viewport.SetContent(colorizeLogs(m.logMessages))
 		m.ready = true
+		m.refreshLog()
+		m.viewport.GotoBottom()
 		return m, nil
 
 	case progress.FrameMsg:
 		progressModel, cmd := m.progressBar.Update(msg)
 		m.progressBar = progressModel.(progress.Model)
-		return m, cmd
+		overallModel, overallCmd := m.overallBar.Update(msg)
+		m.overallBar = overallModel.(progress.Model)
+		return m, tea.Batch(cmd, overallCmd)
 
 	case progressMsg:
 		m.percent = float64(msg)
 		m.currentRow = int(float64(m.totalRows) * float64(msg))
+		m.rate.add(time.Now(), m.currentRow)
+		if m.fileCount > 1 {
+			overall := (float64(m.fileIndex) + m.percent) / float64(m.fileCount)
+			return m, tea.Batch(m.progressBar.SetPercent(float64(msg)), m.overallBar.SetPercent(overall))
+		}
 		return m, m.progressBar.SetPercent(float64(msg))
 
+	case fileProgressMsg:
+		m = m.updateFiles(msg)
+		m.rate.add(time.Now(), m.currentRow)
+		return m, m.progressBar.SetPercent(m.percent)
+
+	case fileDoneMsg:
+		m = m.updateFiles(msg)
+		return m, m.progressBar.SetPercent(m.percent)
+
 	case logMsg:
-		m.logMessages = append(m.logMessages, string(msg))
-		if len(m.logMessages) > 3000 {
-			m.logMessages = m.logMessages[1:]
-		}
-		if m.ready {
-			m.viewport.SetContent(colorizeLogs(m.logMessages))
-			if !m.done {
-				m.viewport.GotoBottom()
-			}
+		return m, m.appendLog(string(msg))
+
+	case logRefreshMsg:
+		m.log.scheduled = false
+		if m.log.dirty && m.ready {
+			m.refreshLog()
 		}
 		return m, nil
 
 	case statMsg:
-		m.stats.translated += msg.translated
-		m.stats.reused += msg.reused
-		m.stats.copied += msg.copied
-		m.stats.errors += msg.errors
-		m.stats.skipped += msg.skipped
+		m.stats.add(stats(msg))
 		return m, nil
 
 	case fileInfoMsg:
+		if m.parallel > 1 {
+			return m.updateFiles(msg), nil
+		}
 		m.fileName = msg.fileName
 		m.mode = msg.mode
 		m.totalRows = msg.totalRows
+		m.fileIndex = msg.fileIndex
+		m.fileCount = msg.fileCount
+		m.percent = 0
+		m.currentRow = 0
+		m.rate = throughput{}
+		return m, m.progressBar.SetPercent(0)
+
+	case reviewRequestMsg:
+		m.review = newReviewModel(msg)
+		return m, nil
+
+	case lockedFileMsg:
+		m.locked = append(m.locked, msg)
+		return m.Update(logMsg(fmt.Sprintf("ERROR: %v", msg.err)))
+
+	case retranslatedMsg:
+		if m.review != nil {
+			return m.updateReview(msg)
+		}
 		return m, nil
 
 	case doneMsg:
 		m.done = true
+		if m.log.dirty && m.ready {
+			m.refreshLog()
+		}
 		m.viewport.GotoBottom()
 		return m, nil
 
@@ -322,6 +457,35 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 }
 
+// updateReview passes msg to the review screen and hands the decisions back
+// to runTasks once the reviewer is done.
+func (m model) updateReview(msg tea.Msg) (tea.Model, tea.Cmd) {
+	finished, cmd := m.review.update(msg)
+	if finished {
+		close(m.review.req.done)
+		m.review = nil
+	}
+	return m, cmd
+}
+
+// updateLocked answers the oldest question about a locked output file.
+func (m model) updateLocked(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var choice lockChoice
+	switch msg.String() {
+	case "r":
+		choice = lockRetry
+	case "a":
+		choice = lockAlternate
+	case "w":
+		choice = lockWaitFree
+	default:
+		return m, nil
+	}
+	m.locked[0].reply <- choice
+	m.locked = m.locked[1:]
+	return m, nil
+}
+
 func (m model) View() string {
 	if m.err != nil {
 		return "\n" + errorBoxStyle.Render(fmt.Sprintf(" Error: %v ", m.err)) + "\n"
@@ -345,6 +509,12 @@ func (m model) View() string {
 	b.WriteString(renderProgress(m))
 	b.WriteString("\n")
 
+	// The review screen takes the place of the log and footer
+	if m.review != nil {
+		b.WriteString(viewportBoxStyle.Width(m.width - 2).Render(m.review.view(m.width - 4)))
+		return b.String()
+	}
+
 	// Viewport (logs) with border
 	viewportContent := m.viewport.View()
 	b.WriteString(viewportBoxStyle.Render(viewportContent))
@@ -374,6 +544,11 @@ func renderStatus(m model) string {
 
 	// Create a compact status line with separators
 	status := fmt.Sprintf("File: %s  |  Mode: %s  |  Rows: %d", fileStr, modeStr, m.totalRows)
+	if m.parallel > 1 {
+		status = fmt.Sprintf("Files: %d/%d done, %d running  |  Mode: %s  |  Rows: %d", m.fileIndex, m.fileCount, len(m.runningFiles()), modeStr, m.totalRows)
+	} else if m.fileCount > 1 {
+		status = fmt.Sprintf("File %d/%d: %s  |  Mode: %s  |  Rows: %d", m.fileIndex+1, m.fileCount, fileStr, modeStr, m.totalRows)
+	}
 	return statusBoxStyle.Render(status)
 }
 
@@ -381,13 +556,37 @@ func renderProgress(m model) string {
 	percent := int(m.percent * 100)
 	progressBar := m.progressBar.View()
 	statsLine := fmt.Sprintf("%3d%% (%d/%d)", percent, m.currentRow, m.totalRows)
+	if rate := m.rate.status(m.totalRows - m.currentRow); rate != "" && !m.done && m.currentRow < m.totalRows {
+		statsLine += "  " + rate
+	}
 
 	// Combine progress bar and stats
 	line := fmt.Sprintf("%s  %s", progressBar, statsLine)
+	if m.parallel > 1 {
+		// The main bar covers all files; each running file gets a line.
+		if files := renderFileSlots(m); files != "" {
+			line += "\n" + files
+		}
+	} else if m.fileCount > 1 {
+		overall := fmt.Sprintf("%s  %d/%d files", m.overallBar.View(), m.fileIndex, m.fileCount)
+		if m.done {
+			overall = fmt.Sprintf("%s  %d/%d files", m.overallBar.View(), m.fileCount, m.fileCount)
+		}
+		line += "\n" + overall
+	}
 	return progressBoxStyle.Render(line)
 }
 
 func renderFooter(m model) string {
+	// A locked output file needs an answer before anything else.
+	if len(m.locked) > 0 {
+		l := m.locked[0]
+		return errorBoxStyle.Render(fmt.Sprintf("The output of %s is open in another program, e.g. Excel.  r: close it and retry  |  a: save as \"...%s\"  |  w: wait until it is closed", filepath.Base(l.fileName), l.alternate))
+	}
+	// The search takes the footer while it is open or has a query.
+	if search := m.searchStatus(); search != "" {
+		return footerBoxStyle.Render(footerStyle.Render(search))
+	}
 	if m.done {
 		// Summary when complete
 		var parts []string
@@ -397,20 +596,23 @@ func renderFooter(m model) string {
 		if m.stats.skipped > 0 {
 			parts = append(parts, fmt.Sprintf("Skipped: %d", m.stats.skipped))
 		}
+		if strings.EqualFold(m.mode, "quick") {
+			parts = append(parts, fmt.Sprintf("Filled: %d", m.stats.translated+m.stats.reused+m.stats.copied))
+			parts = append(parts, fmt.Sprintf("Preserved: %d", m.stats.preserved))
+		}
+		if m.stats.flagged > 0 {
+			parts = append(parts, fmt.Sprintf("Flagged: %d", m.stats.flagged))
+		}
 		parts = append(parts, fmt.Sprintf("Errors: %d", m.stats.errors))
 		summary := "Complete!  " + strings.Join(parts, "  |  ")
 		return successBoxStyle.Render(summary)
 	}
 	// Keyboard shortcuts during translation
-	return footerBoxStyle.Render(footerStyle.Render("j/k: scroll  |  G: bottom  |  g: top  |  q: quit"))
-}
-
-func colorizeLogs(logs []string) string {
-	var colored []string
-	for _, msg := range logs {
-		colored = append(colored, colorizeLog(msg))
+	keys := "space: pause  |  s: skip row  |  r: retry failed  |  j/k: scroll  |  G/g: bottom/top  |  /: search  |  q q: stop and save"
+	if m.paused {
+		keys = "PAUSED  |  space: resume  |  " + strings.TrimPrefix(keys, "space: pause  |  ")
 	}
-	return strings.Join(colored, "\n")
+	return footerBoxStyle.Render(footerStyle.Render(keys))
 }
 
 func colorizeLog(msg string) string {
@@ -423,8 +625,10 @@ func colorizeLog(msg string) string {
 		return logStyleCopied.Render(msg)
 	case strings.HasPrefix(msg, "Translating:"):
 		return logStyleTranslating.Render(msg)
-	case strings.HasPrefix(msg, "Quick mode:"):
+	case strings.HasPrefix(msg, "Quick mode:") || strings.HasPrefix(msg, "Keeping"):
 		return logStyleSkipped.Render(msg)
+	case strings.HasPrefix(msg, "Flagged"):
+		return logStyleFlagged.Render(msg)
 	default:
 		return msg
 	}
@@ -432,6 +636,11 @@ func colorizeLog(msg string) string {
 
 // displayErrorAndExit shows an error in a TUI interface before exiting
 func displayErrorAndExit(err error) {
+	if headless {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitAborted)
+	}
+
 	// Create a simple TUI to display the error
 	errorModel := model{
 		err: err,
@@ -444,249 +653,449 @@ func displayErrorAndExit(err error) {
 	}
 
 	// Exit with error code after TUI closes
-	os.Exit(1)
+	os.Exit(exitAborted)
 }
 
-// hasUnderscoreNumberPattern checks if a text follows the pattern base_number
-func hasUnderscoreNumberPattern(text string) bool {
-	parts := strings.Split(text, "_")
-	if len(parts) < 2 {
-		return false
-	}
-	// Check if the last part is a number
-	_, err := strconv.Atoi(parts[len(parts)-1])
-	return err == nil
+func main() {
+	os.Exit(runCommand(os.Args[1:]))
 }
 
-// extractBaseAndSuffix splits a text into base and suffix parts
-// For "Discrete_alarm_66", returns ("Discrete_alarm", "66")
-func extractBaseAndSuffix(text string) (string, string) {
-	parts := strings.Split(text, "_")
-	if len(parts) < 2 {
-		return text, ""
+// translate runs the translate command: the forms, the translation and the
+// summary. It exits with the code of the run.
+func translate(args []string) {
+	// ///////////////////
+	// 1. GET USER INPUT
+	// ///////////////////
+	opts := parseFlags(args)
+	headless = opts.nonInteractive
+	interactive := !opts.nonInteractive
+
+	// A dry run never calls the API, so it needs no key.
+	var translator Translator
+	var embeddings embedder = ngramEmbedder{}
+	var err error
+	limiter := newRateLimiter(opts.rpm, opts.tpm)
+	if !opts.dryRun {
+		if translator, embeddings, err = newRunTranslator(opts, limiter, interactive); err != nil {
+			displayErrorAndExit(err)
+		}
 	}
-
-	lastIndex := len(parts) - 1
-	base := strings.Join(parts[:lastIndex], "_")
-	suffix := parts[lastIndex]
-
-	return base, suffix
-}
-
-// isVisualSeparator checks if text is mostly visual separators (dashes, underscores, etc.)
-func isVisualSeparator(text string) bool {
-	if len(text) < 5 {
-		return false
+	var review reviewer
+	if opts.reviewModel != "" && !opts.dryRun {
+		if review, err = newReviewer(opts, interactive); err != nil {
+			displayErrorAndExit(err)
+		}
 	}
-	separatorChars := 0
-	for _, char := range text {
-		if char == '-' || char == '_' || char == '=' || char == '*' || char == '.' {
-			separatorChars++
+	var verify verifier
+	if opts.selfCheck && !opts.dryRun {
+		if verify, err = newVerifier(translator, opts.provider); err != nil {
+			displayErrorAndExit(err)
 		}
 	}
-	return float64(separatorChars)/float64(len(text)) >= 0.8
-}
 
-func hasSpaceNumberPattern(text string) bool {
-	lastSpace := strings.LastIndex(text, " ")
-	if lastSpace == -1 || lastSpace == len(text)-1 {
-		return false
+	if interactive {
+		// Print welcome header
+		fmt.Println()
+		fmt.Println(headerBoxStyle.Render(headerStyle.Render(fmt.Sprintf("TIA Text Translator %s", getVersion()))))
+		fmt.Println()
+		fmt.Println(statusStyle.Render("Select options to begin translation..."))
+		fmt.Println()
 	}
-	_, err := strconv.Atoi(text[lastSpace+1:])
-	return err == nil
-}
 
-func extractSpaceBaseAndSuffix(text string) (string, string) {
-	lastSpace := strings.LastIndex(text, " ")
-	if lastSpace == -1 || lastSpace == len(text)-1 {
-		return text, ""
+	var fileNames []string
+	switch {
+	case opts.all:
+		if fileNames, err = listInputFiles(opts.dir); err != nil {
+			displayErrorAndExit(err)
+		}
+	case opts.file != "":
+		if fileNames, err = expandFileArg(opts.file); err != nil {
+			displayErrorAndExit(err)
+		}
+	case len(opts.files) > 0:
+		for _, file := range opts.files {
+			matches, err := expandFileArg(file)
+			if err != nil {
+				displayErrorAndExit(err)
+			}
+			fileNames = append(fileNames, matches...)
+		}
+	case opts.nonInteractive:
+		displayErrorAndExit(fmt.Errorf("--file or --all is required in non-interactive mode"))
+	default:
+		if fileNames, err = selectInputFiles(opts.dir); err != nil {
+			displayErrorAndExit(err)
+		}
 	}
-	base := text[:lastSpace]
-	suffix := text[lastSpace+1:]
-	return base, suffix
-}
 
-func shouldReuseTranslation(currentText, previousText string) (bool, string, string, string) {
-	if hasUnderscoreNumberPattern(currentText) && hasUnderscoreNumberPattern(previousText) {
-		currentBase, currentSuffix := extractBaseAndSuffix(currentText)
-		previousBase, _ := extractBaseAndSuffix(previousText)
-		if currentBase == previousBase {
-			return true, currentBase, currentSuffix, "_"
-		}
+	if opts.previousPath != "" && len(fileNames) > 1 {
+		displayErrorAndExit(fmt.Errorf("--previous is the earlier output of one file and cannot be used with several files"))
 	}
-	if hasSpaceNumberPattern(currentText) && hasSpaceNumberPattern(previousText) {
-		currentBase, currentSuffix := extractSpaceBaseAndSuffix(currentText)
-		previousBase, _ := extractSpaceBaseAndSuffix(previousText)
-		if currentBase == previousBase {
-			return true, currentBase, currentSuffix, " "
-		}
+	if opts.projectPath != "" && len(fileNames) > 1 {
+		displayErrorAndExit(fmt.Errorf("--project keeps the settings of one file and cannot be used with several files"))
 	}
-	if strings.Contains(currentText, "#") && strings.Contains(previousText, "#") {
-		currentParts := strings.SplitN(currentText, "#", 2)
-		previousParts := strings.SplitN(previousText, "#", 2)
-		if len(currentParts) == 2 && len(previousParts) == 2 && currentParts[0] == previousParts[0] {
-			return true, strings.TrimSpace(currentParts[0]), strings.TrimSpace(currentParts[1]), "#"
+
+	var tm *translationMemory
+	if opts.tmPath != "" {
+		if tm, err = openTM(opts.tmPath); err != nil {
+			displayErrorAndExit(err)
 		}
+		defer tm.Close()
 	}
-	return false, "", "", ""
-}
 
-func extractTranslatedBase(translation, delim string) string {
-	switch delim {
-	case "_":
-		base, _ := extractBaseAndSuffix(translation)
-		return base
-	case " ":
-		base, _ := extractSpaceBaseAndSuffix(translation)
-		return base
-	case "#":
-		parts := strings.SplitN(translation, "#", 2)
-		if len(parts) == 2 {
-			return parts[0]
+	// The first file is set up as usual; the rest reuse its sheet, columns
+	// (by header name) and mode, and are skipped with a warning if they
+	// don't fit.
+	var tasks []*fileTask
+	for i, fileName := range fileNames {
+		task, err := prepareFile(opts, fileName, tm, interactive && i == 0)
+		if err != nil {
+			if len(fileNames) == 1 {
+				displayErrorAndExit(err)
+			}
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", fileName, err)
+			continue
 		}
-		return translation
-	default:
-		return translation
+		if len(tasks) == 0 {
+			if opts.sheet != "*" {
+				opts.sheet = strings.Join(task.sheets, ",")
+			}
+			// --source and --target are looked up in each file anew.
+			if opts.sourceLang == "" {
+				opts.sourceCol = task.jobs[0].sourceLang
+			}
+			if opts.targetLang == "" {
+				opts.targetCol = strings.Join(task.targetLangs(), ",")
+			}
+			opts.mode = task.jobs[0].mode
+		}
+		tasks = append(tasks, task)
+	}
+	if len(tasks) == 0 {
+		displayErrorAndExit(fmt.Errorf("No files left to translate."))
 	}
-}
-
-// hasEmbeddedRefs checks if text contains /*...*/ style embedded references
-func hasEmbeddedRefs(text string) bool {
-	return strings.Contains(text, "/*") && strings.Contains(text, "*/")
-}
 
-// embeddedRefRegex matches /*...*/ patterns
-var embeddedRefRegex = regexp.MustCompile(`/\*[^*]*\*/`)
+	if interactive {
+		for _, task := range tasks {
+			if err := rememberSelection(task); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not remember the selections for %s: %v\n", task.fileName, err)
+			}
+		}
+	}
 
-// extractEmbeddedRefs extracts all embedded ref segments from text
-func extractEmbeddedRefs(text string) []string {
-	return embeddedRefRegex.FindAllString(text, -1)
-}
+	var proj *project
+	if opts.projectPath != "" {
+		proj = newProject(opts.projectPath, opts, tasks[0])
+		if err := proj.save(opts.projectPath); err != nil {
+			displayErrorAndExit(err)
+		}
+	}
 
-// splitTextByRefs splits text into alternating segments of text and refs
-// Even indices are text (translatable), odd indices are refs (preserve as-is)
-func splitTextByRefs(text string) []string {
-	if !hasEmbeddedRefs(text) {
-		return []string{text}
+	if opts.dryRun {
+		printDryRun(os.Stdout, tasks, opts)
+		return
 	}
 
-	var segments []string
-	lastEnd := 0
+	if opts.overwrite == "ask" {
+		if err := askOverwrites(tasks); err != nil {
+			displayErrorAndExit(err)
+		}
+	}
 
-	matches := embeddedRefRegex.FindAllStringIndex(text, -1)
+	if interactive {
+		// Show summary screen
+		summaryText := strings.Join(summaryLines(tasks, opts), "\n")
+
+		confirmVar := true
+		summaryForm := huh.NewForm(
+			huh.NewGroup(
+				huh.NewConfirm().
+					Title("Translation Summary").
+					Description(summaryText).
+					Affirmative("Start Translation").
+					Negative("Cancel").
+					Value(&confirmVar),
+			),
+		).WithTheme(formTheme)
+
+		if err := summaryForm.Run(); err != nil {
+			displayErrorAndExit(err)
+		}
 
-	for _, match := range matches {
-		start, end := match[0], match[1]
-		// Add text before this ref (even if empty)
-		segments = append(segments, text[lastEnd:start])
-		// Add the ref itself
-		segments = append(segments, text[start:end])
-		lastEnd = end
+		if !confirmVar {
+			fmt.Println("\nTranslation cancelled.")
+			os.Exit(exitAborted)
+		}
 	}
 
-	// Add any remaining text after the last ref (even if empty)
-	segments = append(segments, text[lastEnd:])
+	// ///////////////////
+	// 2. RUN TRANSLATION WITH TUI
+	// ///////////////////
+	// All jobs share one limiter, as they share the provider's limits.
+	fuzzy := newFuzzyMemory(tm, embeddings, opts.fuzzyTM)
+	var control *runControl
+	if !opts.nonInteractive && !opts.noTUI {
+		control = newRunControl()
+	}
+	for _, task := range tasks {
+		for j := range task.jobs {
+			task.jobs[j].limiter = limiter
+			task.jobs[j].fuzzy = fuzzy
+			task.jobs[j].control = control
+			task.jobs[j].reviewer = review
+			task.jobs[j].verifier = verify
+		}
+	}
 
-	return segments
-}
+	// Each file is saved as soon as it is done, see runTasks. Quitting the
+	// TUI or an interrupt signal cancels ctx, which stops the requests and
+	// saves what was translated so far.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if opts.budget != nil {
+		opts.budget.stop = cancel
+	}
+	result := &runResult{}
+	if opts.nonInteractive || opts.noTUI {
+		printer := &plainPrinter{out: os.Stdout}
+		runTasks(ctx, printer, translator, tasks, opts, result)
+	} else {
+		m := model{
+			progressBar: progress.New(progress.WithDefaultGradient()),
+			overallBar:  progress.New(progress.WithDefaultGradient()),
+			fileName:    tasks[0].fileName,
+			fileType:    tasks[0].fileType,
+			mode:        tasks[0].jobs[0].mode,
+			totalRows:   tasks[0].totalRows(),
+			fileCount:   len(tasks),
+			parallel:    min(opts.parallelFiles, len(tasks)),
+			control:     control,
+		}
+		if m.parallel > 1 {
+			// The main bar and the time left cover all files.
+			m.totalRows = 0
+			for _, task := range tasks {
+				m.totalRows += task.totalRows()
+			}
+		}
+		p := tea.NewProgram(m, tea.WithAltScreen())
+
+		finished := make(chan struct{})
+		go func() {
+			runTasks(ctx, p, translator, tasks, opts, result)
+			close(finished)
+		}()
+
+		_, err := p.Run()
+		cancel()
+		select {
+		case <-finished:
+		default:
+			fmt.Println("Stopping: waiting for running requests and saving the partial translation...")
+			<-finished
+		}
+		if err != nil {
+			displayErrorAndExit(fmt.Errorf("Error running program: %v", err))
+		}
+	}
 
-// reassembleWithRefs joins segments back together, preserving refs
-func reassembleWithRefs(segments []string) string {
-	return strings.Join(segments, "")
-}
+	if proj != nil {
+		proj.finished(opts.projectPath, result)
+		if err := proj.save(opts.projectPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
 
-func main() {
 	// ///////////////////
-	// 1. GET USER INPUT
+	// 3. REPORT SAVED FILES
 	// ///////////////////
-	csvOutput := flag.Bool("csv", false, "Output to a CSV file instead of XLSX for debugging.")
-	flag.Parse()
-
-	apiKey, err := getAPIKey()
-	if err != nil {
-		displayErrorAndExit(err)
+	saved := result.files()
+	partial := result.partialFiles()
+	if len(saved) == 0 && len(partial) == 0 {
+		displayErrorAndExit(fmt.Errorf("No translation was saved."))
+	}
+	for _, newFileName := range saved {
+		if opts.nonInteractive {
+			fmt.Printf("Translation saved to %s\n", newFileName)
+			continue
+		}
+		fmt.Println(successBoxStyle.Render(fmt.Sprintf("Translation saved to %s", newFileName)))
+	}
+	for _, newFileName := range partial {
+		msg := fmt.Sprintf("Cancelled. Partial translation saved to %s; run again with --resume to finish it.", newFileName)
+		if opts.budget.reached() {
+			msg = fmt.Sprintf("Budget used up (%s). Partial translation saved to %s; run again with --resume and a higher limit to finish it.", opts.budget.usage(), newFileName)
+		}
+		if opts.nonInteractive {
+			fmt.Println(msg)
+			continue
+		}
+		fmt.Println(statusBoxStyle.Render(msg))
 	}
 
-	if err := validateAPIKey(apiKey); err != nil {
-		displayErrorAndExit(fmt.Errorf("API key validation failed: %v. Please check your key and try again.", err))
+	// The plain progress lines already end with the summary; the TUI's is
+	// gone with its screen.
+	summary, code := result.summary()
+	if !opts.nonInteractive && !opts.noTUI {
+		fmt.Println(statusStyle.Render(summary.String()))
 	}
+	os.Exit(code)
+}
 
-	// Find both .xls and .xlsx files
-	xlsxFiles, err := filepath.Glob("*.xlsx")
-	if err != nil {
-		displayErrorAndExit(fmt.Errorf("Error finding .xlsx files: %v", err))
+// newRunTranslator sets up the provider selected by opts, with its key,
+// prompt and HTTP client, and checks the key. The embedder is the one the
+// fuzzy translation memory uses.
+func newRunTranslator(opts options, limiter *rateLimiter, interactive bool) (Translator, embedder, error) {
+	var prompt *template.Template
+	if opts.promptPath != "" {
+		var err error
+		if prompt, err = loadPromptTemplate(opts.promptPath); err != nil {
+			return nil, nil, err
+		}
 	}
-	xlsFiles, err := filepath.Glob("*.xls")
+	// A replayed run sends nothing, so it needs no key.
+	var apiKey string
+	if opts.cassette == nil || !opts.cassette.replay {
+		var err error
+		if apiKey, err = getAPIKey(opts.provider, interactive); err != nil {
+			return nil, nil, err
+		}
+	}
+	client, err := newHTTPClient(opts.proxy, opts.caCert)
 	if err != nil {
-		displayErrorAndExit(fmt.Errorf("Error finding .xls files: %v", err))
+		return nil, nil, err
+	}
+	if opts.cassette != nil {
+		client.Transport = opts.cassette.transport(client.Transport)
 	}
-	files := append(xlsxFiles, xlsFiles...)
 
-	var filteredFiles []string
-	for _, file := range files {
-		if !strings.HasPrefix(file, "translated-") {
-			filteredFiles = append(filteredFiles, file)
+	translator, err := newTranslator(opts.provider, providerConfig{apiKey: apiKey, baseURL: opts.baseURL, model: opts.model, prompt: prompt, domain: opts.domain, tone: opts.tone, limiter: limiter, client: client, budget: opts.budget})
+	if err != nil {
+		return nil, nil, err
+	}
+	var embeddings embedder = ngramEmbedder{}
+	if opts.fuzzyTM > 0 && opts.embeddings == "api" {
+		if embeddings, err = newAPIEmbedder(opts.provider, apiKey, opts.baseURL, opts.embeddingModel, client); err != nil {
+			return nil, nil, err
 		}
 	}
-
-	if len(filteredFiles) == 0 {
-		displayErrorAndExit(fmt.Errorf("No .xls or .xlsx files found to translate."))
+	if v, ok := translator.(validator); ok {
+		if err := validateKey(v, opts.requestTimeout); err != nil {
+			return nil, nil, fmt.Errorf("API key validation failed: %v. Please check your key and try again.", err)
+		}
 	}
+	return translator, embeddings, nil
+}
 
-	// Print welcome header
-	fmt.Println()
-	fmt.Println(headerBoxStyle.Render(headerStyle.Render(fmt.Sprintf("TIA Text Translator %s", getVersion()))))
-	fmt.Println()
-	fmt.Println(statusStyle.Render("Select options to begin translation..."))
-	fmt.Println()
+// selectInputFiles lists the spreadsheets in dir and lets the user pick
+// one, or all of them. Files elsewhere are picked by browsing the folders,
+// or by entering a path or pattern, e.g. on a network share.
+func selectInputFiles(dir string) ([]string, error) {
+	filteredFiles, err := listInputFiles(dir)
+	description := ""
+	if err != nil {
+		description = err.Error()
+	}
 
+	const (
+		allFiles = "\x00all"
+		browse   = "\x00browse"
+		enter    = "\x00enter"
+	)
 	var fileName string
-	var sourceLangIndex, targetLangIndex int
-	var translationMode string
-
-	fileOptions := make([]huh.Option[string], len(filteredFiles))
-	for i, f := range filteredFiles {
-		fileOptions[i] = huh.NewOption(f, f)
+	fileOptions := make([]huh.Option[string], 0, len(filteredFiles)+3)
+	for _, f := range filteredFiles {
+		fileOptions = append(fileOptions, huh.NewOption(f, f))
 	}
+	if len(filteredFiles) > 1 {
+		fileOptions = append(fileOptions, huh.NewOption(fmt.Sprintf("All %d files", len(filteredFiles)), allFiles))
+	}
+	fileOptions = append(fileOptions,
+		huh.NewOption("Browse other folders...", browse),
+		huh.NewOption("Enter a path or pattern...", enter),
+	)
 
 	form := huh.NewForm(
-		huh.NewGroup(huh.NewSelect[string]().Title("Select a file to translate").Options(fileOptions...).Value(&fileName)),
+		huh.NewGroup(huh.NewSelect[string]().Title("Select a file to translate").Description(description).Options(fileOptions...).Value(&fileName)),
 	).WithTheme(formTheme)
 
 	if err := form.Run(); err != nil {
-		displayErrorAndExit(err)
+		return nil, err
 	}
-
-	f, err := excelize.OpenFile(fileName)
-	if err != nil {
-		displayErrorAndExit(fmt.Errorf("Error opening file: %v", err))
+	switch fileName {
+	case allFiles:
+		return filteredFiles, nil
+	case browse:
+		picker := huh.NewFilePicker().
+			Title("Select a file to translate").
+			Description("enter opens a folder, backspace goes up").
+			CurrentDirectory(dir).
+			AllowedTypes(inputExtensions).
+			Height(15).
+			Picking(true).
+			Value(&fileName)
+		if err := huh.NewForm(huh.NewGroup(picker)).WithTheme(formTheme).Run(); err != nil {
+			return nil, err
+		}
+		return []string{fileName}, nil
+	case enter:
+		var pattern string
+		input := huh.NewInput().
+			Title("File to translate").
+			Description(`A path, or a pattern for several files, e.g. \\server\share\Project\*.xlsx`).
+			Validate(func(s string) error {
+				if strings.TrimSpace(s) == "" {
+					return fmt.Errorf("enter a path")
+				}
+				_, err := expandFileArg(strings.TrimSpace(s))
+				return err
+			}).
+			Value(&pattern)
+		if err := huh.NewForm(huh.NewGroup(input)).WithTheme(formTheme).Run(); err != nil {
+			return nil, err
+		}
+		return expandFileArg(strings.TrimSpace(pattern))
 	}
-	defer f.Close()
+	return []string{fileName}, nil
+}
 
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		displayErrorAndExit(fmt.Errorf("Error getting rows: %v", err))
+// metadataColumns returns how many leading columns hold metadata rather than
+// texts. metaCols (--meta-cols) wins if it is not -1. TIA Portal, older TIA
+// versions and WinCC Unified put different columns first, so for them it is
+// the number of columns before the first language-code header such as
+// "de-DE", or 4 if there is none.
+func metadataColumns(headers []string, fileType FileType, metaCols int) int {
+	if metaCols >= 0 {
+		return metaCols
 	}
-	headers := rows[0]
-
-	// Detect file type from headers
-	fileType := detectFileType(headers)
-	fmt.Println()
-	fmt.Println(statusBoxStyle.Render(fmt.Sprintf("Detected: %s", fileType.String())))
-	fmt.Println()
-
-	// Determine metadata columns based on file type
-	var metadataCols int
-	var skipRefColumns bool
 	switch fileType {
-	case FileTypeTIA:
-		metadataCols = 4
-		skipRefColumns = true
 	case FileTypeRockwell:
-		metadataCols = 5 // Server, Component Type, Component Name, Description, REF
-		skipRefColumns = false
+		return 5 // Server, Component Type, Component Name, Description, REF
+	case FileTypeTIAXML:
+		return 2 // MultilingualText ID, Composition
+	case FileTypePO:
+		return 2 // PO Line, Context
 	}
+	for i, h := range headers {
+		if isCultureCode(h) {
+			return i
+		}
+	}
+	return 4
+}
+
+// setupDefaults are what the setup form starts at.
+type setupDefaults struct {
+	source  int   // suggested source column; -1 for none
+	targets []int // target columns picked last time
+	mode    string
+}
+
+// runSetupForm asks for whichever of source column, target columns and mode
+// were not already given on the command line. The first metadataCols columns
+// are not offered. detected holds the language found in each column.
+func runSetupForm(headers []string, fileType FileType, metadataCols int, detected []string, defaults setupDefaults, sourceLangIndex *int, targetLangIndices *[]int, translationMode *string) error {
+	skipRefColumns := fileType == FileTypeTIA
 
 	// Build column options, skipping metadata and optionally ref columns
 	var colOptions []huh.Option[int]
@@ -697,7 +1106,11 @@ func main() {
 		if skipRefColumns && strings.HasPrefix(strings.ToLower(h), "ref=") {
 			continue // Skip ref columns in TIA
 		}
-		colOptions = append(colOptions, huh.NewOption(fmt.Sprintf("%s (Col %d)", h, i+1), i))
+		label := fmt.Sprintf("%s (Col %d)", h, i+1)
+		if mismatch := languageMismatch(headers, detected, i); mismatch != "" {
+			label += fmt.Sprintf(" - content looks %s", languageNames[detected[i]])
+		}
+		colOptions = append(colOptions, huh.NewOption(label, i))
 	}
 
 	modeOptions := []huh.Option[string]{
@@ -705,159 +1118,83 @@ func main() {
 		huh.NewOption("Quick (only empty/placeholder target texts)", "quick"),
 	}
 
-	setupForm := huh.NewForm(
-		huh.NewGroup(
-			huh.NewSelect[int]().Title("Select Source Language Column").Options(colOptions...).Value(&sourceLangIndex),
-			huh.NewSelect[int]().Title("Select Target Language Column").Options(colOptions...).Value(&targetLangIndex),
-			huh.NewSelect[string]().Title("Select Translation Mode").Options(modeOptions...).Value(&translationMode),
-		),
-	).WithTheme(formTheme)
-
-	if err := setupForm.Run(); err != nil {
-		displayErrorAndExit(err)
-	}
-
-	// Show summary screen
-	summaryLines := []string{
-		fmt.Sprintf("File:       %s", fileName),
-		fmt.Sprintf("Type:       %s", fileType.String()),
-		fmt.Sprintf("Source:     %s (Column %d)", headers[sourceLangIndex], sourceLangIndex+1),
-		fmt.Sprintf("Target:     %s (Column %d)", headers[targetLangIndex], targetLangIndex+1),
-		fmt.Sprintf("Mode:       %s", map[string]string{"full": "Full", "quick": "Quick"}[translationMode]),
-		fmt.Sprintf("Total rows: %d", len(rows)-1), // -1 for header
+	var fields []huh.Field
+	if *sourceLangIndex == -1 {
+		*sourceLangIndex = defaults.source
+		fields = append(fields, huh.NewSelect[int]().Title("Select Source Language Column").Options(colOptions...).Value(sourceLangIndex))
 	}
-	summaryText := strings.Join(summaryLines, "\n")
-
-	confirmVar := true
-	summaryForm := huh.NewForm(
-		huh.NewGroup(
-			huh.NewConfirm().
-				Title("Translation Summary").
-				Description(summaryText).
-				Affirmative("Start Translation").
-				Negative("Cancel").
-				Value(&confirmVar),
-		),
-	).WithTheme(formTheme)
-
-	if err := summaryForm.Run(); err != nil {
-		displayErrorAndExit(err)
-	}
-
-	if !confirmVar {
-		fmt.Println("\nTranslation cancelled.")
-		os.Exit(0)
-	}
-
-	// ///////////////////
-	// 2. RUN TRANSLATION WITH TUI
-	// ///////////////////
-	m := model{
-		progressBar: progress.New(progress.WithDefaultGradient()),
-		fileName:    fileName,
-		fileType:    fileType,
-		mode:        translationMode,
-		totalRows:   len(rows),
+	if len(*targetLangIndices) == 0 {
+		*targetLangIndices = slices.DeleteFunc(defaults.targets, func(col int) bool { return col == *sourceLangIndex })
+		fields = append(fields, huh.NewMultiSelect[int]().
+			Title("Select Target Language Columns").
+			Description("space to toggle, enter to confirm").
+			Options(colOptions...).
+			Validate(func(cols []int) error {
+				if len(cols) == 0 {
+					return fmt.Errorf("select at least one target column")
+				}
+				return nil
+			}).
+			Value(targetLangIndices))
 	}
-	p := tea.NewProgram(m, tea.WithAltScreen())
-
-	go iterateAndTranslate(p, apiKey, f, sheetName, rows, sourceLangIndex, targetLangIndex, headers[sourceLangIndex], headers[targetLangIndex], translationMode, fileType)
-
-	if _, err := p.Run(); err != nil {
-		displayErrorAndExit(fmt.Errorf("Error running program: %v", err))
+	if *translationMode == "" {
+		*translationMode = defaults.mode
+		fields = append(fields, huh.NewSelect[string]().Title("Select Translation Mode").Options(modeOptions...).Value(translationMode))
 	}
 
-	// ///////////////////
-	// 3. SAVE FILE
-	// ///////////////////
-	baseName := "translated-" + strings.TrimSuffix(fileName, filepath.Ext(fileName))
-	var newFileName string
-
-	if *csvOutput {
-		newFileName = baseName + ".csv"
-		if err := saveAsCSV(f, sheetName, newFileName); err != nil {
-			displayErrorAndExit(fmt.Errorf("Error saving new CSV file: %v", err))
-		}
-	} else {
-		newFileName = baseName + ".xlsx"
-		if err := f.SaveAs(newFileName); err != nil {
-			displayErrorAndExit(fmt.Errorf("Error saving new XLSX file: %v", err))
-		}
-	}
-
-	fmt.Println(successBoxStyle.Render(fmt.Sprintf("Translation saved to %s", newFileName)))
+	return huh.NewForm(huh.NewGroup(fields...)).WithTheme(formTheme).Run()
 }
 
-func translateText(client *openai.Client, text, sourceLang, targetLang string) (string, error) {
-	prompt := fmt.Sprintf("You are a professional translator. Translate the following text from '%s' to '%s'. Do not add any extra conversational text or quotation marks, just provide the translation. If the text is a placeholder or code, return it as is. The text to translate is: %s", sourceLang, targetLang, text)
-	resp, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
-		Model: openai.GPT4oMini,
-		Messages: []openai.ChatCompletionMessage{{
-			Role:    openai.ChatMessageRoleUser,
-			Content: prompt,
-		}},
-	})
+// keyFilePath returns where a key file is looked up: next to the
+// executable.
+func keyFilePath(keyFile string) (string, error) {
+	ex, err := os.Executable()
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("could not get executable path: %w", err)
 	}
-	translation := resp.Choices[0].Message.Content
-	return strings.Trim(translation, "\""), nil
+	return filepath.Join(filepath.Dir(ex), keyFile), nil
 }
 
-var meaninglessAlarmRegex = regexp.MustCompile(`(?i)^alarm\s+\d+:\s*$`) // For alarms like "Alarm 16: "
-
-func isPlaceholder(text string) bool {
-	switch {
-	case strings.HasPrefix(text, "##") && strings.HasSuffix(text, "##"):
-		return true
-	case strings.HasPrefix(text, "#") && strings.HasSuffix(text, "#") && len(text) > 1:
-		return true
-	case strings.HasPrefix(text, "@") && strings.HasSuffix(text, "@"):
-		return true
-	case meaninglessAlarmRegex.MatchString(text):
-		return true
-	default:
-		return false
+// validateKey checks the key with the provider, giving up after timeout
+// (0 = no limit).
+func validateKey(v validator, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
+	return v.Validate(ctx)
 }
 
-func saveAsCSV(f *excelize.File, sheetName, newFileName string) error {
-	file, err := os.Create(newFileName)
-	if err != nil {
-		return fmt.Errorf("failed to create CSV file: %w", err)
+// getAPIKey retrieves the API key for the given provider from one of the
+// following sources in order:
+// 1. The provider's environment variable (e.g. OPENAI_API_KEY, DEEPL_API_KEY)
+// 2. The system keychain (see "key set")
+// 3. The provider's key file in the executable's directory (e.g. api-key.txt)
+// 4. User prompt (only when allowPrompt is set)
+// Providers that need no key get an empty string.
+func getAPIKey(provider string, allowPrompt bool) (string, error) {
+	spec := providers[provider]
+	if spec.keyEnv == "" {
+		return "", nil
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
 
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return fmt.Errorf("failed to get rows from sheet: %w", err)
+	// 1. Check environment variable
+	if key := os.Getenv(spec.keyEnv); key != "" {
+		return key, nil
 	}
 
-	return writer.WriteAll(rows)
-}
-
-// getAPIKey retrieves the OpenAI API key from one of the following sources
-// in order:
-// 1. OPENAI_API_KEY environment variable
-// 2. api-key.txt file in the executable's directory
-// 3. User prompt
-func getAPIKey() (string, error) {
-	// 1. Check environment variable
-	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+	// 2. Check the system keychain
+	if key := keychainKey(provider); key != "" {
 		return key, nil
 	}
 
-	// 2. Check for api-key.txt file
-	// Get the directory of the executable
-	ex, err := os.Executable()
+	// 3. Check for the key file
+	keyPath, err := keyFilePath(spec.keyFile)
 	if err != nil {
-		return "", fmt.Errorf("could not get executable path: %w", err)
+		return "", err
 	}
-	exPath := filepath.Dir(ex)
-	keyPath := filepath.Join(exPath, "api-key.txt")
 
 	// Check if the file exists and read it
 	if _, err := os.Stat(keyPath); err == nil {
@@ -873,13 +1210,16 @@ func getAPIKey() (string, error) {
 		}
 	}
 
-	// 3. Prompt user for key
+	// 4. Prompt user for key
+	if !allowPrompt {
+		return "", fmt.Errorf("no API key found: set %s, store it with \"key set --provider %s\" or create %s next to the executable", spec.keyEnv, provider, spec.keyFile)
+	}
 	var apiKey string
 	form := huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
-				Title("OpenAI API Key Required").
-				Description("Enter your OpenAI API key (not stored).").
+				Title(fmt.Sprintf("%s API Key Required", spec.keyName)).
+				Description(fmt.Sprintf("Enter your %s API key (not stored; run \"key set --provider %s\" to keep it in the system keychain).", spec.keyName, provider)).
 				Value(&apiKey).
 				Password(true),
 		),
@@ -895,266 +1235,3 @@ func getAPIKey() (string, error) {
 
 	return apiKey, nil
 }
-
-// validateAPIKey makes a lightweight call to OpenAI to ensure the key is valid.
-func validateAPIKey(apiKey string) error {
-	client := openai.NewClient(apiKey)
-	// A simple, low-cost request to check for authentication.
-	_, err := client.ListModels(context.Background())
-	if err != nil {
-		// Check for a specific 401 Unauthorized error.
-		if apiErr, ok := err.(*openai.APIError); ok && apiErr.HTTPStatusCode == http.StatusUnauthorized {
-			return fmt.Errorf("the provided API key is invalid or has expired")
-		}
-		// Return a more generic error for other issues (e.g., network problems).
-		return fmt.Errorf("could not connect to OpenAI: %w", err)
-	}
-	return nil
-}
-
-func iterateAndTranslate(p *tea.Program, apiKey string, f *excelize.File, sheetName string, rows [][]string, sourceIndex, targetIndex int, sourceLang, targetLang string, translationMode string, fileType FileType) {
-	var stats struct {
-		translated int
-		reused     int
-		copied     int
-		errors     int
-		skipped    int
-	}
-	defer func() {
-		p.Send(statMsg{
-			translated: stats.translated,
-			reused:     stats.reused,
-			copied:     stats.copied,
-			errors:     stats.errors,
-			skipped:    stats.skipped,
-		})
-		if stats.skipped > 0 {
-			p.Send(logMsg(fmt.Sprintf("Skipped %d rows in quick mode.", stats.skipped)))
-		}
-		p.Send(doneMsg{})
-	}()
-
-	client := openai.NewClient(apiKey)
-	var previousText, previousTranslation string
-	totalRows := len(rows)
-
-	for i, row := range rows {
-		p.Send(progressMsg(float64(i+1) / float64(totalRows))) // Update progress
-
-		if i == 0 { // Skip header row
-			continue
-		}
-
-		if len(row) <= sourceIndex {
-			continue
-		}
-
-		sourceText := strings.TrimSpace(row[sourceIndex])
-		var targetText string
-		if len(row) > targetIndex {
-			targetText = strings.TrimSpace(row[targetIndex])
-		}
-
-		// Rockwell-specific: Handle **REF:N** patterns
-		if fileType == FileTypeRockwell {
-			// Check if source is a REF field
-			isSourceRef := strings.HasPrefix(sourceText, "**REF:") && strings.HasSuffix(sourceText, "**")
-			// Check if target is a REF field
-			isTargetRef := strings.HasPrefix(targetText, "**REF:") && strings.HasSuffix(targetText, "**")
-
-			if isSourceRef {
-				if isTargetRef {
-					// Both source and target are REF fields - skip
-					p.Send(logMsg(fmt.Sprintf("Rockwell: Skipping REF field (both source and target have REF)")))
-					continue
-				} else if targetText == "" {
-					// Source has REF, target is empty - copy source to target
-					cell, _ := excelize.CoordinatesToCellName(targetIndex+1, i+1)
-					f.SetCellValue(sheetName, cell, sourceText)
-					p.Send(logMsg(fmt.Sprintf("Rockwell: Copied REF to target: %s", sourceText)))
-					stats.copied++
-					time.Sleep(10 * time.Millisecond)
-					continue
-				}
-				// Source has REF, target has non-REF content - proceed to check if we should translate
-			}
-
-			// If target already has a REF, skip this row
-			if isTargetRef {
-				p.Send(logMsg(fmt.Sprintf("Rockwell: Skipping row (target has REF): %s", targetText)))
-				continue
-			}
-
-			// Rockwell-specific: Handle embedded refs /*...*/
-			if hasEmbeddedRefs(sourceText) {
-				// In quick mode, if target has same refs pattern, skip
-				if translationMode == "quick" && hasEmbeddedRefs(targetText) {
-					p.Send(logMsg(fmt.Sprintf("Rockwell: Skipping row (target already has embedded refs)")))
-					stats.skipped++
-					continue
-				}
-
-				// Split source into segments
-				segments := splitTextByRefs(sourceText)
-				var translatedSegments []string
-
-				for idx, segment := range segments {
-					if idx%2 == 0 {
-						// Even indices: text segment (translatable)
-						trimmed := strings.TrimSpace(segment)
-						if trimmed == "" {
-							translatedSegments = append(translatedSegments, segment)
-							continue
-						}
-
-						// Translate this text segment
-						p.Send(logMsg(fmt.Sprintf("Rockwell: Translating segment: %s", trimmed)))
-						translated, err := translateText(client, trimmed, sourceLang, targetLang)
-						if err != nil {
-							p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
-							translatedSegments = append(translatedSegments, segment)
-							stats.errors++
-						} else {
-							// Preserve spacing from original
-							if strings.HasPrefix(segment, " ") && !strings.HasPrefix(translated, " ") {
-								translated = " " + translated
-							}
-							if strings.HasSuffix(segment, " ") && !strings.HasSuffix(translated, " ") {
-								translated = translated + " "
-							}
-							translatedSegments = append(translatedSegments, translated)
-							stats.translated++
-						}
-					} else {
-						// Odd indices: ref segment (preserve as-is)
-						translatedSegments = append(translatedSegments, segment)
-					}
-				}
-
-				// Reassemble and save
-				translatedText := reassembleWithRefs(translatedSegments)
-				cell, _ := excelize.CoordinatesToCellName(targetIndex+1, i+1)
-				f.SetCellValue(sheetName, cell, translatedText)
-				p.Send(logMsg(fmt.Sprintf("Rockwell: Saved with embedded refs")))
-				time.Sleep(50 * time.Millisecond)
-				continue
-			}
-		}
-
-		// Skip rows with empty source AND empty target
-		if sourceText == "" && targetText == "" {
-			continue
-		}
-
-		// Skip translating the default "Text" value from TIA Portal.
-		if strings.EqualFold(sourceText, "Text") {
-			continue
-		}
-
-		if isPlaceholder(sourceText) {
-			p.Send(logMsg(fmt.Sprintf("Copied placeholder: %s", sourceText)))
-			cell, _ := excelize.CoordinatesToCellName(targetIndex+1, i+1)
-			f.SetCellValue(sheetName, cell, sourceText)
-			stats.copied++
-			time.Sleep(10 * time.Millisecond) // Slow down for UI
-			continue
-		}
-
-		// Copy short texts and numerals in both modes
-		if len(sourceText) < 3 || (len(sourceText) > 0 && sourceText[0] == '!') {
-			p.Send(logMsg(fmt.Sprintf("Copying short text: %s", sourceText)))
-			cell, _ := excelize.CoordinatesToCellName(targetIndex+1, i+1)
-			f.SetCellValue(sheetName, cell, sourceText)
-			stats.copied++
-			time.Sleep(10 * time.Millisecond) // Slow down for UI
-			continue
-		}
-		if _, err := strconv.Atoi(sourceText); err == nil {
-			p.Send(logMsg(fmt.Sprintf("Copying numeral: %s", sourceText)))
-			cell, _ := excelize.CoordinatesToCellName(targetIndex+1, i+1)
-			f.SetCellValue(sheetName, cell, sourceText)
-			stats.copied++
-			time.Sleep(10 * time.Millisecond) // Slow down for UI
-			continue
-		}
-
-		// Skip visual separators (mostly dashes, underscores, etc.)
-		if isVisualSeparator(sourceText) {
-			p.Send(logMsg(fmt.Sprintf("Skipping visual separator: %s", sourceText)))
-			continue
-		}
-
-		// Quick mode: Only translate if target cell is empty or just "Text"
-		if translationMode == "quick" {
-			if len(row) > targetIndex {
-				targetTextForCheck := strings.ToLower(strings.Trim(targetText, `"`))
-
-				// Skip if target has meaningful content (not empty and not "text")
-				shouldSkip := targetTextForCheck != "" && targetTextForCheck != "text"
-
-				if shouldSkip {
-					p.Send(logMsg(fmt.Sprintf("Quick mode: skipping row %d", i+1)))
-					stats.skipped++
-					continue
-				}
-			}
-		}
-
-		var translatedText string
-		var err error
-		var isReused bool
-
-		// If current text is exactly the same as previous text, reuse translation
-		if sourceText == previousText && previousTranslation != "" {
-			translatedText = previousTranslation
-			p.Send(logMsg(fmt.Sprintf("Reused identical translation for: %s", sourceText)))
-			isReused = true
-			goto saveAndContinue
-		}
-
-		// Check if we can reuse translation based on pattern matching
-		if shouldReuse, _, currentSuffix, delim := shouldReuseTranslation(sourceText, previousText); shouldReuse {
-			translatedPreviousBase := extractTranslatedBase(previousTranslation, delim)
-			if _, err := strconv.Atoi(currentSuffix); err == nil {
-				// Suffix is a number, reuse the translated base
-				translatedText = translatedPreviousBase + delim + currentSuffix
-				p.Send(logMsg(fmt.Sprintf("Reused base for: %s", sourceText)))
-				isReused = true
-			} else {
-				// Suffix is not a number, translate it
-				p.Send(logMsg(fmt.Sprintf("Translating suffix: %s", currentSuffix)))
-				suffixTranslation, err := translateText(client, currentSuffix, sourceLang, targetLang)
-				if err != nil {
-					p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
-					translatedText = sourceText
-					stats.errors++
-				} else {
-					translatedText = translatedPreviousBase + delim + suffixTranslation
-					stats.translated++
-				}
-			}
-			goto saveAndContinue
-		}
-
-		p.Send(logMsg(fmt.Sprintf("Translating: %s", sourceText)))
-		translatedText, err = translateText(client, sourceText, sourceLang, targetLang)
-		if err != nil {
-			p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
-			stats.errors++
-			continue
-		}
-		stats.translated++
-
-	saveAndContinue:
-		cell, _ := excelize.CoordinatesToCellName(targetIndex+1, i+1)
-		f.SetCellValue(sheetName, cell, translatedText)
-
-		if isReused {
-			stats.reused++
-		}
-
-		previousText = sourceText
-		previousTranslation = translatedText
-		time.Sleep(50 * time.Millisecond) // Rate limit and slow down for UI
-	}
-}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ///////////////////
+// MARKUP
+// ///////////////////
+
+// WinCC texts may carry HTML-like markup such as <b>, <br/> or
+// <span style="color:#FF0000">. The tags, and the protected terms, are
+// swapped for numbered markers before a text is sent, so the model can
+// neither translate nor drop them silently, and put back into the reply.
+
+var (
+	markupTagRegex = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9:-]*(?:\s[^<>]*)?/?>`)
+	tagMarkerRegex = regexp.MustCompile(`\{\{\s*(\d+)\s*\}\}`)
+)
+
+// voidElements never have a closing tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// protectTags replaces the markup tags and protected terms in text with
+// the markers {{1}}, {{2}}, ... and returns them in marker order. terms may
+// be nil for the built-in codes only. Texts that already contain "{{" are
+// left alone, so a marker can't be mistaken for text.
+func protectTags(text string, terms *protectedTerms) (string, []string) {
+	if strings.Contains(text, "{{") {
+		return text, nil
+	}
+	var b strings.Builder
+	var tags []string
+	last := 0
+	for _, s := range terms.protectedSpans(text, true) {
+		tags = append(tags, text[s[0]:s[1]])
+		b.WriteString(text[last:s[0]])
+		fmt.Fprintf(&b, "{{%d}}", len(tags))
+		last = s[1]
+	}
+	b.WriteString(text[last:])
+	return b.String(), tags
+}
+
+// restoreTags puts the tags back in place of their markers. Markers the
+// model made up are left as they are; the placeholder check flags them
+// together with any tag that went missing.
+func restoreTags(text string, tags []string) string {
+	if len(tags) == 0 {
+		return text
+	}
+	return tagMarkerRegex.ReplaceAllStringFunc(text, func(marker string) string {
+		n, _ := strconv.Atoi(tagMarkerRegex.FindStringSubmatch(marker)[1])
+		if n < 1 || n > len(tags) {
+			return marker
+		}
+		return tags[n-1]
+	})
+}
+
+// markerInstructions explains the tag markers to the model if any of texts
+// has them.
+func markerInstructions(texts ...string) string {
+	for _, text := range texts {
+		if tagMarkerRegex.MatchString(text) {
+			return " Markers such as {{1}} stand for formatting tags, addresses, codes and units: keep every one of them unchanged, placed around or next to the same words in the translation."
+		}
+	}
+	return ""
+}
+
+// tagsBalanced reports whether every opening tag in text is closed, in the
+// right order. Void elements and self-closing tags need no closing tag.
+func tagsBalanced(text string) bool {
+	var open []string
+	for _, tag := range markupTagRegex.FindAllString(text, -1) {
+		closing := strings.HasPrefix(tag, "</")
+		name := strings.TrimLeft(tag, "</")
+		if i := strings.IndexAny(name, " \t\r\n/>"); i >= 0 {
+			name = name[:i]
+		}
+		name = strings.ToLower(name)
+		switch {
+		case closing:
+			if len(open) == 0 || open[len(open)-1] != name {
+				return false
+			}
+			open = open[:len(open)-1]
+		case strings.HasSuffix(tag, "/>") || voidElements[name]:
+		default:
+			open = append(open, name)
+		}
+	}
+	return len(open) == 0
+}
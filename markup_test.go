@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestProtectTags(t *testing.T) {
+	tests := []struct {
+		text   string
+		masked string
+		tags   []string
+	}{
+		{"Motor fault", "Motor fault", nil},
+		{"<b>Stop</b> the pump<br/>now", "{{1}}Stop{{2}} the pump{{3}}now", []string{"<b>", "</b>", "<br/>"}},
+		{`<span style="color:#FF0000">Alarm</span>`, "{{1}}Alarm{{2}}", []string{`<span style="color:#FF0000">`, "</span>"}},
+		{"a < b > c", "a < b > c", nil},
+		{"{{1}} <b>x</b>", "{{1}} <b>x</b>", nil},
+	}
+	for _, tt := range tests {
+		masked, tags := protectTags(tt.text, nil)
+		if masked != tt.masked || !reflect.DeepEqual(tags, tt.tags) {
+			t.Errorf("protectTags(%q) = %q, %q; want %q, %q", tt.text, masked, tags, tt.masked, tt.tags)
+		}
+		if got := restoreTags(masked, tags); got != tt.text {
+			t.Errorf("restoreTags(%q) = %q, want %q", masked, got, tt.text)
+		}
+	}
+
+	// The model may move markers, space them out or invent new ones.
+	tags := []string{"<b>", "</b>"}
+	if got := restoreTags("Die Pumpe {{ 1 }}stoppen{{2}} {{3}}", tags); got != "Die Pumpe <b>stoppen</b> {{3}}" {
+		t.Errorf("restoreTags = %q", got)
+	}
+}
+
+func TestTagsBalanced(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"plain text", true},
+		{"<b>bold</b> and <i>italic</i>", true},
+		{"<b><i>both</i></b>", true},
+		{"line<br>break<br/>", true},
+		{"<B>bold</b>", true},
+		{"<b>bold", false},
+		{"<b><i>crossed</b></i>", false},
+		{"bold</b>", false},
+	}
+	for _, tt := range tests {
+		if got := tagsBalanced(tt.text); got != tt.want {
+			t.Errorf("tagsBalanced(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMarkupProtectedInPipeline(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"<b>Pumpe</b> aus", ""},
+	}
+	job := newTestJob(t, rows)
+	translator := &upperTranslator{}
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	if want := []string{"{{1}}Pumpe{{2}} aus"}; !reflect.DeepEqual(translator.calls, want) {
+		t.Errorf("sent %q, want %q", translator.calls, want)
+	}
+	if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != "<b>PUMPE</b> AUS" {
+		t.Errorf("B2 = %q", got)
+	}
+}
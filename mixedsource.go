@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ///////////////////
+// MIXED SOURCE LANGUAGE
+// ///////////////////
+
+// Engineers sometimes type English into the German column. Sent as German,
+// such a text comes back unchanged or garbled. With --mixed-source, the
+// language of every source text is checked: "flag" leaves rows in another
+// language for a human, "translate" translates them from the language they
+// are written in.
+
+// Values of --mixed-source.
+const (
+	mixedSourceFlag      = "flag"
+	mixedSourceTranslate = "translate"
+)
+
+// foreignSource returns the language code text is written in if that is
+// clearly not the source language of j, or "" if it is or cannot be told.
+// Short texts usually cannot.
+func (j *translationJob) foreignSource(text string) string {
+	if j.mixedSource == "" {
+		return ""
+	}
+	got := detectTextLanguage(text)
+	if !contradicts(headerLanguage(j.sourceLang), got) {
+		return ""
+	}
+	return got
+}
+
+// planForeignSource handles a source text in another language while rows
+// are planned. A row to flag is skipped and one already in the target
+// language copied, which reports handled. Otherwise it returns the
+// language to translate the row from, or "" for the source language.
+func (j *translationJob) planForeignSource(p msgSender, i int, source, target string, stats *stats) (lang string, handled bool) {
+	got := j.foreignSource(source)
+	if got == "" {
+		return "", false
+	}
+	name := languageNames[got]
+	switch {
+	case j.mixedSource == mixedSourceFlag:
+		p.Send(logMsg(fmt.Sprintf("Skipping row %d, its source looks like %s: %s", i+1, name, source)))
+		copied := j.copySource(i)
+		j.note(i, source, target, rowSkipped, withCopied(fmt.Sprintf("source looks like %s, not %s", name, j.sourceLang), copied))
+		stats.skipped++
+		return "", true
+	case got == headerLanguage(j.targetLang):
+		p.Send(logMsg(fmt.Sprintf("Copying row %d, its source is already %s: %s", i+1, name, source)))
+		j.note(i, source, source, rowCopied, "source is already "+name)
+		j.setCell(i, j.rows[i][j.sourceIndex])
+		stats.copied++
+		return "", true
+	}
+	return cultureFor(j.rows[0], got), false
+}
+
+// cultureFor returns the header of the column in language lang, e.g. "en-US"
+// for "en", so the provider gets a full culture code. Without such a column
+// it returns lang.
+func cultureFor(headers []string, lang string) string {
+	for _, h := range headers {
+		if headerLanguage(h) == lang {
+			return strings.TrimSuffix(strings.TrimSpace(h), "*")
+		}
+	}
+	return lang
+}
+
+// fromLanguage returns a copy of j that translates from lang, for a row
+// whose source text is written in it.
+func (j *translationJob) fromLanguage(lang string) *translationJob {
+	row := *j
+	row.sourceLang = lang
+	row.via = viaFor(j.via, lang, j.targetLang)
+	row.session = nil // its texts are in another language
+	return &row
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// lengthTranslator replies with the target language and the length of the
+// text, which reads as no language, and records the language pairs.
+type lengthTranslator struct {
+	mu    sync.Mutex
+	pairs []string
+}
+
+func (l *lengthTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	l.mu.Lock()
+	l.pairs = append(l.pairs, sourceLang+">"+targetLang)
+	l.mu.Unlock()
+	return fmt.Sprintf("%s:%d", targetLang, utf8.RuneCountInString(text)), nil
+}
+
+func TestMixedSource(t *testing.T) {
+	tests := []struct {
+		mode, targetLang string
+		want             string // B3, the row typed in English
+		wantPair         string // request made for it
+		wantStatus       rowStatus
+	}{
+		{"", "fr-FR", "fr-FR:29", "de-DE>fr-FR", rowTranslated},
+		{mixedSourceFlag, "fr-FR", "", "", rowSkipped},
+		{mixedSourceTranslate, "fr-FR", "fr-FR:29", "en-US>fr-FR", rowTranslated},
+		{mixedSourceTranslate, "en-US", "Pump fault, pressure too high", "", rowCopied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.targetLang, func(t *testing.T) {
+			rows := [][]string{
+				{"de-DE", tt.targetLang, "en-US"},
+				{"Druck zu hoch im Behälter", "", ""},
+				{"Pump fault, pressure too high", "", ""},
+				{"Motor", "", ""},
+			}
+			job := newTestJob(t, rows)
+			job.targetLang = tt.targetLang
+			job.mixedSource = tt.mode
+			job.report = &fileReport{}
+			translator := &lengthTranslator{}
+
+			iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+			if got, _ := job.f.GetCellValue(job.sheetName, "B3"); got != tt.want {
+				t.Errorf("B3 = %q, want %q", got, tt.want)
+			}
+			if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != tt.targetLang+":25" {
+				t.Errorf("B2 = %q", got)
+			}
+			pairs := strings.Join(translator.pairs, " ")
+			if tt.wantPair != "" && !strings.Contains(pairs, tt.wantPair) {
+				t.Errorf("requests = %s, want %s", pairs, tt.wantPair)
+			}
+			if n := len(translator.pairs); tt.wantPair == "" && n != 2 || tt.wantPair != "" && n != 3 {
+				t.Errorf("requests = %s", pairs)
+			}
+			for _, e := range job.report.entries {
+				if e.row != 3 {
+					continue
+				}
+				if e.status != tt.wantStatus {
+					t.Errorf("status = %q, want %q", e.status, tt.wantStatus)
+				}
+				flagged := strings.Contains(strings.Join(e.flags, ";"), "looks like English")
+				if flagged != (tt.mode == mixedSourceTranslate && tt.wantStatus == rowTranslated) {
+					t.Errorf("flags = %q, detail = %q", e.flags, e.detail)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectTextLanguage(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"Pump fault, pressure too high", "en"},
+		{"Druck zu hoch im Behälter", "de"},
+		{"Motor", ""},
+		{"Ventil offen", ""},
+	}
+	for _, tt := range tests {
+		if got := detectTextLanguage(tt.text); got != tt.want {
+			t.Errorf("detectTextLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
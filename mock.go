@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ///////////////////
+// MOCK PROVIDER
+// ///////////////////
+
+// --provider mock translates without a network, key or cost: it returns
+// pseudo-translations that are the same on every run, so the whole
+// pipeline, the TUI and the output files can be tried out, and end-to-end
+// tests need no API. --model picks the style and an optional delay per
+// request, e.g. "reverse:300ms".
+
+func init() {
+	registerProvider("mock", providerSpec{
+		factory: func(cfg providerConfig) (Translator, error) {
+			return newMockTranslator(cfg.model, cfg.budget)
+		},
+	})
+}
+
+// Styles of the mock provider.
+const (
+	mockPrefix  = "prefix"  // "[en-US] Motor fault"
+	mockReverse = "reverse" // "tluaf rotoM", placeholders left as they are
+)
+
+type mockTranslator struct {
+	style  string
+	delay  time.Duration // slept before each reply, to watch the TUI at work
+	budget *budget
+}
+
+// newMockTranslator parses a model of the form STYLE[:DELAY].
+func newMockTranslator(model string, b *budget) (*mockTranslator, error) {
+	style, delay, hasDelay := strings.Cut(model, ":")
+	if style == "" {
+		style = mockPrefix
+	}
+	if style != mockPrefix && style != mockReverse {
+		return nil, fmt.Errorf("unknown mock model %q (available: %s, %s, optionally followed by a delay such as :200ms)", style, mockPrefix, mockReverse)
+	}
+	t := &mockTranslator{style: style, budget: b}
+	if hasDelay {
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid mock delay %q, e.g. 200ms", delay)
+		}
+		t.delay = d
+	}
+	return t, nil
+}
+
+// pseudoTranslate is the mock translation of text into targetLang. It
+// honours the length limit of a retry, like a model would.
+func (t *mockTranslator) pseudoTranslate(ctx context.Context, text, targetLang string) string {
+	var result string
+	switch t.style {
+	case mockReverse:
+		result = reverseAroundPlaceholders(text)
+	default:
+		result = fmt.Sprintf("[%s] %s", targetLang, text)
+	}
+	if limit := promptHintsFrom(ctx).maxLength; limit > 0 && utf8.RuneCountInString(result) > limit {
+		result = string([]rune(result)[:limit])
+	}
+	return result
+}
+
+// reverseAroundPlaceholders reverses the characters of text between its
+// placeholders and markers, which stay where they are.
+func reverseAroundPlaceholders(text string) string {
+	var b strings.Builder
+	last := 0
+	reverse := func(s string) {
+		runes := []rune(s)
+		slices.Reverse(runes)
+		b.WriteString(string(runes))
+	}
+	for _, loc := range placeholderTokenRegex.FindAllStringIndex(text, -1) {
+		reverse(text[last:loc[0]])
+		b.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	reverse(text[last:])
+	return b.String()
+}
+
+// respond waits the delay and accounts for a request as if it had sent
+// input and received output, so token counts and --max-tokens work.
+func (t *mockTranslator) respond(ctx context.Context, input, output string) error {
+	if t.delay > 0 {
+		if err := sleepContext(ctx, t.delay); err != nil {
+			return err
+		}
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+	in, out := estimateTokens(input)+promptOverheadTokens, estimateTokens(output)
+	countTokens(ctx, in+out)
+	t.budget.spend(in, out, 0, 1)
+	return nil
+}
+
+func (t *mockTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	result := t.pseudoTranslate(ctx, text, targetLang)
+	if err := t.respond(ctx, text, result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+func (t *mockTranslator) TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	results := make([]string, len(texts))
+	for i, text := range texts {
+		results[i] = t.pseudoTranslate(ctx, text, targetLang)
+	}
+	if err := t.respond(ctx, strings.Join(texts, "\n"), strings.Join(results, "\n")); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Review confirms every draft, so --review-model mock can be tried.
+func (t *mockTranslator) Review(ctx context.Context, source, draft, sourceLang, targetLang string) (reviewResult, error) {
+	if err := t.respond(ctx, source+draft, draft); err != nil {
+		return reviewResult{}, err
+	}
+	return reviewResult{translation: draft, confidence: confidenceHigh}, nil
+}
+
+// Rate gives every translation full marks, so --self-check can be tried.
+func (t *mockTranslator) Rate(ctx context.Context, source, translation, sourceLang, targetLang string) (rating, error) {
+	if err := t.respond(ctx, source+translation, ""); err != nil {
+		return rating{}, err
+	}
+	return rating{terminology: 5, completeness: 5, placeholders: 5}, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestMockTranslator(t *testing.T) {
+	tests := []struct {
+		model string
+		text  string
+		limit int
+		want  string
+	}{
+		{"", "Motor fault", 0, "[en-US] Motor fault"},
+		{"prefix", "Motor fault", 12, "[en-US] Moto"},
+		{"reverse", "Motor fault", 0, "tluaf rotoM"},
+		{"reverse:1ms", "Level @1%s@ of {0} reached", 0, " leveL@1%s@ fo {0}dehcaer "},
+	}
+	for _, tt := range tests {
+		translator, err := newMockTranslator(tt.model, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		if tt.limit > 0 {
+			ctx = withPromptHints(ctx, promptHints{maxLength: tt.limit})
+		}
+		got, err := translator.Translate(ctx, tt.text, "de-DE", "en-US")
+		if err != nil || got != tt.want {
+			t.Errorf("%s: Translate(%q) = %q, %v; want %q", tt.model, tt.text, got, err, tt.want)
+		}
+	}
+
+	for _, model := range []string{"pirate", "prefix:soon", "prefix:-1s"} {
+		if _, err := newMockTranslator(model, nil); err == nil {
+			t.Errorf("newMockTranslator(%q) succeeded", model)
+		}
+	}
+
+	translator, _ := newMockTranslator("", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := translator.Translate(ctx, "Motor", "de-DE", "en-US"); err == nil {
+		t.Error("Translate succeeded after cancel")
+	}
+}
+
+// TestMockProviderEndToEnd runs a file through the registered provider, as
+// --provider mock does, with batches and the draft review.
+func TestMockProviderEndToEnd(t *testing.T) {
+	translator, err := newTranslator("mock", providerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "TextExport.xlsx")
+	writeSheet(t, path, [][]string{{"de-DE", "en-US"}, {"Pumpe", ""}, {"Ventil {0}", ""}, {"42", ""}})
+	opts := options{workers: 2, batchSize: 10, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US", nonInteractive: true}
+	task, err := prepareFile(opts, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := range task.jobs {
+		task.jobs[j].reviewer = translator.(reviewer)
+		task.jobs[j].verifier = translator.(verifier)
+	}
+
+	result := &runResult{}
+	runTasks(context.Background(), discardSender{}, translator, []*fileTask{task}, opts, result)
+	output := filepath.Join(dir, "translated-TextExport.xlsx")
+	if saved := result.files(); !reflect.DeepEqual(saved, []string{output}) {
+		t.Fatalf("saved %v; expected %s", saved, output)
+	}
+	f, err := excelize.OpenFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for cell, want := range map[string]string{"B2": "[en-US] Pumpe", "B3": "[en-US] Ventil {0}", "B4": "42"} {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+}
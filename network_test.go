@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewHTTPClientCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client, err := newHTTPClient("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Get(server.URL)
+	if err == nil {
+		t.Fatal("request to a server with an unknown CA succeeded")
+	}
+	if hint := networkHint(err); !strings.Contains(hint, "--ca-cert") {
+		t.Errorf("networkHint(%v) = %q; expected a --ca-cert hint", err, hint)
+	}
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caPath, cert, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client, err = newHTTPClient("", caPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with --ca-cert failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := os.WriteFile(caPath, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newHTTPClient("", caPath); err == nil {
+		t.Error("newHTTPClient() accepted a --ca-cert without certificates")
+	}
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	var proxied atomic.Int32
+	var auth atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		auth.Store(r.Header.Get("Proxy-Authorization"))
+	}))
+	defer proxy.Close()
+
+	client, err := newHTTPClient(strings.Replace(proxy.URL, "http://", "http://user:secret@", 1), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://api.example.invalid/v1/models", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if proxied.Load() != 1 {
+		t.Errorf("proxy saw %d requests; expected 1", proxied.Load())
+	}
+	if got, _ := auth.Load().(string); !strings.HasPrefix(got, "Basic ") {
+		t.Errorf("Proxy-Authorization = %q; expected the credentials from --proxy", got)
+	}
+
+	for _, bad := range []string{"proxy:8080", "ftp://proxy:21", "http://"} {
+		if _, err := newHTTPClient(bad, ""); err == nil {
+			t.Errorf("newHTTPClient(%q) succeeded; expected an error", bad)
+		}
+	}
+}
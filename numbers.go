@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ///////////////////
+// NUMBER CHECKS
+// ///////////////////
+
+// A model sometimes "fixes" 24VDC into 24V DC, drops a tolerance or writes a
+// different value, which is easy to miss in review. So the numbers of each
+// translation are compared with those of its source: plain numbers by their
+// digits and decimal point, so 0,5 and 0.5 or 1.000 and 1,000 count as the
+// same but 1.5 and 15 do not, and numbers with a unit such as 24VDC, 5 % or
+// ±0.5 mm by digits, tolerance sign and unit, whether there is a space before
+// the unit or not. I/O addresses, order numbers and tag references are
+// protected terms and flagged when lost already; here they only count when
+// the translation has one the source does not. Numbers inside placeholders
+// and words (M12, Motor_3) are left to the other checks.
+
+// codeRegex matches the addresses, order numbers and tag references of
+// codePatterns.
+var codeRegex = regexp.MustCompile(strings.Join(codePatterns, "|"))
+
+// quantityNumberRegex finds numbers with digit groups and decimals, and a
+// tolerance sign before them.
+var quantityNumberRegex = regexp.MustCompile(`(?:±\s?)?\d+(?:[.,\x{00A0}\x{202F}]\d+)*`)
+
+// unitRegex finds a unit right after a number. Symbols that are also words
+// in some language, such as s, h or m, are left out, and A only counts
+// without a space, as in "Motor 1 A" it is rather a letter.
+var unitRegex = regexp.MustCompile(`^(?:[ \x{00A0}\x{202F}]?(?:VDC|VAC|kVA|kV|mV|V|mA|kW|W|kHz|Hz|rpm|mbar|bar|psi|°C|°F|Nm|mm|ms|%)|A)`)
+
+// unitSpaces are the spaces allowed between a number and its unit.
+const unitSpaces = "\u0020\u00a0\u202f"
+
+// numberToken is a number as written and in the form it is compared in,
+// e.g. "±0.5mm" for "± 0,5 mm".
+type numberToken struct {
+	text, key string
+}
+
+// numberTokens lists the numbers of text outside placeholders and,
+// separately, the codes of codeRegex.
+func numberTokens(text string) (numbers, codes []numberToken) {
+	text = textOutsidePlaceholders(text)
+	text = codeRegex.ReplaceAllStringFunc(text, func(code string) string {
+		codes = append(codes, numberToken{text: code, key: code})
+		return strings.Repeat(" ", len(code))
+	})
+	for _, loc := range quantityNumberRegex.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if before, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && (unicode.IsLetter(before) || before == '_') {
+			continue
+		}
+		key := numberDigits(text[start:end])
+		if unit := unitRegex.FindString(text[end:]); unit != "" {
+			after, _ := utf8.DecodeRuneInString(text[end+len(unit):])
+			if end+len(unit) == len(text) || !unicode.IsLetter(after) && !unicode.IsDigit(after) {
+				key += strings.TrimLeft(unit, unitSpaces)
+				end += len(unit)
+			}
+		}
+		if after, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && (unicode.IsLetter(after) || after == '_') {
+			continue
+		}
+		numbers = append(numbers, numberToken{text: text[start:end], key: key})
+	}
+	return numbers, codes
+}
+
+// numberDigits keeps the tolerance sign, the digits and the decimal point
+// of a number. The last "." or "," is a decimal point when one or two
+// digits follow it; any other is a digit group separator and dropped, so
+// 2,5 becomes 2.5 but 1.000 becomes 1000.
+func numberDigits(number string) string {
+	decimal := strings.LastIndexAny(number, ".,")
+	if decimal >= 0 {
+		if rest := number[decimal+1:]; len(rest) > 2 || strings.ContainsFunc(rest, func(r rune) bool { return !unicode.IsDigit(r) }) {
+			decimal = -1
+		}
+	}
+	var b strings.Builder
+	for i, r := range number {
+		switch {
+		case i == decimal:
+			b.WriteByte('.')
+		case r == '±' || unicode.IsDigit(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// numberFlags reports the numbers of source missing from translation and
+// those translation has that source does not.
+func numberFlags(source, translation string) []string {
+	sourceNumbers, sourceCodes := numberTokens(source)
+	translationNumbers, translationCodes := numberTokens(translation)
+	missing, added := numberDiff(sourceNumbers, translationNumbers)
+	_, addedCodes := numberDiff(sourceCodes, translationCodes)
+	added = append(added, addedCodes...)
+	var parts []string
+	if len(missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(missing, ", "))
+	}
+	if len(added) > 0 {
+		parts = append(parts, "added "+strings.Join(added, ", "))
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("numbers changed: %s", strings.Join(parts, "; "))}
+}
+
+// numberDiff returns, as written, the tokens of a not in b and those of b
+// not in a, as often as they are extra.
+func numberDiff(a, b []numberToken) (onlyA, onlyB []string) {
+	count := make(map[string]int)
+	for _, t := range a {
+		count[t.key]++
+	}
+	for _, t := range b {
+		if count[t.key] > 0 {
+			count[t.key]--
+		} else {
+			onlyB = append(onlyB, t.text)
+		}
+	}
+	for _, t := range a {
+		if count[t.key] > 0 {
+			count[t.key]--
+			onlyA = append(onlyA, t.text)
+		}
+	}
+	return onlyA, onlyB
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNumberFlags(t *testing.T) {
+	tests := []struct {
+		source, translation string
+		want                []string
+	}{
+		{"Druck 2,5 bar", "Pressure 2.5 bar", nil},
+		{"1.000 Zyklen", "1,000 cycles", nil},
+		{"1.234,5 Zyklen", "1,234.5 cycles", nil},
+		{"Druck 1.5 bar", "Pressure 15 bar", []string{"numbers changed: missing 1.5 bar; added 15 bar"}},
+		{"Druck 2,5 bar", "Pressure 25 bar", []string{"numbers changed: missing 2,5 bar; added 25 bar"}},
+		{"Versorgung 24VDC", "Supply 24 VDC", nil},
+		{"Temperatur 80 °C", "Température 80°C", nil},
+		{"Füllstand 5 %", "Level 5%", nil},
+		{"Versorgung 24VDC fehlt", "24V DC supply missing", []string{"numbers changed: missing 24VDC; added 24V"}},
+		{"Position ±0,5 mm", "Position 0.5 mm", []string{"numbers changed: missing ±0,5 mm; added 0.5 mm"}},
+		{"Ventil 3 offen", "Valve 4 open", []string{"numbers changed: missing 3; added 4"}},
+		{"Pumpe 1 und Pumpe 1", "Pump 1 and pump 2", []string{"numbers changed: missing 1; added 2"}},
+		{"Motor 1 A Seite", "Motor 1 side A", nil},
+		{"Eingang E1.3 gestört", "Input I1.3 faulty", []string{"numbers changed: added I1.3"}},
+		{"Eingang E1.3 gestört", "Input faulty", nil}, // lost codes are protected-term findings
+		{"Motor M12 @1%d@ U/min", "Motor M12 @1%d@ rpm", nil},
+		{"Zwei Pumpen", "Two pumps", nil},
+	}
+	for _, tt := range tests {
+		if got := numberFlags(tt.source, tt.translation); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("numberFlags(%q, %q) = %q; expected %q", tt.source, tt.translation, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ///////////////////
+// OPENAI PROVIDER
+// ///////////////////
+
+func init() {
+	registerProvider("openai", providerSpec{
+		factory: func(cfg providerConfig) (Translator, error) {
+			return newOpenAITranslator("OpenAI", cfg, openai.GPT4oMini), nil
+		},
+		keyEnv:  "OPENAI_API_KEY",
+		keyFile: "api-key.txt",
+		keyName: "OpenAI",
+	})
+	// Ollama, llama.cpp and vLLM all speak the OpenAI chat completions API,
+	// so the local provider is the OpenAI client pointed at another server.
+	registerProvider("ollama", providerSpec{
+		factory: func(cfg providerConfig) (Translator, error) {
+			if cfg.baseURL == "" {
+				cfg.baseURL = defaultOllamaURL
+			}
+			return newOpenAITranslator("local server", cfg, defaultOllamaModel), nil
+		},
+	})
+}
+
+const (
+	defaultOllamaURL   = "http://localhost:11434/v1"
+	defaultOllamaModel = "llama3.1"
+)
+
+type openaiTranslator struct {
+	client  *openai.Client
+	model   string
+	name    string             // used in connection errors
+	prompt  *template.Template // user prompt template; nil uses the built-in prompt
+	domain  string
+	tone    string
+	limiter *rateLimiter
+	budget  *budget
+	poll    time.Duration // how often a --batch-api job is checked; 0 = batchAPIPollInterval
+}
+
+// newOpenAITranslator builds a client for the OpenAI API or any server that
+// mimics it. An empty cfg.model falls back to defaultModel.
+func newOpenAITranslator(name string, cfg providerConfig, defaultModel string) *openaiTranslator {
+	clientConfig := openai.DefaultConfig(cfg.apiKey)
+	if cfg.baseURL != "" {
+		clientConfig.BaseURL = strings.TrimSuffix(cfg.baseURL, "/")
+	}
+	if cfg.client != nil {
+		clientConfig.HTTPClient = cfg.client
+	}
+	model := cfg.model
+	if model == "" {
+		model = defaultModel
+	}
+	return &openaiTranslator{client: openai.NewClientWithConfig(clientConfig), model: model, name: name, prompt: cfg.prompt, domain: cfg.domain, tone: cfg.tone, limiter: cfg.limiter, budget: cfg.budget}
+}
+
+func (t *openaiTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	req, err := t.chatRequest(ctx, text, sourceLang, targetLang)
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", t.filtered(err)
+	}
+	t.limiter.observe(resp.Header())
+	countTokens(ctx, resp.Usage.TotalTokens)
+	t.budget.spend(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, 0, 1)
+	return t.reply(resp)
+}
+
+// chatRequest builds the request that translates a single text, with the
+// hints attached to ctx.
+func (t *openaiTranslator) chatRequest(ctx context.Context, text, sourceLang, targetLang string) (openai.ChatCompletionRequest, error) {
+	messages := []openai.ChatCompletionMessage{{
+		Role:    openai.ChatMessageRoleUser,
+		Content: fmt.Sprintf("%s Translate the following text from '%s' to '%s'. If the text is a placeholder or code, return it as is.%s%s%s%s The text to translate is: %s", t.role(), sourceLang, targetLang, toneInstructions(t.tone), hintInstructions(ctx), markerInstructions(text), jsonReplyInstructions, text),
+	}}
+	if t.prompt != nil {
+		// A custom prompt becomes the system message, the text is sent on
+		// its own so the template does not have to place it.
+		instructions, err := t.customInstructions(ctx, sourceLang, targetLang)
+		if err != nil {
+			return openai.ChatCompletionRequest{}, err
+		}
+		messages = []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: instructions + referenceInstructions(promptHintsFrom(ctx).references) + sessionInstructions(promptHintsFrom(ctx).session) + placeholderInstructions(promptHintsFrom(ctx).placeholders) + lengthInstructions(promptHintsFrom(ctx).maxLength) + sanitizedInstructions(promptHintsFrom(ctx).sanitized) + wrongLanguageInstructions(promptHintsFrom(ctx).wrongLanguage) + markerInstructions(text) + jsonReplyInstructions},
+			{Role: openai.ChatMessageRoleUser, Content: text},
+		}
+	}
+	return openai.ChatCompletionRequest{
+		Model:          t.model,
+		Messages:       messages,
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	}, nil
+}
+
+// reply reads the translation from the response to a chatRequest.
+func (t *openaiTranslator) reply(resp openai.ChatCompletionResponse) (string, error) {
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("%s returned no choices", t.name)
+	}
+	if resp.Choices[0].FinishReason == openai.FinishReasonContentFilter {
+		return "", fmt.Errorf("%w: %s stopped the reply with its content filter", errRefused, t.name)
+	}
+	if resp.Choices[0].FinishReason == openai.FinishReasonLength {
+		return "", fmt.Errorf("%s: %w", t.name, errCutShort)
+	}
+	return parseTranslation(resp.Choices[0].Message.Content)
+}
+
+// jsonReplyInstructions asks for the reply as a JSON object, so quotation
+// marks in the translation survive and chatter around it cannot end up in
+// a cell.
+const jsonReplyInstructions = ` Reply with a JSON object of the form {"translation": "..."} and nothing else.`
+
+type singleResponse struct {
+	Translation *string `json:"translation"`
+}
+
+// parseTranslation reads the translation field of a JSON reply.
+func parseTranslation(content string) (string, error) {
+	var parsed singleResponse
+	if err := json.Unmarshal([]byte(jsonObject(content)), &parsed); err != nil {
+		return "", fmt.Errorf("could not parse response: %w", err)
+	}
+	if parsed.Translation == nil {
+		return "", fmt.Errorf("response has no translation field")
+	}
+	return *parsed.Translation, nil
+}
+
+// jsonObject cuts the outermost JSON object out of a reply. Models without
+// a JSON mode tend to wrap it in a code fence or a sentence.
+func jsonObject(content string) string {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start == -1 || end < start {
+		return content
+	}
+	return content[start : end+1]
+}
+
+type batchResponse struct {
+	Translations []string `json:"translations"`
+}
+
+// TranslateBatch sends the texts as a JSON array and asks for a JSON object
+// with the translations in the same order.
+func (t *openaiTranslator) TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	input, err := json.Marshal(texts)
+	if err != nil {
+		return nil, err
+	}
+	prompt := fmt.Sprintf("%s Translate each string in the following JSON array from '%s' to '%s'. If a string is a placeholder or code, return it as is.%s%s%s Reply with a JSON object of the form {\"translations\": [...]} containing exactly %d strings in the same order, and nothing else.", t.role(), sourceLang, targetLang, toneInstructions(t.tone), hintInstructions(ctx), markerInstructions(texts...), len(texts))
+	if t.prompt != nil {
+		instructions, err := t.customInstructions(ctx, sourceLang, targetLang)
+		if err != nil {
+			return nil, err
+		}
+		prompt = fmt.Sprintf("%s The input is a JSON array of strings; translate each of them. Reply with a JSON object of the form {\"translations\": [...]} containing exactly %d strings in the same order, and nothing else.", instructions, len(texts))
+	}
+	resp, err := t.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: t.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: prompt},
+			{Role: openai.ChatMessageRoleUser, Content: string(input)},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	})
+	if err != nil {
+		return nil, t.filtered(err)
+	}
+	t.limiter.observe(resp.Header())
+	t.budget.spend(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, 0, 1)
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%s returned no choices", t.name)
+	}
+	if resp.Choices[0].FinishReason == openai.FinishReasonContentFilter {
+		return nil, fmt.Errorf("%w: %s stopped the reply with its content filter", errRefused, t.name)
+	}
+	if resp.Choices[0].FinishReason == openai.FinishReasonLength {
+		return nil, fmt.Errorf("%s: %w", t.name, errCutShort)
+	}
+	var parsed batchResponse
+	if err := json.Unmarshal([]byte(jsonObject(resp.Choices[0].Message.Content)), &parsed); err != nil {
+		return nil, fmt.Errorf("could not parse batch response: %w", err)
+	}
+	return parsed.Translations, nil
+}
+
+// filtered marks requests the provider's content filter rejected, as
+// Azure OpenAI does with a 400 and code content_filter.
+func (t *openaiTranslator) filtered(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		if code, _ := apiErr.Code.(string); code == "content_filter" || code == "content_policy_violation" {
+			return fmt.Errorf("%w: %s: %v", errRefused, t.name, err)
+		}
+	}
+	return err
+}
+
+// role opens the built-in prompt.
+func (t *openaiTranslator) role() string {
+	if t.domain == "" {
+		return "You are a professional translator."
+	}
+	return fmt.Sprintf("You are a professional translator specialized in %s texts.", t.domain)
+}
+
+// hintInstructions renders the prompt hints attached to ctx.
+func hintInstructions(ctx context.Context) string {
+	hints := promptHintsFrom(ctx)
+	instructions := glossaryInstructions(hints.glossary)
+	if hints.context != "" {
+		instructions += fmt.Sprintf(" Use this context to pick the right meaning, but do not translate it: %q.", hints.context)
+	}
+	return instructions + referenceInstructions(hints.references) + sessionInstructions(hints.session) + placeholderInstructions(hints.placeholders) + lengthInstructions(hints.maxLength) + sanitizedInstructions(hints.sanitized) + wrongLanguageInstructions(hints.wrongLanguage)
+}
+
+// placeholderInstructions insists on the placeholders a previous reply
+// changed.
+func placeholderInstructions(tokens []string) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" The text contains placeholders that are replaced at runtime: %s. Copy every one of them into the translation exactly as written, character for character, without translating, reformatting or dropping any.", strings.Join(tokens, " "))
+}
+
+// customInstructions renders the user prompt template for a request.
+func (t *openaiTranslator) customInstructions(ctx context.Context, sourceLang, targetLang string) (string, error) {
+	hints := promptHintsFrom(ctx)
+	return renderPrompt(t.prompt, promptData{
+		SourceLang: sourceLang,
+		TargetLang: targetLang,
+		Glossary:   glossaryPairs(hints.glossary),
+		Context:    hints.context,
+		Domain:     t.domain,
+		Tone:       t.tone,
+	})
+}
+
+// Validate makes a lightweight call to OpenAI to ensure the key is valid.
+func (t *openaiTranslator) Validate(ctx context.Context) error {
+	// A simple, low-cost request to check for authentication.
+	_, err := t.client.ListModels(ctx)
+	if err != nil {
+		// Check for a specific 401 Unauthorized error.
+		if apiErr, ok := err.(*openai.APIError); ok && apiErr.HTTPStatusCode == http.StatusUnauthorized {
+			return fmt.Errorf("the provided API key is invalid or has expired")
+		}
+		// Return a more generic error for other issues (e.g., network problems).
+		return fmt.Errorf("could not connect to %s: %w%s", t.name, err, networkHint(err))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTranslation(t *testing.T) {
+	testCases := []struct {
+		content  string
+		expected string
+		wantErr  bool
+	}{
+		{`{"translation": "Motorstörung"}`, "Motorstörung", false},
+		{`{"translation": "\"Start\" drücken"}`, `"Start" drücken`, false},
+		{"```json\n{\"translation\": \"Pumpe läuft\"}\n```", "Pumpe läuft", false},
+		{`Here is the translation: {"translation": "Ventil offen"}`, "Ventil offen", false},
+		{`{"translation": ""}`, "", false},
+		{`Ventil offen`, "", true},
+		{`{"text": "Ventil offen"}`, "", true},
+	}
+
+	for _, tc := range testCases {
+		result, err := parseTranslation(tc.content)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseTranslation(%q) error = %v; wantErr %t", tc.content, err, tc.wantErr)
+			continue
+		}
+		if result != tc.expected {
+			t.Errorf("parseTranslation(%q) = %q; expected %q", tc.content, result, tc.expected)
+		}
+	}
+}
+
+func TestOpenAIJSONMode(t *testing.T) {
+	var format string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResponseFormat struct {
+				Type string `json:"type"`
+			} `json:"response_format"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		format = req.ResponseFormat.Type
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{
+				"message": map[string]string{"role": "assistant", "content": `{"translation": "\"Start\" drücken"}`},
+			}},
+		})
+	}))
+	defer server.Close()
+
+	translator := newOpenAITranslator("test server", providerConfig{baseURL: server.URL}, "test-model")
+	result, err := translator.Translate(context.Background(), `Press "Start"`, "en-US", "de-DE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != `"Start" drücken` {
+		t.Errorf("Translate() = %q; expected the quotes kept", result)
+	}
+	if format != "json_object" {
+		t.Errorf("response_format = %q; expected json_object", format)
+	}
+}
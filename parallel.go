@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ///////////////////
+// PARALLEL FILES
+// ///////////////////
+
+// fileProgressMsg is the progress of one of several files running at once,
+// from 0 to 1. A single file reports plain progressMsgs.
+type fileProgressMsg struct {
+	fileIndex int
+	percent   float64
+}
+
+// fileDoneMsg is sent when a file is saved, or left unsaved after an error.
+type fileDoneMsg struct {
+	fileIndex int
+}
+
+// fileSender tags the progress of one file with its index.
+type fileSender struct {
+	msgSender
+	fileIndex int
+}
+
+func (s fileSender) Send(msg tea.Msg) {
+	if pm, ok := msg.(progressMsg); ok {
+		msg = fileProgressMsg{fileIndex: s.fileIndex, percent: float64(pm)}
+	}
+	s.msgSender.Send(msg)
+}
+
+// runFilesParallel runs up to parallel files at once. The files share the
+// translator and its rate limiter, so together they stay under the
+// provider's limits; what one file leaves of them, the others use. Files
+// start in order, and none are started once ctx is cancelled.
+func runFilesParallel(ctx context.Context, p msgSender, translator Translator, tasks []*fileTask, frs []*fileReport, parallel int, opts options, result *runResult) {
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := range tasks {
+			select {
+			case next <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var reviewMu sync.Mutex
+	var wg sync.WaitGroup
+	for range parallel {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if ctx.Err() != nil {
+					return
+				}
+				runFile(ctx, fileSender{p, i}, translator, tasks, i, frs[i], opts, result, &reviewMu)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// fileSlot is the TUI's view of one file of a parallel run.
+type fileSlot struct {
+	fileName  string
+	totalRows int
+	percent   float64
+	started   bool
+	done      bool
+}
+
+// updateFiles records the progress of parallel files.
+func (m model) updateFiles(msg tea.Msg) model {
+	if m.files == nil {
+		m.files = make([]fileSlot, m.fileCount)
+	}
+	switch msg := msg.(type) {
+	case fileInfoMsg:
+		if msg.fileIndex < len(m.files) {
+			m.files[msg.fileIndex] = fileSlot{fileName: msg.fileName, totalRows: msg.totalRows, started: true}
+		}
+	case fileProgressMsg:
+		if msg.fileIndex < len(m.files) {
+			m.files[msg.fileIndex].percent = msg.percent
+		}
+	case fileDoneMsg:
+		if msg.fileIndex < len(m.files) {
+			m.files[msg.fileIndex].done = true
+		}
+	}
+
+	// The overall bar and rate count rows over all files.
+	done, finished := 0, 0
+	for _, f := range m.files {
+		done += int(float64(f.totalRows) * f.percent)
+		if f.done {
+			finished++
+		}
+	}
+	m.currentRow = done
+	m.fileIndex = finished
+	if m.totalRows > 0 {
+		m.percent = float64(done) / float64(m.totalRows)
+	}
+	return m
+}
+
+// runningFiles returns the files started and not yet done.
+func (m model) runningFiles() []fileSlot {
+	var running []fileSlot
+	for _, f := range m.files {
+		if f.started && !f.done {
+			running = append(running, f)
+		}
+	}
+	return running
+}
+
+// renderFileSlots is a line per running file: its name, a small bar and
+// its rows.
+func renderFileSlots(m model) string {
+	bar := m.progressBar
+	bar.Width = 24
+	bar.ShowPercentage = false
+	var lines []string
+	for _, f := range m.runningFiles() {
+		name := filepath.Base(f.fileName)
+		if len(name) > 30 {
+			name = name[:27] + "..."
+		}
+		done := int(float64(f.totalRows) * f.percent)
+		lines = append(lines, fmt.Sprintf("%-30s  %s  %3d%% (%d/%d)", name, bar.ViewAs(f.percent), int(f.percent*100), done, f.totalRows))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xuri/excelize/v2"
+)
+
+// meetingTranslator holds the first calls until n of them are waiting, so a
+// run only gets past them if n files translate at the same time.
+type meetingTranslator struct {
+	mu      sync.Mutex
+	n       int
+	waiting int
+	met     chan struct{}
+}
+
+func (m *meetingTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	m.mu.Lock()
+	m.waiting++
+	if m.waiting == m.n {
+		close(m.met)
+	}
+	m.mu.Unlock()
+	select {
+	case <-m.met:
+		return strings.ToUpper(text), nil
+	case <-time.After(5 * time.Second):
+		return "", errors.New("the files were not translated at the same time")
+	}
+}
+
+// recordingSender keeps the messages sent from several files.
+type recordingSender struct {
+	mu   sync.Mutex
+	msgs []tea.Msg
+}
+
+func (r *recordingSender) Send(msg tea.Msg) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, msg)
+}
+
+func TestRunTasksParallel(t *testing.T) {
+	dir := t.TempDir()
+	opts := options{workers: 1, parallelFiles: 2, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US"}
+
+	names := []string{"one.xlsx", "two.xlsx", "three.xlsx"}
+	var tasks []*fileTask
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		f := excelize.NewFile()
+		f.SetSheetRow("Sheet1", "A1", &[]string{"de-DE", "en-US"})
+		f.SetSheetRow("Sheet1", "A2", &[]string{"Pumpe " + name, ""})
+		if err := f.SaveAs(path); err != nil {
+			t.Fatal(err)
+		}
+		task, err := prepareFile(opts, path, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	sender := &recordingSender{}
+	result := &runResult{}
+	runTasks(context.Background(), sender, &meetingTranslator{n: 2, met: make(chan struct{})}, tasks, opts, result)
+
+	if summary, code := result.summary(); code != exitClean || summary.translated != 3 {
+		t.Fatalf("summary %v, exit code %d; expected 3 translated rows and %d", summary, code, exitClean)
+	}
+	saved := result.files()
+	slices.Sort(saved)
+	var want []string
+	for _, name := range names {
+		want = append(want, filepath.Join(dir, "translated-"+name))
+	}
+	slices.Sort(want)
+	if !slices.Equal(saved, want) {
+		t.Errorf("saved %v; expected %v", saved, want)
+	}
+
+	// Progress comes per file and each file reports when it is done.
+	progress := make(map[int]float64)
+	done := make(map[int]bool)
+	for _, msg := range sender.msgs {
+		switch msg := msg.(type) {
+		case progressMsg:
+			t.Errorf("untagged progress %v from a parallel run", msg)
+		case fileProgressMsg:
+			progress[msg.fileIndex] = msg.percent
+		case fileDoneMsg:
+			done[msg.fileIndex] = true
+		}
+	}
+	for i := range names {
+		if progress[i] != 1 || !done[i] {
+			t.Errorf("file %d: progress %v, done %t", i, progress[i], done[i])
+		}
+	}
+}
+
+func TestPlainPrinterParallelFiles(t *testing.T) {
+	var out strings.Builder
+	pp := &plainPrinter{out: &out}
+	pp.Send(fileInfoMsg{fileName: "a.xlsx", fileIndex: 0})
+	pp.Send(fileInfoMsg{fileName: "b.xlsx", fileIndex: 1})
+	pp.Send(fileProgressMsg{fileIndex: 0, percent: 0.25})
+	pp.Send(fileProgressMsg{fileIndex: 1, percent: 0.5})
+	pp.Send(fileProgressMsg{fileIndex: 0, percent: 0.29})
+	pp.Send(fileProgressMsg{fileIndex: 0, percent: 1})
+
+	want := "Progress: 20% of a.xlsx\nProgress: 50% of b.xlsx\nProgress: 100% of a.xlsx"
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Errorf("got %q; expected %q", got, want)
+	}
+}
@@ -0,0 +1,1076 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// TRANSLATION PIPELINE
+// ///////////////////
+
+// translationJob describes one sheet to translate.
+type translationJob struct {
+	f              *excelize.File
+	sheetName      string
+	rows           [][]string // only the columns the job reads if streamed
+	rowCount       int        // rows of the sheet; rows holds a sample until loadRows
+	sourceIndex    int
+	targetIndex    int
+	contextCol     int // 1-based column passed to the model as context; 0 if none
+	sourceLang     string
+	targetLang     string
+	mode           string
+	fileType       FileType
+	workers        int
+	batchSize      int           // texts per request when the provider supports batching; <= 1 disables it
+	batchAPI       bool          // send the sheet as one OpenAI Batch job first
+	planOnly       bool          // planned for an estimate only; setCell leaves the workbook alone
+	retries        int           // retries of a request after a rate limit or transient error
+	reqTimeout     time.Duration // deadline of each request attempt; 0 = none
+	rowTimeout     time.Duration // deadline of a row with all its requests and retries; 0 = none
+	limiter        *rateLimiter  // shared by all jobs of a run; nil = no pacing
+	tm             *translationMemory
+	glossary       *glossary
+	previous       *previousRun // --previous; nil = none
+	hashes         columnHashes // recorded by an earlier run with --hashes; nil = none
+	checkpoint     *checkpoint
+	autosave       *autosaver
+	edits          *cellEdits        // set when the workbook is streamed; f is not written to
+	review         *reviewList       // translated cells collected for --review; nil = no review
+	audit          *fileAudit        // --log-file; nil = no audit log
+	control        *runControl       // pause, skip and retry keys of the TUI; nil = none
+	maxLength      int               // most characters a target cell may hold; 0 = no limit
+	report         *fileReport       // rows collected for --report; nil = no report
+	resumed        map[int]string    // 1-based sheet row -> translation from an interrupted run
+	keep           map[int]bool      // 0-based rows whose existing translation must not be replaced
+	filter         *rowFilter        // rows left out of the run stay as they are
+	failures       *failureList      // rows still failing after the retry pass; nil = not collected
+	rules          *tiatrans.RuleSet // --rules; nil = built-in rules only
+	fuzzy          *fuzzyMemory      // --fuzzy-tm; nil = exact matches only
+	reviewer       reviewer          // --review-model; nil = drafts are written as they are
+	verifier       verifier          // --self-check; nil = translations are not rated
+	via            string            // --via pivot language; "" = translate directly
+	mixedSource    string            // --mixed-source: mixedSourceFlag, mixedSourceTranslate or "" for no check
+	splitSentences int               // texts longer than this many characters are translated sentence by sentence; 0 = never
+	keepCase       bool              // translations keep the ALL CAPS or Title Case of their source
+	protected      *protectedTerms   // --protect; nil = built-in codes only
+	session        *sessionTerms     // terms used so far for the target language in the file; nil = none
+	fallback       *fallbackList     // --untranslated copy; nil = untranslated rows stay as they are
+}
+
+// rowJob is a row that needs the translator. Rows are planned serially so
+// that pattern reuse can see the previous row, then translated by a worker
+// pool, and finally written back by a single goroutine.
+type rowJob struct {
+	index   int // 0-based row index in the sheet
+	source  string
+	context string // content of the context column, sent along as a hint
+
+	// segments is set for Rockwell texts with embedded refs and for long
+	// texts split into sentences; odd entries are refs or the whitespace
+	// between sentences, which are kept as-is.
+	segments []string
+	chunked  bool // segments are chunks of a text too long for one request
+
+	// lead and trail are the whitespace around the source text in its
+	// cell, which source and result are without.
+	lead, trail string
+
+	// prev is an earlier planned row whose translation this row can reuse,
+	// either because the text is identical (the first row with the same
+	// text, see dedupe in planRows) or because it is the first member of
+	// the same pattern family (see pkg/tiatrans/pattern.go) and only the suffix differs.
+	prev      *rowJob
+	identical bool
+	delim     string
+	suffix    string
+
+	resumed    bool // result comes from a checkpoint
+	status     rowStatus
+	result     string
+	write      bool
+	err        error
+	translated int
+	reused     int
+	errors     int
+	tokens     int64         // reported by the provider, over all attempts
+	latency    time.Duration // spent in translateRow, over all attempts
+	retries    int           // requests sent again, including the retry pass
+	flags      []string      // QA findings reviewers should look at
+	doubtful   bool          // rated as likely wrong by --self-check or --review-model
+	pivot      string        // intermediate translation into job.via
+	sourceLang string        // language the source is written in, if not the job's (--mixed-source translate)
+	conflict   string        // a reviewer's edit of the old text this row replaces
+	done       chan struct{}
+}
+
+func (j *translationJob) setCell(rowIndex int, value string) {
+	if j.planOnly {
+		return
+	}
+	if j.edits != nil {
+		j.edits.set(j.sheetName, rowIndex, j.targetIndex, value)
+		return
+	}
+	cell, _ := excelize.CoordinatesToCellName(j.targetIndex+1, rowIndex+1)
+	j.f.SetCellValue(j.sheetName, cell, value)
+}
+
+// cellValue returns what the target cell of a row holds now.
+func (j *translationJob) cellValue(rowIndex int) string {
+	if j.edits != nil {
+		if value, ok := j.edits.get(j.sheetName, rowIndex, j.targetIndex); ok {
+			return value
+		}
+		if row := j.rows[rowIndex]; j.targetIndex < len(row) {
+			return row[j.targetIndex]
+		}
+		return ""
+	}
+	cell, _ := excelize.CoordinatesToCellName(j.targetIndex+1, rowIndex+1)
+	value, _ := j.f.GetCellValue(j.sheetName, cell)
+	return value
+}
+
+// note records what happened to a row for the --report and --log-file.
+func (j *translationJob) note(rowIndex int, source, result string, status rowStatus, detail string) {
+	j.record(reportEntry{
+		sheet:       j.sheetName,
+		row:         rowIndex + 1,
+		sourceLang:  j.sourceLang,
+		targetLang:  j.targetLang,
+		source:      source,
+		translation: result,
+		status:      status,
+		detail:      detail,
+	})
+}
+
+// record adds a handled row to the report and the audit log.
+func (j *translationJob) record(e reportEntry) {
+	e.at = time.Now()
+	j.audit.record(e)
+	if j.report != nil {
+		j.report.entries = append(j.report.entries, e)
+	}
+}
+
+// memoryLookup returns the stored translation of text, if the run uses a
+// translation memory and it has one. Lookup errors count as a miss.
+func (j *translationJob) memoryLookup(text string) (string, bool) {
+	if j.tm == nil {
+		return "", false
+	}
+	translation, ok, err := j.tm.lookup(text, j.sourceLang, j.targetLang)
+	if err != nil {
+		return "", false
+	}
+	return translation, ok
+}
+
+// memoryMatch is memoryLookup plus stored texts that differ from text only
+// in their numbers. flag is set for such fuzzy matches, so reviewers check
+// them.
+func (j *translationJob) memoryMatch(text string) (translation, flag string, ok bool) {
+	if translation, ok := j.memoryLookup(text); ok {
+		return translation, "", true
+	}
+	if translation, source, ok := j.fuzzy.renumbered(text, j.sourceLang, j.targetLang); ok {
+		return translation, fmt.Sprintf("fuzzy memory match: numbers of %q replaced", source), true
+	}
+	return "", "", false
+}
+
+// memoryStore records a fresh translation in the translation memory.
+func (j *translationJob) memoryStore(p msgSender, text, translation string) {
+	// A translation with broken placeholders would come back in later runs.
+	if j.tm == nil || len(missingPlaceholders(text, translation)) > 0 {
+		return
+	}
+	if err := j.tm.store(text, j.sourceLang, j.targetLang, translation); err != nil {
+		p.Send(logMsg(fmt.Sprintf("Translation memory: could not store %q: %v", text, err)))
+		return
+	}
+	j.fuzzy.stored(j.sourceLang, j.targetLang)
+}
+
+// withHints attaches the prompt hints relevant to texts to ctx. A row
+// context or retry hints already attached to ctx are kept.
+func (j *translationJob) withHints(ctx context.Context, texts ...string) context.Context {
+	from := promptHintsFrom(ctx)
+	hints := promptHints{context: from.context, placeholders: from.placeholders, maxLength: from.maxLength, sanitized: from.sanitized, wrongLanguage: from.wrongLanguage}
+	seen := make(map[string]bool)
+	referenced := make(map[string]bool)
+	for _, text := range texts {
+		for _, term := range j.glossary.matches(text) {
+			if !seen[term.source] {
+				seen[term.source] = true
+				hints.glossary = append(hints.glossary, term)
+			}
+		}
+		if hints.sanitized {
+			continue
+		}
+		if ref, ok := j.fuzzy.reference(ctx, text, j.sourceLang, j.targetLang); ok && !referenced[ref.source] {
+			referenced[ref.source] = true
+			hints.references = append(hints.references, ref)
+		}
+	}
+	if !hints.sanitized {
+		hints.session = j.session.relevant(texts...)
+	}
+	return withPromptHints(ctx, hints)
+}
+
+// checkTranslation runs the QA checks on a finished translation and records
+// any findings on rj.
+func (j *translationJob) checkTranslation(rj *rowJob, source, translation string) {
+	rj.flags = append(rj.flags, j.qaFlags(source, translation)...)
+}
+
+// qaFlags lists what reviewers should look at in a translation.
+func (j *translationJob) qaFlags(source, translation string) []string {
+	var flags []string
+	for _, term := range j.glossary.violations(source, translation) {
+		flags = append(flags, fmt.Sprintf("glossary: %q should be rendered as %q", term.source, term.target))
+	}
+	if missing := missingPlaceholders(source, translation); len(missing) > 0 {
+		flags = append(flags, fmt.Sprintf("placeholders changed: %s", strings.Join(missing, " ")))
+	}
+	if tagsBalanced(source) && !tagsBalanced(translation) {
+		flags = append(flags, "markup tags are not balanced")
+	}
+	if language := j.wrongLanguage(translation); language != "" {
+		flags = append(flags, fmt.Sprintf("wrong language: looks like %s, not %s", language, j.targetLang))
+	}
+	flags = append(flags, j.protected.protectedFlags(source, translation)...)
+	flags = append(flags, numberFlags(source, translation)...)
+	flags = append(flags, whitespaceFlags(source, translation)...)
+	return flags
+}
+
+// translate sends text to the translator with its markup tags swapped for
+// markers. A reply that lost or changed placeholders is asked for once more
+// with a prompt that lists them, and one longer than limit characters (0 =
+// no limit) is asked for in a shorter version. The better reply is kept;
+// whatever is still wrong gets flagged. A refused text is asked for once
+// more with a sanitized prompt; a second refusal fails with errRefused. A
+// reply in another language than the target is asked for once more too.
+// Line breaks get the style of the source, and with keepCase the
+// translation gets its ALL CAPS or Title Case.
+// With a pivot language, the text is first translated into it; that
+// intermediate translation is reused by the requests asked for again,
+// unless it lost placeholders.
+func (j *translationJob) translate(ctx context.Context, p msgSender, translator Translator, text string, limit int) (string, error) {
+	masked, tags := protectTags(text, j.protected)
+	var intermediate string
+	send := func(ctx context.Context) (string, error) {
+		input, sourceLang := masked, j.sourceLang
+		if j.via != "" {
+			if intermediate == "" || len(missingPlaceholders(masked, intermediate)) > 0 {
+				first, err := translator.Translate(pivotHints(ctx), masked, j.sourceLang, j.via)
+				if err != nil {
+					return "", err
+				}
+				intermediate = first
+				notePivot(ctx, text, restoreTags(first, tags))
+			}
+			input, sourceLang = intermediate, j.via
+		}
+		result, err := translator.Translate(j.withHints(ctx, text), input, sourceLang, j.targetLang)
+		return restoreTags(result, tags), err
+	}
+	result, err := send(ctx)
+	if errors.Is(err, errRefused) || err == nil && isRefusal(text, result) {
+		p.Send(logMsg(fmt.Sprintf("The model refused %q, retrying with a sanitized prompt", text)))
+		// The row context and the references are left out: they may be
+		// what set the refusal off.
+		hints := promptHintsFrom(ctx)
+		hints.context = ""
+		hints.sanitized = true
+		ctx = withPromptHints(ctx, hints)
+		result, err = send(ctx)
+		if err == nil && isRefusal(text, result) {
+			err = fmt.Errorf("%w: %q", errRefused, result)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if language := j.wrongLanguage(result); language != "" {
+		p.Send(logMsg(fmt.Sprintf("Translation of %q looks like %s, not %s, retrying", text, language, j.targetLang)))
+		hints := promptHintsFrom(ctx)
+		hints.wrongLanguage = language
+		ctx = withPromptHints(ctx, hints)
+		retried, err := send(ctx)
+		if err != nil {
+			p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+		} else if j.wrongLanguage(retried) == "" && len(missingPlaceholders(text, retried)) <= len(missingPlaceholders(text, result)) {
+			result = retried
+		}
+	}
+	if missing := missingPlaceholders(text, result); len(missing) > 0 {
+		p.Send(logMsg(fmt.Sprintf("Placeholders %s changed in %q, retrying with a stricter prompt", strings.Join(missing, " "), text)))
+		hints := promptHintsFrom(ctx)
+		hints.placeholders = placeholderTokens(masked)
+		retried, err := send(withPromptHints(ctx, hints))
+		if err != nil {
+			p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+		} else if len(missingPlaceholders(text, retried)) < len(missing) {
+			result = retried
+		}
+	}
+	if n := utf8.RuneCountInString(result); limit > 0 && n > limit {
+		p.Send(logMsg(fmt.Sprintf("Translation of %q has %d characters, limit %d, asking for a shorter one", text, n, limit)))
+		hints := promptHintsFrom(ctx)
+		hints.maxLength = limit
+		shorter, err := send(withPromptHints(ctx, hints))
+		if err != nil {
+			p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+		} else if utf8.RuneCountInString(shorter) < n && len(missingPlaceholders(text, shorter)) <= len(missingPlaceholders(text, result)) {
+			result = shorter
+		}
+	}
+	result = restoreLineBreaks(text, result)
+	if j.keepCase {
+		result = applyCase(text, result, j.sourceLang, j.targetLang)
+	}
+	return result, nil
+}
+
+// iterateAndTranslate translates one job and returns its stats. Once ctx is
+// cancelled no further rows are sent; rows already done are still written
+// and checkpointed.
+func iterateAndTranslate(ctx context.Context, p msgSender, translator Translator, job translationJob) (stats stats) {
+	defer func() {
+		p.Send(statMsg(stats))
+		if stats.preserved > 0 {
+			p.Send(logMsg(fmt.Sprintf("Quick mode: preserved %d existing translations.", stats.preserved)))
+		}
+	}()
+
+	totalRows := len(job.rows)
+	completed := 0
+	rowDone := func() {
+		completed++
+		p.Send(progressMsg(float64(completed) / float64(totalRows))) // Update progress
+	}
+
+	jobs := planRows(p, &job, &stats, rowDone)
+	if job.fuzzy != nil {
+		var texts []string
+		for _, rj := range jobs {
+			if rj.prev == nil && !rj.resumed && rj.segments == nil {
+				texts = append(texts, rj.source)
+			}
+		}
+		job.fuzzy.prepare(ctx, p, job.sourceLang, job.targetLang, texts)
+	}
+
+	workers := job.workers
+	if workers < 1 {
+		workers = 1
+	}
+	// Which rows finish first would decide the session terms of a request;
+	// for a cassette, their translations are held back until the sheet is
+	// done.
+	if workers > 1 {
+		job.session.hold()
+		defer job.session.release()
+	}
+
+	retry := retryPolicy{
+		retries:   job.retries,
+		baseDelay: defaultRetryBaseDelay,
+		maxDelay:  defaultRetryMaxDelay,
+		timeout:   job.reqTimeout,
+		notify: func(err error, wait time.Duration) {
+			p.Send(logMsg(retryNotice(err, wait)))
+			// Hold back the other workers too, they would only hit the
+			// same limit.
+			job.limiter.pause(wait)
+		},
+	}
+	if bj, ok := translator.(batchJobTranslator); ok && job.batchAPI {
+		prefetcher := newPrefetchTranslator(translator)
+		prefetcher.prefetchJob(ctx, p, bj, job.pendingTexts(jobs), job.sourceLang, job.targetLang, job.withHints)
+		translator = prefetcher
+	} else if bt, ok := translator.(batchTranslator); ok && job.batchSize > 1 {
+		bt = &retryBatchTranslator{batchTranslator: &pausableBatchTranslator{batchTranslator: &limitedBatchTranslator{batchTranslator: bt, limiter: job.limiter}, control: job.control}, policy: retry}
+		prefetcher := newPrefetchTranslator(translator)
+		prefetcher.prefetch(ctx, p, bt, job.pendingTexts(jobs), job.sourceLang, job.targetLang, job.batchSize, workers, job.withHints)
+		translator = prefetcher
+	}
+	translator = &retryTranslator{Translator: &pausableTranslator{Translator: &limitedTranslator{Translator: translator, limiter: job.limiter}, control: job.control}, policy: retry}
+	// Share results between segments and suffixes that send the same text.
+	translator = newDedupTranslator(translator)
+	queue := make(chan *rowJob)
+	finished := make(chan *rowJob)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rj := range queue {
+				if ctx.Err() != nil {
+					rj.err = ctx.Err()
+					rj.status = rowSkipped
+				} else {
+					runRow(ctx, p, translator, &job, rj)
+				}
+				close(rj.done)
+				finished <- rj
+			}
+		}()
+	}
+	go func() {
+		// Jobs are queued in row order, so a job's prev is always picked up
+		// before the job itself and waiting on it cannot deadlock the pool.
+		for _, rj := range jobs {
+			queue <- rj
+		}
+		close(queue)
+		wg.Wait()
+		close(finished)
+	}()
+
+	// All writes to the workbook happen here, on a single goroutine.
+	collect := func(rj *rowJob) {
+		if rj.write {
+			// The whitespace around the source goes around the translation.
+			value := rj.lead + rj.result + rj.trail
+			if flag := job.lengthFlag(value); flag != "" {
+				rj.flags = append(rj.flags, flag)
+			}
+			job.setCell(rj.index, value)
+			if err := job.checkpoint.record(job.sheetName, job.targetIndex, rj.index, rj.result); err != nil {
+				p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+			}
+			if err := job.autosave.rowWritten(); err != nil {
+				p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+			}
+			job.review.add(&job, rj)
+		}
+		if rj.conflict != "" {
+			rj.flags = append(rj.flags, rj.conflict)
+		}
+		note := strings.Join(rj.flags, "; ")
+		if rj.err != nil {
+			note = rj.err.Error()
+		}
+		// A cancelled run is incomplete anyway; its rows are left to resume.
+		copied := !rj.write && (rj.status == rowFailed || rj.status == rowSkipped) && ctx.Err() == nil && job.copySource(rj.index)
+		job.record(reportEntry{
+			sheet:       job.sheetName,
+			row:         rj.index + 1,
+			sourceLang:  job.sourceLang,
+			targetLang:  job.targetLang,
+			source:      rj.source,
+			translation: rj.result,
+			status:      rj.status,
+			detail:      withCopied(note, copied),
+			flags:       rj.flags,
+			doubtful:    rj.doubtful,
+			via:         job.via,
+			pivot:       rj.pivot,
+			tokens:      rj.tokens,
+			latency:     rj.latency,
+			retries:     rj.retries,
+		})
+		if rj.status == rowFailed {
+			job.failures.add(&job, rj, note)
+		}
+		stats.translated += rj.translated
+		stats.reused += rj.reused
+		stats.errors += rj.errors
+		if rj.status == rowSkipped {
+			stats.skipped++
+		}
+		if len(rj.flags) > 0 {
+			stats.flagged++
+			for _, flag := range rj.flags {
+				p.Send(logMsg(fmt.Sprintf("Flagged row %d: %s", rj.index+1, flag)))
+			}
+		}
+		rowDone()
+	}
+	var failed []*rowJob
+	for finished != nil {
+		select {
+		case rj, ok := <-finished:
+			if !ok {
+				finished = nil
+				continue
+			}
+			if rj.status == rowFailed && ctx.Err() == nil {
+				failed = append(failed, rj)
+				continue
+			}
+			collect(rj)
+		case <-job.control.retryRequests():
+			if len(failed) == 0 {
+				p.Send(logMsg("No failed row to retry"))
+				continue
+			}
+			rj := failed[len(failed)-1]
+			p.Send(logMsg(fmt.Sprintf("Retrying row %d: %s", rj.index+1, rj.source)))
+			rj.reset()
+			runRow(ctx, p, translator, &job, rj)
+			if rj.status == rowFailed {
+				continue
+			}
+			failed = failed[:len(failed)-1]
+			collect(rj)
+		}
+	}
+	if len(failed) > 0 {
+		slices.SortFunc(failed, func(a, b *rowJob) int { return a.index - b.index })
+		retryFailed(ctx, p, translator, &job, failed)
+		for _, rj := range failed {
+			collect(rj)
+		}
+	}
+	if err := job.checkpoint.flush(); err != nil {
+		p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+	}
+	if ctx.Err() != nil {
+		p.Send(logMsg(fmt.Sprintf("Cancelled, %s (sheet %s) -> %s is incomplete", job.sourceLang, job.sheetName, job.targetLang)))
+	}
+	return stats
+}
+
+// planRows walks the sheet, handles every row that needs no translator
+// (copies and skips) and returns the rows that do, in order.
+func planRows(p msgSender, job *translationJob, stats *stats, rowDone func()) []*rowJob {
+	var jobs []*rowJob
+	// families maps each pattern family and context to its first member,
+	// whose translated base the other members reuse.
+	families := make(map[string]*rowJob)
+	familyRows := 0
+	// firstBySource maps each distinct source text and context to the first
+	// row that translates it, so every later copy is fanned out from that
+	// one result.
+	firstBySource := make(map[string]*rowJob)
+	duplicates := 0
+	split, chunked := 0, 0
+	filtered := 0
+	unchanged, edited, conflicts := 0, 0, 0
+	hashKept, hashStale := 0, 0
+
+	for i, row := range job.rows {
+		if i == 0 { // Skip header row
+			rowDone()
+			continue
+		}
+
+		if len(row) <= job.sourceIndex {
+			rowDone()
+			continue
+		}
+
+		sourceText := strings.TrimSpace(row[job.sourceIndex])
+		if !job.filter.allows(i+1, sourceText) {
+			job.note(i, sourceText, "", rowSkipped, "not selected")
+			stats.skipped++
+			filtered++
+			rowDone()
+			continue
+		}
+		var targetText, contextText string
+		if len(row) > job.targetIndex {
+			targetText = strings.TrimSpace(row[job.targetIndex])
+		}
+		if job.contextCol > 0 && len(row) >= job.contextCol {
+			contextText = strings.TrimSpace(row[job.contextCol-1])
+		}
+
+		// Rockwell-specific: Handle **REF:N** patterns
+		if job.fileType == FileTypeRockwell {
+			// Check if source is a REF field
+			isSourceRef := strings.HasPrefix(sourceText, "**REF:") && strings.HasSuffix(sourceText, "**")
+			// Check if target is a REF field
+			isTargetRef := strings.HasPrefix(targetText, "**REF:") && strings.HasSuffix(targetText, "**")
+
+			if isSourceRef {
+				if isTargetRef {
+					// Both source and target are REF fields - skip
+					p.Send(logMsg("Rockwell: Skipping REF field (both source and target have REF)"))
+					job.note(i, sourceText, targetText, rowSkipped, "REF field")
+					stats.skipped++
+					rowDone()
+					continue
+				} else if targetText == "" {
+					// Source has REF, target is empty - copy source to target
+					job.setCell(i, row[job.sourceIndex])
+					p.Send(logMsg(fmt.Sprintf("Rockwell: Copied REF to target: %s", sourceText)))
+					job.note(i, sourceText, sourceText, rowCopied, "REF field")
+					stats.copied++
+					rowDone()
+					continue
+				}
+				// Source has REF, target has non-REF content - proceed to check if we should translate
+			}
+
+			// If target already has a REF, skip this row
+			if isTargetRef {
+				p.Send(logMsg(fmt.Sprintf("Rockwell: Skipping row (target has REF): %s", targetText)))
+				job.note(i, sourceText, targetText, rowSkipped, "target is a REF field")
+				stats.skipped++
+				rowDone()
+				continue
+			}
+
+			// Rockwell-specific: Handle embedded refs /*...*/
+			if tiatrans.HasEmbeddedRefs(sourceText) {
+				// In quick mode, if target has same refs pattern, skip
+				if job.mode == "quick" && tiatrans.HasEmbeddedRefs(targetText) {
+					p.Send(logMsg("Rockwell: Skipping row (target already has embedded refs)"))
+					job.note(i, sourceText, targetText, rowPreserved, "")
+					stats.preserved++
+					rowDone()
+					continue
+				}
+
+				rj := &rowJob{
+					index:    i,
+					source:   sourceText,
+					context:  contextText,
+					segments: tiatrans.SplitTextByRefs(sourceText),
+					done:     make(chan struct{}),
+				}
+				rj.lead, rj.trail = padding(row[job.sourceIndex])
+				rj.result, rj.resumed = job.resumed[i+1]
+				jobs = append(jobs, rj)
+				continue
+			}
+		}
+
+		// Skip rows with empty source AND empty target
+		if sourceText == "" && targetText == "" {
+			rowDone()
+			continue
+		}
+
+		// With --hashes, a translation recorded for the same source text is
+		// kept, as is one edited by hand since. One whose source text is not
+		// recorded is of an older text and is translated again, even in
+		// quick mode.
+		stale := false
+		if job.hashes != nil && tiatrans.HasTranslation(targetText) {
+			switch job.hashes.check(sourceText, targetText) {
+			case hashUnchanged:
+				job.session.note(i, sourceText, targetText)
+				job.note(i, sourceText, targetText, rowPreserved, "unchanged since the last run")
+				stats.preserved++
+				hashKept++
+				rowDone()
+				continue
+			case hashEdited:
+				if !job.planOnly {
+					job.memoryStore(p, sourceText, targetText)
+				}
+				job.session.note(i, sourceText, targetText)
+				job.note(i, sourceText, targetText, rowEdited, "")
+				stats.preserved++
+				edited++
+				rowDone()
+				continue
+			}
+			stale = true
+			hashStale++
+		}
+
+		// Quick mode (--fill-missing): never touch a target that already
+		// has a translation, not even with a copy of the source.
+		if job.mode == "quick" && !stale && tiatrans.HasTranslation(targetText) {
+			p.Send(logMsg(fmt.Sprintf("Quick mode: preserving row %d", i+1)))
+			job.session.note(i, sourceText, targetText)
+			job.note(i, sourceText, targetText, rowPreserved, "")
+			stats.preserved++
+			rowDone()
+			continue
+		}
+		if job.keep[i] && tiatrans.HasTranslation(targetText) {
+			p.Send(logMsg(fmt.Sprintf("Keeping existing translation in row %d", i+1)))
+			job.session.note(i, sourceText, targetText)
+			job.note(i, sourceText, targetText, rowPreserved, "")
+			stats.preserved++
+			rowDone()
+			continue
+		}
+
+		// Placeholders, numbers, separators, ... (see pkg/tiatrans/rules.go)
+		if r := job.rules.Decide(sourceText); r != nil {
+			switch r.Action {
+			case tiatrans.RuleSkip:
+				p.Send(logMsg(fmt.Sprintf("Skipping (%s): %s", r.Reason(), sourceText)))
+				copied := job.copySource(i)
+				job.note(i, sourceText, targetText, rowSkipped, withCopied(r.Reason(), copied))
+				stats.skipped++
+			case tiatrans.RuleCopy:
+				p.Send(logMsg(fmt.Sprintf("Copying (%s): %s", r.Reason(), sourceText)))
+				status := rowCopied
+				if r.Match == "placeholder" {
+					status = rowPlaceholder
+				}
+				job.note(i, sourceText, sourceText, status, r.Reason())
+				job.setCell(i, row[job.sourceIndex])
+				stats.copied++
+			}
+			rowDone()
+			continue
+		}
+
+		// Texts unchanged since --previous keep their translation, and a
+		// reviewer's edit of it goes into the translation memory.
+		match, ok := job.previous.lookup(row, sourceText)
+		if ok {
+			status := rowPrevious
+			if match.edited {
+				status = rowEdited
+				edited++
+				if !job.planOnly {
+					job.memoryStore(p, sourceText, match.translation)
+				}
+			}
+			job.session.note(i, sourceText, match.translation)
+			job.note(i, sourceText, match.translation, status, "")
+			job.setCell(i, match.translation)
+			stats.reused++
+			unchanged++
+			rowDone()
+			continue
+		}
+
+		// With --mixed-source, a text in another language than the column's
+		// is left alone, copied or translated from that language.
+		sourceLang, handled := job.planForeignSource(p, i, sourceText, targetText, stats)
+		if handled {
+			rowDone()
+			continue
+		}
+
+		rj := &rowJob{index: i, source: sourceText, context: contextText, sourceLang: sourceLang, done: make(chan struct{})}
+		rj.lead, rj.trail = padding(row[job.sourceIndex])
+		if match.oldSource != "" {
+			rj.conflict = fmt.Sprintf("conflict: the text changed from %q, whose translation %q a reviewer had edited", match.oldSource, match.translation)
+			conflicts++
+		}
+		rj.result, rj.resumed = job.resumed[i+1]
+		// The same text in another context may need another translation,
+		// so rows are only shared within the same context.
+		key := sourceText + "\x00" + contextText
+		if first, ok := firstBySource[key]; ok {
+			// The same text was already planned, reuse its translation
+			rj.prev = first
+			rj.identical = true
+			duplicates++
+		} else if tp, ok := tiatrans.SplitPattern(sourceText); ok {
+			family := tp.Delim + "\x00" + tp.Base + "\x00" + contextText + "\x00" + sourceLang
+			if head, ok := families[family]; ok {
+				rj.prev = head
+				rj.suffix = tp.Suffix
+				rj.delim = tp.Delim
+				familyRows++
+			} else {
+				families[family] = rj
+			}
+		}
+		// Long texts are translated sentence by sentence, so none gets near
+		// the model's limits and comes back cut short or summarized.
+		if rj.prev == nil && job.splitSentences > 0 && utf8.RuneCountInString(sourceText) > job.splitSentences {
+			if segments := tiatrans.SplitSentences(sourceText); len(segments) > 1 {
+				rj.segments = segments
+				split++
+			}
+		}
+		if rj.prev == nil && rj.segments == nil && estimateTokens(sourceText) > chunkTokens {
+			rj.segments = chunkText(sourceText, chunkTokens)
+			rj.chunked = true
+			chunked++
+		}
+		jobs = append(jobs, rj)
+		if _, ok := firstBySource[key]; !ok {
+			firstBySource[key] = rj
+		}
+	}
+	if split > 0 {
+		p.Send(logMsg(fmt.Sprintf("Split %d texts longer than %d characters into sentences", split, job.splitSentences)))
+	}
+	if chunked > 0 {
+		p.Send(logMsg(fmt.Sprintf("Split %d texts too long for one request into chunks", chunked)))
+	}
+	if filtered > 0 {
+		p.Send(logMsg(fmt.Sprintf("Left out %d rows not selected by --rows/--match/--exclude", filtered)))
+	}
+	if unchanged > 0 {
+		p.Send(logMsg(fmt.Sprintf("Reused %d translations of texts unchanged since the previous run", unchanged)))
+	}
+	if hashKept > 0 {
+		p.Send(logMsg(fmt.Sprintf("Kept %d translations whose source text is unchanged since the last run", hashKept)))
+	}
+	if hashStale > 0 {
+		p.Send(logMsg(fmt.Sprintf("%d translations are of a source text changed since the last run and are translated again", hashStale)))
+	}
+	if edited > 0 {
+		p.Send(logMsg(fmt.Sprintf("Kept %d translations edited by a reviewer", edited)))
+	}
+	if conflicts > 0 {
+		p.Send(logMsg(fmt.Sprintf("%d rows changed since a reviewer edited their translation; they are translated again and flagged", conflicts)))
+	}
+	if len(jobs) > 0 {
+		p.Send(logMsg(fmt.Sprintf("%d rows to translate, %d of them duplicates of an earlier row", len(jobs), duplicates)))
+	}
+	if familyRows > 0 {
+		p.Send(logMsg(fmt.Sprintf("%d rows reuse the translated base of their pattern family", familyRows)))
+	}
+	return jobs
+}
+
+// runRow translates rj on a worker, unless the user skips it with s. A row
+// still unfinished after job.rowTimeout is given up and fails, so that one
+// stuck connection cannot hold up the run; the retry pass tries it again.
+func runRow(ctx context.Context, p msgSender, translator Translator, job *translationJob, rj *rowJob) {
+	rctx, row := job.control.begin(ctx, rj.source)
+	if job.rowTimeout > 0 {
+		var cancel context.CancelFunc
+		rctx, cancel = context.WithTimeout(rctx, job.rowTimeout)
+		defer cancel()
+	}
+	measureRow(rctx, p, translator, job, rj)
+	switch {
+	case job.control.end(row):
+		rj.reset()
+		rj.err = errSkippedByUser
+		rj.status = rowSkipped
+		p.Send(logMsg(fmt.Sprintf("Skipped by user: %s", rj.source)))
+	case ctx.Err() == nil && rctx.Err() == context.DeadlineExceeded && rj.status == rowFailed:
+		rj.reset()
+		rj.err = fmt.Errorf("row not finished within %s (--row-timeout)", job.rowTimeout)
+		rj.status = rowFailed
+		rj.errors++
+		p.Send(logMsg(fmt.Sprintf("ERROR: row %d: %v", rj.index+1, rj.err)))
+	}
+}
+
+// measureRow runs translateRow and adds the tokens and time it took to rj,
+// and the intermediate translations of --via.
+func measureRow(ctx context.Context, p msgSender, translator Translator, job *translationJob, rj *rowJob) {
+	var tokens tokenCounter
+	var pivots pivotLog
+	start := time.Now()
+	translateRow(withPivotLog(withTokenCounter(ctx, &tokens), &pivots), p, translator, job, rj)
+	rj.pivot = pivots.String()
+	rj.latency += time.Since(start)
+	rj.tokens += tokens.n.Load()
+	rj.retries += int(tokens.retries.Load())
+}
+
+// translateRow fills in the result of a single rowJob. It runs on a worker
+// goroutine and must not touch the workbook.
+func translateRow(ctx context.Context, p msgSender, translator Translator, job *translationJob, rj *rowJob) {
+	if rj.resumed {
+		rj.write = true
+		rj.status = rowResumed
+		p.Send(logMsg(fmt.Sprintf("Resumed row %d from checkpoint", rj.index+1)))
+		return
+	}
+
+	if rj.sourceLang != "" {
+		job = job.fromLanguage(rj.sourceLang)
+		rj.flags = append(rj.flags, fmt.Sprintf("source looks like %s, translated from %s", languageNames[headerLanguage(rj.sourceLang)], rj.sourceLang))
+	}
+	if rj.context != "" {
+		ctx = withPromptHints(ctx, promptHints{context: rj.context})
+	}
+	if rj.segments != nil {
+		translateSegments(ctx, p, translator, job, rj)
+		return
+	}
+
+	if rj.prev != nil {
+		<-rj.prev.done
+		// If the previous row failed there is nothing to reuse; fall
+		// through and translate this row on its own.
+		if rj.prev.err == nil && rj.prev.result != "" {
+			if rj.identical {
+				rj.result = rj.prev.result
+				rj.write = true
+				rj.status = rowReused
+				rj.reused++
+				p.Send(logMsg(fmt.Sprintf("Reused identical translation for: %s", rj.source)))
+				return
+			}
+
+			head, _ := tiatrans.SplitPattern(rj.prev.source)
+			if base, delim, ok := head.TranslatedBase(rj.prev.result); ok {
+				reuseFamilyBase(ctx, p, translator, job, rj, base, delim)
+				return
+			}
+			p.Send(logMsg(fmt.Sprintf("Translation of %q lost its pattern, translating on its own: %s", rj.prev.source, rj.source)))
+		}
+	}
+
+	// The translation memory is keyed by text alone, so rows with a
+	// context neither use nor feed it.
+	if translatedText, flag, ok := job.memoryMatch(rj.source); ok && rj.context == "" {
+		rj.result = translatedText
+		rj.write = true
+		rj.status = rowMemory
+		rj.reused++
+		if flag != "" {
+			rj.flags = append(rj.flags, flag)
+		}
+		job.session.note(rj.index, rj.source, translatedText)
+		// A bad entry would spread to every file that reuses it.
+		job.checkTranslation(rj, rj.source, translatedText)
+		p.Send(logMsg(fmt.Sprintf("Reused from translation memory: %s", rj.source)))
+		return
+	}
+
+	p.Send(logMsg(fmt.Sprintf("Translating: %s", rj.source)))
+	translatedText, err := job.translate(ctx, p, translator, rj.source, job.maxLength)
+	if err != nil {
+		p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+		rj.err = err
+		rj.status = rowFailed
+		rj.errors++
+		return
+	}
+	translatedText = job.reviewDraft(ctx, p, rj, rj.source, translatedText, job.maxLength)
+	job.selfCheck(ctx, p, rj, rj.source, translatedText)
+	rj.result = translatedText
+	rj.write = true
+	rj.status = rowTranslated
+	rj.translated++
+	if rj.context == "" {
+		job.memoryStore(p, rj.source, translatedText)
+	}
+	job.session.note(rj.index, rj.source, translatedText)
+	job.checkTranslation(rj, rj.source, translatedText)
+}
+
+// reuseFamilyBase completes a pattern family member from the translated base
+// of the family's first member. A numeric suffix is kept, anything else is
+// translated on its own.
+func reuseFamilyBase(ctx context.Context, p msgSender, translator Translator, job *translationJob, rj *rowJob, base, delim string) {
+	rj.write = true
+	if _, err := strconv.Atoi(rj.suffix); err == nil {
+		rj.result = base + delim + rj.suffix
+		rj.status = rowReusedPrefix
+		rj.reused++
+		p.Send(logMsg(fmt.Sprintf("Reused base for: %s", rj.source)))
+		return
+	}
+
+	p.Send(logMsg(fmt.Sprintf("Translating suffix: %s", rj.suffix)))
+	// The suffix has to fit in what the base leaves of the limit.
+	limit := 0
+	if rest := job.maxLength - utf8.RuneCountInString(base+delim); job.maxLength > 0 && rest > 0 {
+		limit = rest
+	}
+	suffixTranslation, err := job.translate(ctx, p, translator, rj.suffix, limit)
+	if err != nil {
+		p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+		rj.result = rj.source
+		rj.err = err
+		rj.status = rowFailed
+		rj.errors++
+		return
+	}
+	suffixTranslation = job.reviewDraft(ctx, p, rj, rj.suffix, suffixTranslation, limit)
+	job.selfCheck(ctx, p, rj, rj.suffix, suffixTranslation)
+	rj.result = base + delim + suffixTranslation
+	rj.status = rowReusedPrefix
+	rj.translated++
+	job.checkTranslation(rj, rj.suffix, suffixTranslation)
+}
+
+// translateSegments translates the text between Rockwell embedded refs, or
+// the sentences or chunks of a long text, and keeps the refs and line breaks
+// themselves untouched. A chunk gets the end of the one before as context.
+func translateSegments(ctx context.Context, p msgSender, translator Translator, job *translationJob, rj *rowJob) {
+	var translatedSegments []string
+	rockwell := tiatrans.HasEmbeddedRefs(rj.source)
+
+	for idx, segment := range rj.segments {
+		if idx%2 == 1 {
+			// Odd indices: ref segment or separator (preserve as-is)
+			translatedSegments = append(translatedSegments, segment)
+			continue
+		}
+
+		// Even indices: text segment (translatable)
+		trimmed := strings.TrimSpace(segment)
+		if trimmed == "" {
+			translatedSegments = append(translatedSegments, segment)
+			continue
+		}
+
+		sctx := ctx
+		if rj.chunked && idx > 0 {
+			sctx = withPrecedingText(ctx, rj.segments[idx-2])
+		}
+		translated, ok := job.memoryLookup(trimmed)
+		if ok && rj.context == "" {
+			p.Send(logMsg(fmt.Sprintf("Reused from translation memory: %s", trimmed)))
+			rj.reused++
+			job.checkTranslation(rj, trimmed, translated)
+		} else {
+			// Translate this text segment
+			switch {
+			case rockwell:
+				p.Send(logMsg(fmt.Sprintf("Rockwell: Translating segment: %s", trimmed)))
+			case rj.chunked:
+				p.Send(logMsg(fmt.Sprintf("Translating chunk %d of %d of row %d", idx/2+1, (len(rj.segments)+1)/2, rj.index+1)))
+			default:
+				p.Send(logMsg(fmt.Sprintf("Translating sentence: %s", trimmed)))
+			}
+			var err error
+			// Segments share the row's limit, which is only checked on
+			// the reassembled text.
+			translated, err = job.translate(sctx, p, translator, trimmed, 0)
+			if err != nil {
+				p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+				translatedSegments = append(translatedSegments, segment)
+				if rj.err == nil {
+					rj.err = err
+				}
+				rj.errors++
+				continue
+			}
+			translated = job.reviewDraft(sctx, p, rj, trimmed, translated, 0)
+			job.selfCheck(sctx, p, rj, trimmed, translated)
+			rj.translated++
+			if rj.context == "" {
+				job.memoryStore(p, trimmed, translated)
+			}
+			job.session.note(rj.index, trimmed, translated)
+			job.checkTranslation(rj, trimmed, translated)
+		}
+		translatedSegments = append(translatedSegments, tiatrans.KeepSpacing(segment, translated))
+	}
+
+	// Reassemble and save
+	rj.result = tiatrans.ReassembleWithRefs(translatedSegments)
+	rj.write = true
+	rj.status = rowTranslated
+	if rj.errors > 0 {
+		rj.status = rowFailed
+	}
+	if rockwell {
+		p.Send(logMsg("Rockwell: Saved with embedded refs"))
+	}
+}
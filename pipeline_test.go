@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xuri/excelize/v2"
+)
+
+// upperTranslator "translates" by upper-casing and counts its calls.
+type upperTranslator struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (u *upperTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	u.mu.Lock()
+	u.calls = append(u.calls, text)
+	u.mu.Unlock()
+	return strings.ToUpper(text), nil
+}
+
+func newTestJob(t *testing.T, rows [][]string) translationJob {
+	t.Helper()
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	for i, row := range rows {
+		for j, v := range row {
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			f.SetCellValue(sheet, cell, v)
+		}
+	}
+	return translationJob{
+		f:           f,
+		sheetName:   sheet,
+		rows:        rows,
+		sourceIndex: 0,
+		targetIndex: 1,
+		sourceLang:  "de-DE",
+		targetLang:  "en-US",
+		mode:        "full",
+		fileType:    FileTypeTIA,
+		workers:     4,
+	}
+}
+
+func TestIterateAndTranslate(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Motor fault", ""},
+		{"Discrete_alarm_66", ""},
+		{"Discrete_alarm_67", ""},
+		{"##Tag##", ""},
+		{"42", ""},
+		{"-----------", ""},
+		{"Pump running", ""},
+		{"Motor fault", ""},
+	}
+	job := newTestJob(t, rows)
+	translator := &upperTranslator{}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	expected := map[string]string{
+		"B2":  "MOTOR FAULT",
+		"B3":  "MOTOR FAULT",
+		"B4":  "DISCRETE_ALARM_66",
+		"B5":  "DISCRETE_ALARM_67",
+		"B6":  "##Tag##",
+		"B7":  "42",
+		"B8":  "",
+		"B9":  "PUMP RUNNING",
+		"B10": "MOTOR FAULT",
+	}
+	for cell, want := range expected {
+		got, _ := job.f.GetCellValue(job.sheetName, cell)
+		if got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if len(translator.calls) != 3 {
+		t.Errorf("translator called %d times (%v); expected 3", len(translator.calls), translator.calls)
+	}
+}
+
+// statSender keeps the stats reported by iterateAndTranslate.
+type statSender struct {
+	mu    sync.Mutex
+	stats statMsg
+}
+
+func (s *statSender) Send(msg tea.Msg) {
+	if m, ok := msg.(statMsg); ok {
+		s.mu.Lock()
+		s.stats = m
+		s.mu.Unlock()
+	}
+}
+
+func TestFillMissing(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Pump running", "Pump is running"},
+		{"Valve open", "Text"},
+		{"OK", "Okay"},
+		{"42", ""},
+	}
+	job := newTestJob(t, rows)
+	job.mode = "quick"
+	sender := &statSender{}
+
+	iterateAndTranslate(context.Background(), sender, &upperTranslator{}, job)
+
+	expected := map[string]string{
+		"B2": "MOTOR FAULT",
+		"B3": "Pump is running",
+		"B4": "VALVE OPEN",
+		"B5": "Okay", // short texts are copied, but not over a translation
+		"B6": "42",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if got := sender.stats; got.translated != 2 || got.copied != 1 || got.preserved != 2 {
+		t.Errorf("stats = %+v; expected 2 translated, 1 copied, 2 preserved", got)
+	}
+}
+
+func TestKeepRows(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", "Reviewed motor fault"},
+		{"Pump running", "Old pump text"},
+	}
+	job := newTestJob(t, rows)
+	job.keep = map[int]bool{1: true}
+
+	iterateAndTranslate(context.Background(), discardSender{}, &upperTranslator{}, job)
+
+	expected := map[string]string{
+		"B2": "Reviewed motor fault",
+		"B3": "PUMP RUNNING",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+}
+
+// contextTranslator appends the row context it was given.
+type contextTranslator struct{}
+
+func (contextTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	if c := promptHintsFrom(ctx).context; c != "" {
+		return text + " [" + c + "]", nil
+	}
+	return text + " []", nil
+}
+
+func TestContextColumn(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US", "Comment"},
+		{"Öffnen", "", "Valve V1"},
+		{"Öffnen", "", "File menu"},
+		{"Öffnen", "", "Valve V1"},
+		{"Schließen", "", ""},
+	}
+	job := newTestJob(t, rows)
+	job.contextCol = 3
+
+	iterateAndTranslate(context.Background(), discardSender{}, contextTranslator{}, job)
+
+	expected := map[string]string{
+		"B2": "Öffnen [Valve V1]",
+		"B3": "Öffnen [File menu]",
+		"B4": "Öffnen [Valve V1]",
+		"B5": "Schließen []",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+}
+
+func TestPatternFamilies(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Discrete_alarm_66", ""},
+		{"Motor fault", ""},
+		{"Discrete_alarm_67", ""}, // not next to its family head
+		{"AR: Warning 1", ""},
+		{"Valve_1", ""},
+		{"Valve_2", ""},
+		{"Discrete_alarm_68", ""},
+		{"AR: Warning 2", ""},
+	}
+	job := newTestJob(t, rows)
+	translator := &reorderingTranslator{}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	expected := map[string]string{
+		"B2": "DISCRETE_ALARM_66",
+		"B4": "DISCRETE_ALARM_67",
+		"B8": "DISCRETE_ALARM_68",
+		"B5": "(1) AR: WARNING",
+		"B9": "(2) AR: WARNING", // the head lost its pattern
+		"B6": "VALVE_1",
+		"B7": "VALVE_2",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if len(translator.calls) != 5 {
+		t.Errorf("translator called %d times (%v); expected 5", len(translator.calls), translator.calls)
+	}
+}
+
+// reorderingTranslator upper-cases, but moves the number of "AR: Warning N"
+// to the front like a model might.
+type reorderingTranslator struct {
+	upperTranslator
+}
+
+func (r *reorderingTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	result, err := r.upperTranslator.Translate(ctx, text, sourceLang, targetLang)
+	if base, number, ok := strings.Cut(result, "WARNING "); ok {
+		result = "(" + number + ") " + base + "WARNING"
+	}
+	return result, err
+}
+
+func TestSplitLongTexts(t *testing.T) {
+	long := "Pressure too high. Check the relief valve.\nThen restart the pump."
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{long, ""},
+		{"Short. Text.", ""},
+	}
+	job := newTestJob(t, rows)
+	job.splitSentences = 30
+	translator := &upperTranslator{}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != strings.ToUpper(long) {
+		t.Errorf("B2 = %q; expected %q", got, strings.ToUpper(long))
+	}
+	if got, _ := job.f.GetCellValue(job.sheetName, "B3"); got != "SHORT. TEXT." {
+		t.Errorf("B3 = %q", got)
+	}
+	want := []string{"Check the relief valve.", "Pressure too high.", "Short. Text.", "Then restart the pump."}
+	calls := append([]string(nil), translator.calls...)
+	slices.Sort(calls)
+	if !slices.Equal(calls, want) {
+		t.Errorf("translator called with %q; expected %q", calls, want)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// ///////////////////
+// PIVOT LANGUAGE
+// ///////////////////
+
+// With --via en-US, texts are translated in two passes, first into the
+// pivot language and from there into the target, which models handle
+// better than a direct rare pair such as Czech to Portuguese. The
+// intermediate translations are kept for the provenance records.
+
+// viaFor is the pivot language of a job from sourceLang to targetLang, or
+// "" where a pivot makes no sense because it is one of the two.
+func viaFor(via, sourceLang, targetLang string) string {
+	if strings.EqualFold(via, sourceLang) || strings.EqualFold(via, targetLang) {
+		return ""
+	}
+	return via
+}
+
+// pivotHints are the hints of the first pass. The glossary, the references
+// and the length limit are about the target language and are left for the
+// second.
+func pivotHints(ctx context.Context) context.Context {
+	from := promptHintsFrom(ctx)
+	return withPromptHints(ctx, promptHints{context: from.context, placeholders: from.placeholders, sanitized: from.sanitized})
+}
+
+// pivotLog collects the intermediate translations of a row, by source
+// text, in the order they were made.
+type pivotLog struct {
+	mu      sync.Mutex
+	sources []string
+	texts   map[string]string
+}
+
+type pivotLogKey struct{}
+
+func withPivotLog(ctx context.Context, l *pivotLog) context.Context {
+	return context.WithValue(ctx, pivotLogKey{}, l)
+}
+
+// notePivot records intermediate as the pivot translation of source in the
+// log in ctx, if there is one, replacing an earlier one.
+func notePivot(ctx context.Context, source, intermediate string) {
+	l, ok := ctx.Value(pivotLogKey{}).(*pivotLog)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.texts == nil {
+		l.texts = make(map[string]string)
+	}
+	if _, ok := l.texts[source]; !ok {
+		l.sources = append(l.sources, source)
+	}
+	l.texts[source] = intermediate
+}
+
+// String joins the intermediate translations, e.g. of the segments of a
+// row, with " / ".
+func (l *pivotLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	texts := make([]string, len(l.sources))
+	for i, source := range l.sources {
+		texts[i] = l.texts[source]
+	}
+	return strings.Join(texts, " / ")
+}
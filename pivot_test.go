@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// pairTranslator tags texts with the language pair it translated them
+// between and records the pairs.
+type pairTranslator struct {
+	mu    sync.Mutex
+	pairs []string
+}
+
+func (p *pairTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	p.mu.Lock()
+	p.pairs = append(p.pairs, sourceLang+">"+targetLang)
+	p.mu.Unlock()
+	return fmt.Sprintf("%s(%s)", targetLang, text), nil
+}
+
+func TestViaPivotLanguage(t *testing.T) {
+	rows := [][]string{
+		{"cs-CZ", "pt-BR"},
+		{"Porucha motoru", ""},
+		{"Ventil {0}", ""},
+	}
+	job := newTestJob(t, rows)
+	job.sourceLang, job.targetLang = "cs-CZ", "pt-BR"
+	job.via = "en-US"
+	job.report = &fileReport{}
+	translator := &pairTranslator{}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	want := map[string]string{
+		"B2": "pt-BR(en-US(Porucha motoru))",
+		"B3": "pt-BR(en-US(Ventil {0}))",
+	}
+	for cell, w := range want {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != w {
+			t.Errorf("%s = %q, want %q", cell, got, w)
+		}
+	}
+	if got := strings.Join(translator.pairs, " "); strings.Count(got, "cs-CZ>en-US") != 2 || strings.Count(got, "en-US>pt-BR") != 2 {
+		t.Errorf("requests = %s, want two passes per text", got)
+	}
+	pivots := make(map[string]string)
+	for _, e := range job.report.entries {
+		if e.via != "en-US" {
+			t.Errorf("row %d: via = %q", e.row, e.via)
+		}
+		pivots[e.source] = e.pivot
+	}
+	if got := pivots["Ventil {0}"]; got != "en-US(Ventil {0})" {
+		t.Errorf("pivot = %q", got)
+	}
+}
+
+func TestViaFor(t *testing.T) {
+	tests := []struct {
+		via, source, target, want string
+	}{
+		{"en-US", "cs-CZ", "pt-BR", "en-US"},
+		{"en-US", "en-US", "pt-BR", ""},
+		{"en-us", "cs-CZ", "en-US", ""},
+		{"", "cs-CZ", "pt-BR", ""},
+	}
+	for _, tt := range tests {
+		if got := viaFor(tt.via, tt.source, tt.target); got != tt.want {
+			t.Errorf("viaFor(%q, %q, %q) = %q, want %q", tt.via, tt.source, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+// Package tiatrans is the translation engine of the TIA text translator as a
+// library, for tools that want to translate TIA Portal text exports without
+// the terminal interface.
+//
+// A TranslationJob translates one column of a Workbook with any
+// Translator:
+//
+//	wb, err := tiatrans.OpenWorkbook("export.xlsx")
+//	if err != nil {
+//		return err
+//	}
+//	defer wb.Close()
+//	job := tiatrans.TranslationJob{Workbook: wb, SourceCol: "de-DE", TargetCol: "en-US", FillMissing: true}
+//	stats, err := job.Run(ctx, myTranslator)
+//	if err != nil {
+//		return err
+//	}
+//	err = wb.SaveAs("translated-export.xlsx")
+//
+// The skip and copy rules (RuleSet), pattern families (SplitPattern) and
+// the text checks are the ones the command line tool uses.
+package tiatrans
@@ -0,0 +1,199 @@
+package tiatrans
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ///////////////////
+// TRANSLATION JOB
+// ///////////////////
+
+// TranslationJob translates one source column of a sheet into one target
+// column. Rows are handled like the command line tool does: the skip and
+// copy rules come first, a text is sent only once per sheet, members of a
+// pattern family reuse the translated base of the first one, and Rockwell
+// /*...*/ refs are kept as they are. The tool's extras, such as the
+// translation memory, QA checks, checkpoints and parallel workers, are left
+// to the caller.
+type TranslationJob struct {
+	Workbook  *Workbook
+	Sheet     string // "" = first sheet
+	SourceCol string // 1-based column number or header, see ResolveColumn
+	TargetCol string
+
+	// Languages passed to the Translator; "" = the column headers.
+	SourceLang string
+	TargetLang string
+
+	// FillMissing keeps targets that already hold a translation.
+	FillMissing bool
+	Rules       *RuleSet // nil = built-in rules only
+
+	// Progress, if set, is called after each row with the number of rows
+	// done and the total.
+	Progress func(done, total int)
+}
+
+// Stats counts what a job did with the data rows of the sheet.
+type Stats struct {
+	Translated int // sent to the Translator
+	Reused     int // same text or family earlier in the sheet
+	Copied     int // copied by a rule
+	Skipped    int // left alone by a rule
+	Preserved  int // kept with FillMissing
+	Failed     []RowError
+}
+
+// RowError is a row the Translator failed on. Its target is left as it was.
+type RowError struct {
+	Row int // 1-based sheet row
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// Run translates the sheet with t. Rows that fail are listed in the stats
+// and do not stop the job; an error is returned only if the job cannot
+// start or ctx is cancelled, together with the stats so far.
+func (j *TranslationJob) Run(ctx context.Context, t Translator) (Stats, error) {
+	var stats Stats
+	rows, err := j.Workbook.Rows(j.Sheet)
+	if err != nil {
+		return stats, err
+	}
+	if len(rows) == 0 {
+		return stats, fmt.Errorf("sheet %q is empty", j.Sheet)
+	}
+	src, err := ResolveColumn(rows[0], j.SourceCol)
+	if err != nil {
+		return stats, fmt.Errorf("source column: %w", err)
+	}
+	tgt, err := ResolveColumn(rows[0], j.TargetCol)
+	if err != nil {
+		return stats, fmt.Errorf("target column: %w", err)
+	}
+	sourceLang, targetLang := j.SourceLang, j.TargetLang
+	if sourceLang == "" {
+		sourceLang = strings.TrimSuffix(strings.TrimSpace(rows[0][src]), "*")
+	}
+	if targetLang == "" {
+		targetLang = strings.TrimSuffix(strings.TrimSpace(rows[0][tgt]), "*")
+	}
+	translate := func(text string) (string, error) {
+		return t.Translate(ctx, text, sourceLang, targetLang)
+	}
+
+	done := make(map[string]string)     // source text -> translation
+	families := make(map[string]string) // delim and base -> source of the first member
+	for i := 1; i < len(rows); i++ {
+		if j.Progress != nil {
+			j.Progress(i-1, len(rows)-1)
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		row := rows[i]
+		if src >= len(row) {
+			continue
+		}
+		source := strings.TrimSpace(row[src])
+		var target string
+		if tgt < len(row) {
+			target = strings.TrimSpace(row[tgt])
+		}
+		if source == "" {
+			continue
+		}
+		if j.FillMissing && HasTranslation(target) {
+			stats.Preserved++
+			continue
+		}
+		if r := j.Rules.Decide(source); r != nil {
+			if r.Action == RuleSkip {
+				stats.Skipped++
+				continue
+			}
+			if err := j.Workbook.SetCell(j.Sheet, i, tgt, source); err != nil {
+				return stats, err
+			}
+			stats.Copied++
+			continue
+		}
+
+		result, reused, err := j.translateText(source, done, families, translate)
+		if err != nil {
+			if ctx.Err() != nil {
+				return stats, ctx.Err()
+			}
+			stats.Failed = append(stats.Failed, RowError{Row: i + 1, Err: err})
+			continue
+		}
+		if err := j.Workbook.SetCell(j.Sheet, i, tgt, result); err != nil {
+			return stats, err
+		}
+		if reused {
+			stats.Reused++
+		} else {
+			stats.Translated++
+		}
+	}
+	if j.Progress != nil {
+		j.Progress(len(rows)-1, len(rows)-1)
+	}
+	return stats, nil
+}
+
+// translateText translates source, reusing the translation of the same text
+// or of its pattern family where there is one. reused is true if nothing
+// had to be sent.
+func (j *TranslationJob) translateText(source string, done, families map[string]string, translate func(string) (string, error)) (result string, reused bool, err error) {
+	if result, ok := done[source]; ok {
+		return result, true, nil
+	}
+	defer func() {
+		if err == nil {
+			done[source] = result
+		}
+	}()
+
+	if HasEmbeddedRefs(source) {
+		segments := SplitTextByRefs(source)
+		for i, seg := range segments {
+			if i%2 == 1 || strings.TrimSpace(seg) == "" {
+				continue
+			}
+			translated, err := translate(strings.TrimSpace(seg))
+			if err != nil {
+				return "", false, err
+			}
+			segments[i] = KeepSpacing(seg, translated)
+		}
+		return ReassembleWithRefs(segments), false, nil
+	}
+
+	if tp, ok := SplitPattern(source); ok {
+		family := tp.Delim + "\x00" + tp.Base
+		if head, ok := families[family]; ok {
+			headPattern, _ := SplitPattern(head)
+			if base, delim, ok := headPattern.TranslatedBase(done[head]); ok {
+				if _, err := strconv.Atoi(tp.Suffix); err == nil {
+					return base + delim + tp.Suffix, true, nil
+				}
+				suffix, err := translate(tp.Suffix)
+				if err != nil {
+					return "", false, err
+				}
+				return base + delim + suffix, false, nil
+			}
+		} else {
+			families[family] = source
+		}
+	}
+	result, err = translate(source)
+	return result, false, err
+}
@@ -0,0 +1,103 @@
+package tiatrans
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// upperTranslator upper-cases texts and fails on texts containing "Broken".
+type upperTranslator struct {
+	calls []string
+}
+
+func (u *upperTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	u.calls = append(u.calls, sourceLang+">"+targetLang+":"+text)
+	if strings.Contains(text, "Broken") {
+		return "", errors.New("connection reset")
+	}
+	return strings.ToUpper(text), nil
+}
+
+func TestTranslationJob(t *testing.T) {
+	f := excelize.NewFile()
+	rows := [][]string{
+		{"ID", "de-DE*", "en-US"},
+		{"1", "Motor fault", ""},
+		{"2", "Text", ""},
+		{"3", "##Tag##", ""},
+		{"4", "Motor fault", ""},
+		{"5", "Pump running", "Pump on"},
+		{"6", "Discrete_alarm_1", ""},
+		{"7", "Discrete_alarm_2", ""},
+		{"8", "Broken valve", ""},
+		{"9", "Weight: /*N:6 {Value}*/ kg", ""},
+		{"10", "", ""},
+	}
+	for i, row := range rows {
+		f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", i+1), &row)
+	}
+	wb := NewWorkbook(f)
+	var progress []int
+	job := TranslationJob{
+		Workbook:    wb,
+		SourceCol:   "de-DE",
+		TargetCol:   "3",
+		FillMissing: true,
+		Progress:    func(done, total int) { progress = append(progress, done) },
+	}
+	translator := &upperTranslator{}
+	stats, err := job.Run(context.Background(), translator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"C2":  "MOTOR FAULT",
+		"C3":  "",
+		"C4":  "##Tag##",
+		"C5":  "MOTOR FAULT",
+		"C6":  "Pump on",
+		"C7":  "DISCRETE_ALARM_1",
+		"C8":  "DISCRETE_ALARM_2",
+		"C9":  "",
+		"C10": "WEIGHT: /*N:6 {Value}*/ KG",
+	}
+	for cell, want := range expected {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if stats.Translated != 3 || stats.Reused != 2 || stats.Copied != 1 || stats.Skipped != 1 || stats.Preserved != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if len(stats.Failed) != 1 || stats.Failed[0].Row != 9 {
+		t.Errorf("failed rows = %v; expected row 9", stats.Failed)
+	}
+	if len(translator.calls) != 5 || translator.calls[0] != "de-DE>en-US:Motor fault" {
+		t.Errorf("calls = %q; expected 5 with the header languages", translator.calls)
+	}
+	if len(progress) != len(rows) || progress[len(progress)-1] != len(rows)-1 {
+		t.Errorf("progress = %v", progress)
+	}
+}
+
+func TestTranslationJobCancelled(t *testing.T) {
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]string{"de-DE", "en-US"})
+	f.SetSheetRow("Sheet1", "A2", &[]string{"Motor fault", ""})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job := TranslationJob{Workbook: NewWorkbook(f), SourceCol: "1", TargetCol: "2"}
+	if _, err := job.Run(ctx, &upperTranslator{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v; expected context.Canceled", err)
+	}
+	job.TargetCol = "fr-FR"
+	if _, err := job.Run(context.Background(), &upperTranslator{}); err == nil {
+		t.Error("no error for a missing target column")
+	}
+}
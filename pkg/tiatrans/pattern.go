@@ -0,0 +1,119 @@
+package tiatrans
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ///////////////////
+// PATTERN REUSE
+// ///////////////////
+
+// Alarm lists generated from tags come in families such as
+// Discrete_alarm_66 .. Discrete_alarm_99 or "AR: Warning 1" .. "AR: Warning
+// 40". Only the first member of a family is translated; the others take its
+// translated base and keep their own number, wherever they are in the sheet.
+
+// Pattern is a text split into the base it shares with its family and
+// the part that varies.
+type Pattern struct {
+	Base   string
+	Delim  string // "_", " " or "#"
+	Suffix string
+}
+
+// SplitPattern finds the family of text: a number after the last "_" or the
+// last space, or anything after the first "#". ok is false for texts that
+// belong to no family.
+func SplitPattern(text string) (tp Pattern, ok bool) {
+	if hasUnderscoreNumberPattern(text) {
+		base, suffix := extractBaseAndSuffix(text)
+		return Pattern{Base: base, Delim: "_", Suffix: suffix}, true
+	}
+	if hasSpaceNumberPattern(text) {
+		base, suffix := extractSpaceBaseAndSuffix(text)
+		return Pattern{Base: base, Delim: " ", Suffix: suffix}, true
+	}
+	if before, after, found := strings.Cut(text, "#"); found {
+		return Pattern{Base: strings.TrimSpace(before), Delim: "#", Suffix: strings.TrimSpace(after)}, true
+	}
+	return Pattern{}, false
+}
+
+// TranslatedBase cuts the translation of the family's first member down to
+// its base and the delimiter the translation uses. ok is false if the model
+// did not keep the pattern, e.g. moved the number into the middle; the other
+// members are then translated on their own.
+func (tp Pattern) TranslatedBase(translation string) (base, delim string, ok bool) {
+	if tp.Delim == "#" {
+		if before, _, found := strings.Cut(translation, "#"); found {
+			return before, "#", true
+		}
+		return "", "", false
+	}
+	for _, d := range []string{tp.Delim, "_", " "} {
+		if base, found := strings.CutSuffix(translation, d+tp.Suffix); found && strings.TrimSpace(base) != "" {
+			return base, d, true
+		}
+	}
+	return "", "", false
+}
+
+// hasUnderscoreNumberPattern checks if a text follows the pattern base_number
+func hasUnderscoreNumberPattern(text string) bool {
+	parts := strings.Split(text, "_")
+	if len(parts) < 2 {
+		return false
+	}
+	// Check if the last part is a number
+	_, err := strconv.Atoi(parts[len(parts)-1])
+	return err == nil
+}
+
+// extractBaseAndSuffix splits a text into base and suffix parts
+// For "Discrete_alarm_66", returns ("Discrete_alarm", "66")
+func extractBaseAndSuffix(text string) (string, string) {
+	parts := strings.Split(text, "_")
+	if len(parts) < 2 {
+		return text, ""
+	}
+
+	lastIndex := len(parts) - 1
+	base := strings.Join(parts[:lastIndex], "_")
+	suffix := parts[lastIndex]
+
+	return base, suffix
+}
+
+func hasSpaceNumberPattern(text string) bool {
+	lastSpace := strings.LastIndex(text, " ")
+	if lastSpace == -1 || lastSpace == len(text)-1 {
+		return false
+	}
+	_, err := strconv.Atoi(text[lastSpace+1:])
+	return err == nil
+}
+
+func extractSpaceBaseAndSuffix(text string) (string, string) {
+	lastSpace := strings.LastIndex(text, " ")
+	if lastSpace == -1 || lastSpace == len(text)-1 {
+		return text, ""
+	}
+	base := text[:lastSpace]
+	suffix := text[lastSpace+1:]
+	return base, suffix
+}
+
+// shouldReuseTranslation reports whether two texts belong to the same family
+// and returns the base, the current text's suffix and the delimiter.
+func shouldReuseTranslation(currentText, previousText string) (bool, string, string, string) {
+	current, ok := SplitPattern(currentText)
+	if !ok {
+		return false, "", "", ""
+	}
+	previous, ok := SplitPattern(previousText)
+	if !ok || previous.Base != current.Base || previous.Delim != current.Delim {
+		return false, "", "", ""
+	}
+	return true, current.Base, current.Suffix, current.Delim
+}
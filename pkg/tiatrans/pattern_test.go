@@ -1,4 +1,4 @@
-package main
+package tiatrans
 
 import (
 	"testing"
@@ -231,9 +231,9 @@ func TestHasEmbeddedRefs(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		result := hasEmbeddedRefs(tc.input)
+		result := HasEmbeddedRefs(tc.input)
 		if result != tc.expected {
-			t.Errorf("hasEmbeddedRefs(%q) = %t; expected %t", tc.input, result, tc.expected)
+			t.Errorf("HasEmbeddedRefs(%q) = %t; expected %t", tc.input, result, tc.expected)
 		}
 	}
 }
@@ -321,14 +321,14 @@ func TestSplitTextByRefs(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := splitTextByRefs(tc.input)
+			result := SplitTextByRefs(tc.input)
 			if len(result) != len(tc.expected) {
-				t.Errorf("splitTextByRefs(%q) returned %d segments; expected %d: %v", tc.input, len(result), len(tc.expected), result)
+				t.Errorf("SplitTextByRefs(%q) returned %d segments; expected %d: %v", tc.input, len(result), len(tc.expected), result)
 				return
 			}
 			for i, seg := range result {
 				if seg != tc.expected[i] {
-					t.Errorf("splitTextByRefs(%q)[%d] = %q; expected %q", tc.input, i, seg, tc.expected[i])
+					t.Errorf("SplitTextByRefs(%q)[%d] = %q; expected %q", tc.input, i, seg, tc.expected[i])
 				}
 			}
 		})
@@ -370,10 +370,57 @@ func TestReassembleWithRefs(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := reassembleWithRefs(tc.translated)
+			result := ReassembleWithRefs(tc.translated)
 			if result != tc.expected {
-				t.Errorf("reassembleWithRefs(%v) = %q; expected %q", tc.translated, result, tc.expected)
+				t.Errorf("ReassembleWithRefs(%v) = %q; expected %q", tc.translated, result, tc.expected)
 			}
 		})
 	}
 }
+
+func TestSplitPattern(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Pattern
+		ok       bool
+	}{
+		{"Discrete_alarm_66", Pattern{"Discrete_alarm", "_", "66"}, true},
+		{"AR: Warning 82", Pattern{"AR: Warning", " ", "82"}, true},
+		{"Warning #82 overheat", Pattern{"Warning", "#", "82 overheat"}, true},
+		{"Motor fault", Pattern{}, false},
+		{"NoNumber_Here", Pattern{}, false},
+	}
+
+	for _, tc := range testCases {
+		result, ok := SplitPattern(tc.input)
+		if ok != tc.ok || result != tc.expected {
+			t.Errorf("SplitPattern(%q) = %+v, %t; expected %+v, %t", tc.input, result, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+func TestTranslatedBase(t *testing.T) {
+	testCases := []struct {
+		source       string
+		translation  string
+		expectedBase string
+		expectedDel  string
+		ok           bool
+	}{
+		{"Discrete_alarm_66", "Diskreter_Alarm_66", "Diskreter_Alarm", "_", true},
+		{"Discrete_alarm_66", "Diskreter Alarm 66", "Diskreter Alarm", " ", true},
+		{"AR: Warning 82", "AR: Warnung 82", "AR: Warnung", " ", true},
+		{"Warning#82", "Warnung#82", "Warnung", "#", true},
+		{"AR: Warning 82", "Warnung 82 (AR)", "", "", false},
+		{"Warning#82", "Warnung 82", "", "", false},
+		{"Valve_7", "7", "", "", false},
+	}
+
+	for _, tc := range testCases {
+		tp, _ := SplitPattern(tc.source)
+		base, delim, ok := tp.TranslatedBase(tc.translation)
+		if ok != tc.ok || base != tc.expectedBase || delim != tc.expectedDel {
+			t.Errorf("TranslatedBase(%q -> %q) = %q, %q, %t; expected %q, %q, %t", tc.source, tc.translation, base, delim, ok, tc.expectedBase, tc.expectedDel, tc.ok)
+		}
+	}
+}
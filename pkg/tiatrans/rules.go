@@ -0,0 +1,262 @@
+package tiatrans
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ///////////////////
+// SKIP/COPY RULES
+// ///////////////////
+
+// Which texts are not worth a request differs from plant to plant: one
+// names its tags "M12", another has two-letter words that do need a
+// translation. The decision is made by rules, one per line of a plain text
+// file (--rules in the CLI):
+//
+//	action  match  [value]
+//
+// action is skip (leave the target as it is), copy (copy the source into
+// the target) or translate. The first matching rule wins; the rules of the
+// file are checked before the built-in ones, so a translate rule lifts a
+// built-in skip or copy. Lines starting with # are comments.
+//
+// A line
+//
+//	placeholder  RE
+//
+// declares a placeholder syntax, such as one of WinCC Unified's. A text
+// that RE matches entirely is a placeholder for the placeholder match, and
+// the CLI also keeps the places RE matches inside texts unchanged.
+
+type RuleAction string
+
+const (
+	RuleSkip      RuleAction = "skip"
+	RuleCopy      RuleAction = "copy"
+	RuleTranslate RuleAction = "translate"
+)
+
+// Rule is one line of a rules file.
+type Rule struct {
+	Action RuleAction
+	Match  string // exact, prefix, suffix, regex, shorter, integer, placeholder or separator
+	Value  string
+	re     *regexp.Regexp // for regex
+	n      int            // for shorter, and the percent of separator
+	chars  string         // for separator
+}
+
+// DefaultRules are the built-in rules, in the rules file format.
+const DefaultRules = `# Field placeholders such as ##Tag##, #Tag# or @1@, and alarm texts that
+# only carry their number, such as "Alarm 16: ".
+placeholder  ##.*##
+placeholder  #.*#
+placeholder  @.*@
+placeholder  (?i)alarm\s+\d+:\s*
+# TIA fills empty texts with "Text".
+skip  exact        Text
+copy  placeholder
+copy  shorter      3
+copy  prefix       !
+copy  integer
+# Lines of dashes, underscores, dots, ...
+skip  separator    -_=*. 80%
+`
+
+var builtinRules = mustParseRules(DefaultRules)
+
+func mustParseRules(text string) *RuleSet {
+	rs, err := ParseRules(text)
+	if err != nil {
+		panic(err)
+	}
+	return rs
+}
+
+// ParseRules reads rules and placeholder syntaxes in the rules file format.
+func ParseRules(text string) (*RuleSet, error) {
+	rs := &RuleSet{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if strings.EqualFold(fields[0], "placeholder") {
+			pattern := strings.TrimSpace(text[len(fields[0]):])
+			if pattern == "" {
+				return nil, fmt.Errorf("line %d: placeholder needs a regular expression", line)
+			}
+			// Anchored, so that only texts that are nothing but a
+			// placeholder match as a whole.
+			re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			rs.Placeholders = append(rs.Placeholders, pattern)
+			rs.placeholders = append(rs.placeholders, re)
+			continue
+		}
+		r := Rule{Action: RuleAction(strings.ToLower(fields[0]))}
+		switch r.Action {
+		case RuleSkip, RuleCopy, RuleTranslate:
+		default:
+			return nil, fmt.Errorf("line %d: unknown action %q, must be skip, copy or translate", line, fields[0])
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: missing match", line)
+		}
+		r.Match = strings.ToLower(fields[1])
+		// The value is the rest of the line, so it may contain spaces.
+		r.Value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text[len(fields[0]):]), fields[1]))
+
+		var err error
+		switch r.Match {
+		case "exact", "prefix", "suffix":
+			if r.Value == "" {
+				return nil, fmt.Errorf("line %d: %s needs a text", line, r.Match)
+			}
+		case "regex":
+			if r.re, err = regexp.Compile(r.Value); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		case "shorter":
+			if r.n, err = strconv.Atoi(r.Value); err != nil || r.n < 1 {
+				return nil, fmt.Errorf("line %d: shorter needs a positive number", line)
+			}
+		case "separator":
+			if r.chars, r.n, err = parseSeparator(r.Value); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		case "integer", "placeholder":
+			if r.Value != "" {
+				return nil, fmt.Errorf("line %d: %s takes no value", line, r.Match)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown match %q", line, fields[1])
+		}
+		rs.Rules = append(rs.Rules, r)
+	}
+	return rs, scanner.Err()
+}
+
+// parseSeparator reads the value of a separator match: the separator
+// characters and the percent of the text they must make up, e.g. "-_=~ 70%".
+// Both are optional and default to those of the built-in rule.
+func parseSeparator(value string) (string, int, error) {
+	chars, percent := DefaultSeparatorChars, DefaultSeparatorPercent
+	fields := strings.Fields(value)
+	if len(fields) > 2 {
+		return "", 0, fmt.Errorf("separator takes characters and a percentage, e.g. -_=~ 70%%")
+	}
+	if len(fields) > 0 && !strings.HasSuffix(fields[0], "%") {
+		chars, fields = fields[0], fields[1:]
+	}
+	if len(fields) > 0 {
+		n, err := strconv.Atoi(strings.TrimSuffix(fields[0], "%"))
+		if err != nil || !strings.HasSuffix(fields[0], "%") || n < 1 || n > 100 {
+			return "", 0, fmt.Errorf("separator needs a percentage from 1%% to 100%%, not %q", fields[0])
+		}
+		percent = n
+	}
+	return chars, percent, nil
+}
+
+// LoadRules reads a rules file.
+func LoadRules(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read rules: %w", err)
+	}
+	rs, err := ParseRules(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	rs.Path = path
+	return rs, nil
+}
+
+func (r *Rule) matches(rs *RuleSet, text string) bool {
+	switch r.Match {
+	case "exact":
+		return strings.EqualFold(text, r.Value)
+	case "prefix":
+		return strings.HasPrefix(text, r.Value)
+	case "suffix":
+		return strings.HasSuffix(text, r.Value)
+	case "regex":
+		return r.re.MatchString(text)
+	case "shorter":
+		return utf8.RuneCountInString(text) < r.n
+	case "integer":
+		_, err := strconv.Atoi(text)
+		return err == nil
+	case "placeholder":
+		return rs.IsPlaceholder(text)
+	case "separator":
+		return IsSeparatorLine(text, r.chars, r.n)
+	}
+	return false
+}
+
+// Reason describes the rule in the log and report, e.g. "shorter 3".
+func (r *Rule) Reason() string {
+	if r.Value == "" {
+		return r.Match
+	}
+	return r.Match + " " + r.Value
+}
+
+// RuleSet holds the rules of a rules file. A nil *RuleSet has only the
+// built-in rules.
+type RuleSet struct {
+	Path         string
+	Rules        []Rule
+	Placeholders []string // placeholder syntaxes declared in the file, as written
+
+	placeholders []*regexp.Regexp // Placeholders, anchored at both ends
+}
+
+// IsPlaceholder reports whether text is nothing but a placeholder of a
+// built-in syntax or one declared in rs.
+func (rs *RuleSet) IsPlaceholder(text string) bool {
+	var declared []*regexp.Regexp
+	if rs != nil {
+		declared = rs.placeholders
+	}
+	for _, list := range [][]*regexp.Regexp{declared, builtinRules.placeholders} {
+		for _, re := range list {
+			if re.MatchString(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Decide returns the first rule that matches text, or nil if text is to be
+// translated.
+func (rs *RuleSet) Decide(text string) *Rule {
+	var rules []Rule
+	if rs != nil {
+		rules = rs.Rules
+	}
+	for _, list := range [][]Rule{rules, builtinRules.Rules} {
+		for i := range list {
+			if list[i].matches(rs, text) {
+				if list[i].Action == RuleTranslate {
+					return nil
+				}
+				return &list[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package tiatrans
+
+import "testing"
+
+func TestBuiltinRules(t *testing.T) {
+	testCases := []struct {
+		text   string
+		action RuleAction // "" = translate
+	}{
+		{"Text", RuleSkip},
+		{"##Tag##", RuleCopy},
+		{"@1@", RuleCopy},
+		{"Alarm 16: ", RuleCopy},
+		{"OK", RuleCopy},
+		{"!Motor", RuleCopy},
+		{"42", RuleCopy},
+		{"----------", RuleSkip},
+		{"Motor fault", ""},
+		{"Öle", ""}, // three characters, even if more bytes
+	}
+
+	var rs *RuleSet
+	for _, tc := range testCases {
+		var got RuleAction
+		if r := rs.Decide(tc.text); r != nil {
+			got = r.Action
+		}
+		if got != tc.action {
+			t.Errorf("Decide(%q) = %q; expected %q", tc.text, got, tc.action)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rs, err := ParseRules("# tags\ncopy regex ^M\\d+ [A-Z]$\n\nTRANSLATE exact OK\nskip suffix  (spare)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := rs.Rules
+	if len(rules) != 3 {
+		t.Fatalf("%d rules; expected 3", len(rules))
+	}
+	if rules[0].Value != `^M\d+ [A-Z]$` || rules[1].Action != RuleTranslate || rules[2].Value != "(spare)" {
+		t.Errorf("rules = %+v", rules)
+	}
+
+	for _, text := range []string{
+		"ignore exact OK",
+		"copy",
+		"copy likely foo",
+		"copy regex (",
+		"copy shorter x",
+		"copy shorter 0",
+		"copy integer 5",
+		"skip exact",
+		"placeholder",
+		"placeholder {[",
+		"skip separator -~ 80",
+		"skip separator -~ 0%",
+		"skip separator -~ 80% extra",
+	} {
+		if _, err := ParseRules(text); err == nil {
+			t.Errorf("ParseRules(%q) succeeded; expected an error", text)
+		}
+	}
+}
+
+func TestPlaceholderSyntaxes(t *testing.T) {
+	rs, err := ParseRules("placeholder  \\{[A-Za-z_][\\w.]*\\}\nPLACEHOLDER  \\$\\w+\\$\ncopy placeholder\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Placeholders) != 2 || rs.Placeholders[0] != `\{[A-Za-z_][\w.]*\}` {
+		t.Fatalf("Placeholders = %q", rs.Placeholders)
+	}
+	testCases := []struct {
+		text string
+		want bool
+	}{
+		{"{Tank.Level}", true},
+		{"$Speed$", true},
+		{"##Tag##", true}, // built-in syntaxes still apply
+		{"Alarm 16: ", true},
+		{"Level {Tank.Level}", false},
+		{"{1 2}", false},
+	}
+	for _, tc := range testCases {
+		if got := rs.IsPlaceholder(tc.text); got != tc.want {
+			t.Errorf("IsPlaceholder(%q) = %v; expected %v", tc.text, got, tc.want)
+		}
+		if r := rs.Decide(tc.text); (r != nil && r.Match == "placeholder") != tc.want {
+			t.Errorf("Decide(%q) = %+v; expected a placeholder copy: %v", tc.text, r, tc.want)
+		}
+	}
+	if IsPlaceholder("{Tank.Level}") {
+		t.Error("a declared syntax counts without the rules that declare it")
+	}
+}
+
+func TestSeparatorRule(t *testing.T) {
+	rs, err := ParseRules("copy separator ~#- 60%\ncopy separator 50%\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		text   string
+		action RuleAction // "" = translate
+		reason string
+	}{
+		{"~~~~~~~~", RuleCopy, "separator ~#- 60%"},
+		{"##~~~ Pump ~~~##", RuleCopy, "separator ~#- 60%"}, // 10 of 16
+		{"-----", RuleCopy, "separator ~#- 60%"},
+		{"=== Pump ===", RuleCopy, "separator 50%"}, // default characters
+		{"Pump ~ running", "", ""},
+	}
+	for _, tc := range testCases {
+		var action RuleAction
+		var reason string
+		if r := rs.Decide(tc.text); r != nil {
+			action, reason = r.Action, r.Reason()
+		}
+		if action != tc.action || reason != tc.reason {
+			t.Errorf("Decide(%q) = %q (%s); expected %q (%s)", tc.text, action, reason, tc.action, tc.reason)
+		}
+	}
+	// Without a rule of the file, separators are skipped.
+	if r := (*RuleSet)(nil).Decide("~~~~~~~~"); r != nil {
+		t.Errorf("Decide(~~~~~~~~) with the built-in rules = %+v; expected a translation", r)
+	}
+	if r := (*RuleSet)(nil).Decide("-----"); r == nil || r.Action != RuleSkip {
+		t.Errorf("Decide(-----) with the built-in rules = %+v; expected a skip", r)
+	}
+}
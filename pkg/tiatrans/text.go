@@ -0,0 +1,183 @@
+package tiatrans
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ///////////////////
+// TEXT CHECKS
+// ///////////////////
+
+// HasTranslation reports whether a target cell holds a real translation.
+// Empty cells and TIA's default "Text" (also quoted) count as missing.
+func HasTranslation(target string) bool {
+	check := strings.ToLower(strings.Trim(strings.TrimSpace(target), `"`))
+	return check != "" && check != "text"
+}
+
+// DefaultSeparatorChars and DefaultSeparatorPercent define the visual
+// separators of the built-in rules.
+const (
+	DefaultSeparatorChars   = "-_=*."
+	DefaultSeparatorPercent = 80
+)
+
+// IsVisualSeparator checks if text is mostly visual separators (dashes, underscores, etc.)
+func IsVisualSeparator(text string) bool {
+	return IsSeparatorLine(text, DefaultSeparatorChars, DefaultSeparatorPercent)
+}
+
+// IsSeparatorLine reports whether text is at least 5 characters long and at
+// least percent of its characters are in chars.
+func IsSeparatorLine(text, chars string, percent int) bool {
+	length := utf8.RuneCountInString(text)
+	if length < 5 {
+		return false
+	}
+	separatorChars := 0
+	for _, char := range text {
+		if strings.ContainsRune(chars, char) {
+			separatorChars++
+		}
+	}
+	return separatorChars*100 >= percent*length
+}
+
+// IsPlaceholder reports whether text is a field placeholder such as ##Tag##,
+// #Tag# or @1@, or an alarm text that only carries its number. The
+// syntaxes are the placeholder lines of DefaultRules.
+func IsPlaceholder(text string) bool {
+	var rs *RuleSet
+	return rs.IsPlaceholder(text)
+}
+
+// HasEmbeddedRefs checks if text contains /*...*/ style embedded references
+func HasEmbeddedRefs(text string) bool {
+	return strings.Contains(text, "/*") && strings.Contains(text, "*/")
+}
+
+// embeddedRefRegex matches /*...*/ patterns
+var embeddedRefRegex = regexp.MustCompile(`/\*[^*]*\*/`)
+
+// extractEmbeddedRefs extracts all embedded ref segments from text
+func extractEmbeddedRefs(text string) []string {
+	return embeddedRefRegex.FindAllString(text, -1)
+}
+
+// SplitTextByRefs splits text into alternating segments of text and refs
+// Even indices are text (translatable), odd indices are refs (preserve as-is)
+func SplitTextByRefs(text string) []string {
+	if !HasEmbeddedRefs(text) {
+		return []string{text}
+	}
+
+	var segments []string
+	lastEnd := 0
+
+	matches := embeddedRefRegex.FindAllStringIndex(text, -1)
+
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		// Add text before this ref (even if empty)
+		segments = append(segments, text[lastEnd:start])
+		// Add the ref itself
+		segments = append(segments, text[start:end])
+		lastEnd = end
+	}
+
+	// Add any remaining text after the last ref (even if empty)
+	segments = append(segments, text[lastEnd:])
+
+	return segments
+}
+
+// ReassembleWithRefs joins segments back together, preserving refs
+func ReassembleWithRefs(segments []string) string {
+	return strings.Join(segments, "")
+}
+
+// KeepSpacing gives the translation of a segment the leading and trailing
+// space of the segment, which models tend to drop.
+func KeepSpacing(segment, translation string) string {
+	if strings.HasPrefix(segment, " ") && !strings.HasPrefix(translation, " ") {
+		translation = " " + translation
+	}
+	if strings.HasSuffix(segment, " ") && !strings.HasSuffix(translation, " ") {
+		translation += " "
+	}
+	return translation
+}
+
+// abbreviations end in a full stop without ending a sentence.
+var abbreviations = map[string]bool{
+	"abb.": true, "approx.": true, "bzw.": true, "ca.": true, "cf.": true, "dr.": true,
+	"etc.": true, "evtl.": true, "fig.": true, "ggf.": true, "inkl.": true, "max.": true,
+	"min.": true, "nr.": true, "no.": true, "pos.": true, "s.": true, "usw.": true, "vgl.": true, "vs.": true,
+}
+
+// dottedAbbreviationRegex matches abbreviations such as "z.B." or "e.g.",
+// ordinals such as "1." and initials.
+var dottedAbbreviationRegex = regexp.MustCompile(`^(\p{L}(\.\p{L})*|\d+)\.$`)
+
+// SplitSentences splits text into alternating segments of sentences and the
+// whitespace between them, like SplitTextByRefs: even indices are
+// sentences, odd indices separators to keep as they are. Sentences end at
+// line breaks, and at a full stop, question or exclamation mark followed by
+// a blank and no lowercase letter, unless it ends an abbreviation. Chinese
+// and Japanese sentence marks end one without a blank.
+func SplitSentences(text string) []string {
+	var segments []string
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '。' || r == '！' || r == '？':
+			end := i + size
+			if end < len(text) {
+				segments = append(segments, text[start:end], "")
+				start = end
+			}
+			i = end
+		case unicode.IsSpace(r):
+			end := i
+			for end < len(text) {
+				r, size := utf8.DecodeRuneInString(text[end:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				end += size
+			}
+			if end < len(text) && i > start && sentenceBreak(text[start:i], text[i:end], text[end:]) {
+				segments = append(segments, text[start:i], text[i:end])
+				start = end
+			}
+			i = end
+		default:
+			i += size
+		}
+	}
+	return append(segments, text[start:])
+}
+
+// sentenceBreak reports whether the whitespace space between before and
+// after ends a sentence.
+func sentenceBreak(before, space, after string) bool {
+	if strings.ContainsAny(space, "\r\n") {
+		return true
+	}
+	word := before[strings.LastIndexAny(before, " \t")+1:]
+	word = strings.TrimRight(word, `"')]»”’`)
+	if !strings.HasSuffix(word, ".") && !strings.HasSuffix(word, "!") && !strings.HasSuffix(word, "?") && !strings.HasSuffix(word, "…") {
+		return false
+	}
+	if next, _ := utf8.DecodeRuneInString(after); unicode.IsLower(next) {
+		return false
+	}
+	if strings.HasSuffix(word, ".") && (abbreviations[strings.ToLower(word)] || dottedAbbreviationRegex.MatchString(word)) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package tiatrans
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHasTranslation(t *testing.T) {
+	testCases := []struct {
+		target   string
+		expected bool
+	}{
+		{"", false},
+		{"   ", false},
+		{"Text", false},
+		{" text ", false},
+		{"\"TEXT\"", false},
+		{"\" text \"", true},
+		{"Some text", true},
+		{"Pump", true},
+	}
+	for _, tc := range testCases {
+		if got := HasTranslation(tc.target); got != tc.expected {
+			t.Errorf("HasTranslation(%q) = %t; expected %t", tc.target, got, tc.expected)
+		}
+	}
+}
+
+func TestIsVisualSeparator(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		// Should be true - mostly separators
+		{"---------------------------------------------", true},
+		{"=============================================", true},
+		{"_____________________________________________", true},
+		{"*********************************************", true},
+		{".............................................", true},
+		{"-----", true},
+		{"=====", true},
+		{"_____", true},
+
+		// Should be false - too short
+		{"-", false},
+		{"--", false},
+		{"==", false},
+		{"__", false},
+
+		// Should be false - not mostly separators
+		{"Hello world", false},
+		{"Some-text-with-dashes", false},
+		{"Text with underscores_here", false},
+		{"123-456-789", false},
+		{"A-B-C-D-E", false},
+
+		// Edge cases - mixed but mostly separators
+		{"---------------------------------------------text", true}, // still more than 80% separators
+		{"text---------------------------------------------", true}, // still more than 80% separators
+		{"-----text-----", false},                                   // 10/13 = 0.77 < 0.8
+		{"─────────", false},                                        // not a default separator character
+		{"-----ü", true},                                            // 5 of 6 characters, though ü takes two bytes
+	}
+
+	for _, tc := range testCases {
+		result := IsVisualSeparator(tc.input)
+		if result != tc.expected {
+			t.Errorf("IsVisualSeparator(%q) = %t; expected %t", tc.input, result, tc.expected)
+		}
+	}
+	if !IsSeparatorLine("──── Pumpe ────", "─", 50) {
+		t.Error("IsSeparatorLine with box-drawing characters = false; expected true")
+	}
+}
+
+func TestKeepSpacing(t *testing.T) {
+	testCases := []struct {
+		segment, translation, expected string
+	}{
+		{" läuft ", "running", " running "},
+		{"Motor ", " motor ", " motor "},
+		{"Motor", "motor", "motor"},
+	}
+	for _, tc := range testCases {
+		if got := KeepSpacing(tc.segment, tc.translation); got != tc.expected {
+			t.Errorf("KeepSpacing(%q, %q) = %q; expected %q", tc.segment, tc.translation, got, tc.expected)
+		}
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected []string
+	}{
+		{"Pump fault", []string{"Pump fault"}},
+		{"Pump fault. Check the motor.", []string{"Pump fault.", " ", "Check the motor."}},
+		{"Druck zu hoch.\r\nVentil prüfen!", []string{"Druck zu hoch.", "\r\n", "Ventil prüfen!"}},
+		{"Line one\n\n  Line two", []string{"Line one", "\n\n  ", "Line two"}},
+		{"z.B. Motor prüfen. Dann starten.", []string{"z.B. Motor prüfen.", " ", "Dann starten."}},
+		{"Schritt 1. Motor aus", []string{"Schritt 1. Motor aus"}},
+		{"Druck ca. 3.5 bar. Nr. 4 prüfen?  Ja", []string{"Druck ca. 3.5 bar.", " ", "Nr. 4 prüfen?", "  ", "Ja"}},
+		{"Fault. see manual", []string{"Fault. see manual"}},
+		{`He said "Stop." Then left.`, []string{`He said "Stop."`, " ", "Then left."}},
+		{"温度过高。请检查。", []string{"温度过高。", "", "请检查。"}},
+		{"Valve closed. ", []string{"Valve closed. "}},
+	}
+	for _, tc := range testCases {
+		got := SplitSentences(tc.text)
+		if strings.Join(got, "\x00") != strings.Join(tc.expected, "\x00") {
+			t.Errorf("SplitSentences(%q) = %q; expected %q", tc.text, got, tc.expected)
+		}
+		if strings.Join(got, "") != tc.text {
+			t.Errorf("SplitSentences(%q) does not join back to the text", tc.text)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package tiatrans
+
+import "context"
+
+// Translator is implemented by every translation backend. sourceLang and
+// targetLang are language codes or column headers such as "de-DE".
+type Translator interface {
+	Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error)
+}
@@ -0,0 +1,97 @@
+package tiatrans
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// WORKBOOK
+// ///////////////////
+
+// Workbook is a TIA Portal text export, or any other workbook with one
+// column per language under a header row.
+type Workbook struct {
+	f *excelize.File
+}
+
+// OpenWorkbook opens an .xlsx file.
+func OpenWorkbook(path string) (*Workbook, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+	return &Workbook{f: f}, nil
+}
+
+// NewWorkbook wraps a workbook the caller has opened or built itself.
+func NewWorkbook(f *excelize.File) *Workbook {
+	return &Workbook{f: f}
+}
+
+// File returns the underlying excelize file, e.g. to format cells.
+func (w *Workbook) File() *excelize.File {
+	return w.f
+}
+
+// Sheets lists the sheet names in order.
+func (w *Workbook) Sheets() []string {
+	return w.f.GetSheetList()
+}
+
+// Rows returns the cells of sheet, the header row first. "" is the first
+// sheet.
+func (w *Workbook) Rows(sheet string) ([][]string, error) {
+	if sheet == "" {
+		sheet = w.f.GetSheetName(0)
+	}
+	rows, err := w.f.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("could not read sheet %q: %w", sheet, err)
+	}
+	return rows, nil
+}
+
+// SetCell writes value to the 0-based row and column of sheet.
+func (w *Workbook) SetCell(sheet string, row, col int, value string) error {
+	if sheet == "" {
+		sheet = w.f.GetSheetName(0)
+	}
+	cell, err := excelize.CoordinatesToCellName(col+1, row+1)
+	if err != nil {
+		return err
+	}
+	return w.f.SetCellValue(sheet, cell, value)
+}
+
+// SaveAs writes the workbook to path.
+func (w *Workbook) SaveAs(path string) error {
+	return w.f.SaveAs(path)
+}
+
+// Close releases the workbook's temporary files.
+func (w *Workbook) Close() error {
+	return w.f.Close()
+}
+
+// ResolveColumn turns a column spec into a 0-based column index. The spec is
+// either a 1-based column number or a header name; header matching ignores
+// case and the "*" marker TIA puts on the reference language.
+func ResolveColumn(headers []string, spec string) (int, error) {
+	spec = strings.TrimSpace(spec)
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n < 1 || n > len(headers) {
+			return -1, fmt.Errorf("column %d out of range (1-%d)", n, len(headers))
+		}
+		return n - 1, nil
+	}
+	for i, h := range headers {
+		if strings.EqualFold(strings.TrimSuffix(strings.TrimSpace(h), "*"), strings.TrimSuffix(spec, "*")) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("no column with header %q", spec)
+}
@@ -0,0 +1,36 @@
+package tiatrans
+
+import "testing"
+
+func TestResolveColumn(t *testing.T) {
+	headers := []string{"Text list", "ID", "Reference", "Comment", "de-DE*", "en-US", "fr-FR"}
+
+	testCases := []struct {
+		spec     string
+		expected int
+		wantErr  bool
+	}{
+		{"5", 4, false},
+		{"1", 0, false},
+		{"7", 6, false},
+		{"0", -1, true},
+		{"8", -1, true},
+		{"en-US", 5, false},
+		{"EN-us", 5, false},
+		{"de-DE", 4, false},
+		{"de-DE*", 4, false},
+		{" fr-FR ", 6, false},
+		{"it-IT", -1, true},
+	}
+
+	for _, tc := range testCases {
+		result, err := ResolveColumn(headers, tc.spec)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ResolveColumn(%q) error = %v; wantErr %t", tc.spec, err, tc.wantErr)
+			continue
+		}
+		if result != tc.expected {
+			t.Errorf("ResolveColumn(%q) = %d; expected %d", tc.spec, result, tc.expected)
+		}
+	}
+}
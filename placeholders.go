@@ -0,0 +1,62 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ///////////////////
+// PLACEHOLDER CHECKS
+// ///////////////////
+
+// placeholderTokenRegex matches the tokens HMI texts use for runtime values:
+// TIA tag references (@Tag@, ##Tag##, #1#), printf verbs (%s, %5.2f), .NET
+// style arguments ({0}, {1:N2}), markup such as <b> or <field ref="0"/> and
+// the {{1}} markers that stand in for markup while a text is translated.
+const builtinPlaceholderPattern = `@[^@\s]+@|##[^#]+##|#[^#\s]+#|%(?:\d+\$)?[-+0#]*\d*(?:\.\d+)?[sdfiuxXc]|\{\{\d+\}\}|\{\d+(?:[:,][^{}]*)?\}|</?[A-Za-z][^<>]*>`
+
+var placeholderTokenRegex = regexp.MustCompile(builtinPlaceholderPattern)
+
+// addPlaceholderSyntaxes makes the placeholder syntaxes declared in a
+// --rules file count as placeholders inside texts too: they must come back
+// unchanged, and the case rules leave them alone. They are tried before the
+// built-in ones. The patterns were compiled when the rules were read.
+func addPlaceholderSyntaxes(patterns []string) {
+	if len(patterns) == 0 {
+		return
+	}
+	alternatives := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		alternatives[i] = "(?:" + pattern + ")"
+	}
+	placeholderTokenRegex = regexp.MustCompile(strings.Join(alternatives, "|") + "|" + builtinPlaceholderPattern)
+}
+
+// placeholderTokens lists the placeholders in text, in order and with
+// repeats.
+func placeholderTokens(text string) []string {
+	return placeholderTokenRegex.FindAllString(text, -1)
+}
+
+// missingPlaceholders lists the placeholders of source that translation does
+// not contain unchanged, as often as source has them. A placeholder the model
+// translated, reformatted or dropped breaks the HMI screen at runtime.
+func missingPlaceholders(source, translation string) []string {
+	tokens := placeholderTokens(source)
+	if len(tokens) == 0 {
+		return nil
+	}
+	found := make(map[string]int)
+	for _, token := range placeholderTokens(translation) {
+		found[token]++
+	}
+	var missing []string
+	for _, token := range tokens {
+		if found[token] > 0 {
+			found[token]--
+			continue
+		}
+		missing = append(missing, token)
+	}
+	return missing
+}
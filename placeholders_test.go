@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMissingPlaceholders(t *testing.T) {
+	tests := []struct {
+		source, translation string
+		want                []string
+	}{
+		{"Motor fault", "Motorstörung", nil},
+		{"Level @Tank_Level@ reached", "Füllstand @Tank_Level@ erreicht", nil},
+		{"Level @Tank_Level@ reached", "Füllstand @Tank_Füllstand@ erreicht", []string{"@Tank_Level@"}},
+		{"Value ##Speed## of #1#", "Wert #1# von ##Speed##", nil},
+		{"Value ##Speed##", "Wert ## Speed ##", []string{"##Speed##"}},
+		{"%s of %d items, %5.2f%%", "%s von %d Elementen, %5,2f%%", []string{"%5.2f"}},
+		{"Step {0} of {1}", "Schritt {0} von {0}", []string{"{1}"}},
+		{"Step {0:N2}", "Schritt {0:N2}", nil},
+		{"<b>Stop</b> the pump", "<b>Stoppen</b> Sie die Pumpe", nil},
+		{"<b>Stop</b> the pump", "<fett>Stoppen</fett> Sie die Pumpe", []string{"<b>", "</b>"}},
+		{"a < b > c", "a < b > c", nil},
+		{"80% full", "80 % voll", nil},
+		{"@A@ and @A@", "@A@ und A", []string{"@A@"}},
+	}
+	for _, tt := range tests {
+		if got := missingPlaceholders(tt.source, tt.translation); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("missingPlaceholders(%q, %q) = %q, want %q", tt.source, tt.translation, got, tt.want)
+		}
+	}
+}
+
+// manglingTranslator upper-cases texts, which breaks tag references. Asked
+// with the placeholders listed it keeps them, except printf verbs.
+type manglingTranslator struct {
+	mu     sync.Mutex
+	strict int
+}
+
+func (m *manglingTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	if promptHintsFrom(ctx).placeholders == nil {
+		return strings.ToUpper(text), nil
+	}
+	m.mu.Lock()
+	m.strict++
+	m.mu.Unlock()
+	keep := placeholderTokens(text)
+	result := strings.ToUpper(text)
+	for _, token := range keep {
+		if !strings.HasPrefix(token, "%") {
+			result = strings.Replace(result, strings.ToUpper(token), token, 1)
+		}
+	}
+	return result, nil
+}
+
+func TestPlaceholderRetry(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Level @Tank_Level@ reached", ""},
+		{"Motor fault", ""},
+		{"%s pumps running", ""},
+	}
+	job := newTestJob(t, rows)
+	job.report = &fileReport{}
+	translator := &manglingTranslator{}
+	iterateAndTranslate(context.Background(), discardSender{}, newDedupTranslator(translator), job)
+
+	expected := []struct {
+		cell, value, detail string
+	}{
+		{"B2", "LEVEL @Tank_Level@ REACHED", ""},
+		{"B3", "MOTOR FAULT", ""},
+		{"B4", "%S PUMPS RUNNING", "placeholders changed: %s"},
+	}
+	entries := job.report.sorted()
+	for i, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, want.cell); got != want.value {
+			t.Errorf("%s = %q, want %q", want.cell, got, want.value)
+		}
+		if entries[i].detail != want.detail {
+			t.Errorf("%s detail = %q, want %q", want.cell, entries[i].detail, want.detail)
+		}
+	}
+	if translator.strict != 2 {
+		t.Errorf("%d strict retries, want 2", translator.strict)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// GETTEXT PO FILES
+// ///////////////////
+
+// A .po file is loaded into a sheet with one row per empty msgstr, so the
+// normal pipeline translates it. Entries that already have a translation
+// are left alone. Writing back replaces only the msgstr lines of entries
+// that were filled; comments, flags (including fuzzy), obsolete entries and
+// everything else are copied line by line.
+
+// poLineHeader is the first header of the sheet built from a PO file;
+// detectFileType recognises such sheets by it.
+const poLineHeader = "PO Line"
+
+// poSourceLang is the language msgids are assumed to be written in.
+const poSourceLang = "en"
+
+// poEntry is one block of a PO file.
+type poEntry struct {
+	lines       []string // the block as read, without trailing newlines
+	line        int      // 1-based line number of the block in the file
+	msgctxt     string
+	msgid       string
+	msgidPlural string
+	msgstr      []string // msgstr, or msgstr[0], msgstr[1], ... for plurals
+	plural      bool
+	strStart    int // first line of the msgstr block, -1 if there is none
+	strEnd      int // line after the msgstr block
+}
+
+// poUnit is one translatable msgstr: a sheet row.
+type poUnit struct {
+	entry int
+	form  int
+}
+
+// poDoc is a parsed PO file.
+type poDoc struct {
+	entries  []*poEntry
+	units    []poUnit
+	language string // from the header entry, "" if not set
+}
+
+// isPOFile reports whether fileName should be read as a gettext PO file.
+func isPOFile(fileName string) bool {
+	return strings.EqualFold(filepath.Ext(fileName), ".po")
+}
+
+// poUnquote decodes the C-style string literal of a PO line.
+func poUnquote(s string) string {
+	s = strings.TrimSpace(s)
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return strings.Trim(s, `"`)
+}
+
+// poQuote formats a msgstr value, splitting it after each newline the way
+// gettext tools do.
+func poQuote(keyword, value string) []string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	if !strings.Contains(strings.TrimSuffix(value, "\n"), "\n") {
+		return []string{fmt.Sprintf(`%s "%s"`, keyword, escape.Replace(value))}
+	}
+	lines := []string{keyword + ` ""`}
+	for _, part := range strings.SplitAfter(value, "\n") {
+		if part != "" {
+			lines = append(lines, `"`+escape.Replace(part)+`"`)
+		}
+	}
+	return lines
+}
+
+// parsePO splits a PO file into entries at blank lines.
+func parsePO(data string) (*poDoc, error) {
+	doc := &poDoc{}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+
+	var block []string
+	flush := func(next int) {
+		if block != nil {
+			e := parsePOEntry(block)
+			e.line = next - len(block)
+			doc.entries = append(doc.entries, e)
+			block = nil
+		}
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			flush(i + 1)
+			// Blank lines are kept as their own entries so the layout
+			// survives unchanged.
+			doc.entries = append(doc.entries, &poEntry{lines: []string{line}, line: i + 1, strStart: -1})
+			continue
+		}
+		block = append(block, line)
+	}
+	flush(len(lines) + 1)
+
+	for i, e := range doc.entries {
+		if e.msgid == "" && e.msgctxt == "" {
+			if e.strStart != -1 && len(e.msgstr) > 0 {
+				for _, h := range strings.Split(e.msgstr[0], "\n") {
+					if v, ok := strings.CutPrefix(h, "Language:"); ok {
+						doc.language = strings.TrimSpace(v)
+					}
+				}
+			}
+			continue // header or blank line
+		}
+		for form, s := range e.msgstr {
+			if s == "" {
+				doc.units = append(doc.units, poUnit{entry: i, form: form})
+			}
+		}
+	}
+	if len(doc.units) == 0 {
+		return nil, errors.New("no untranslated entries found in PO file")
+	}
+	return doc, nil
+}
+
+// parsePOEntry reads the keywords of one block. Continuation lines append
+// to whatever keyword came last.
+func parsePOEntry(lines []string) *poEntry {
+	e := &poEntry{lines: lines, strStart: -1}
+	var target *string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			target = nil
+			continue
+		case strings.HasPrefix(trimmed, `"`):
+			if target != nil {
+				*target += poUnquote(trimmed)
+				if e.strStart != -1 && e.strEnd == i {
+					e.strEnd = i + 1
+				}
+			}
+			continue
+		}
+		keyword, value, _ := strings.Cut(trimmed, " ")
+		switch {
+		case keyword == "msgctxt":
+			e.msgctxt = poUnquote(value)
+			target = &e.msgctxt
+		case keyword == "msgid":
+			e.msgid = poUnquote(value)
+			target = &e.msgid
+		case keyword == "msgid_plural":
+			e.msgidPlural = poUnquote(value)
+			e.plural = true
+			target = &e.msgidPlural
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+			if e.strStart == -1 {
+				e.strStart = i
+			}
+			e.strEnd = i + 1
+			e.msgstr = append(e.msgstr, poUnquote(value))
+			target = &e.msgstr[len(e.msgstr)-1]
+		default:
+			target = nil
+		}
+	}
+	return e
+}
+
+// source is the text a form of the entry is translated from.
+func (e *poEntry) source(form int) string {
+	if form > 0 && e.msgidPlural != "" {
+		return e.msgidPlural
+	}
+	return e.msgid
+}
+
+// openPO reads a PO file into a workbook: line number and msgctxt followed
+// by the source and target text.
+func openPO(fileName string) (*excelize.File, *poDoc, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error opening file: %v", err)
+	}
+	doc, err := parsePO(string(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targetLang := doc.language
+	if targetLang == "" {
+		targetLang = "msgstr"
+	}
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	f.SetSheetRow(sheet, "A1", &[]interface{}{poLineHeader, "Context", poSourceLang, targetLang})
+	for i, u := range doc.units {
+		e := doc.entries[u.entry]
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(sheet, cell, &[]interface{}{e.line, e.msgctxt, e.source(u.form), ""}); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	return f, doc, nil
+}
+
+// write saves the PO file with the translations from the sheet.
+func (doc *poDoc) write(f *excelize.File, sheetName, newFileName string) error {
+	changed := make(map[int]bool)
+	for i, u := range doc.units {
+		cell, _ := excelize.CoordinatesToCellName(4, i+2)
+		value, err := f.GetCellValue(sheetName, cell)
+		if err != nil {
+			return err
+		}
+		if value != "" {
+			doc.entries[u.entry].msgstr[u.form] = value
+			changed[u.entry] = true
+		}
+	}
+
+	var b strings.Builder
+	for i, e := range doc.entries {
+		lines := e.lines
+		if changed[i] {
+			var strLines []string
+			for form, s := range e.msgstr {
+				keyword := "msgstr"
+				if e.plural {
+					keyword = fmt.Sprintf("msgstr[%d]", form)
+				}
+				strLines = append(strLines, poQuote(keyword, s)...)
+			}
+			lines = append(append(append([]string(nil), e.lines[:e.strStart]...), strLines...), e.lines[e.strEnd:]...)
+		}
+		for _, line := range lines {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+	return os.WriteFile(newFileName, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const poSample = `# Translation of the SCADA frontend.
+msgid ""
+msgstr ""
+"Language: pl\n"
+"Plural-Forms: nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n"
+
+#: src/alarms.js:12
+#, fuzzy
+msgctxt "alarm"
+msgid "Motor fault"
+msgstr ""
+
+msgid "Pump"
+msgstr "Pompa"
+
+msgid "%d alarm"
+msgid_plural "%d alarms"
+msgstr[0] ""
+msgstr[1] "%d alarmy"
+msgstr[2] ""
+
+#~ msgid "Old text"
+#~ msgstr ""
+`
+
+func TestPORoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "frontend.po")
+	if err := os.WriteFile(in, []byte(poSample), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, doc, err := openPO(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet := f.GetSheetName(0)
+	rows, _ := f.GetRows(sheet)
+	expected := [][]string{
+		{poLineHeader, "Context", "en", "pl"},
+		{"7", "alarm", "Motor fault"},
+		{"16", "", "%d alarm"},
+		{"16", "", "%d alarms"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("rows = %q; expected %q", rows, expected)
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if rows[i][j] != expected[i][j] {
+				t.Errorf("row %d = %q; expected %q", i+1, rows[i], expected[i])
+				break
+			}
+		}
+	}
+
+	f.SetCellValue(sheet, "D2", "Awaria \"silnika\"")
+	f.SetCellValue(sheet, "D3", "%d alarm")
+	f.SetCellValue(sheet, "D4", "%d alarmów\nsprawdź")
+	out := filepath.Join(dir, "out.po")
+	if err := doc.write(f, sheet, out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# Translation of the SCADA frontend.
+msgid ""
+msgstr ""
+"Language: pl\n"
+"Plural-Forms: nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n"
+
+#: src/alarms.js:12
+#, fuzzy
+msgctxt "alarm"
+msgid "Motor fault"
+msgstr "Awaria \"silnika\""
+
+msgid "Pump"
+msgstr "Pompa"
+
+msgid "%d alarm"
+msgid_plural "%d alarms"
+msgstr[0] "%d alarm"
+msgstr[1] "%d alarmy"
+msgstr[2] ""
+"%d alarmów\n"
+"sprawdź"
+
+#~ msgid "Old text"
+#~ msgstr ""
+`
+	got, _ := os.ReadFile(out)
+	if string(got) != want {
+		t.Errorf("output = \n%s\nexpected\n%s", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ///////////////////
+// TERMINOLOGY PRESETS
+// ///////////////////
+
+// A preset is a glossary shipped with the tool, one CSV per vendor in the
+// presets directory with a column per language. With --preset siemens its
+// terms are sent and checked like those of a --glossary file, and the
+// --glossary terms are layered on top.
+
+//go:embed presets/*.csv
+var presetFiles embed.FS
+
+// presetNames lists the built-in presets, sorted.
+func presetNames() []string {
+	entries, _ := presetFiles.ReadDir("presets")
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), path.Ext(entry.Name())))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// presetGlossary reads the terms of preset name from sourceLang to
+// targetLang. A preset without columns for the pair gives no terms rather
+// than an error, so --preset can stay on for every target.
+func presetGlossary(name, sourceLang, targetLang string) (*glossary, error) {
+	data, err := presetFiles.ReadFile("presets/" + name + ".csv")
+	if err != nil {
+		return nil, fmt.Errorf("unknown preset %q: must be one of %s", name, strings.Join(presetNames(), ", "))
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse preset %s: %w", name, err)
+	}
+	src, dst := presetColumn(header, sourceLang), presetColumn(header, targetLang)
+	if src == "" || dst == "" || src == dst {
+		return nil, nil
+	}
+	return parseGlossary(bytes.NewReader(data), src, dst)
+}
+
+// presetColumn is the header of the preset column for lang: the same
+// culture, or else the same language, so en-GB uses the en-US terms.
+// Chinese is matched by culture only, as zh-TW is written in other
+// characters than zh-CN.
+func presetColumn(header []string, lang string) string {
+	for _, h := range header {
+		if normalizeLang(h) == normalizeLang(lang) {
+			return h
+		}
+	}
+	base := headerLanguage(lang)
+	if base == "" || base == "zh" {
+		return ""
+	}
+	for _, h := range header {
+		if headerLanguage(h) == base {
+			return h
+		}
+	}
+	return ""
+}
+
+// layered returns the terms of base with those of over on top: a term of
+// over replaces the base term with the same source, ignoring case.
+func (base *glossary) layered(over *glossary) *glossary {
+	if base == nil {
+		return over
+	}
+	if over == nil {
+		return base
+	}
+	g := &glossary{terms: slices.Clone(over.terms)}
+	for _, term := range base.terms {
+		if !slices.ContainsFunc(over.terms, func(o glossaryTerm) bool { return strings.EqualFold(o.source, term.source) }) {
+			g.terms = append(g.terms, term)
+		}
+	}
+	return g
+}
+
+// jobGlossary is the glossary of a job: the --preset terms with the
+// --glossary terms on top, or nil if there is neither.
+func jobGlossary(opts options, sourceLang, targetLang string) (*glossary, error) {
+	var preset, terms *glossary
+	var err error
+	if opts.preset != "" {
+		if preset, err = presetGlossary(opts.preset, sourceLang, targetLang); err != nil {
+			return nil, err
+		}
+	}
+	if opts.glossaryPath != "" {
+		if terms, err = loadGlossary(opts.glossaryPath, sourceLang, targetLang); err != nil {
+			return nil, err
+		}
+	}
+	return preset.layered(terms), nil
+}
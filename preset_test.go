@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPresetGlossary(t *testing.T) {
+	tests := []struct {
+		source, target string
+		term           string
+		want           string // "" = term not in the glossary
+	}{
+		{"de-DE", "en-US", "Not-Halt", "Emergency stop"},
+		{"de-DE", "en-GB", "Störung", "Fault"},
+		{"de-DE", "fr-FR", "FB", "FB"},
+		{"de-DE", "it-IT", "Freigabe", "Abilitazione"},
+		{"en-US", "fr-FR", "Emergency stop", "Arrêt d'urgence"},
+		{"de-DE", "zh-CN", "Störung", "故障"},
+		{"de-DE", "zh-TW", "Störung", ""},
+		{"de-DE", "cs-CZ", "Störung", ""},
+	}
+	for _, tt := range tests {
+		g, err := presetGlossary("siemens", tt.source, tt.target)
+		if err != nil {
+			t.Fatalf("%s->%s: %v", tt.source, tt.target, err)
+		}
+		if got := presetTarget(g, tt.term); got != tt.want {
+			t.Errorf("%s->%s %q = %q, want %q", tt.source, tt.target, tt.term, got, tt.want)
+		}
+	}
+	if _, err := presetGlossary("rockwell", "de-DE", "en-US"); err == nil || !strings.Contains(err.Error(), "siemens") {
+		t.Errorf("unknown preset: err = %v, want the list of presets", err)
+	}
+}
+
+func TestPresetLayering(t *testing.T) {
+	preset, err := presetGlossary("siemens", "de-DE", "en-US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := parseGlossary(strings.NewReader("source,target\nstörung,Malfunction\nFörderband,Conveyor\n"), "de-DE", "en-US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := preset.layered(user)
+	for term, want := range map[string]string{"Störung": "Malfunction", "Förderband": "Conveyor", "Not-Halt": "Emergency stop"} {
+		if got := presetTarget(g, term); got != want {
+			t.Errorf("%q = %q, want %q", term, got, want)
+		}
+	}
+	if len(g.terms) != len(preset.terms)+1 {
+		t.Errorf("%d terms, want %d", len(g.terms), len(preset.terms)+1)
+	}
+	if got := (*glossary)(nil).layered(user); got != user {
+		t.Errorf("no preset: got %v, want the user glossary", got)
+	}
+}
+
+func presetTarget(g *glossary, source string) string {
+	if g == nil {
+		return ""
+	}
+	for _, term := range g.terms {
+		if strings.EqualFold(term.source, source) {
+			return term.target
+		}
+	}
+	return ""
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// PREVIOUS RUN
+// ///////////////////
+
+// previousKeyHeaders name the columns that identify a text across exports:
+// TIA Portal and WinCC Unified write a text list, an ID and a reference.
+var previousKeyHeaders = []string{"text list", "id", "reference"}
+
+// previousRun holds the translations of one target column in the output of
+// an earlier run (--previous). A row of the new export takes its previous
+// translation if its source text is unchanged; new and changed texts are
+// translated.
+//
+// Translations a reviewer changed by hand after that run are told apart
+// from the tool's by comparing them with --previous-base, the output as the
+// tool wrote it, or else with the translation memory. They win over the
+// tool's, and a changed source text that had one is reported as a conflict.
+type previousRun struct {
+	keyCols  []int                    // key columns in the new sheet
+	byKey    map[string]previousEntry // key columns -> source and translation
+	bySource map[string]previousEntry // source -> translation, for rows without a known key
+}
+
+type previousEntry struct {
+	source      string
+	translation string
+	edited      bool // by a reviewer, after the run that wrote it
+}
+
+// previousMatch is what the previous run has for a row of the new export.
+type previousMatch struct {
+	translation string
+	edited      bool
+	oldSource   string // set for a conflict: the reviewer's translation is of this older text
+}
+
+// previousSheet is the source and target column of a sheet of an earlier
+// output, with the keys of its rows.
+type previousSheet struct {
+	rows     [][]string
+	src, tgt int
+	keyCols  []int // in rows, one per name in keyNames that it has
+	keyNames []string
+}
+
+// readPreviousSheet reads a sheet of an earlier output for --previous or
+// --previous-base (flag). The sheet is looked up by name, or is the only
+// one; the columns are looked up by their headers.
+func readPreviousSheet(opts options, flag, path, sheetName string, headers []string, sourceIndex, targetIndex int, keyNames []string) (*previousSheet, error) {
+	f, _, _, _, err := openInput(opts, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", flag, err)
+	}
+	defer f.Close()
+	sheets := f.GetSheetList()
+	if !slices.Contains(sheets, sheetName) {
+		if len(sheets) != 1 {
+			return nil, fmt.Errorf("%s: %s has no sheet %q", flag, path, sheetName)
+		}
+		sheetName = sheets[0]
+	}
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", flag, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: sheet %q of %s is empty", flag, sheetName, path)
+	}
+	ps := &previousSheet{rows: rows}
+	if ps.src, err = tiatrans.ResolveColumn(rows[0], headers[sourceIndex]); err != nil {
+		return nil, fmt.Errorf("%s: source column: %v", flag, err)
+	}
+	if ps.tgt, err = tiatrans.ResolveColumn(rows[0], headers[targetIndex]); err != nil {
+		return nil, fmt.Errorf("%s: target column: %v", flag, err)
+	}
+	for _, name := range keyNames {
+		if col, err := tiatrans.ResolveColumn(rows[0], name); err == nil {
+			ps.keyCols = append(ps.keyCols, col)
+			ps.keyNames = append(ps.keyNames, name)
+		}
+	}
+	return ps, nil
+}
+
+// each calls fn with the key, source and translation of every row that has
+// a translation.
+func (ps *previousSheet) each(fn func(key string, hasKey bool, source, translation string)) {
+	for _, row := range ps.rows[1:] {
+		source, translation := cellAt(row, ps.src), cellAt(row, ps.tgt)
+		if source == "" || !tiatrans.HasTranslation(translation) {
+			continue
+		}
+		key, hasKey := rowKey(row, ps.keyCols)
+		fn(key, hasKey, source, translation)
+	}
+}
+
+// loadPrevious reads the translations of a target column from --previous,
+// and marks those a reviewer edited.
+func loadPrevious(opts options, sheetName string, headers []string, sourceIndex, targetIndex int, tm *translationMemory) (*previousRun, error) {
+	var keyNames []string
+	for _, name := range previousKeyHeaders {
+		if _, err := tiatrans.ResolveColumn(headers, name); err == nil {
+			keyNames = append(keyNames, name)
+		}
+	}
+	prev, err := readPreviousSheet(opts, "--previous", opts.previousPath, sheetName, headers, sourceIndex, targetIndex, keyNames)
+	if err != nil {
+		return nil, err
+	}
+	pr := &previousRun{byKey: make(map[string]previousEntry), bySource: make(map[string]previousEntry)}
+	for _, name := range prev.keyNames {
+		col, _ := tiatrans.ResolveColumn(headers, name)
+		pr.keyCols = append(pr.keyCols, col)
+	}
+
+	// What the tool wrote, to tell the reviewer's edits from it.
+	baseByKey := make(map[string]string)
+	baseBySource := make(map[string]string)
+	if opts.previousBasePath != "" {
+		base, err := readPreviousSheet(opts, "--previous-base", opts.previousBasePath, sheetName, headers, sourceIndex, targetIndex, prev.keyNames)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Equal(base.keyNames, prev.keyNames) {
+			return nil, fmt.Errorf("--previous-base: the key columns %s are not the same as in --previous", strings.Join(base.keyNames, ", "))
+		}
+		base.each(func(key string, hasKey bool, source, translation string) {
+			if hasKey {
+				baseByKey[key] = translation
+			}
+			if _, ok := baseBySource[source]; !ok {
+				baseBySource[source] = translation
+			}
+		})
+	}
+	wrote := func(key string, hasKey bool, source string) (string, bool) {
+		if opts.previousBasePath != "" {
+			if hasKey {
+				translation, ok := baseByKey[key]
+				return translation, ok
+			}
+			translation, ok := baseBySource[source]
+			return translation, ok
+		}
+		if tm == nil {
+			return "", false
+		}
+		translation, ok, _ := tm.lookup(source, headers[sourceIndex], headers[targetIndex])
+		return translation, ok
+	}
+
+	prev.each(func(key string, hasKey bool, source, translation string) {
+		e := previousEntry{source: source, translation: translation}
+		if written, ok := wrote(key, hasKey, source); ok {
+			e.edited = written != translation
+		}
+		if hasKey {
+			pr.byKey[key] = e
+		}
+		// A reviewer's translation of a text wins over the tool's.
+		if old, ok := pr.bySource[source]; !ok || e.edited && !old.edited {
+			pr.bySource[source] = e
+		}
+	})
+	return pr, nil
+}
+
+// lookup returns what the previous run has for a row. A row whose key was
+// in the previous file matches its own old text; a new row matches the same
+// text anywhere in it. ok is false if the text is to be translated, which
+// for a changed text with a reviewer's edit comes with the conflict.
+func (pr *previousRun) lookup(row []string, source string) (m previousMatch, ok bool) {
+	if pr == nil {
+		return m, false
+	}
+	if key, hasKey := rowKey(row, pr.keyCols); hasKey {
+		if e, found := pr.byKey[key]; found {
+			if e.source == source {
+				return previousMatch{translation: e.translation, edited: e.edited}, true
+			}
+			if e.edited {
+				return previousMatch{translation: e.translation, edited: true, oldSource: e.source}, false
+			}
+			return m, false
+		}
+	}
+	if e, found := pr.bySource[source]; found {
+		return previousMatch{translation: e.translation, edited: e.edited}, true
+	}
+	return m, false
+}
+
+// rowKey joins the key columns of a row; ok is false if they are all empty.
+func rowKey(row []string, cols []int) (key string, ok bool) {
+	parts := make([]string, len(cols))
+	for i, col := range cols {
+		parts[i] = cellAt(row, col)
+		ok = ok || parts[i] != ""
+	}
+	return strings.Join(parts, "\x00"), ok
+}
+
+func cellAt(row []string, col int) string {
+	if col < len(row) {
+		return strings.TrimSpace(row[col])
+	}
+	return ""
+}
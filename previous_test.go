@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestPrevious(t *testing.T) {
+	dir := t.TempDir()
+	previousPath := filepath.Join(dir, "translated-old.xlsx")
+	writeSheet(t, previousPath, [][]string{
+		{"ID", "de-DE*", "en-US"},
+		{"1", "Pumpe läuft", "Pump running"},
+		{"2", "Ventil offen", "Valve open"},
+		{"3", "Motor aus", "Text"},
+	})
+
+	testCases := []struct {
+		name     string
+		headers  []string
+		rows     [][]string
+		expected []string // targets after the run
+		sent     []string
+	}{
+		{
+			"by ID",
+			[]string{"ID", "de-DE", "en-US"},
+			[][]string{
+				{"1", "Pumpe läuft", ""},        // unchanged
+				{"2", "Ventil geschlossen", ""}, // changed
+				{"3", "Motor aus", ""},          // no translation before
+				{"4", "Ventil offen", ""},       // new row, known text
+			},
+			[]string{"Pump running", "VENTIL GESCHLOSSEN", "MOTOR AUS", "Valve open"},
+			[]string{"Motor aus", "Ventil geschlossen"},
+		},
+		{
+			"by text without an ID column",
+			[]string{"de-DE", "en-US"},
+			[][]string{
+				{"Ventil offen", "old"},
+				{"Pumpe steht", ""},
+			},
+			[]string{"Valve open", "PUMPE STEHT"},
+			[]string{"Pumpe steht"},
+		},
+	}
+
+	for _, tc := range testCases {
+		rows := append([][]string{tc.headers}, tc.rows...)
+		job := newTestJob(t, rows)
+		job.sourceIndex = len(tc.headers) - 2
+		job.targetIndex = len(tc.headers) - 1
+		previous, err := loadPrevious(options{previousPath: previousPath}, job.sheetName, tc.headers, job.sourceIndex, job.targetIndex, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.previous = previous
+		translator := &upperTranslator{}
+		stats := iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+		for i, want := range tc.expected {
+			if got := job.cellValue(i + 1); got != want {
+				t.Errorf("%s: row %d = %q; expected %q", tc.name, i+2, got, want)
+			}
+		}
+		slices.Sort(translator.calls)
+		if !slices.Equal(translator.calls, tc.sent) {
+			t.Errorf("%s: sent %q; expected %q", tc.name, translator.calls, tc.sent)
+		}
+		if want := len(tc.rows) - len(tc.sent); stats.reused != want {
+			t.Errorf("%s: %d reused; expected %d", tc.name, stats.reused, want)
+		}
+	}
+}
+
+func TestPreviousReviewerEdits(t *testing.T) {
+	dir := t.TempDir()
+	reviewedPath := filepath.Join(dir, "reviewed-old.xlsx")
+	writeSheet(t, reviewedPath, [][]string{
+		{"ID", "de-DE", "en-US"},
+		{"1", "Pumpe läuft", "Pump is running"},
+		{"2", "Ventil offen", "Valve opened"},
+		{"3", "Motor aus", "Motor off"},
+	})
+	basePath := filepath.Join(dir, "translated-old.xlsx")
+	writeSheet(t, basePath, [][]string{
+		{"ID", "de-DE", "en-US"},
+		{"1", "Pumpe läuft", "Pump running"},
+		{"2", "Ventil offen", "Valve open"},
+		{"3", "Motor aus", "Motor off"},
+	})
+	rows := [][]string{
+		{"ID", "de-DE", "en-US"},
+		{"1", "Pumpe läuft", ""},        // edited, unchanged
+		{"2", "Ventil geschlossen", ""}, // edited, changed: conflict
+		{"3", "Motor aus", ""},          // not edited
+		{"4", "Ventil offen", ""},       // new row, edited text
+	}
+	expected := []string{"Pump is running", "VENTIL GESCHLOSSEN", "Motor off", "Valve opened"}
+	statuses := []rowStatus{rowEdited, rowTranslated, rowPrevious, rowEdited}
+
+	for _, base := range []string{"--previous-base", "translation memory"} {
+		opts := options{previousPath: reviewedPath}
+		if base == "--previous-base" {
+			opts.previousBasePath = basePath
+		}
+		// The memory has what the tool translated, from which the
+		// reviewer's edits differ.
+		tm, err := openTM(filepath.Join(t.TempDir(), "tm.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tm.Close()
+		for source, translation := range map[string]string{"Pumpe läuft": "Pump running", "Ventil offen": "Valve open", "Motor aus": "Motor off"} {
+			if err := tm.store(source, "de-DE", "en-US", translation); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		job := newTestJob(t, rows)
+		job.sourceIndex, job.targetIndex = 1, 2
+		job.tm = tm
+		previous, err := loadPrevious(opts, job.sheetName, rows[0], 1, 2, tm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.previous = previous
+		job.report = &fileReport{}
+		stats := iterateAndTranslate(context.Background(), discardSender{}, &upperTranslator{}, job)
+
+		byRow := make(map[int]reportEntry)
+		for _, e := range job.report.entries {
+			byRow[e.row] = e
+		}
+		for i, want := range expected {
+			if got := job.cellValue(i + 1); got != want {
+				t.Errorf("%s: row %d = %q; expected %q", base, i+2, got, want)
+			}
+			if got := byRow[i+2].status; got != statuses[i] {
+				t.Errorf("%s: row %d is %q; expected %q", base, i+2, got, statuses[i])
+			}
+		}
+		if detail := byRow[3].detail; !strings.Contains(detail, "conflict") || !strings.Contains(detail, "Valve opened") {
+			t.Errorf("%s: row 3 detail %q; expected the conflict with the reviewer's edit", base, detail)
+		}
+		if stats.flagged != 1 {
+			t.Errorf("%s: %d rows flagged; expected 1", base, stats.flagged)
+		}
+		// The reviewer's edits went into the translation memory.
+		if got, _, _ := tm.lookup("Pumpe läuft", "de-DE", "en-US"); got != "Pump is running" {
+			t.Errorf("%s: memory has %q for Pumpe läuft", base, got)
+		}
+	}
+}
+
+func writeSheet(t *testing.T, path string, rows [][]string) {
+	t.Helper()
+	f := excelize.NewFile()
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		f.SetSheetRow("Sheet1", cell, &row)
+	}
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+}
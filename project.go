@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ///////////////////
+// PROJECT FILES
+// ///////////////////
+
+// A project file (--project FILE.tiatrans) keeps what was chosen for a file
+// in the forms, and how its last run went, so that "translator open FILE"
+// repeats the job on the next export without asking again, or resumes it
+// if it was interrupted. Paths in it are relative to the project file.
+
+// projectExt is the extension of project files.
+const projectExt = ".tiatrans"
+
+type project struct {
+	File       string          `json:"file"`
+	Sheet      string          `json:"sheet"`
+	Source     string          `json:"source"`  // header of the source column
+	Targets    []string        `json:"targets"` // headers of the target columns
+	ContextCol string          `json:"context_col,omitempty"`
+	Mode       string          `json:"mode"`
+	Glossary   string          `json:"glossary,omitempty"`
+	Preset     string          `json:"preset,omitempty"`
+	Provider   string          `json:"provider"`
+	Model      string          `json:"model,omitempty"`
+	Progress   projectProgress `json:"progress"`
+}
+
+// projectProgress is how the last run of a project went.
+type projectProgress struct {
+	LastRun    string   `json:"last_run,omitempty"`
+	Outputs    []string `json:"outputs,omitempty"`
+	Partial    bool     `json:"partial,omitempty"` // cancelled or stopped by the budget; open resumes it
+	Translated int      `json:"translated"`
+	Errors     int      `json:"errors"`
+}
+
+// newProject records the settings a task was set up with. Paths are made
+// relative to the project file at path.
+func newProject(path string, opts options, task *fileTask) *project {
+	job := task.jobs[0]
+	p := &project{
+		File:     relativeTo(path, task.fileName),
+		Sheet:    strings.Join(task.sheets, ","),
+		Source:   job.sourceLang,
+		Targets:  task.targetLangs(),
+		Mode:     job.mode,
+		Provider: opts.provider,
+		Model:    opts.model,
+	}
+	if opts.sheet == "*" {
+		p.Sheet = "*"
+	}
+	if job.contextCol > 0 {
+		p.ContextCol = task.headers[job.contextCol-1]
+	}
+	if opts.glossaryPath != "" {
+		p.Glossary = relativeTo(path, opts.glossaryPath)
+	}
+	p.Preset = opts.preset
+	return p
+}
+
+// loadProject reads a project file.
+func loadProject(path string) (*project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read project: %w", err)
+	}
+	var p project
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("could not parse project %s: %w", path, err)
+	}
+	if p.File == "" || p.Source == "" || len(p.Targets) == 0 {
+		return nil, fmt.Errorf("project %s has no file or columns", path)
+	}
+	return &p, nil
+}
+
+// save writes the project file atomically.
+func (p *project) save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("could not write project: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("could not write project: %w", err)
+	}
+	return nil
+}
+
+// finished records the outcome of a run.
+func (p *project) finished(path string, result *runResult) {
+	summary, _ := result.summary()
+	outputs := result.files()
+	partial := result.partialFiles()
+	p.Progress = projectProgress{
+		LastRun:    time.Now().UTC().Format(time.RFC3339),
+		Partial:    len(partial) > 0,
+		Translated: summary.translated,
+		Errors:     summary.errors,
+	}
+	for _, output := range append(outputs, partial...) {
+		p.Progress.Outputs = append(p.Progress.Outputs, relativeTo(path, output))
+	}
+}
+
+// args are the translate flags that repeat the project at path. An
+// interrupted run is resumed from its checkpoint.
+func (p *project) args(path string) []string {
+	args := []string{
+		"--project", path,
+		"--file", resolveFrom(path, p.File),
+		"--sheet", p.Sheet,
+		"--source-col", p.Source,
+		"--target-col", strings.Join(p.Targets, ","),
+		"--mode", p.Mode,
+		"--provider", p.Provider,
+	}
+	if p.ContextCol != "" {
+		args = append(args, "--context-col", p.ContextCol)
+	}
+	if p.Glossary != "" {
+		args = append(args, "--glossary", resolveFrom(path, p.Glossary))
+	}
+	if p.Preset != "" {
+		args = append(args, "--preset", p.Preset)
+	}
+	if p.Model != "" {
+		args = append(args, "--model", p.Model)
+	}
+	if _, err := os.Stat(checkpointPath(resolveFrom(path, p.File))); err == nil {
+		args = append(args, "--resume")
+	}
+	return args
+}
+
+// relativeTo returns file relative to the directory of the project file at
+// path, if it can be.
+func relativeTo(path, file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return file
+	}
+	if rel, err := filepath.Rel(dir, abs); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
+
+// resolveFrom is the inverse of relativeTo.
+func resolveFrom(path, file string) string {
+	file = filepath.FromSlash(file)
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(filepath.Dir(path), file)
+}
+
+// runOpenCommand repeats or resumes the job of a project file. Flags after
+// the file are passed on to translate and win over the project's.
+func runOpenCommand(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("usage: open PROJECT" + projectExt + " [FLAGS]")
+	}
+	path := args[0]
+	p, err := loadProject(path)
+	if err != nil {
+		return err
+	}
+	return runTranslateCommand(append(p.args(path), args[1:]...))
+}
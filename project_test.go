@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProject(t *testing.T) {
+	dir := t.TempDir()
+	exports := filepath.Join(dir, "exports")
+	if err := os.Mkdir(exports, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(exports, "TextExport.xlsx")
+	writeSheet(t, input, [][]string{
+		{"ID", "Comment", "de-DE", "en-US", "fr-FR"},
+		{"1", "Pump P1", "Pumpe läuft", "", ""},
+	})
+	glossaryPath := filepath.Join(dir, "terms.csv")
+	if err := os.WriteFile(glossaryPath, []byte("de-DE,en-US,fr-FR\nPumpe,pump,pompe\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{mode: "quick", sourceCol: "3", targetCol: "en-US,5", contextCol: "Comment", glossaryPath: glossaryPath, provider: "ollama", model: "llama3.1"}
+	task, err := prepareFile(opts, input, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.f.Close()
+	path := filepath.Join(dir, "plant"+projectExt)
+	if err := newProject(path, opts, task).save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := loadProject(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.File != "exports/TextExport.xlsx" || p.Glossary != "terms.csv" {
+		t.Errorf("paths %q and %q are not relative to the project", p.File, p.Glossary)
+	}
+	want := []string{
+		"--project", path,
+		"--file", input,
+		"--sheet", "Sheet1",
+		"--source-col", "de-DE",
+		"--target-col", "en-US,fr-FR",
+		"--mode", "quick",
+		"--provider", "ollama",
+		"--context-col", "Comment",
+		"--glossary", glossaryPath,
+		"--model", "llama3.1",
+	}
+	if args := p.args(path); !slices.Equal(args, want) {
+		t.Errorf("args %q; expected %q", args, want)
+	}
+
+	// An interrupted run is resumed.
+	if err := os.WriteFile(checkpointPath(input), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if args := p.args(path); args[len(args)-1] != "--resume" {
+		t.Errorf("args %q do not resume the run", args)
+	}
+
+	result := &runResult{}
+	result.addPartial(filepath.Join(exports, "partial-TextExport.xlsx"))
+	p.finished(path, result)
+	if !p.Progress.Partial || !slices.Equal(p.Progress.Outputs, []string{"exports/partial-TextExport.xlsx"}) || p.Progress.LastRun == "" {
+		t.Errorf("progress %+v", p.Progress)
+	}
+
+	if _, err := loadProject(filepath.Join(dir, "missing"+projectExt)); err == nil || !strings.Contains(err.Error(), "could not read project") {
+		t.Errorf("missing project: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// ///////////////////
+// PROMPT TEMPLATES
+// ///////////////////
+
+// promptData is what a prompt template can refer to.
+type promptData struct {
+	SourceLang string
+	TargetLang string
+	Glossary   string // "'source' -> 'target'" pairs separated by "; ", empty if no term applies
+	Context    string // disambiguation context for the text, empty if there is none
+	Domain     string // --domain, empty if not set
+	Tone       string // --tone: "formal", "informal" or empty
+}
+
+// loadPromptTemplate reads a user prompt from path. The file is a Go text
+// template, e.g.
+//
+//	Translate from {{.SourceLang}} to {{.TargetLang}}. Address the reader formally.
+//	{{if .Glossary}}Use this terminology: {{.Glossary}}.{{end}}
+//
+// The template is tried once with sample data so that typos in placeholder
+// names are reported before the run starts.
+func loadPromptTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read prompt template: %w", err)
+	}
+	tmpl, err := template.New(path).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse prompt template: %w", err)
+	}
+	sample := promptData{SourceLang: "de-DE", TargetLang: "en-US", Glossary: "'Ventil' -> 'valve'", Context: "Pump station", Domain: "industrial automation HMI", Tone: "formal"}
+	if _, err := renderPrompt(tmpl, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// toneInstructions tells the model how to address the reader, so that rows
+// translated in separate requests don't mix "Sie" and "du".
+func toneInstructions(tone string) string {
+	switch tone {
+	case "formal":
+		return " Address the reader formally and consistently (e.g. \"Sie\" in German, \"vous\" in French, \"usted\" in Spanish)."
+	case "informal":
+		return " Address the reader informally and consistently (e.g. \"du\" in German, \"tu\" in French, \"tú\" in Spanish)."
+	}
+	return ""
+}
+
+func renderPrompt(tmpl *template.Template, data promptData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("could not render prompt template: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
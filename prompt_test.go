@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPromptTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     promptData
+		expected string
+		err      string
+	}{
+		{
+			name:     "placeholders",
+			template: "Translate from {{.SourceLang}} to {{.TargetLang}}. Use \"Sie\".\n",
+			data:     promptData{SourceLang: "en-US", TargetLang: "de-DE"},
+			expected: "Translate from en-US to de-DE. Use \"Sie\".",
+		},
+		{
+			name:     "glossary present",
+			template: "Formal tone.{{if .Glossary}} Terms: {{.Glossary}}.{{end}}",
+			data:     promptData{Glossary: "'Ventil' -> 'valve'"},
+			expected: "Formal tone. Terms: 'Ventil' -> 'valve'.",
+		},
+		{
+			name:     "glossary absent",
+			template: "Formal tone.{{if .Glossary}} Terms: {{.Glossary}}.{{end}}",
+			expected: "Formal tone.",
+		},
+		{
+			name:     "domain and tone",
+			template: "You translate {{.Domain}} texts.{{if eq .Tone \"formal\"}} Use \"Sie\".{{end}}",
+			data:     promptData{Domain: "industrial automation HMI", Tone: "formal"},
+			expected: "You translate industrial automation HMI texts. Use \"Sie\".",
+		},
+		{
+			name:     "unknown placeholder",
+			template: "Translate to {{.Target}}.",
+			err:      "can't evaluate field Target",
+		},
+		{
+			name:     "syntax error",
+			template: "Translate to {{.TargetLang}.",
+			err:      "could not parse prompt template",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "prompt.txt")
+			if err := os.WriteFile(path, []byte(tt.template), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			tmpl, err := loadPromptTemplate(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := renderPrompt(tmpl, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ///////////////////
+// PROTECTED TERMS
+// ///////////////////
+
+// Codes in HMI texts must reach the panel unchanged, and a prompt that asks
+// the model to leave them alone does not always work. So they are swapped
+// for markers before a text is sent, like markup, and put back afterwards:
+// I/O addresses such as %Q0.5 or E1.3, order numbers such as
+// 6ES7 214-1AG40-0XB0, tag references such as "Pump".Start, and unit codes
+// such as VDC. --protect adds the terms of a project, such as tag names;
+// names like Valve_1 are often texts to translate, so they are not
+// protected by default.
+
+// codePatterns match addresses, order numbers and tag references.
+var codePatterns = []string{
+	`%DB\d+\.DB[XBWD]\d+(?:\.[0-7])?`,                 // %DB1.DBX0.0
+	`%(?:P?[IQEAM])[XBWD]?\d+(?:\.[0-7])?\b`,          // %Q0.5, %IW64, %MD100
+	`\b[IQEAM]\d+\.[0-7]\b`,                           // E1.3, Q0.5
+	`\b\d[A-Z]{2}\d{1,4}(?:[ -][0-9A-Z]{3,5}){1,3}\b`, // 6ES7 214-1AG40-0XB0
+	`"[A-Za-z_][^"\s]*"(?:\.[A-Za-z_]\w*)+`,           // "Pump".Start
+}
+
+// protectedCodeRegex matches the codes that are always protected.
+var protectedCodeRegex = regexp.MustCompile(strings.Join(append(slices.Clip(codePatterns),
+	`\b(?:VDC|VAC|mA|mV|kW|kVA|kHz|Hz|rpm|mbar|psi)\b|°[CF]\b`,
+), "|"))
+
+// protectedTerms are the terms of a --protect file, longest first so a term
+// wins over one it contains.
+type protectedTerms struct {
+	terms []string
+}
+
+// loadProtectedTerms reads a --protect file: one term per line, kept
+// exactly as written and matched as a whole word. Empty lines and lines
+// starting with # are ignored.
+func loadProtectedTerms(path string) (*protectedTerms, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	p := &protectedTerms{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		term := strings.TrimSpace(scanner.Text())
+		if term == "" || strings.HasPrefix(term, "#") {
+			continue
+		}
+		p.terms = append(p.terms, term)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(p.terms, func(i, j int) bool { return len(p.terms[i]) > len(p.terms[j]) })
+	return p, nil
+}
+
+// protectedSpans returns the byte ranges of markup tags and protected terms
+// in text, in order and without overlaps. Placeholders such as @Tag_1@ are
+// checked on their own and left out, codes inside them too.
+func (p *protectedTerms) protectedSpans(text string, tags bool) [][2]int {
+	var spans [][2]int
+	taken := func(start, end int) bool {
+		for _, s := range spans {
+			if start < s[1] && s[0] < end {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(start, end int) {
+		if !taken(start, end) {
+			spans = append(spans, [2]int{start, end})
+		}
+	}
+	var placeholders [][2]int
+	for _, loc := range markupTagRegex.FindAllStringIndex(text, -1) {
+		if tags {
+			add(loc[0], loc[1])
+		}
+	}
+	for _, loc := range placeholderTokenRegex.FindAllStringIndex(text, -1) {
+		placeholders = append(placeholders, [2]int{loc[0], loc[1]})
+	}
+	inPlaceholder := func(start, end int) bool {
+		for _, s := range placeholders {
+			if start < s[1] && s[0] < end {
+				return true
+			}
+		}
+		return false
+	}
+	if p != nil {
+		for _, term := range p.terms {
+			for offset := 0; ; {
+				i := strings.Index(text[offset:], term)
+				if i < 0 {
+					break
+				}
+				start := offset + i
+				if !inPlaceholder(start, start+len(term)) && !insideWord(text, start, start+len(term)) {
+					add(start, start+len(term))
+				}
+				offset = start + len(term)
+			}
+		}
+	}
+	for _, loc := range protectedCodeRegex.FindAllStringIndex(text, -1) {
+		if !inPlaceholder(loc[0], loc[1]) {
+			add(loc[0], loc[1])
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	return spans
+}
+
+// insideWord reports whether text[start:end] is part of a longer word or
+// number, such as M1 in M12.
+func insideWord(text string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	first, _ := utf8.DecodeRuneInString(text[start:end])
+	last, _ := utf8.DecodeLastRuneInString(text[start:end])
+	word := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	return start > 0 && word(before) && word(first) || end < len(text) && word(after) && word(last)
+}
+
+// find lists the protected terms and codes in text, without markup tags.
+func (p *protectedTerms) find(text string) []string {
+	var found []string
+	for _, s := range p.protectedSpans(text, false) {
+		found = append(found, text[s[0]:s[1]])
+	}
+	return found
+}
+
+// protectedFlags lists the protected terms of source that translation lost.
+func (p *protectedTerms) protectedFlags(source, translation string) []string {
+	var missing []string
+	for _, term := range p.find(source) {
+		if !strings.Contains(translation, term) {
+			missing = append(missing, fmt.Sprintf("%q", term))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return []string{"protected terms changed: " + strings.Join(missing, " ")}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProtectTerms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "protect.txt")
+	if err := os.WriteFile(path, []byte("# tag names\nFoerderband1\n\nM1\nM1_Ready\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	terms, err := loadProtectedTerms(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text   string
+		terms  *protectedTerms
+		masked string
+		tags   []string
+	}{
+		{"Ausgang %Q0.5 gesetzt", nil, "Ausgang {{1}} gesetzt", []string{"%Q0.5"}},
+		{"Eingang E1.3 und %IW64", nil, "Eingang {{1}} und {{2}}", []string{"E1.3", "%IW64"}},
+		{"Baugruppe 6ES7 214-1AG40-0XB0 fehlt", nil, "Baugruppe {{1}} fehlt", []string{"6ES7 214-1AG40-0XB0"}},
+		{"Versorgung 24 VDC <b>fehlt</b>", nil, "Versorgung 24 {{1}} {{2}}fehlt{{3}}", []string{"VDC", "<b>", "</b>"}},
+		{`"Pumpe".Start gesetzt`, nil, "{{1}} gesetzt", []string{`"Pumpe".Start`}},
+		{"Temperatur @1%5.2f@ °C", nil, "Temperatur @1%5.2f@ {{1}}", []string{"°C"}},
+		{"Druck in bar", nil, "Druck in bar", nil},
+		{"M1_Ready von M1 an Foerderband1", terms, "{{1}} von {{2}} an {{3}}", []string{"M1_Ready", "M1", "Foerderband1"}},
+		{"Motor M12 an", terms, "Motor M12 an", nil},
+	}
+	for _, tt := range tests {
+		masked, tags := protectTags(tt.text, tt.terms)
+		if masked != tt.masked || !reflect.DeepEqual(tags, tt.tags) {
+			t.Errorf("protectTags(%q) = %q, %q; want %q, %q", tt.text, masked, tags, tt.masked, tt.tags)
+		}
+		if got := restoreTags(masked, tags); got != tt.text {
+			t.Errorf("restoreTags(%q) = %q, want %q", masked, got, tt.text)
+		}
+	}
+
+	if got := terms.protectedFlags("Ausgang %Q0.5 an M1", "Output %Q 0.5 on M1"); !reflect.DeepEqual(got, []string{`protected terms changed: "%Q0.5"`}) {
+		t.Errorf("protectedFlags = %q", got)
+	}
+}
+
+// codeMangler lower-cases everything, codes included, as a model might.
+type codeMangler struct {
+	sent []string
+}
+
+func (c *codeMangler) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	c.sent = append(c.sent, text)
+	return strings.ToLower(text), nil
+}
+
+func TestProtectedTermsSurvive(t *testing.T) {
+	job := newTestJob(t, [][]string{{"de-DE", "en-US"}, {"Ausgang %Q0.5 gesetzt, 24 VDC fehlt", ""}})
+	job.workers = 1
+	translator := &codeMangler{}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != "ausgang %Q0.5 gesetzt, 24 VDC fehlt" {
+		t.Errorf("B2 = %q", got)
+	}
+	if len(translator.sent) != 1 || strings.Contains(translator.sent[0], "%Q0.5") {
+		t.Errorf("sent %q", translator.sent)
+	}
+}
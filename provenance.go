@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// PROVENANCE
+// ///////////////////
+
+// With --provenance-comments, every target cell the run filled gets a
+// comment that says when, by which provider and model, and from which
+// source text, so reviewers can tell in Excel where a translation came from.
+// Like highlighting, it only affects XLSX output.
+
+// provenanceAuthor is the author of the comments the tool adds.
+const provenanceAuthor = "translator"
+
+// provenanceComment is the comment of a target cell filled as e records;
+// before is what the cell held before the run.
+func provenanceComment(e reportEntry, model, before string) string {
+	lines := []string{
+		fmt.Sprintf("%s, %s", model, e.at.UTC().Format("2006-01-02 15:04:05 MST")),
+		"Status: " + string(e.status),
+		fmt.Sprintf("Source (%s): %s", e.sourceLang, e.source),
+	}
+	if e.pivot != "" {
+		lines = append(lines, fmt.Sprintf("Via (%s): %s", e.via, e.pivot))
+	}
+	if before != "" && before != e.translation {
+		lines = append(lines, "Before translation: "+before)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// addProvenanceComments comments the target cells of task that the run
+// filled and that still hold what it wrote: cells rejected during review
+// are left out, and so are cells that already have a comment. It returns
+// how many cells were commented.
+func addProvenanceComments(task *fileTask, model string) (int, error) {
+	fr := task.jobs[0].report
+	if fr == nil {
+		return 0, nil
+	}
+	// Target columns and their values before the run, by sheet and target.
+	targetCols := make(map[string]int)
+	before := make(map[string]map[int]string) // 1-based row -> value
+	for _, job := range task.jobs {
+		key := job.sheetName + "\x00" + job.targetLang
+		targetCols[key] = job.targetIndex + 1
+		before[key] = make(map[int]string)
+		for i, row := range job.rows {
+			if job.targetIndex < len(row) {
+				before[key][i+1] = row[job.targetIndex]
+			}
+		}
+	}
+
+	commented := make(map[string]map[string]bool)
+	n := 0
+	for _, e := range fr.entries {
+		switch e.status {
+		case rowSkipped, rowPreserved, rowFailed:
+			continue
+		}
+		key := e.sheet + "\x00" + e.targetLang
+		col, ok := targetCols[key]
+		if !ok {
+			continue
+		}
+		if _, ok := commented[e.sheet]; !ok {
+			existing, err := task.f.GetComments(e.sheet)
+			if err != nil {
+				return n, fmt.Errorf("could not read comments of %s: %w", e.sheet, err)
+			}
+			commented[e.sheet] = make(map[string]bool)
+			for _, c := range existing {
+				commented[e.sheet][c.Cell] = true
+			}
+		}
+		cell, _ := excelize.CoordinatesToCellName(col, e.row)
+		if commented[e.sheet][cell] {
+			continue
+		}
+		value, err := task.f.GetCellValue(e.sheet, cell)
+		if err != nil {
+			return n, err
+		}
+		if value != e.translation {
+			continue
+		}
+		err = task.f.AddComment(e.sheet, excelize.Comment{
+			Author: provenanceAuthor,
+			Cell:   cell,
+			Text:   provenanceComment(e, model, before[key][e.row]),
+		})
+		if err != nil {
+			return n, fmt.Errorf("could not comment %s: %w", cell, err)
+		}
+		commented[e.sheet][cell] = true
+		n++
+	}
+	return n, nil
+}
+
+// With --provenance-json, the same and more goes to a file next to the
+// output instead, <output>.provenance.json, so the workbook stays as it is:
+// for every cell the run handled, where the translation came from, the
+// tokens and retries it took and what the QA checks found.
+
+// provenanceFile is the content of a .provenance.json file.
+type provenanceFile struct {
+	File   string           `json:"file"`
+	Output string           `json:"output"`
+	Model  string           `json:"model"`
+	Cells  []provenanceCell `json:"cells"`
+}
+
+// provenanceCell is the metadata of one target cell.
+type provenanceCell struct {
+	Cell        string   `json:"cell"` // e.g. Texts!C2
+	Time        string   `json:"time"`
+	SourceLang  string   `json:"source_lang"`
+	TargetLang  string   `json:"target_lang"`
+	Source      string   `json:"source"`
+	Translation string   `json:"translation"`
+	Via         string   `json:"via,omitempty"`   // pivot language of --via
+	Pivot       string   `json:"pivot,omitempty"` // intermediate translation into it
+	Status      string   `json:"status"`
+	Model       string   `json:"model,omitempty"` // set if the text came from the model rather than e.g. the memory
+	Tokens      int64    `json:"tokens"`
+	Retries     int      `json:"retries"`
+	LatencyMS   int64    `json:"latency_ms"`
+	QA          []string `json:"qa,omitempty"`
+	Doubtful    bool     `json:"doubtful,omitempty"` // rated as likely wrong by --self-check or --review-model
+	Detail      string   `json:"detail,omitempty"`   // reason for a skip or copy, or the error
+}
+
+// provenancePath is the sidecar file of the output file output.
+func provenancePath(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".provenance.json"
+}
+
+// fromModel reports whether the translation of a row with status came from
+// the model in this run.
+func fromModel(status rowStatus) bool {
+	switch status {
+	case rowTranslated, rowReused, rowReusedPrefix:
+		return true
+	}
+	return false
+}
+
+// writeProvenance writes the sidecar file of task's output and returns its
+// name. Cells are listed sheet by sheet and target by target, in row order.
+func writeProvenance(task *fileTask, output, model string) (string, error) {
+	fr := task.jobs[0].report
+	if fr == nil {
+		return "", nil
+	}
+	targetCols := make(map[string]int)
+	for _, job := range task.jobs {
+		targetCols[job.sheetName+"\x00"+job.targetLang] = job.targetIndex + 1
+	}
+	pf := provenanceFile{File: filepath.Base(task.fileName), Output: filepath.Base(output), Model: model, Cells: []provenanceCell{}}
+	for _, e := range fr.sorted() {
+		cell, _ := excelize.CoordinatesToCellName(targetCols[e.sheet+"\x00"+e.targetLang], e.row)
+		pc := provenanceCell{
+			Cell:        e.sheet + "!" + cell,
+			Time:        e.at.Format(time.RFC3339),
+			SourceLang:  e.sourceLang,
+			TargetLang:  e.targetLang,
+			Source:      e.source,
+			Translation: e.translation,
+			Pivot:       e.pivot,
+			Status:      string(e.status),
+			Tokens:      e.tokens,
+			Retries:     e.retries,
+			LatencyMS:   e.latency.Milliseconds(),
+			QA:          e.flags,
+			Doubtful:    e.doubtful,
+		}
+		if fromModel(e.status) {
+			pc.Model = model
+		}
+		if e.pivot != "" {
+			pc.Via = e.via
+		}
+		if len(e.flags) == 0 {
+			pc.Detail = e.detail
+		}
+		pf.Cells = append(pf.Cells, pc)
+	}
+
+	data, err := json.MarshalIndent(pf, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := provenancePath(output)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", fmt.Errorf("could not write provenance: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("could not write provenance: %w", err)
+	}
+	return path, nil
+}
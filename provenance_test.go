@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestProvenanceComments(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "TextExport.xlsx")
+	writeSheet(t, path, [][]string{
+		{"de-DE", "en-US"},
+		{"Pumpe", ""},
+		{"Ventil", "old valve"},
+		{"", ""},
+	})
+	opts := options{workers: 1, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US", provider: "openai", model: "gpt-4o", provenanceComments: true, highlightComments: true}
+	task, err := prepareFile(opts, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &runResult{}
+	runTasks(context.Background(), discardSender{}, &upperTranslator{}, []*fileTask{task}, opts, result)
+
+	f, err := excelize.OpenFile(filepath.Join(dir, "translated-TextExport.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	comments, err := f.GetComments("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, c := range comments {
+		text := c.Text
+		for _, p := range c.Paragraph {
+			text += p.Text
+		}
+		got[c.Cell] = text
+	}
+	want := map[string]*regexp.Regexp{
+		"B2": regexp.MustCompile(`^openai/gpt-4o, \d{4}-\d\d-\d\d \d\d:\d\d:\d\d UTC\nStatus: translated\nSource \(de-DE\): Pumpe$`),
+		"B3": regexp.MustCompile(`\nSource \(de-DE\): Ventil\nBefore translation: old valve$`),
+	}
+	if len(got) != len(want) {
+		t.Errorf("comments %q; expected B2 and B3 only", got)
+	}
+	for cell, re := range want {
+		if !re.MatchString(got[cell]) {
+			t.Errorf("%s comment %q does not match %s", cell, got[cell], re)
+		}
+	}
+}
+
+func TestProvenanceJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "TextExport.xlsx")
+	writeSheet(t, path, [][]string{
+		{"de-DE", "en-US"},
+		{"Flaky pump", ""},
+		{"Broken valve", ""},
+		{"100", ""},
+	})
+	opts := options{workers: 1, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US", provider: "openai", model: "gpt-4o", provenanceJSON: true}
+	task, err := prepareFile(opts, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &runResult{}
+	runTasks(context.Background(), discardSender{}, &flakyTranslator{seen: make(map[string]int)}, []*fileTask{task}, opts, result)
+
+	data, err := os.ReadFile(filepath.Join(dir, "translated-TextExport.provenance.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pf provenanceFile
+	if err := json.Unmarshal(data, &pf); err != nil {
+		t.Fatal(err)
+	}
+	if pf.File != "TextExport.xlsx" || pf.Output != "translated-TextExport.xlsx" || pf.Model != "openai/gpt-4o" {
+		t.Errorf("header %q %q %q", pf.File, pf.Output, pf.Model)
+	}
+	type cell struct {
+		Cell, Translation, Status, Model, Detail string
+		Retries                                  int
+	}
+	var got []cell
+	for _, c := range pf.Cells {
+		if c.Time == "" {
+			t.Errorf("%s has no time", c.Cell)
+		}
+		got = append(got, cell{c.Cell, c.Translation, c.Status, c.Model, c.Detail, c.Retries})
+	}
+	want := []cell{
+		{"Sheet1!B2", "FLAKY PUMP", "translated", "openai/gpt-4o", "", 1},
+		{"Sheet1!B3", "", "error", "", "connection reset", 1},
+		{"Sheet1!B4", "100", "copied", "", "integer", 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cells\n%+v\nexpected\n%+v", got, want)
+	}
+}
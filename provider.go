@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// TRANSLATION PROVIDERS
+// ///////////////////
+
+// Translator is implemented by every translation backend. It is the
+// library's interface, so the providers here also work with
+// tiatrans.TranslationJob.
+type Translator = tiatrans.Translator
+
+// validator is optionally implemented by a Translator that can check its
+// credentials before the run starts.
+type validator interface {
+	Validate(ctx context.Context) error
+}
+
+// providerConfig carries the settings a provider factory may need.
+type providerConfig struct {
+	apiKey  string
+	baseURL string             // overrides the provider's default endpoint
+	model   string             // empty selects the provider's default model
+	prompt  *template.Template // replaces the built-in prompt; nil keeps it
+	domain  string             // subject area named in the prompt, e.g. "industrial automation HMI"
+	tone    string             // "formal", "informal" or "" for no preference
+	limiter *rateLimiter       // fed with the provider's rate-limit headers; may be nil
+	client  *http.Client       // proxy and CA settings; nil uses http.DefaultClient
+	budget  *budget            // --max-tokens and --max-cost; nil = no limit
+}
+
+// promptHints carries per-request extras that prompt-based providers weave
+// into their instructions. Providers without a prompt ignore them.
+type promptHints struct {
+	glossary   []glossaryTerm
+	context    string    // content of the context column for the row, if any
+	references []tmEntry // similar texts from the translation memory
+	session    []tmEntry // earlier texts of the file with the same terms
+
+	// Set when a reply is asked for again: placeholders must be copied
+	// unchanged, the translation must not exceed maxLength characters, a
+	// sanitized prompt frames a refused text as a machine label, and
+	// wrongLanguage names the language a reply came back in instead of
+	// the target.
+	placeholders  []string
+	maxLength     int
+	sanitized     bool
+	wrongLanguage string
+}
+
+// retry reports whether the hints ask again for a reply that was not good
+// enough, which must never be served from a cache.
+func (h promptHints) retry() bool {
+	return h.placeholders != nil || h.maxLength > 0 || h.sanitized || h.wrongLanguage != ""
+}
+
+type promptHintsKey struct{}
+
+func withPromptHints(ctx context.Context, hints promptHints) context.Context {
+	return context.WithValue(ctx, promptHintsKey{}, hints)
+}
+
+func promptHintsFrom(ctx context.Context) promptHints {
+	hints, _ := ctx.Value(promptHintsKey{}).(promptHints)
+	return hints
+}
+
+type providerFactory func(cfg providerConfig) (Translator, error)
+
+// providerSpec describes a registered backend and where its API key lives.
+type providerSpec struct {
+	factory providerFactory
+	keyEnv  string // environment variable holding the key; empty if no key is needed
+	keyFile string // key file looked up next to the executable
+	keyName string // shown in the key prompt
+}
+
+var providers = map[string]providerSpec{}
+
+// registerProvider makes a backend selectable via --provider. Backends call
+// it from an init function in their own file.
+func registerProvider(name string, spec providerSpec) {
+	providers[name] = spec
+}
+
+func providerNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newTranslator(name string, cfg providerConfig) (Translator, error) {
+	spec, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (available: %s)", name, strings.Join(providerNames(), ", "))
+	}
+	return spec.factory(cfg)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ///////////////////
+// RATE LIMITING
+// ///////////////////
+
+// rateLimiter paces requests with two token buckets, one for requests and
+// one for tokens per minute, shared by all workers of a run. Limits not set
+// with --rpm/--tpm are taken from the provider's rate-limit headers once a
+// response carries them; until then that dimension is unlimited. A nil
+// *rateLimiter never waits.
+type rateLimiter struct {
+	mu          sync.Mutex
+	rpm, tpm    float64 // bucket sizes and refill per minute; 0 = unlimited
+	fixedRPM    bool    // set by the user, headers don't override it
+	fixedTPM    bool
+	requests    float64 // currently available
+	tokens      float64
+	last        time.Time // last refill
+	pausedUntil time.Time // the provider said the limit is used up until then
+
+	now func() time.Time
+}
+
+func newRateLimiter(rpm, tpm int) *rateLimiter {
+	return &rateLimiter{
+		rpm:      float64(rpm),
+		tpm:      float64(tpm),
+		fixedRPM: rpm > 0,
+		fixedTPM: tpm > 0,
+		requests: float64(rpm),
+		tokens:   float64(tpm),
+		now:      time.Now,
+	}
+}
+
+// refill adds what accumulated since the last call. l.mu must be held.
+func (l *rateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() {
+		minutes := now.Sub(l.last).Minutes()
+		l.requests = math.Min(l.rpm, l.requests+minutes*l.rpm)
+		l.tokens = math.Min(l.tpm, l.tokens+minutes*l.tpm)
+	}
+	l.last = now
+}
+
+// reserve takes one request and tokens from the buckets, or returns how long
+// to wait before trying again.
+func (l *rateLimiter) reserve(tokens int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	l.refill(now)
+
+	// A request larger than the whole bucket could never be served, so it
+	// only waits for a full bucket.
+	need := math.Min(float64(tokens), l.tpm)
+	var wait time.Duration
+	if l.rpm > 0 && l.requests < 1 {
+		wait = max(wait, minutesToDuration((1-l.requests)/l.rpm))
+	}
+	if l.tpm > 0 && l.tokens < need {
+		wait = max(wait, minutesToDuration((need-l.tokens)/l.tpm))
+	}
+	if wait > 0 {
+		return wait
+	}
+	if l.rpm > 0 {
+		l.requests--
+	}
+	if l.tpm > 0 {
+		l.tokens -= need
+	}
+	return 0
+}
+
+func minutesToDuration(minutes float64) time.Duration {
+	return time.Duration(math.Ceil(minutes * float64(time.Minute)))
+}
+
+// wait blocks until a request of about tokens tokens may be sent.
+func (l *rateLimiter) wait(ctx context.Context, tokens int) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		d := l.reserve(tokens)
+		if d == 0 {
+			return nil
+		}
+		if err := sleepContext(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// pause holds back every worker for d, e.g. after a 429.
+func (l *rateLimiter) pause(d time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := l.now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// observe adapts to the x-ratelimit-* headers OpenAI (and some compatible
+// servers) send with every response: the limits replace unset --rpm/--tpm,
+// and the remaining counts shrink the buckets when other clients share the
+// same key.
+func (l *rateLimiter) observe(h http.Header) {
+	if l == nil || h == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.refill(now)
+	adapt := func(suffix string, limit, available *float64, fixed bool) {
+		if n, err := strconv.ParseFloat(h.Get("x-ratelimit-limit-"+suffix), 64); err == nil && n > 0 && !fixed && *limit != n {
+			if *limit == 0 {
+				*available = n
+			}
+			*limit = n
+		}
+		remaining, err := strconv.ParseFloat(h.Get("x-ratelimit-remaining-"+suffix), 64)
+		if err != nil || *limit == 0 {
+			return
+		}
+		*available = math.Min(*available, remaining)
+		if remaining < 1 {
+			if reset, err := time.ParseDuration(h.Get("x-ratelimit-reset-" + suffix)); err == nil {
+				if until := now.Add(reset); until.After(l.pausedUntil) {
+					l.pausedUntil = until
+				}
+			}
+		}
+	}
+	adapt("requests", &l.rpm, &l.requests, l.fixedRPM)
+	adapt("tokens", &l.tpm, &l.tokens, l.fixedTPM)
+}
+
+// limitedTranslator waits for the rate limiter before each request.
+type limitedTranslator struct {
+	Translator
+	limiter *rateLimiter
+}
+
+func (t *limitedTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	if err := t.limiter.wait(ctx, requestTokens(text)); err != nil {
+		return "", err
+	}
+	return t.Translator.Translate(ctx, text, sourceLang, targetLang)
+}
+
+// limitedBatchTranslator does the same for batch requests.
+type limitedBatchTranslator struct {
+	batchTranslator
+	limiter *rateLimiter
+}
+
+func (t *limitedBatchTranslator) TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	if err := t.limiter.wait(ctx, requestTokens(texts...)); err != nil {
+		return nil, err
+	}
+	return t.batchTranslator.TranslateBatch(ctx, texts, sourceLang, targetLang)
+}
+
+// requestTokens estimates what a request counts against a token limit: the
+// prompt, the texts and about as much again for the reply.
+func requestTokens(texts ...string) int {
+	tokens := promptOverheadTokens
+	for _, text := range texts {
+		tokens += 2 * estimateTokens(text)
+	}
+	return tokens
+}
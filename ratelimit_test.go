@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable time source for the rate limiter.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(rpm, tpm int) (*rateLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newRateLimiter(rpm, tpm)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestRateLimiterRequests(t *testing.T) {
+	l, clock := newTestLimiter(60, 0)
+	for i := 0; i < 60; i++ {
+		if wait := l.reserve(10); wait != 0 {
+			t.Fatalf("request %d: wait %s; expected none within the bucket", i, wait)
+		}
+	}
+	if wait := l.reserve(10); wait != time.Second {
+		t.Errorf("61st request: wait %s; expected 1s", wait)
+	}
+	clock.advance(time.Second)
+	if wait := l.reserve(10); wait != 0 {
+		t.Errorf("after refill: wait %s; expected none", wait)
+	}
+}
+
+func TestRateLimiterTokens(t *testing.T) {
+	l, clock := newTestLimiter(0, 600)
+	if wait := l.reserve(500); wait != 0 {
+		t.Fatalf("wait %s; expected none", wait)
+	}
+	if wait := l.reserve(200); wait != 10*time.Second {
+		t.Errorf("wait %s; expected 10s for 100 missing tokens", wait)
+	}
+	clock.advance(10 * time.Second)
+	if wait := l.reserve(200); wait != 0 {
+		t.Errorf("after refill: wait %s; expected none", wait)
+	}
+	// Larger than the bucket: served once the bucket is full.
+	clock.advance(time.Minute)
+	if wait := l.reserve(5000); wait != 0 {
+		t.Errorf("oversized request: wait %s; expected none with a full bucket", wait)
+	}
+}
+
+func TestRateLimiterHeaders(t *testing.T) {
+	l, clock := newTestLimiter(0, 0)
+	if wait := l.reserve(10); wait != 0 {
+		t.Fatalf("unlimited: wait %s; expected none", wait)
+	}
+
+	h := http.Header{}
+	h.Set("x-ratelimit-limit-requests", "500")
+	h.Set("x-ratelimit-remaining-requests", "0")
+	h.Set("x-ratelimit-reset-requests", "2s")
+	l.observe(h)
+	if l.rpm != 500 {
+		t.Errorf("rpm = %v; expected 500 from the header", l.rpm)
+	}
+	if wait := l.reserve(10); wait != 2*time.Second {
+		t.Errorf("exhausted: wait %s; expected the 2s reset", wait)
+	}
+	clock.advance(2 * time.Second)
+	if wait := l.reserve(10); wait != 0 {
+		t.Errorf("after reset: wait %s; expected none", wait)
+	}
+
+	// A limit given by the user wins over the header.
+	fixed, _ := newTestLimiter(100, 0)
+	fixed.observe(h)
+	if fixed.rpm != 100 {
+		t.Errorf("rpm = %v; expected --rpm 100 to be kept", fixed.rpm)
+	}
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	l, clock := newTestLimiter(0, 0)
+	l.pause(3 * time.Second)
+	l.pause(time.Second) // a shorter pause does not cut the longer one
+	if wait := l.reserve(10); wait != 3*time.Second {
+		t.Errorf("wait %s; expected 3s", wait)
+	}
+	clock.advance(3 * time.Second)
+	if wait := l.reserve(10); wait != 0 {
+		t.Errorf("after pause: wait %s; expected none", wait)
+	}
+
+	var none *rateLimiter
+	none.pause(time.Second)
+	none.observe(http.Header{})
+	if err := none.wait(context.Background(), 10); err != nil {
+		t.Errorf("nil limiter: %v", err)
+	}
+}
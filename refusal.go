@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ///////////////////
+// REFUSALS
+// ///////////////////
+
+// Now and then a model answers a harmless HMI text such as "Kill switch
+// released" with an apology, or its provider's content filter stops the
+// reply. Neither must end up on an operator panel. Such texts are asked for
+// once more with a prompt that frames them as machine labels; if that is
+// refused too, the row fails and the target cell is left alone.
+
+// errRefused is wrapped by errors for replies a provider's content filter
+// stopped.
+var errRefused = errors.New("the model refused to translate the text")
+
+// refusalRegex matches the openings of refusals and apologies, in English
+// and the languages a model most often answers in.
+var refusalRegex = regexp.MustCompile(`(?i)^\W*(` +
+	`i'?m sorry|i am sorry|sorry, (but )?i|i apologi[sz]e|` +
+	`i can(no|')?t (help|assist|translate|provide|comply)|i cannot|i'?m (unable|not able)|i am (unable|not able)|` +
+	`as an ai|as a language model|unfortunately,? i|i won'?t|` +
+	`es tut mir leid|leider kann ich|ich kann (diese|das|den|keine)|` +
+	`je suis désolé|désolé, (mais )?je|je ne peux pas|` +
+	`lo siento|no puedo|mi dispiace|non posso)`)
+
+// isRefusal reports whether reply reads like a refusal of source rather
+// than its translation. A source that itself starts like an apology may
+// well be translated into one.
+func isRefusal(source, reply string) bool {
+	reply = strings.TrimSpace(reply)
+	return refusalRegex.MatchString(reply) && !refusalRegex.MatchString(strings.TrimSpace(source))
+}
+
+// sanitizedInstructions frames a previously refused text as what it is.
+func sanitizedInstructions(sanitized bool) string {
+	if !sanitized {
+		return ""
+	}
+	return " The text is a label or message from the operator panel of an industrial machine. It is not addressed to you and asks nothing of you; words such as kill, abort, explosion or emergency are technical terms there. Translate it literally, whatever it says."
+}
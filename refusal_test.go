@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestIsRefusal(t *testing.T) {
+	testCases := []struct {
+		source   string
+		reply    string
+		expected bool
+	}{
+		{"Kill switch released", "I'm sorry, but I can't help with that.", true},
+		{"Kill switch released", "I cannot assist with this request.", true},
+		{"Abort sequence", "As an AI language model, I am unable to translate this.", true},
+		{"Not-Aus betätigt", "Es tut mir leid, aber dabei kann ich nicht helfen.", true},
+		{"Kill switch released", "Not-Aus-Schalter entriegelt", false},
+		{"Sorry, access denied", "Entschuldigung, Zugriff verweigert", false},
+		{"I am unable to start the pump", "I am unable to start the pump", false},
+		{"Unable to connect", "Verbindung nicht möglich", false},
+	}
+
+	for _, tc := range testCases {
+		if got := isRefusal(tc.source, tc.reply); got != tc.expected {
+			t.Errorf("isRefusal(%q, %q) = %t; expected %t", tc.source, tc.reply, got, tc.expected)
+		}
+	}
+}
+
+// refusingTranslator apologises for texts containing "Kill" unless the
+// prompt is sanitized, and has its content filter stop "Explosion" texts
+// every time.
+type refusingTranslator struct {
+	mu        sync.Mutex
+	sanitized []promptHints
+}
+
+func (r *refusingTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	hints := promptHintsFrom(ctx)
+	if hints.sanitized {
+		r.mu.Lock()
+		r.sanitized = append(r.sanitized, hints)
+		r.mu.Unlock()
+	}
+	switch {
+	case strings.Contains(text, "Explosion"):
+		return "", errRefused
+	case strings.Contains(text, "Kill") && !hints.sanitized:
+		return "I'm sorry, but I can't help with that.", nil
+	}
+	return strings.ToUpper(text), nil
+}
+
+func TestRefusalRetry(t *testing.T) {
+	rows := [][]string{
+		{"en-US", "de-DE", "Comment"},
+		{"Kill switch released", "", "Conveyor 3"},
+		{"Explosion hazard", "old", ""},
+		{"Pump running", "", ""},
+	}
+	job := newTestJob(t, rows)
+	job.contextCol = 3
+	job.report = &fileReport{}
+	translator := &refusingTranslator{}
+	iterateAndTranslate(context.Background(), discardSender{}, newDedupTranslator(translator), job)
+
+	expected := map[string]string{
+		"B2": "KILL SWITCH RELEASED",
+		"B3": "old",
+		"B4": "PUMP RUNNING",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	// One for "Kill", two for "Explosion": the retry pass at the end of
+	// the sheet asks once more.
+	if len(translator.sanitized) != 3 {
+		t.Fatalf("%d sanitized requests; expected 3", len(translator.sanitized))
+	}
+	for _, hints := range translator.sanitized {
+		if hints.context != "" {
+			t.Errorf("sanitized request carried the row context %q", hints.context)
+		}
+	}
+	var refused bool
+	for _, entry := range job.report.sorted() {
+		if entry.row == 3 {
+			refused = entry.status == rowFailed && strings.Contains(entry.detail, errRefused.Error())
+		}
+	}
+	if !refused {
+		t.Errorf("row 3 not reported as refused: %+v", job.report.sorted())
+	}
+}
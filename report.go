@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ///////////////////
+// REVIEW REPORT
+// ///////////////////
+
+// rowStatus says how a row's target cell came about.
+type rowStatus string
+
+const (
+	rowTranslated   rowStatus = "translated"
+	rowReused       rowStatus = "reused"        // identical source earlier in the sheet
+	rowReusedPrefix rowStatus = "reused prefix" // base of the previous row, suffix kept or translated
+	rowMemory       rowStatus = "translation memory"
+	rowPrevious     rowStatus = "previous run"  // source unchanged since --previous
+	rowEdited       rowStatus = "reviewer edit" // source unchanged, translation edited by hand since the last run
+	rowResumed      rowStatus = "resumed"
+	rowPlaceholder  rowStatus = "placeholder copied"
+	rowCopied       rowStatus = "copied"
+	rowPreserved    rowStatus = "preserved"
+	rowSkipped      rowStatus = "skipped"
+	rowFailed       rowStatus = "error"
+)
+
+// reportStatuses is the order statuses are counted in the summary.
+var reportStatuses = []rowStatus{
+	rowTranslated, rowReused, rowReusedPrefix, rowMemory, rowPrevious, rowEdited, rowResumed,
+	rowPlaceholder, rowCopied, rowPreserved, rowSkipped, rowFailed,
+}
+
+// reportEntry is one row of one target column.
+type reportEntry struct {
+	sheet       string
+	row         int // 1-based sheet row
+	sourceLang  string
+	targetLang  string
+	source      string
+	translation string // what the target cell holds after the row was handled
+	status      rowStatus
+	detail      string        // reason for a skip or copy, QA flags, or the error
+	flags       []string      // QA findings, also in detail
+	doubtful    bool          // rated as likely wrong by --self-check or --review-model
+	via         string        // pivot language of --via, if used
+	pivot       string        // intermediate translation into via
+	tokens      int64         // tokens the provider reported for the row's requests
+	latency     time.Duration // time the row spent with the translator
+	retries     int           // requests sent again for the row
+	at          time.Time     // when the row was handled
+}
+
+// fileReport collects the rows of one file. Like the checkpoint it is only
+// written to from the goroutine that writes the workbook.
+type fileReport struct {
+	fileName string
+	entries  []reportEntry
+	outputs  []string // files saved for it
+	partial  bool     // the outputs are partial- files of a cancelled run
+	pending  bool     // not started yet; left out of the report if the run ends first
+}
+
+// runReport is the --report file of a run.
+type runReport struct {
+	model string // provider and model that produced the translations
+	files []*fileReport
+}
+
+func (r *runReport) addFile(fileName string) *fileReport {
+	fr := &fileReport{fileName: fileName}
+	r.files = append(r.files, fr)
+	return fr
+}
+
+// startedFiles returns the files the run got to.
+func (r *runReport) startedFiles() []*fileReport {
+	var files []*fileReport
+	for _, fr := range r.files {
+		if !fr.pending {
+			files = append(files, fr)
+		}
+	}
+	return files
+}
+
+// modelLabel names the provider and model a run uses, e.g. "openai/gpt-4o-mini".
+func modelLabel(provider, model string) string {
+	if model == "" {
+		switch provider {
+		case "openai":
+			model = openai.GPT4oMini
+		case "ollama":
+			model = defaultOllamaModel
+		default:
+			return provider
+		}
+	}
+	return provider + "/" + model
+}
+
+// sorted returns the entries sheet by sheet and target by target, in row
+// order. Translated rows are recorded as they finish, so they come in mixed
+// up.
+func (fr *fileReport) sorted() []reportEntry {
+	order := make(map[string]int)
+	for _, e := range fr.entries {
+		key := e.sheet + "\x00" + e.targetLang
+		if _, ok := order[key]; !ok {
+			order[key] = len(order)
+		}
+	}
+	entries := slices.Clone(fr.entries)
+	slices.SortStableFunc(entries, func(a, b reportEntry) int {
+		if ka, kb := order[a.sheet+"\x00"+a.targetLang], order[b.sheet+"\x00"+b.targetLang]; ka != kb {
+			return ka - kb
+		}
+		return a.row - b.row
+	})
+	return entries
+}
+
+func (r *runReport) counts() []string {
+	count := make(map[rowStatus]int)
+	for _, fr := range r.startedFiles() {
+		for _, e := range fr.entries {
+			count[e.status]++
+		}
+	}
+	var parts []string
+	for _, status := range reportStatuses {
+		if count[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", status, count[status]))
+		}
+	}
+	return parts
+}
+
+// write saves the report as HTML if path ends in .html or .htm, and as
+// Markdown otherwise.
+func (r *runReport) write(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not write report: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		err = r.writeHTML(file)
+	default:
+		err = r.writeMarkdown(file)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write report: %w", err)
+	}
+	return nil
+}
+
+// entryModel is the model column: only rows the model actually produced
+// name it.
+func (r *runReport) entryModel(e reportEntry) string {
+	if e.status == rowTranslated || e.status == rowReusedPrefix {
+		return r.model
+	}
+	return ""
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+func (r *runReport) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Translation Report\n\n")
+	fmt.Fprintf(&b, "Created %s with %s.\n\n", time.Now().Format("2006-01-02 15:04"), r.model)
+	fmt.Fprintf(&b, "%s\n", strings.Join(r.counts(), ", "))
+	for _, fr := range r.startedFiles() {
+		fmt.Fprintf(&b, "\n## %s\n\n", fr.fileName)
+		fmt.Fprintln(&b, "| Sheet | Row | Languages | Source | Translation | Status | Model | Note |")
+		fmt.Fprintln(&b, "|---|---|---|---|---|---|---|---|")
+		for _, e := range fr.sorted() {
+			fmt.Fprintf(&b, "| %s | %d | %s -> %s | %s | %s | %s | %s | %s |\n",
+				markdownEscaper.Replace(e.sheet), e.row, e.sourceLang, e.targetLang,
+				markdownEscaper.Replace(e.source), markdownEscaper.Replace(e.translation),
+				e.status, r.entryModel(e), markdownEscaper.Replace(e.detail))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Translation Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; position: sticky; top: 0; }
+tr.translated td.status { color: #1a7f37; }
+tr.error td { background: #ffebe9; }
+tr.skipped td, tr.preserved td { color: #888; }
+td.note { color: #9a6700; }
+</style>
+</head>
+<body>
+<h1>Translation Report</h1>
+<p>Created {{.Created}} with {{.Model}}.</p>
+<p>{{.Counts}}</p>
+{{range .Files}}
+<h2>{{.Name}}</h2>
+<table>
+<tr><th>Sheet</th><th>Row</th><th>Languages</th><th>Source</th><th>Translation</th><th>Status</th><th>Model</th><th>Note</th></tr>
+{{range .Rows}}<tr class="{{.Class}}"><td>{{.Sheet}}</td><td>{{.Row}}</td><td>{{.Languages}}</td><td>{{.Source}}</td><td>{{.Translation}}</td><td class="status">{{.Status}}</td><td>{{.Model}}</td><td class="note">{{.Note}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+type htmlReportRow struct {
+	Class, Sheet                                        string
+	Row                                                 int
+	Languages, Source, Translation, Status, Model, Note string
+}
+
+type htmlReportFile struct {
+	Name string
+	Rows []htmlReportRow
+}
+
+func (r *runReport) writeHTML(w io.Writer) error {
+	data := struct {
+		Created, Model, Counts string
+		Files                  []htmlReportFile
+	}{
+		Created: time.Now().Format("2006-01-02 15:04"),
+		Model:   r.model,
+		Counts:  strings.Join(r.counts(), ", "),
+	}
+	for _, fr := range r.startedFiles() {
+		hf := htmlReportFile{Name: fr.fileName}
+		for _, e := range fr.sorted() {
+			hf.Rows = append(hf.Rows, htmlReportRow{
+				Class:       strings.ReplaceAll(string(e.status), " ", "-"),
+				Sheet:       e.sheet,
+				Row:         e.row,
+				Languages:   e.sourceLang + " -> " + e.targetLang,
+				Source:      e.source,
+				Translation: e.translation,
+				Status:      string(e.status),
+				Model:       r.entryModel(e),
+				Note:        e.detail,
+			})
+		}
+		data.Files = append(data.Files, hf)
+	}
+	return reportTemplate.Execute(w, data)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReportStatuses(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Motor fault", ""},
+		{"Discrete_alarm_66", ""},
+		{"Discrete_alarm_67", ""},
+		{"##Tag##", ""},
+		{"42", ""},
+		{"-----------", ""},
+		{"Pump running", ""},
+	}
+	job := newTestJob(t, rows)
+	job.report = &fileReport{fileName: "plant.xlsx"}
+	iterateAndTranslate(context.Background(), discardSender{}, &upperTranslator{}, job)
+
+	expected := []struct {
+		row         int
+		translation string
+		status      rowStatus
+	}{
+		{2, "MOTOR FAULT", rowTranslated},
+		{3, "MOTOR FAULT", rowReused},
+		{4, "DISCRETE_ALARM_66", rowTranslated},
+		{5, "DISCRETE_ALARM_67", rowReusedPrefix},
+		{6, "##Tag##", rowPlaceholder},
+		{7, "42", rowCopied},
+		{8, "", rowSkipped},
+		{9, "PUMP RUNNING", rowTranslated},
+	}
+	entries := job.report.sorted()
+	if len(entries) != len(expected) {
+		t.Fatalf("%d report entries, expected %d: %+v", len(entries), len(expected), entries)
+	}
+	for i, want := range expected {
+		got := entries[i]
+		if got.row != want.row || got.translation != want.translation || got.status != want.status {
+			t.Errorf("entry %d = row %d %q %s; expected row %d %q %s", i, got.row, got.translation, got.status, want.row, want.translation, want.status)
+		}
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	report := &runReport{model: modelLabel("openai", "")}
+	fr := report.addFile("plant.xlsx")
+	fr.entries = []reportEntry{
+		{sheet: "Sheet1", row: 3, sourceLang: "de-DE", targetLang: "en-US", source: "Ein | Aus", translation: "On | Off", status: rowTranslated},
+		{sheet: "Sheet1", row: 2, sourceLang: "de-DE", targetLang: "en-US", source: "<b>Stop</b>", translation: "", status: rowFailed, detail: "timeout"},
+	}
+
+	tests := []struct {
+		name     string
+		contains []string
+	}{
+		{"report.md", []string{
+			"| Sheet1 | 2 | de-DE -> en-US | <b>Stop</b> |  | error |  | timeout |",
+			`| Sheet1 | 3 | de-DE -> en-US | Ein \| Aus | On \| Off | translated | openai/gpt-4o-mini |  |`,
+			"translated: 1, error: 1",
+		}},
+		{"report.html", []string{
+			"<td>&lt;b&gt;Stop&lt;/b&gt;</td>",
+			`<tr class="error">`,
+			"<td>openai/gpt-4o-mini</td>",
+		}},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := report.write(path); err != nil {
+			t.Fatalf("write(%s): %v", tt.name, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.contains {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s does not contain %q:\n%s", tt.name, want, data)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ///////////////////
+// RETRIES
+// ///////////////////
+
+// statusError is an unsuccessful HTTP response from a provider that talks
+// HTTP itself (the OpenAI client has its own error types).
+type statusError struct {
+	code       int
+	retryAfter time.Duration // from the Retry-After header; 0 if absent
+	err        error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+func (e *statusError) Unwrap() error { return e.err }
+
+// parseRetryAfter reads a Retry-After header given in seconds. The HTTP date
+// form is not used by any of our providers and counts as absent.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// transientError reports whether err is worth retrying: rate limits, server
+// errors and network failures. retryAfter is the wait the server asked for,
+// if it said so.
+func transientError(err error) (transient bool, retryAfter time.Duration) {
+	var timeoutErr *requestTimeoutError
+	if errors.As(err, &timeoutErr) {
+		return true, 0
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, 0
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		// OpenAI answers an exhausted quota with 429 too, but waiting does
+		// not help there.
+		if code, _ := apiErr.Code.(string); code == "insufficient_quota" {
+			return false, 0
+		}
+		return retryableStatus(apiErr.HTTPStatusCode), 0
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return retryableStatus(reqErr.HTTPStatusCode), 0
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.code), statusErr.retryAfter
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true, 0
+	}
+	return false, 0
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// retryPolicy retries transient errors with jittered exponential backoff.
+type retryPolicy struct {
+	retries   int           // retries after the first attempt; 0 disables retrying
+	baseDelay time.Duration // wait before the first retry, doubled for each further one
+	maxDelay  time.Duration
+	timeout   time.Duration                       // deadline of each attempt; 0 = none
+	notify    func(err error, wait time.Duration) // called before each wait; may be nil
+
+	// sleep waits for d or until ctx is done; nil uses a timer.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+const (
+	defaultRetryBaseDelay = time.Second
+	defaultRetryMaxDelay  = time.Minute
+)
+
+// requestTimeoutError is an attempt cut off by retryPolicy.timeout. Unlike a
+// cancelled run, it is worth retrying.
+type requestTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *requestTimeoutError) Error() string {
+	return fmt.Sprintf("no response within %s (--request-timeout)", e.timeout)
+}
+
+// run calls fn until it succeeds, fails with a permanent error or the
+// retries are used up. The last error is returned.
+func (rp retryPolicy) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := rp.attempt(ctx, fn)
+	for attempt := 0; err != nil && attempt < rp.retries; attempt++ {
+		transient, retryAfter := transientError(err)
+		if !transient {
+			return err
+		}
+		wait := rp.backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if rp.notify != nil {
+			rp.notify(err, wait)
+		}
+		sleep := rp.sleep
+		if sleep == nil {
+			sleep = sleepContext
+		}
+		if sleepErr := sleep(ctx, wait); sleepErr != nil {
+			return err
+		}
+		countRetry(ctx)
+		err = rp.attempt(ctx, fn)
+	}
+	return err
+}
+
+// attempt calls fn once under the per-attempt timeout.
+func (rp retryPolicy) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if rp.timeout <= 0 {
+		return fn(ctx)
+	}
+	actx, cancel := context.WithTimeout(ctx, rp.timeout)
+	defer cancel()
+	err := fn(actx)
+	if err != nil && ctx.Err() == nil && actx.Err() == context.DeadlineExceeded {
+		return &requestTimeoutError{timeout: rp.timeout}
+	}
+	return err
+}
+
+// backoff returns the wait before retry number attempt (0-based): a random
+// duration between half and all of baseDelay*2^attempt, capped at maxDelay.
+// The jitter keeps parallel workers from retrying in lockstep.
+func (rp retryPolicy) backoff(attempt int) time.Duration {
+	delay := rp.baseDelay
+	for i := 0; i < attempt && delay < rp.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > rp.maxDelay {
+		delay = rp.maxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryTranslator retries the wrapped Translator's transient failures.
+type retryTranslator struct {
+	Translator
+	policy retryPolicy
+}
+
+func (t *retryTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	var result string
+	err := t.policy.run(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = t.Translator.Translate(ctx, text, sourceLang, targetLang)
+		return err
+	})
+	return result, err
+}
+
+// retryBatchTranslator does the same for batch requests.
+type retryBatchTranslator struct {
+	batchTranslator
+	policy retryPolicy
+}
+
+func (t *retryBatchTranslator) TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	var results []string
+	err := t.policy.run(ctx, func(ctx context.Context) error {
+		var err error
+		results, err = t.batchTranslator.TranslateBatch(ctx, texts, sourceLang, targetLang)
+		return err
+	})
+	return results, err
+}
+
+// retryNotice formats a retry for the log.
+func retryNotice(err error, wait time.Duration) string {
+	return fmt.Sprintf("Retrying in %s after: %v", wait.Round(100*time.Millisecond), err)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestTransientError(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		transient  bool
+		retryAfter time.Duration
+	}{
+		{"openai rate limit", &openai.APIError{HTTPStatusCode: 429, Code: "rate_limit_exceeded"}, true, 0},
+		{"openai quota", &openai.APIError{HTTPStatusCode: 429, Code: "insufficient_quota"}, false, 0},
+		{"openai server error", &openai.RequestError{HTTPStatusCode: 503}, true, 0},
+		{"openai bad request", &openai.APIError{HTTPStatusCode: 400}, false, 0},
+		{"deepl too many requests", &statusError{code: 429, retryAfter: 3 * time.Second, err: errors.New("busy")}, true, 3 * time.Second},
+		{"deepl quota", &statusError{code: 456, err: errors.New("quota")}, false, 0},
+		{"wrapped network error", fmt.Errorf("could not connect: %w", &net.OpError{Op: "dial", Err: errors.New("refused")}), true, 0},
+		{"request timeout", &requestTimeoutError{timeout: time.Minute}, true, 0},
+		{"canceled", context.Canceled, false, 0},
+		{"other", errors.New("expected 3 translations, got 2"), false, 0},
+	}
+
+	for _, tc := range testCases {
+		transient, retryAfter := transientError(tc.err)
+		if transient != tc.transient || retryAfter != tc.retryAfter {
+			t.Errorf("%s: transientError = %t, %s; expected %t, %s", tc.name, transient, retryAfter, tc.transient, tc.retryAfter)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	rateLimited := &openai.APIError{HTTPStatusCode: 429}
+	permanent := &openai.APIError{HTTPStatusCode: 401}
+
+	testCases := []struct {
+		name     string
+		failures []error // returned by the first calls, then nil
+		retries  int
+		calls    int
+		err      error
+	}{
+		{"succeeds at once", nil, 3, 1, nil},
+		{"recovers", []error{rateLimited, rateLimited}, 3, 3, nil},
+		{"gives up", []error{rateLimited, rateLimited, rateLimited}, 2, 3, rateLimited},
+		{"permanent error", []error{permanent}, 3, 1, permanent},
+		{"retries disabled", []error{rateLimited}, 0, 1, rateLimited},
+	}
+
+	for _, tc := range testCases {
+		var waits []time.Duration
+		rp := retryPolicy{
+			retries:   tc.retries,
+			baseDelay: time.Second,
+			maxDelay:  time.Minute,
+			sleep: func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			},
+		}
+		calls := 0
+		err := rp.run(context.Background(), func(context.Context) error {
+			calls++
+			if calls <= len(tc.failures) {
+				return tc.failures[calls-1]
+			}
+			return nil
+		})
+		if calls != tc.calls || err != tc.err {
+			t.Errorf("%s: %d calls, err %v; expected %d calls, err %v", tc.name, calls, err, tc.calls, tc.err)
+		}
+		for i, wait := range waits {
+			ceiling := time.Second << i
+			if wait < ceiling/2 || wait > ceiling {
+				t.Errorf("%s: wait %d = %s; expected between %s and %s", tc.name, i, wait, ceiling/2, ceiling)
+			}
+		}
+	}
+}
+
+func TestRetryBackoffCap(t *testing.T) {
+	rp := retryPolicy{baseDelay: time.Second, maxDelay: 10 * time.Second}
+	for attempt := 0; attempt < 70; attempt++ {
+		if wait := rp.backoff(attempt); wait > 10*time.Second || wait <= 0 {
+			t.Fatalf("backoff(%d) = %s; expected within (0, 10s]", attempt, wait)
+		}
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	rp := retryPolicy{
+		retries: 2,
+		timeout: 20 * time.Millisecond,
+		sleep:   func(context.Context, time.Duration) error { return nil },
+	}
+	calls := 0
+	err := rp.run(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("%d calls, err %v; expected the hanging request to be retried once", calls, err)
+	}
+
+	// A cancelled run is not a timeout and is not retried.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	err = rp.run(ctx, func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if calls != 1 || !errors.Is(err, context.Canceled) {
+		t.Errorf("%d calls, err %v; expected one call and the cancellation", calls, err)
+	}
+}
+
+// stuckTranslator never answers the first request for a text containing
+// "Stuck".
+type stuckTranslator struct {
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+func (s *stuckTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	s.mu.Lock()
+	s.seen[text]++
+	n := s.seen[text]
+	s.mu.Unlock()
+	if strings.Contains(text, "Stuck") && n == 1 {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return strings.ToUpper(text), nil
+}
+
+func TestRowTimeout(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Stuck pump", ""},
+		{"Motor fault", ""},
+	}
+	job := newTestJob(t, rows)
+	job.rowTimeout = 50 * time.Millisecond
+	job.report = &fileReport{}
+	translator := &stuckTranslator{seen: make(map[string]int)}
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	for cell, want := range map[string]string{"B2": "STUCK PUMP", "B3": "MOTOR FAULT"} {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if n := translator.seen["Stuck pump"]; n != 2 {
+		t.Errorf("stuck text sent %d times; expected 2", n)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ///////////////////
+// REVIEW
+// ///////////////////
+
+type reviewDecision int
+
+const (
+	reviewPending reviewDecision = iota
+	reviewAccepted
+	reviewEdited
+	reviewRejected
+)
+
+// reviewItem is one translated cell waiting for a reviewer's decision.
+type reviewItem struct {
+	job       *translationJob
+	row       int // 0-based row index in the sheet
+	source    string
+	context   string // row context, passed along when re-translating
+	original  string // target cell before the run
+	proposed  string // machine translation, replaced on re-translation
+	edited    string
+	flags     []string
+	segmented bool // Rockwell text with embedded refs; cannot be re-translated as a whole
+	decision  reviewDecision
+
+	lead, trail string // whitespace around the source, put around the translation
+}
+
+// text is the translation as the reviewer sees it, without the
+// whitespace around it.
+func (it *reviewItem) text() string {
+	switch it.decision {
+	case reviewRejected:
+		return it.original
+	case reviewEdited:
+		return it.edited
+	}
+	return it.proposed
+}
+
+// value is what the target cell should hold after the review.
+func (it *reviewItem) value() string {
+	if it.decision == reviewRejected {
+		return it.original
+	}
+	return it.lead + it.text() + it.trail
+}
+
+// reviewList collects the translated cells of a file. Like the checkpoint
+// it is only appended to from the goroutine that writes the workbook. A nil
+// *reviewList means no review.
+type reviewList struct {
+	items []*reviewItem
+}
+
+func (l *reviewList) add(job *translationJob, rj *rowJob) {
+	if l == nil {
+		return
+	}
+	var original string
+	if row := job.rows[rj.index]; len(row) > job.targetIndex {
+		original = row[job.targetIndex]
+	}
+	l.items = append(l.items, &reviewItem{
+		job:       job,
+		row:       rj.index,
+		source:    rj.source,
+		context:   rj.context,
+		original:  original,
+		proposed:  rj.result,
+		flags:     rj.flags,
+		segmented: rj.segments != nil,
+		lead:      rj.lead,
+		trail:     rj.trail,
+	})
+}
+
+// unify replaces the proposed translation of a cell, e.g. when the
+// consistency check settles on another variant.
+func (l *reviewList) unify(sheetName string, targetIndex, row int, translation string) {
+	if l == nil {
+		return
+	}
+	for _, it := range l.items {
+		if it.row == row && it.job.sheetName == sheetName && it.job.targetIndex == targetIndex {
+			it.proposed = translation
+			it.flags = it.job.qaFlags(it.source, translation)
+		}
+	}
+}
+
+// apply writes the reviewed values into the workbook and counts the
+// decisions. Undecided cells count as accepted.
+func (l *reviewList) apply() (accepted, edited, rejected int) {
+	for _, it := range l.items {
+		switch it.decision {
+		case reviewEdited:
+			edited++
+		case reviewRejected:
+			rejected++
+		default:
+			accepted++
+		}
+		it.job.setCell(it.row, it.value())
+	}
+	return accepted, edited, rejected
+}
+
+// reviewRequestMsg asks the TUI to review a file. The TUI closes done when
+// the reviewer is finished.
+type reviewRequestMsg struct {
+	fileName    string
+	items       []*reviewItem
+	retranslate func(*reviewItem) (string, error)
+	done        chan<- struct{}
+}
+
+type retranslatedMsg struct {
+	item   *reviewItem
+	result string
+	err    error
+}
+
+// reviewFile hands the translated cells of task to the TUI, waits for the
+// reviewer and writes the decisions into the workbook. If ctx is cancelled
+// first, the workbook keeps the unreviewed translations.
+func reviewFile(ctx context.Context, p msgSender, translator Translator, task *fileTask) {
+	// Rows finish in any order; show them job by job in sheet order.
+	jobOrder := make(map[*translationJob]int)
+	for _, it := range task.review.items {
+		if _, ok := jobOrder[it.job]; !ok {
+			jobOrder[it.job] = len(jobOrder)
+		}
+	}
+	slices.SortStableFunc(task.review.items, func(a, b *reviewItem) int {
+		if a.job != b.job {
+			return jobOrder[a.job] - jobOrder[b.job]
+		}
+		return a.row - b.row
+	})
+
+	done := make(chan struct{})
+	p.Send(logMsg(fmt.Sprintf("Waiting for review of %d translations in %s", len(task.review.items), task.fileName)))
+	p.Send(reviewRequestMsg{
+		fileName: task.fileName,
+		items:    task.review.items,
+		retranslate: func(it *reviewItem) (string, error) {
+			rctx := ctx
+			if it.context != "" {
+				rctx = withPromptHints(rctx, promptHints{context: it.context})
+			}
+			if err := it.job.limiter.wait(rctx, requestTokens(it.source)); err != nil {
+				return "", err
+			}
+			return it.job.translate(rctx, p, translator, it.source, it.job.maxLength)
+		},
+		done: done,
+	})
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return
+	}
+	accepted, edited, rejected := task.review.apply()
+	p.Send(logMsg(fmt.Sprintf("Review of %s: %d accepted, %d edited, %d rejected", task.fileName, accepted, edited, rejected)))
+}
+
+// reviewModel is the review screen shown in place of the log.
+type reviewModel struct {
+	req     reviewRequestMsg
+	index   int
+	editing bool
+	busy    bool // a re-translation is running
+	status  string
+	input   textinput.Model
+}
+
+func newReviewModel(req reviewRequestMsg) *reviewModel {
+	input := textinput.New()
+	input.Prompt = ""
+	return &reviewModel{req: req, input: input}
+}
+
+func (r *reviewModel) current() *reviewItem {
+	return r.req.items[r.index]
+}
+
+// next moves to the following item and reports whether the review is done.
+func (r *reviewModel) next() bool {
+	r.status = ""
+	if r.index == len(r.req.items)-1 {
+		return true
+	}
+	r.index++
+	return false
+}
+
+// update handles a key or a re-translation result. finished is set once every
+// item has been decided.
+func (r *reviewModel) update(msg tea.Msg) (finished bool, cmd tea.Cmd) {
+	switch msg := msg.(type) {
+	case retranslatedMsg:
+		r.busy = false
+		if msg.err != nil {
+			r.status = fmt.Sprintf("Re-translation failed: %v", msg.err)
+			return false, nil
+		}
+		it := msg.item
+		it.proposed = msg.result
+		it.decision = reviewPending
+		it.flags = it.job.qaFlags(it.source, msg.result)
+		if flag := it.job.lengthFlag(msg.result); flag != "" {
+			it.flags = append(it.flags, flag)
+		}
+		r.status = "Re-translated"
+		return false, nil
+
+	case tea.KeyMsg:
+		it := r.current()
+		if r.editing {
+			switch msg.String() {
+			case "enter":
+				r.editing = false
+				r.input.Blur()
+				if value := r.input.Value(); value != it.proposed {
+					it.edited = value
+					it.decision = reviewEdited
+				} else {
+					it.decision = reviewAccepted
+				}
+				return r.next(), nil
+			case "esc":
+				r.editing = false
+				r.input.Blur()
+				return false, nil
+			}
+			r.input, cmd = r.input.Update(msg)
+			return false, cmd
+		}
+
+		switch msg.String() {
+		case "enter", "a":
+			it.decision = reviewAccepted
+			return r.next(), nil
+		case "x":
+			it.decision = reviewRejected
+			return r.next(), nil
+		case "e":
+			r.editing = true
+			r.input.SetValue(it.text())
+			r.input.CursorEnd()
+			return false, r.input.Focus()
+		case "r":
+			if r.busy {
+				return false, nil
+			}
+			if it.segmented {
+				r.status = "Texts with embedded refs cannot be re-translated here, edit them instead"
+				return false, nil
+			}
+			r.busy = true
+			r.status = "Re-translating..."
+			retranslate := r.req.retranslate
+			return false, func() tea.Msg {
+				result, err := retranslate(it)
+				return retranslatedMsg{item: it, result: result, err: err}
+			}
+		case "left", "b":
+			if r.index > 0 {
+				r.index--
+				r.status = ""
+			}
+			return false, nil
+		case "A":
+			for _, it := range r.req.items[r.index:] {
+				if it.decision == reviewPending {
+					it.decision = reviewAccepted
+				}
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *reviewModel) view(width int) string {
+	it := r.current()
+	var b strings.Builder
+	counts := map[reviewDecision]int{}
+	for _, item := range r.req.items {
+		counts[item.decision]++
+	}
+	fmt.Fprintf(&b, "%s\n", headerStyle.Render(fmt.Sprintf("Review %d/%d", r.index+1, len(r.req.items))))
+	fmt.Fprintf(&b, "%s\n\n", statusStyle.Render(fmt.Sprintf("%s, sheet %s, row %d, %s -> %s  |  accepted %d, edited %d, rejected %d",
+		r.req.fileName, it.job.sheetName, it.row+1, it.job.sourceLang, it.job.targetLang,
+		counts[reviewAccepted], counts[reviewEdited], counts[reviewRejected])))
+
+	label := func(name string) string { return footerStyle.Render(fmt.Sprintf("%-12s", name)) }
+	fmt.Fprintf(&b, "%s %s\n", label("Source"), it.source)
+	if it.context != "" {
+		fmt.Fprintf(&b, "%s %s\n", label("Context"), it.context)
+	}
+	if it.original != "" && it.original != it.proposed {
+		fmt.Fprintf(&b, "%s %s\n", label("Before"), it.original)
+	}
+	if r.editing {
+		r.input.Width = max(width-20, 20)
+		fmt.Fprintf(&b, "%s %s\n", label("Edit"), r.input.View())
+	} else {
+		fmt.Fprintf(&b, "%s %s\n", label("Translation"), logStyleReused.Render(it.text()))
+	}
+	switch it.decision {
+	case reviewAccepted:
+		fmt.Fprintf(&b, "%s accepted\n", label(""))
+	case reviewEdited:
+		fmt.Fprintf(&b, "%s edited\n", label(""))
+	case reviewRejected:
+		fmt.Fprintf(&b, "%s rejected, the cell keeps its previous value\n", label(""))
+	}
+	for _, flag := range it.flags {
+		fmt.Fprintf(&b, "%s %s\n", label(""), logStyleFlagged.Render(flag))
+	}
+	if r.status != "" {
+		fmt.Fprintf(&b, "\n%s\n", logStyleTranslating.Render(r.status))
+	}
+
+	keys := "enter/a: accept  |  e: edit  |  r: re-translate  |  x: reject  |  b: back  |  A: accept all remaining"
+	if r.editing {
+		keys = "enter: save edit  |  esc: cancel"
+	}
+	return b.String() + "\n" + footerStyle.Render(keys)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// reviewingSender answers review requests with keys, like a reviewer would.
+type reviewingSender struct {
+	keys []tea.KeyMsg
+}
+
+func (s *reviewingSender) Send(msg tea.Msg) {
+	req, ok := msg.(reviewRequestMsg)
+	if !ok {
+		return
+	}
+	r := newReviewModel(req)
+	for _, key := range s.keys {
+		finished, cmd := r.update(key)
+		// Run re-translations synchronously; other commands only blink
+		// the cursor.
+		if cmd != nil && key.String() == "r" {
+			if result, ok := cmd().(retranslatedMsg); ok {
+				finished, _ = r.update(result)
+			}
+		}
+		if finished {
+			break
+		}
+	}
+	close(req.done)
+}
+
+func runeKeys(s string) []tea.KeyMsg {
+	var keys []tea.KeyMsg
+	for _, r := range s {
+		keys = append(keys, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return keys
+}
+
+func TestReview(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Pump running", "Pump is running"},
+		{"Valve open", ""},
+		{"Tank full", ""},
+	}
+	job := newTestJob(t, rows)
+	job.review = &reviewList{}
+	iterateAndTranslate(context.Background(), discardSender{}, &upperTranslator{}, job)
+	if len(job.review.items) != 4 {
+		t.Fatalf("%d items to review, expected 4", len(job.review.items))
+	}
+	task := &fileTask{fileName: "plant.xlsx", f: job.f, review: job.review}
+
+	// Row 2: accept. Row 3: reject. Row 4: edit to "Valve opened".
+	// Row 5: re-translate, then accept.
+	var keys []tea.KeyMsg
+	keys = append(keys, tea.KeyMsg{Type: tea.KeyEnter})
+	keys = append(keys, runeKeys("x")...)
+	keys = append(keys, runeKeys("e")...)
+	for range "VALVE OPEN" {
+		keys = append(keys, tea.KeyMsg{Type: tea.KeyBackspace})
+	}
+	keys = append(keys, runeKeys("Valve opened")...)
+	keys = append(keys, tea.KeyMsg{Type: tea.KeyEnter})
+	keys = append(keys, runeKeys("ra")...)
+
+	translator := &upperTranslator{}
+	reviewFile(context.Background(), &reviewingSender{keys: keys}, translator, task)
+
+	expected := map[string]string{
+		"B2": "MOTOR FAULT",
+		"B3": "Pump is running",
+		"B4": "Valve opened",
+		"B5": "TANK FULL",
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if len(translator.calls) != 1 || translator.calls[0] != "Tank full" {
+		t.Errorf("re-translated %v; expected only %q", translator.calls, "Tank full")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ///////////////////
+// REVIEW MODEL
+// ///////////////////
+
+// With --review-model, every fresh translation is a draft that a second,
+// usually stronger model checks and corrects before it is written. The
+// cheap model does the bulk of the work, the reviewer mostly confirms it,
+// and rows it is unsure about are flagged for a human.
+
+// reviewer is optionally implemented by a Translator that can check a draft
+// translation and correct it.
+type reviewer interface {
+	Review(ctx context.Context, source, draft, sourceLang, targetLang string) (reviewResult, error)
+}
+
+// Confidence levels of a review.
+const (
+	confidenceHigh   = "high"
+	confidenceMedium = "medium"
+	confidenceLow    = "low"
+)
+
+// reviewResult is a reviewer's verdict on a draft.
+type reviewResult struct {
+	translation string // the corrected translation, or the draft if it is right
+	confidence  string // confidenceHigh, confidenceMedium or confidenceLow
+	issues      string // what was wrong or remains doubtful
+}
+
+// newReviewer sets up the provider and model named by --review-model, e.g.
+// openai/gpt-4o.
+func newReviewer(opts options, interactive bool) (reviewer, error) {
+	provider, model, err := parseSide(opts.reviewModel)
+	if err != nil {
+		return nil, fmt.Errorf("--review-model: %v", err)
+	}
+	reviewOpts := options{provider: provider, model: model, proxy: opts.proxy, caCert: opts.caCert, domain: opts.domain, tone: opts.tone, requestTimeout: opts.requestTimeout, cassette: opts.cassette}
+	if provider == opts.provider {
+		reviewOpts.baseURL = opts.baseURL
+	}
+	translator, _, err := newRunTranslator(reviewOpts, nil, interactive)
+	if err != nil {
+		return nil, fmt.Errorf("--review-model: %v", err)
+	}
+	r, ok := translator.(reviewer)
+	if !ok {
+		return nil, fmt.Errorf("--review-model: %s cannot review translations, use a chat model such as openai/gpt-4o", provider)
+	}
+	return r, nil
+}
+
+// reviewModelLabel names the models of a run for reports, e.g.
+// "openai/gpt-4o-mini, reviewed by openai/gpt-4o".
+func reviewModelLabel(opts options) string {
+	label := modelLabel(opts.provider, opts.model)
+	if provider, model, err := parseSide(opts.reviewModel); err == nil && opts.reviewModel != "" {
+		label += ", reviewed by " + modelLabel(provider, model)
+	}
+	return label
+}
+
+// reviewDraft has the review model check draft, the translation of text,
+// and returns what to write. A correction that loses placeholders or breaks
+// the length limit where the draft did not is not taken. Doubts of the
+// reviewer, and a failed review, are flagged on rj; the draft is kept then.
+func (j *translationJob) reviewDraft(ctx context.Context, p msgSender, rj *rowJob, text, draft string, limit int) string {
+	if j.reviewer == nil {
+		return draft
+	}
+	policy := retryPolicy{retries: j.retries, baseDelay: defaultRetryBaseDelay, maxDelay: defaultRetryMaxDelay, timeout: j.reqTimeout}
+	var r reviewResult
+	err := policy.run(j.withHints(ctx, text), func(ctx context.Context) error {
+		var err error
+		r, err = j.reviewer.Review(ctx, text, draft, j.sourceLang, j.targetLang)
+		return err
+	})
+	if err != nil {
+		p.Send(logMsg(fmt.Sprintf("ERROR: review of %q: %v", text, err)))
+		rj.flags = append(rj.flags, fmt.Sprintf("review failed, draft kept: %v", err))
+		return draft
+	}
+	result := draft
+	switch n := utf8.RuneCountInString(r.translation); {
+	case r.translation == "" || r.translation == draft:
+	case len(missingPlaceholders(text, r.translation)) > len(missingPlaceholders(text, draft)):
+		rj.flags = append(rj.flags, fmt.Sprintf("review changed placeholders, draft kept instead of %q", r.translation))
+	case limit > 0 && n > limit && n > utf8.RuneCountInString(draft):
+		rj.flags = append(rj.flags, fmt.Sprintf("review exceeds the length limit, draft kept instead of %q", r.translation))
+	default:
+		result = r.translation
+		p.Send(logMsg(fmt.Sprintf("Review corrected %q: %q -> %q", text, draft, result)))
+	}
+	if r.confidence != confidenceHigh {
+		flag := fmt.Sprintf("review: %s confidence", r.confidence)
+		if r.issues != "" {
+			flag += ": " + r.issues
+		}
+		rj.flags = append(rj.flags, flag)
+		rj.doubtful = true
+	}
+	return result
+}
+
+// Review asks the model to check and correct a draft translation, with the
+// glossary, context and limits of the hints attached to ctx.
+func (t *openaiTranslator) Review(ctx context.Context, source, draft, sourceLang, targetLang string) (reviewResult, error) {
+	input, err := json.Marshal(map[string]string{"source": source, "draft": draft})
+	if err != nil {
+		return reviewResult{}, err
+	}
+	instructions := fmt.Sprintf("%s You review machine translations from '%s' to '%s'. The input is a JSON object with a source text and a draft translation of it. Check the draft for mistranslations, omissions, additions, wrong terminology, grammar and changed placeholders, and correct it. Keep what is already correct and keep the length close to the draft's.%s%s%s", t.role(), sourceLang, targetLang, toneInstructions(t.tone), hintInstructions(ctx), markerInstructions(source))
+	resp, err := t.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: t.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: instructions + reviewReplyInstructions},
+			{Role: openai.ChatMessageRoleUser, Content: string(input)},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	})
+	if err != nil {
+		return reviewResult{}, t.filtered(err)
+	}
+	t.limiter.observe(resp.Header())
+	countTokens(ctx, resp.Usage.TotalTokens)
+	t.budget.spend(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, 0, 1)
+	if len(resp.Choices) == 0 {
+		return reviewResult{}, fmt.Errorf("%s returned no choices", t.name)
+	}
+	return parseReview(resp.Choices[0].Message.Content)
+}
+
+// reviewReplyInstructions asks for the verdict as a JSON object.
+const reviewReplyInstructions = ` Reply with a JSON object of the form {"translation": "...", "confidence": "high", "issues": "..."} and nothing else: the corrected translation, or the draft unchanged if it is right; "high", "medium" or "low" for how sure you are that the translation is now correct; and in a few words what was wrong or remains doubtful, or "" if nothing.`
+
+type reviewResponse struct {
+	Translation *string `json:"translation"`
+	Confidence  string  `json:"confidence"`
+	Issues      string  `json:"issues"`
+}
+
+// parseReview reads a reply to reviewReplyInstructions. A confidence the
+// model made up counts as low.
+func parseReview(content string) (reviewResult, error) {
+	var parsed reviewResponse
+	if err := json.Unmarshal([]byte(jsonObject(content)), &parsed); err != nil {
+		return reviewResult{}, fmt.Errorf("could not parse review: %w", err)
+	}
+	if parsed.Translation == nil {
+		return reviewResult{}, fmt.Errorf("review has no translation field")
+	}
+	r := reviewResult{translation: *parsed.Translation, confidence: strings.ToLower(strings.TrimSpace(parsed.Confidence)), issues: strings.TrimSpace(parsed.Issues)}
+	if r.confidence != confidenceHigh && r.confidence != confidenceMedium {
+		r.confidence = confidenceLow
+	}
+	return r, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeReviewer returns the verdicts set up per draft; drafts without one
+// are confirmed with high confidence.
+type fakeReviewer struct {
+	mu       sync.Mutex
+	verdicts map[string]reviewResult
+	drafts   []string
+}
+
+func (r *fakeReviewer) Review(ctx context.Context, source, draft, sourceLang, targetLang string) (reviewResult, error) {
+	r.mu.Lock()
+	r.drafts = append(r.drafts, draft)
+	r.mu.Unlock()
+	if draft == "BROKEN" {
+		return reviewResult{}, errors.New("no verdict")
+	}
+	if v, ok := r.verdicts[draft]; ok {
+		return v, nil
+	}
+	return reviewResult{translation: draft, confidence: confidenceHigh}, nil
+}
+
+func TestReviewDraft(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Pump stoped", ""},
+		{"Valve {0}", ""},
+		{"Druck zu hoch", ""},
+		{"Broken", ""},
+		{"Motor fault", ""},
+	}
+	job := newTestJob(t, rows)
+	job.report = &fileReport{}
+	reviewer := &fakeReviewer{verdicts: map[string]reviewResult{
+		"PUMP STOPED":   {translation: "PUMP STOPPED", confidence: confidenceHigh},
+		"VALVE {0}":     {translation: "VALVE", confidence: confidenceHigh},
+		"DRUCK ZU HOCH": {translation: "PRESSURE TOO HIGH", confidence: confidenceLow, issues: "unsure about the unit"},
+	}}
+	job.reviewer = reviewer
+
+	iterateAndTranslate(context.Background(), discardSender{}, &upperTranslator{}, job)
+
+	want := map[string]string{
+		"B2": "MOTOR FAULT",
+		"B3": "PUMP STOPPED",
+		"B4": "VALVE {0}", // the correction lost the placeholder
+		"B5": "PRESSURE TOO HIGH",
+		"B6": "BROKEN",
+		"B7": "MOTOR FAULT",
+	}
+	for cell, value := range want {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != value {
+			t.Errorf("%s = %q, want %q", cell, got, value)
+		}
+	}
+	// The duplicate reuses the reviewed translation.
+	if len(reviewer.drafts) != 5 {
+		t.Errorf("reviewed %d drafts, want 5: %q", len(reviewer.drafts), reviewer.drafts)
+	}
+	flags := make(map[int]string)
+	for _, e := range job.report.entries {
+		flags[e.row] = strings.Join(e.flags, "; ")
+	}
+	for row, want := range map[int]string{
+		2: "",
+		3: "",
+		4: "review changed placeholders",
+		5: "review: low confidence: unsure about the unit",
+		6: "review failed, draft kept",
+	} {
+		if want == "" && flags[row] != "" || !strings.Contains(flags[row], want) {
+			t.Errorf("flags of row %d = %q, want %q", row, flags[row], want)
+		}
+	}
+}
+
+func TestParseReview(t *testing.T) {
+	tests := []struct {
+		content string
+		want    reviewResult
+		wantErr bool
+	}{
+		{`{"translation": "Pump off", "confidence": "high", "issues": ""}`, reviewResult{"Pump off", confidenceHigh, ""}, false},
+		{"```json\n{\"translation\": \"Pump off\", \"confidence\": \"Medium\", \"issues\": \"term\"}\n```", reviewResult{"Pump off", confidenceMedium, "term"}, false},
+		{`{"translation": "Pump off", "confidence": "95%"}`, reviewResult{"Pump off", confidenceLow, ""}, false},
+		{`{"confidence": "high"}`, reviewResult{}, true},
+		{`Pump off`, reviewResult{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseReview(tt.content)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseReview(%q) = %+v, %v; want %+v", tt.content, got, err, tt.want)
+		}
+	}
+}
+
+func TestOpenAIReview(t *testing.T) {
+	var input string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		input = req.Messages[len(req.Messages)-1].Content
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{
+				"message": map[string]string{"role": "assistant", "content": `{"translation": "Pumpe aus", "confidence": "high", "issues": "wrong verb"}`},
+			}},
+		})
+	}))
+	defer server.Close()
+
+	translator := newOpenAITranslator("test server", providerConfig{baseURL: server.URL}, "test-model")
+	got, err := translator.Review(context.Background(), "Pump off", "Pumpe ab", "en-US", "de-DE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (reviewResult{"Pumpe aus", confidenceHigh, "wrong verb"}); got != want {
+		t.Errorf("Review() = %+v, want %+v", got, want)
+	}
+	if input != `{"draft":"Pumpe ab","source":"Pump off"}` {
+		t.Errorf("input = %s", input)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// REVIEW SHEET
+// ///////////////////
+
+// With --review-sheet, the output workbook gets a sheet that lists every row
+// the run handled with only its source, translation, status and QA flags:
+// failed rows first, flagged rows next, then the rest in sheet order. A
+// reviewer can work through it without scrolling across the full export
+// layout. Rows that were skipped or kept as they were are left out.
+
+// reviewSheetName is the sheet the rows are listed on.
+const reviewSheetName = "Review"
+
+var reviewSheetHeader = []string{"Sheet", "Row", "Target", "Source", "Translation", "Status", "Flags"}
+
+// reviewRank orders the entries of the review sheet; -1 leaves an entry out.
+func reviewRank(e reportEntry) int {
+	switch {
+	case e.status == rowSkipped || e.status == rowPreserved:
+		return -1
+	case e.status == rowFailed:
+		return 0
+	// The detail of a copied row is why it was copied, not a finding.
+	case e.detail != "" && e.status != rowCopied && e.status != rowPlaceholder:
+		return 1
+	}
+	return 2
+}
+
+// reviewEntries returns the entries of fr for the review sheet, in order.
+func reviewEntries(fr *fileReport) []reportEntry {
+	var entries []reportEntry
+	for _, e := range fr.sorted() {
+		if reviewRank(e) >= 0 {
+			entries = append(entries, e)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b reportEntry) int {
+		return cmp.Compare(reviewRank(a), reviewRank(b))
+	})
+	return entries
+}
+
+// writeReviewSheet lists the rows of fr on a new sheet of f and returns its
+// name: reviewSheetName, or "Review 2" and so on if the workbook already has
+// one.
+func writeReviewSheet(f *excelize.File, fr *fileReport) (string, error) {
+	name := freeSheetName(f, reviewSheetName)
+	if _, err := f.NewSheet(name); err != nil {
+		return "", err
+	}
+	header := make([]any, len(reviewSheetHeader))
+	for i, h := range reviewSheetHeader {
+		header[i] = h
+	}
+	if err := f.SetSheetRow(name, "A1", &header); err != nil {
+		return "", err
+	}
+	entries := reviewEntries(fr)
+	for i, e := range entries {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		values := []any{e.sheet, strconv.Itoa(e.row), e.targetLang, e.source, e.translation, string(e.status), e.detail}
+		if err := f.SetSheetRow(name, cell, &values); err != nil {
+			return "", err
+		}
+	}
+	// Room for the texts, and a filter to narrow them down by status.
+	if err := f.SetColWidth(name, "D", "E", 50); err != nil {
+		return "", err
+	}
+	if err := f.SetColWidth(name, "G", "G", 60); err != nil {
+		return "", err
+	}
+	last, _ := excelize.CoordinatesToCellName(len(reviewSheetHeader), len(entries)+1)
+	if err := f.AutoFilter(name, "A1:"+last, nil); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// isReviewSheet reports whether a sheet with the given rows was written by
+// writeReviewSheet.
+func isReviewSheet(rows [][]string) bool {
+	return len(rows) > 0 && slices.Equal(rows[0], reviewSheetHeader)
+}
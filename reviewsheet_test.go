@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestWriteReviewSheet(t *testing.T) {
+	fr := &fileReport{entries: []reportEntry{
+		{sheet: "Texts", row: 4, targetLang: "en-US", source: "Pumpe", translation: "Pump", status: rowTranslated},
+		{sheet: "Texts", row: 2, targetLang: "en-US", source: "Ventil {0}", translation: "Valve", status: rowTranslated, detail: "placeholders changed: {0}"},
+		{sheet: "Texts", row: 3, targetLang: "en-US", source: "", status: rowSkipped, detail: "empty"},
+		{sheet: "Texts", row: 5, targetLang: "en-US", source: "Motor", status: rowFailed, detail: "timeout"},
+		{sheet: "Texts", row: 6, targetLang: "en-US", source: "P-101", translation: "P-101", status: rowCopied, detail: "tag name"},
+		{sheet: "Texts", row: 7, targetLang: "en-US", source: "Start", translation: "Start", status: rowPreserved},
+	}}
+	f := excelize.NewFile()
+	defer f.Close()
+	f.NewSheet(reviewSheetName)
+
+	name, err := writeReviewSheet(f, fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Review 2" {
+		t.Errorf("sheet %q; expected Review 2 next to the existing one", name)
+	}
+	rows, _ := f.GetRows(name)
+	want := [][]string{
+		reviewSheetHeader,
+		{"Texts", "5", "en-US", "Motor", "", "error", "timeout"},
+		{"Texts", "2", "en-US", "Ventil {0}", "Valve", "translated", "placeholders changed: {0}"},
+		{"Texts", "4", "en-US", "Pumpe", "Pump", "translated"},
+		{"Texts", "6", "en-US", "P-101", "P-101", "copied", "tag name"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows\n%q\nexpected\n%q", rows, want)
+	}
+	if !isReviewSheet(rows) {
+		t.Error("the review sheet is not recognized")
+	}
+}
+
+func TestRunTasksReviewSheet(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "TextExport.xlsx")
+	writeSheet(t, path, [][]string{{"de-DE", "en-US"}, {"Pumpe", ""}})
+	opts := options{workers: 1, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US", reviewSheet: true}
+	task, err := prepareFile(opts, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &runResult{}
+	runTasks(context.Background(), discardSender{}, &upperTranslator{}, []*fileTask{task}, opts, result)
+
+	f, err := excelize.OpenFile(filepath.Join(dir, "translated-TextExport.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(reviewSheetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || !reflect.DeepEqual(rows[1], []string{"Sheet1", "2", "en-US", "Pumpe", "PUMPE", "translated"}) {
+		t.Errorf("review sheet %q", rows)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+func TestRulesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	rules := "copy regex ^[A-Z]\\d+$\ntranslate exact OK\nskip prefix SPARE\n"
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := tiatrans.LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"M12", ""},
+		{"Ok", ""},
+		{"SPARE 3", "old"},
+		{"42", ""},
+		{"Motor fault", ""},
+	}
+	job := newTestJob(t, rows)
+	job.rules = rs
+	iterateAndTranslate(context.Background(), discardSender{}, &upperTranslator{}, job)
+
+	expected := map[string]string{
+		"B2": "M12",         // copied by the file
+		"B3": "OK",          // translated despite the built-in short text rule
+		"B4": "old",         // skipped by the file
+		"B5": "42",          // built-in rules still apply
+		"B6": "MOTOR FAULT", // translated
+	}
+	for cell, want := range expected {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+}
+
+func TestRulesFilePlaceholders(t *testing.T) {
+	rs, err := tiatrans.ParseRules("placeholder  \\{[A-Za-z_][\\w.]*\\}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := placeholderTokenRegex
+	t.Cleanup(func() { placeholderTokenRegex = saved })
+	addPlaceholderSyntaxes(rs.Placeholders)
+
+	if got := placeholderTokens("Level {Tank.Level} of @1%d@"); len(got) != 2 || got[0] != "{Tank.Level}" {
+		t.Errorf("placeholderTokens = %q; expected the declared and the built-in syntax", got)
+	}
+
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"{Tank.Level}", ""},
+		{"Füllstand {Tank.Level}", ""},
+	}
+	job := newTestJob(t, rows)
+	job.rules = rs
+	iterateAndTranslate(context.Background(), discardSender{}, &upperTranslator{}, job)
+
+	if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != "{Tank.Level}" {
+		t.Errorf("B2 = %q; expected the placeholder copied", got)
+	}
+	// The upper-cased placeholder no longer matches the source's.
+	if flags := job.qaFlags("Füllstand {Tank.Level}", "FÜLLSTAND {TANK.LEVEL}"); len(flags) == 0 || !strings.Contains(flags[0], "{Tank.Level}") {
+		t.Errorf("flags = %q; expected the changed placeholder", flags)
+	}
+}
@@ -0,0 +1,1306 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/xuri/excelize/v2"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// FILE TASKS
+// ///////////////////
+
+// fileTask is one workbook, opened and configured for translation. It has
+// one job per sheet and target column; the jobs share the workbook and the
+// checkpoint. headers and fileType are those of the first sheet.
+type fileTask struct {
+	fileName    string
+	fileType    FileType
+	headers     []string
+	f           *excelize.File
+	sheets      []string
+	csv         *csvFormat  // set when the input is a CSV file
+	simatic     *simaticDoc // set when the input is a TIA Portal XML export
+	po          *poDoc      // set when the input is a gettext PO file
+	checkpoint  *checkpoint
+	autosave    *autosaver
+	review      *reviewList // set with --review
+	failures    *failureList
+	highlight   *highlighter
+	fallback    *fallbackList // set with --untranslated copy
+	edits       *cellEdits    // set when the workbook is streamed, see streamRows
+	errorsSheet string        // sheet the failed rows were listed on, once saved
+	backup      string        // copy of the input made by --in-place, once saved
+	reviewSheet string        // sheet of --review-sheet, once saved
+	hashes      *rowHashes    // set with --hashes
+	jobs        []translationJob
+}
+
+// inputExtensions are the extensions of the files that can be translated.
+var inputExtensions = []string{".xlsx", ".xls", ".csv", ".xml", ".po"}
+
+// listInputFiles returns the spreadsheets in dir that are not output of an
+// earlier run.
+func listInputFiles(dir string) ([]string, error) {
+	// Find every supported input file
+	var files []string
+	for _, ext := range inputExtensions {
+		matches, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+		if err != nil {
+			return nil, fmt.Errorf("Error finding %s files: %v", ext, err)
+		}
+		files = append(files, matches...)
+	}
+
+	var filteredFiles []string
+	for _, file := range files {
+		if !isSidecarFile(file) {
+			filteredFiles = append(filteredFiles, file)
+		}
+	}
+
+	if len(filteredFiles) == 0 {
+		return nil, fmt.Errorf("No .xls, .xlsx, .csv, .xml or .po files found to translate in %s.", dir)
+	}
+	return filteredFiles, nil
+}
+
+// expandFileArg resolves --file, which may be a pattern such as
+// \\server\share\Project\*.xlsx. Outputs of earlier runs are left out of
+// what a pattern matches.
+func expandFileArg(file string) ([]string, error) {
+	if !strings.ContainsAny(file, "*?[") {
+		return []string{file}, nil
+	}
+	matches, err := filepath.Glob(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", file, err)
+	}
+	var files []string
+	for _, match := range matches {
+		if slices.Contains(inputExtensions, strings.ToLower(filepath.Ext(match))) && !isSidecarFile(match) {
+			files = append(files, match)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("No files to translate match %s", file)
+	}
+	return files, nil
+}
+
+// prepareFile opens a workbook and settles sheet, columns and mode from the
+// options, asking the user for anything missing when interactive is set.
+// opts is a copy, so filling in values from a checkpoint does not leak into
+// other files.
+func prepareFile(opts options, fileName string, tm *translationMemory, interactive bool) (*fileTask, error) {
+	f, format, simatic, po, err := openInput(opts, fileName)
+	if err != nil {
+		return nil, err
+	}
+	task, err := configureFile(opts, fileName, f, tm, interactive)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	task.csv = format
+	task.simatic = simatic
+	task.po = po
+	if format == nil && simatic == nil && po == nil && !opts.csvOutput && task.totalRows() >= streamRows {
+		task.stream()
+	}
+	if err := task.loadRows(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return task, nil
+}
+
+// openInput opens any supported input file as a workbook. CSV, XML and PO
+// files come with what is needed to write them back.
+func openInput(opts options, fileName string) (f *excelize.File, format *csvFormat, simatic *simaticDoc, po *poDoc, err error) {
+	switch {
+	case isCSVFile(fileName):
+		f, format, err = openCSV(fileName, opts.csvDelimiter, opts.csvEncoding)
+	case isSimaticFile(fileName):
+		f, simatic, err = openSimatic(fileName)
+	case isPOFile(fileName):
+		f, po, err = openPO(fileName)
+	default:
+		if f, err = excelize.OpenFile(fileName); err != nil {
+			err = fmt.Errorf("Error opening file: %v", err)
+		}
+	}
+	return f, format, simatic, po, err
+}
+
+func configureFile(opts options, fileName string, f *excelize.File, tm *translationMemory, interactive bool) (*fileTask, error) {
+	// A resumed run takes its sheets, columns and mode from the checkpoint
+	// unless they are given explicitly.
+	var saved *checkpointState
+	if opts.resume {
+		var err error
+		if saved, err = loadCheckpoint(checkpointPath(fileName)); err != nil {
+			return nil, err
+		}
+		if saved == nil {
+			return nil, fmt.Errorf("No checkpoint found for %s", fileName)
+		}
+		if opts.sheet == "" {
+			var names []string
+			for _, name := range f.GetSheetList() {
+				if saved.Sheets[name] != nil {
+					names = append(names, name)
+				}
+			}
+			opts.sheet = strings.Join(names, ",")
+		}
+		if opts.mode == "" {
+			opts.mode = saved.Mode
+		}
+	}
+
+	var last *selection
+	if interactive {
+		last = lastSelection(fileName)
+	}
+	sheetNames, err := selectSheets(f, opts.sheet, interactive, last)
+	if err != nil {
+		return nil, fmt.Errorf("%v in %s", err, fileName)
+	}
+
+	task := &fileTask{fileName: fileName, f: f}
+	cpState := checkpointState{File: filepath.Base(fileName), Sheets: make(map[string]*sheetProgress)}
+
+	// The first sheet is set up as usual; the others reuse its columns (by
+	// header name) and mode, and are skipped if they don't have them.
+	for _, sheetName := range sheetNames {
+		sheetOpts := opts
+		if saved != nil && saved.Sheets[sheetName] != nil {
+			sp := saved.Sheets[sheetName]
+			if opts.sourceCol == "" && opts.sourceLang == "" {
+				sheetOpts.sourceCol = strconv.Itoa(sp.SourceCol)
+			}
+			if opts.targetCol == "" && opts.targetLang == "" {
+				cols := make([]string, len(sp.TargetCols))
+				for i, col := range sp.TargetCols {
+					cols[i] = strconv.Itoa(col)
+				}
+				sheetOpts.targetCol = strings.Join(cols, ",")
+			}
+		}
+		if len(task.sheets) > 0 && saved == nil {
+			// --source and --target are looked up in each sheet anew.
+			if opts.sourceLang == "" {
+				sheetOpts.sourceCol = task.jobs[0].sourceLang
+			}
+			if opts.targetLang == "" {
+				sheetOpts.targetCol = strings.Join(task.targetLangs(), ",")
+			}
+			sheetOpts.mode = task.jobs[0].mode
+		}
+
+		jobs, headers, fileType, err := configureSheet(sheetOpts, f, sheetName, tm, interactive && len(task.sheets) == 0, last)
+		if err != nil {
+			if len(sheetNames) == 1 {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "Skipping sheet %q of %s: %v\n", sheetName, fileName, err)
+			continue
+		}
+		if len(task.sheets) == 0 {
+			task.headers = headers
+			task.fileType = fileType
+			cpState.Mode = jobs[0].mode
+		}
+		task.sheets = append(task.sheets, sheetName)
+		sp := &sheetProgress{SourceCol: jobs[0].sourceIndex + 1}
+		for _, job := range jobs {
+			sp.TargetCols = append(sp.TargetCols, job.targetIndex+1)
+		}
+		cpState.Sheets[sheetName] = sp
+		task.jobs = append(task.jobs, jobs...)
+	}
+	if len(task.sheets) == 0 {
+		return nil, fmt.Errorf("No sheet of %s could be set up", fileName)
+	}
+
+	if saved != nil {
+		if err := saved.matches(cpState); err != nil {
+			return nil, fmt.Errorf("Cannot resume: %v", err)
+		}
+		cpState.Sheets = saved.Sheets
+		for i := range task.jobs {
+			job := &task.jobs[i]
+			job.resumed = saved.Sheets[job.sheetName].Rows[job.targetIndex+1]
+		}
+	}
+	if opts.hashes {
+		if task.hashes, err = loadHashes(hashesPath(fileName)); err != nil {
+			return nil, err
+		}
+		for i := range task.jobs {
+			job := &task.jobs[i]
+			job.hashes = task.hashes.column(job.sheetName, job.sourceLang, job.targetLang)
+		}
+	}
+	task.checkpoint = newCheckpoint(checkpointPath(fileName), opts.checkpointEvery, cpState)
+	task.autosave = newAutosaver(f, autosavePath(fileName), opts.autosaveEvery)
+	task.highlight = newHighlighter(opts.highlight, opts.highlightComments)
+	if opts.review {
+		task.review = &reviewList{}
+	}
+	task.failures = &failureList{}
+	if opts.untranslated == untranslatedCopy {
+		task.fallback = &fallbackList{}
+	}
+	// Sheets of a file share the terms of each target language.
+	sessions := make(map[string]*sessionTerms)
+	for i := range task.jobs {
+		job := &task.jobs[i]
+		if !opts.noSessionTerms {
+			if sessions[job.targetLang] == nil {
+				sessions[job.targetLang] = newSessionTerms(job.sourceLang)
+				sessions[job.targetLang].inOrder = opts.cassette != nil
+			}
+			job.session = sessions[job.targetLang]
+		}
+	}
+	for i := range task.jobs {
+		task.jobs[i].failures = task.failures
+		task.jobs[i].fallback = task.fallback
+		task.jobs[i].checkpoint = task.checkpoint
+		task.jobs[i].autosave = task.autosave
+		task.jobs[i].review = task.review
+	}
+	return task, nil
+}
+
+// selectSheets resolves --sheet: a comma-separated list of sheet names, or
+// "*" for all of them. Without it, interactive runs ask when the workbook has
+// more than one sheet, starting from the sheets picked last time, and other
+// runs use the first sheet.
+func selectSheets(f *excelize.File, spec string, interactive bool, last *selection) ([]string, error) {
+	all := f.GetSheetList()
+	switch {
+	case spec == "*":
+		return all, nil
+	case spec != "":
+		var names []string
+		for _, name := range strings.Split(spec, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || slices.Contains(names, name) {
+				continue
+			}
+			if !slices.Contains(all, name) {
+				return nil, fmt.Errorf("Sheet %q not found", name)
+			}
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("No sheet given")
+		}
+		return names, nil
+	case !interactive || len(all) == 1:
+		return all[:1], nil
+	}
+
+	picked := all[:1]
+	if last != nil {
+		if kept := slices.DeleteFunc(slices.Clone(last.Sheets), func(name string) bool { return !slices.Contains(all, name) }); len(kept) > 0 {
+			picked = kept
+		}
+	}
+	var names []string
+	options := make([]huh.Option[string], len(all))
+	for i, name := range all {
+		options[i] = huh.NewOption(name, name).Selected(slices.Contains(picked, name))
+	}
+	form := huh.NewForm(huh.NewGroup(
+		huh.NewMultiSelect[string]().
+			Title("Select Sheets to Translate").
+			Description("space to toggle, enter to confirm").
+			Options(options...).
+			Validate(func(names []string) error {
+				if len(names) == 0 {
+					return fmt.Errorf("select at least one sheet")
+				}
+				return nil
+			}).
+			Value(&names),
+	)).WithTheme(formTheme)
+	if err := form.Run(); err != nil {
+		return nil, err
+	}
+	// Keep workbook order regardless of selection order.
+	var ordered []string
+	for _, name := range all {
+		if slices.Contains(names, name) {
+			ordered = append(ordered, name)
+		}
+	}
+	return ordered, nil
+}
+
+// configureSheet settles the columns and mode of one sheet and returns a job
+// per target column. The forms start from last, if the workbook was
+// translated before.
+func configureSheet(opts options, f *excelize.File, sheetName string, tm *translationMemory, interactive bool, last *selection) ([]translationJob, []string, FileType, error) {
+	// A sheet may be too big to hold in memory; loadRows reads the rest.
+	rows, count, err := readSheet(f, sheetName, sheetSample)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("Error getting rows: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil, 0, fmt.Errorf("Sheet %q is empty", sheetName)
+	}
+	headers := rows[0]
+
+	// Detect file type from headers
+	fileType := detectFileType(headers)
+	if interactive {
+		fmt.Println()
+		fmt.Println(statusBoxStyle.Render(fmt.Sprintf("Detected: %s", fileType.String())))
+		fmt.Println()
+	}
+
+	detected := columnLanguages(rows)
+	sourceLangIndex := -1
+	var targetLangIndices []int
+	switch {
+	case opts.sourceLang != "":
+		var note string
+		if sourceLangIndex, note, err = resolveLanguage(rows, opts.sourceLang, true); err != nil {
+			return nil, nil, 0, fmt.Errorf("--source: %v", err)
+		}
+		if note != "" {
+			setupWarning(interactive, sheetName, note)
+		}
+	case opts.sourceCol == "auto":
+		if sourceLangIndex = suggestSourceColumn(rows, detected); sourceLangIndex == -1 {
+			return nil, nil, 0, fmt.Errorf("--source-col auto: no column of sheet %q is marked as reference language or recognizably matches its header", sheetName)
+		}
+	case opts.sourceCol != "":
+		if sourceLangIndex, err = tiatrans.ResolveColumn(headers, opts.sourceCol); err != nil {
+			return nil, nil, 0, fmt.Errorf("--source-col: %v", err)
+		}
+	}
+	switch {
+	case opts.targetLang != "":
+		for _, code := range strings.Split(opts.targetLang, ",") {
+			if strings.TrimSpace(code) == "" {
+				continue
+			}
+			col, note, err := resolveLanguage(rows, code, false)
+			if err != nil {
+				return nil, nil, 0, fmt.Errorf("--target: %v", err)
+			}
+			if note != "" {
+				setupWarning(interactive, sheetName, note)
+			}
+			if !slices.Contains(targetLangIndices, col) {
+				targetLangIndices = append(targetLangIndices, col)
+			}
+		}
+	case opts.targetCol != "":
+		if targetLangIndices, err = resolveColumns(headers, opts.targetCol); err != nil {
+			return nil, nil, 0, fmt.Errorf("--target-col: %v", err)
+		}
+	}
+	contextCol := 0
+	if opts.contextCol != "" {
+		index, err := tiatrans.ResolveColumn(headers, opts.contextCol)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("--context-col: %v", err)
+		}
+		contextCol = index + 1
+	}
+	translationMode := opts.mode
+	if fileType == FileTypePO {
+		// A PO sheet only has one source and one target column.
+		if sourceLangIndex == -1 {
+			sourceLangIndex = 2
+		}
+		if len(targetLangIndices) == 0 {
+			targetLangIndices = []int{3}
+		}
+		// msgctxt exists to tell identical msgids apart.
+		if contextCol == 0 {
+			contextCol = 2
+		}
+	}
+
+	if !interactive {
+		if sourceLangIndex == -1 || len(targetLangIndices) == 0 {
+			return nil, nil, 0, fmt.Errorf("--source-col/--source and --target-col/--target are required in non-interactive mode")
+		}
+		if translationMode == "" {
+			translationMode = "full"
+		}
+	} else if sourceLangIndex == -1 || len(targetLangIndices) == 0 || translationMode == "" {
+		defaults := setupDefaults{source: suggestSourceColumn(rows, detected)}
+		if last != nil {
+			if col, err := tiatrans.ResolveColumn(headers, last.Source); err == nil {
+				defaults.source = col
+			}
+			for _, header := range last.Targets {
+				if col, err := tiatrans.ResolveColumn(headers, header); err == nil {
+					defaults.targets = append(defaults.targets, col)
+				}
+			}
+			defaults.mode = last.Mode
+		}
+		if err := runSetupForm(headers, fileType, metadataColumns(headers, fileType, opts.metaCols), detected, defaults, &sourceLangIndex, &targetLangIndices, &translationMode); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	if slices.Contains(targetLangIndices, sourceLangIndex) {
+		return nil, nil, 0, fmt.Errorf("Column %d cannot be both source and target", sourceLangIndex+1)
+	}
+	if slices.Contains(targetLangIndices, contextCol-1) {
+		return nil, nil, 0, fmt.Errorf("Column %d cannot be both context and target", contextCol)
+	}
+	for _, col := range append([]int{sourceLangIndex}, targetLangIndices...) {
+		if mismatch := languageMismatch(headers, detected, col); mismatch != "" {
+			setupWarning(interactive, sheetName, mismatch)
+		}
+	}
+
+	var jobs []translationJob
+	for _, targetLangIndex := range targetLangIndices {
+		terms, err := jobGlossary(opts, headers[sourceLangIndex], headers[targetLangIndex])
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		var previous *previousRun
+		if opts.previousPath != "" {
+			if previous, err = loadPrevious(opts, sheetName, headers, sourceLangIndex, targetLangIndex, tm); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+		jobs = append(jobs, translationJob{
+			f:              f,
+			sheetName:      sheetName,
+			rows:           rows,
+			rowCount:       count,
+			sourceIndex:    sourceLangIndex,
+			targetIndex:    targetLangIndex,
+			contextCol:     contextCol,
+			sourceLang:     headers[sourceLangIndex],
+			targetLang:     headers[targetLangIndex],
+			mode:           translationMode,
+			fileType:       fileType,
+			workers:        opts.workers,
+			batchSize:      opts.batchSize,
+			batchAPI:       opts.batchAPI,
+			retries:        opts.retries,
+			reqTimeout:     opts.requestTimeout,
+			rowTimeout:     opts.rowTimeout,
+			tm:             tm,
+			glossary:       terms,
+			previous:       previous,
+			filter:         opts.filter,
+			rules:          opts.rules,
+			protected:      opts.protected,
+			maxLength:      opts.maxLength.forColumn(headers, targetLangIndex),
+			via:            viaFor(opts.via, headers[sourceLangIndex], headers[targetLangIndex]),
+			mixedSource:    opts.mixedSource,
+			splitSentences: opts.splitSentences,
+			keepCase:       !opts.noCaseRules,
+		})
+	}
+	return jobs, headers, fileType, nil
+}
+
+// setupWarning reports a doubt about the column choice of a sheet.
+func setupWarning(interactive bool, sheetName, msg string) {
+	if interactive {
+		fmt.Println(logStyleFlagged.Render(fmt.Sprintf("Warning: sheet %s: %s", sheetName, msg)))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "WARNING: sheet %s: %s\n", sheetName, msg)
+}
+
+// askOverwrites lets the user decide, before anything is translated, which
+// existing target translations may be replaced (--overwrite=ask).
+func askOverwrites(tasks []*fileTask) error {
+	type candidate struct {
+		job            *translationJob
+		row            int
+		source, target string
+	}
+	var candidates []candidate
+	for _, task := range tasks {
+		for j := range task.jobs {
+			job := &task.jobs[j]
+			for i, row := range job.rows {
+				if i == 0 || len(row) <= job.targetIndex || len(row) <= job.sourceIndex {
+					continue
+				}
+				if _, ok := job.resumed[i+1]; ok {
+					continue
+				}
+				source, target := strings.TrimSpace(row[job.sourceIndex]), strings.TrimSpace(row[job.targetIndex])
+				if source != "" && tiatrans.HasTranslation(target) && job.filter.allows(i+1, source) {
+					candidates = append(candidates, candidate{job, i, source, target})
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	const replaceAll, keepAll, eachRow = "replace", "keep", "each"
+	choice := keepAll
+	form := huh.NewForm(huh.NewGroup(
+		huh.NewSelect[string]().
+			Title(fmt.Sprintf("%d rows already have a translation", len(candidates))).
+			Options(
+				huh.NewOption("Keep all existing translations", keepAll),
+				huh.NewOption("Replace all of them", replaceAll),
+				huh.NewOption("Decide row by row", eachRow),
+			).
+			Value(&choice),
+	)).WithTheme(formTheme)
+	if err := form.Run(); err != nil {
+		return err
+	}
+
+	for n, c := range candidates {
+		keep := choice == keepAll
+		if choice == eachRow {
+			replace := false
+			confirm := huh.NewForm(huh.NewGroup(
+				huh.NewConfirm().
+					Title(fmt.Sprintf("Row %d, %s (%d/%d)", c.row+1, c.job.targetLang, n+1, len(candidates))).
+					Description(fmt.Sprintf("Source:  %s\nCurrent: %s", c.source, c.target)).
+					Affirmative("Replace").
+					Negative("Keep").
+					Value(&replace),
+			)).WithTheme(formTheme)
+			if err := confirm.Run(); err != nil {
+				return err
+			}
+			keep = !replace
+		}
+		if keep {
+			if c.job.keep == nil {
+				c.job.keep = make(map[int]bool)
+			}
+			c.job.keep[c.row] = true
+		}
+	}
+	return nil
+}
+
+// targetLangs lists the target column headers of a task.
+func (t *fileTask) targetLangs() []string {
+	var langs []string
+	for _, job := range t.jobs {
+		if !slices.Contains(langs, job.targetLang) {
+			langs = append(langs, job.targetLang)
+		}
+	}
+	return langs
+}
+
+// totalRows counts the data rows of all sheets in a task.
+func (t *fileTask) totalRows() int {
+	total := 0
+	seen := make(map[string]bool)
+	for _, job := range t.jobs {
+		if !seen[job.sheetName] {
+			seen[job.sheetName] = true
+			total += max(job.rowCount, len(job.rows)) - 1 // -1 for header
+		}
+	}
+	return total
+}
+
+// summaryLines describes the prepared tasks for the confirmation screen.
+func summaryLines(tasks []*fileTask, opts options) []string {
+	first := tasks[0]
+	job := first.jobs[0]
+	var lines []string
+	if len(tasks) == 1 {
+		targets := make([]string, len(first.jobs))
+		for i, j := range first.jobs {
+			targets[i] = fmt.Sprintf("%s (Column %d)", j.targetLang, j.targetIndex+1)
+		}
+		lines = append(lines,
+			fmt.Sprintf("File:       %s", first.fileName),
+			fmt.Sprintf("Sheet:      %s", strings.Join(first.sheets, ", ")),
+			fmt.Sprintf("Type:       %s", first.fileType.String()),
+			fmt.Sprintf("Source:     %s (Column %d)", job.sourceLang, job.sourceIndex+1),
+			fmt.Sprintf("Target:     %s", strings.Join(targets, ", ")),
+		)
+	} else {
+		names := make([]string, len(tasks))
+		for i, task := range tasks {
+			names[i] = task.fileName
+		}
+		lines = append(lines,
+			fmt.Sprintf("Files:      %d (%s)", len(tasks), strings.Join(names, ", ")),
+			fmt.Sprintf("Source:     %s", job.sourceLang),
+			fmt.Sprintf("Target:     %s", strings.Join(first.targetLangs(), ", ")),
+		)
+	}
+
+	totalRows := 0
+	for _, task := range tasks {
+		totalRows += task.totalRows()
+	}
+	lines = append(lines,
+		fmt.Sprintf("Mode:       %s", map[string]string{"full": "Full", "quick": "Quick"}[job.mode]),
+		fmt.Sprintf("Provider:   %s", opts.provider),
+		fmt.Sprintf("Workers:    %d", opts.workers),
+		fmt.Sprintf("Total rows: %d", totalRows),
+	)
+	if files := min(opts.parallelFiles, len(tasks)); files > 1 {
+		lines = append(lines, fmt.Sprintf("Parallel:   %d files at once", files))
+	}
+	if job.glossary != nil {
+		lines = append(lines, fmt.Sprintf("Glossary:   %d terms", len(job.glossary.terms)))
+	}
+	if opts.preset != "" {
+		lines = append(lines, fmt.Sprintf("Preset:     %s", opts.preset))
+	}
+	if opts.previousPath != "" {
+		lines = append(lines, fmt.Sprintf("Previous:   %s", opts.previousPath))
+		if opts.previousBasePath != "" {
+			lines = append(lines, fmt.Sprintf("Base:       %s", opts.previousBasePath))
+		}
+	}
+	if job.contextCol > 0 {
+		lines = append(lines, fmt.Sprintf("Context:    %s (Col %d)", job.rows[0][job.contextCol-1], job.contextCol))
+	}
+	if opts.fuzzyTM > 0 {
+		lines = append(lines, fmt.Sprintf("Fuzzy TM:   similarity %g (%s embeddings)", opts.fuzzyTM, opts.embeddings))
+	}
+	if opts.via != "" {
+		lines = append(lines, fmt.Sprintf("Via:        %s", opts.via))
+	}
+	if opts.mixedSource != "" {
+		lines = append(lines, fmt.Sprintf("Languages:  %s source texts in another language", opts.mixedSource))
+	}
+	if opts.untranslated == untranslatedCopy {
+		lines = append(lines, "Fallback:   source copied into untranslated rows, in red")
+	}
+	if opts.reviewModel != "" {
+		lines = append(lines, fmt.Sprintf("Review:     %s", opts.reviewModel))
+	}
+	if opts.selfCheck {
+		lines = append(lines, "Self-check: on, doubtful rows in orange")
+	}
+	if opts.rules != nil {
+		lines = append(lines, fmt.Sprintf("Rules:      %s (%d rules)", opts.rules.Path, len(opts.rules.Rules)))
+	}
+	if opts.promptPath != "" {
+		lines = append(lines, fmt.Sprintf("Prompt:     %s", opts.promptPath))
+	}
+	if opts.domain != "" {
+		lines = append(lines, fmt.Sprintf("Domain:     %s", opts.domain))
+	}
+	if opts.tone != "" {
+		lines = append(lines, fmt.Sprintf("Tone:       %s", opts.tone))
+	}
+	if opts.maxLength != nil {
+		var limits []string
+		seen := make(map[string]bool)
+		for _, j := range first.jobs {
+			if j.maxLength > 0 && !seen[j.targetLang] {
+				seen[j.targetLang] = true
+				limits = append(limits, fmt.Sprintf("%s %d", j.targetLang, j.maxLength))
+			}
+		}
+		if len(limits) > 0 {
+			lines = append(lines, fmt.Sprintf("Max length: %s characters", strings.Join(limits, ", ")))
+		}
+	}
+	if opts.reportPath != "" {
+		lines = append(lines, fmt.Sprintf("Report:     %s", opts.reportPath))
+	}
+	if opts.logPath != "" {
+		lines = append(lines, fmt.Sprintf("Log file:   %s", opts.logPath))
+	}
+	if opts.jsonSummaryPath != "" {
+		lines = append(lines, fmt.Sprintf("Result:     %s", opts.jsonSummaryPath))
+	}
+	if opts.budget != nil {
+		lines = append(lines, fmt.Sprintf("Budget:     %s", opts.budget.limits()))
+	}
+	lines = append(lines, "")
+	lines = append(lines, analysisLines(tasks, opts)...)
+	if len(tasks) == 1 {
+		resumed := 0
+		for _, j := range first.jobs {
+			resumed += len(j.resumed)
+		}
+		if opts.resume {
+			lines = append(lines, fmt.Sprintf("Resuming:   %d rows from checkpoint", resumed))
+		} else if _, err := os.Stat(checkpointPath(first.fileName)); err == nil {
+			lines = append(lines, "Checkpoint: found, run with --resume to continue it")
+		}
+	}
+	return lines
+}
+
+// ///////////////////
+// RUNNING TASKS
+// ///////////////////
+
+// runResult collects the output files written by runTasks. It is read by
+// main after the TUI exits, possibly while the run is still going.
+type runResult struct {
+	mu          sync.Mutex
+	saved       []string
+	partial     []string // written when the run was cancelled
+	stats       stats    // of all jobs run
+	stopped     bool     // the run ended before all files were done
+	writeErrors int      // outputs, reports and logs that could not be written
+}
+
+// Exit codes of a translation run, for wrapper scripts. Invalid flags exit
+// with exitAborted as well, as the flag package does.
+const (
+	exitClean   = 0 // every row translated, copied or left alone as planned
+	exitErrors  = 1 // the run finished, but rows failed or a file was not written
+	exitAborted = 2 // cancelled, stopped by the budget, or unable to start
+)
+
+func (r *runResult) addStats(s stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.add(s)
+}
+
+func (r *runResult) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+}
+
+func (r *runResult) writeError() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeErrors++
+}
+
+// summary returns the stats of the run and its exit code.
+func (r *runResult) summary() (stats, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case r.stopped:
+		return r.stats, exitAborted
+	case r.stats.errors > 0 || r.writeErrors > 0:
+		return r.stats, exitErrors
+	}
+	return r.stats, exitClean
+}
+
+func (r *runResult) add(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saved = append(r.saved, path)
+}
+
+func (r *runResult) addPartial(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.partial = append(r.partial, path)
+}
+
+func (r *runResult) partialFiles() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.partial...)
+}
+
+func (r *runResult) files() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.saved...)
+}
+
+// runTasks translates and saves each file, one target column after the
+// other. With --parallel-files, several files run at once. If ctx is
+// cancelled, the files in progress are saved as far as they got to partial-
+// files, their checkpoints are kept for --resume, and the remaining files
+// are left alone.
+func runTasks(ctx context.Context, p msgSender, translator Translator, tasks []*fileTask, opts options, result *runResult) {
+	defer p.Send(doneMsg{})
+
+	// The JSON summary collects its rows like the report does, and is
+	// written last, once the exit code is known.
+	var report *runReport
+	if opts.reportPath != "" || opts.jsonSummaryPath != "" || opts.reviewSheet || opts.provenanceComments || opts.provenanceJSON || opts.reviewModel != "" || opts.selfCheck {
+		report = &runReport{model: reviewModelLabel(opts)}
+	}
+	if opts.jsonSummaryPath != "" {
+		started := time.Now()
+		defer func() {
+			if err := writeJSONSummary(opts.jsonSummaryPath, report, tasks, result, started); err != nil {
+				p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+				result.writeError()
+				return
+			}
+			p.Send(logMsg(fmt.Sprintf("Saved JSON summary to %s", opts.jsonSummaryPath)))
+		}()
+	}
+	if opts.reportPath != "" {
+		defer func() {
+			if err := report.write(opts.reportPath); err != nil {
+				p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+				result.writeError()
+				return
+			}
+			p.Send(logMsg(fmt.Sprintf("Saved report to %s", opts.reportPath)))
+		}()
+	}
+
+	var audit *auditLog
+	if opts.logPath != "" {
+		var err error
+		// The log is the audit trail of the run; without it nothing is
+		// translated.
+		if audit, err = openAuditLog(opts.logPath, modelLabel(opts.provider, opts.model)); err != nil {
+			p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+			result.stop()
+			return
+		}
+		defer func() {
+			if err := audit.Close(); err != nil {
+				p.Send(logMsg(fmt.Sprintf("ERROR: could not write log file: %v", err)))
+				result.writeError()
+			}
+		}()
+	}
+
+	// The files are added to the report in their order, whichever starts
+	// first.
+	frs := make([]*fileReport, len(tasks))
+	for i, task := range tasks {
+		if report != nil {
+			frs[i] = report.addFile(task.fileName)
+			frs[i].pending = true
+			for j := range task.jobs {
+				task.jobs[j].report = frs[i]
+			}
+		}
+		fa := audit.forFile(task.fileName)
+		for j := range task.jobs {
+			task.jobs[j].audit = fa
+		}
+	}
+
+	if parallel := min(opts.parallelFiles, len(tasks)); parallel > 1 {
+		runFilesParallel(ctx, p, translator, tasks, frs, parallel, opts, result)
+		return
+	}
+	for i := range tasks {
+		if ctx.Err() != nil {
+			return
+		}
+		runFile(ctx, p, translator, tasks, i, frs[i], opts, result, nil)
+	}
+}
+
+// runFile translates and saves tasks[i]. If ctx is cancelled, the file is
+// saved as far as it got to a partial- file. reviewMu, if set, keeps the
+// review screens of files running at the same time from overlapping.
+func runFile(ctx context.Context, p msgSender, translator Translator, tasks []*fileTask, i int, fr *fileReport, opts options, result *runResult, reviewMu *sync.Mutex) {
+	task := tasks[i]
+	if fr != nil {
+		fr.pending = false
+	}
+	defer p.Send(fileDoneMsg{fileIndex: i})
+	p.Send(fileInfoMsg{
+		fileName:  task.fileName,
+		mode:      task.jobs[0].mode,
+		totalRows: task.totalRows(),
+		fileIndex: i,
+		fileCount: len(tasks),
+	})
+	if task.edits != nil {
+		p.Send(logMsg(fmt.Sprintf("%s has %d rows: it is streamed to save memory, without auto-save, highlighting or comments", task.fileName, task.totalRows())))
+	}
+
+	for j, job := range task.jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		status := fmt.Sprintf("Translating %s (sheet %s, %s -> %s, mode %s)", task.fileName, job.sheetName, job.sourceLang, job.targetLang, job.mode)
+		if len(tasks) > 1 {
+			status = fmt.Sprintf("File %d/%d: %s", i+1, len(tasks), status)
+		}
+		p.Send(logMsg(status))
+
+		// Each target covers its share of the file's progress bar.
+		share := 1 / float64(len(task.jobs))
+		result.addStats(iterateAndTranslate(ctx, scaledProgress{p, float64(j) * share, share}, translator, job))
+		if opts.budget.reached() {
+			p.Send(logMsg(fmt.Sprintf("Budget used up (%s): stopped in %s, sheet %s, %s -> %s", opts.budget.usage(), task.fileName, job.sheetName, job.sourceLang, job.targetLang)))
+			break
+		}
+	}
+	if ctx.Err() == nil {
+		checkConsistency(p, task, opts.fixInconsistent)
+	}
+	if task.review != nil && len(task.review.items) > 0 && ctx.Err() == nil {
+		if reviewMu != nil {
+			reviewMu.Lock()
+		}
+		reviewFile(ctx, p, translator, task)
+		if reviewMu != nil {
+			reviewMu.Unlock()
+		}
+	}
+
+	if ctx.Err() != nil {
+		result.stop()
+		newFileNames, err := saveLocked(ctx, p, task, opts, "partial-")
+		task.f.Close()
+		if err != nil {
+			p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+			return
+		}
+		if err := task.autosave.remove(); err != nil {
+			p.Send(logMsg(fmt.Sprintf("ERROR: could not remove auto-save: %v", err)))
+		}
+		for _, newFileName := range newFileNames {
+			p.Send(logMsg(fmt.Sprintf("Saved partial translation to %s", newFileName)))
+			result.addPartial(newFileName)
+		}
+		if fr != nil {
+			fr.outputs, fr.partial = newFileNames, true
+		}
+		saveProvenance(p, task, newFileNames, opts, result)
+		return
+	}
+
+	prefix := "translated-"
+	if opts.inPlace {
+		backup, err := backupInput(task.fileName, time.Now())
+		if err != nil {
+			p.Send(logMsg(fmt.Sprintf("ERROR: %v; saving to %s instead", err, prefix+filepath.Base(task.fileName))))
+		} else {
+			p.Send(logMsg(fmt.Sprintf("Backed up %s to %s", task.fileName, backup)))
+			task.backup, prefix = backup, ""
+		}
+	}
+	newFileNames, err := saveLocked(ctx, p, task, opts, prefix)
+	task.f.Close()
+	if err != nil {
+		p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+		result.writeError()
+		return
+	}
+	if err := task.checkpoint.remove(); err != nil {
+		p.Send(logMsg(fmt.Sprintf("ERROR: could not remove checkpoint: %v", err)))
+	}
+	if task.hashes != nil {
+		// Rows the budget left untranslated would pass for translated.
+		if opts.budget.reached() {
+			p.Send(logMsg(fmt.Sprintf("Hashes of %s not updated: the budget stopped the run", task.fileName)))
+		} else {
+			task.hashes.update(task)
+			if err := task.hashes.save(); err != nil {
+				p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+			}
+		}
+	}
+	if err := task.autosave.remove(); err != nil {
+		p.Send(logMsg(fmt.Sprintf("ERROR: could not remove auto-save: %v", err)))
+	}
+	for _, newFileName := range newFileNames {
+		p.Send(logMsg(fmt.Sprintf("Saved translation to %s", newFileName)))
+		result.add(newFileName)
+	}
+	if fr != nil {
+		fr.outputs = newFileNames
+	}
+	saveProvenance(p, task, newFileNames, opts, result)
+}
+
+// saveProvenance writes the --provenance-json file of a saved output.
+func saveProvenance(p msgSender, task *fileTask, newFileNames []string, opts options, result *runResult) {
+	if !opts.provenanceJSON || len(newFileNames) == 0 {
+		return
+	}
+	path, err := writeProvenance(task, newFileNames[0], modelLabel(opts.provider, opts.model))
+	if err != nil {
+		p.Send(logMsg(fmt.Sprintf("ERROR: %v", err)))
+		result.writeError()
+		return
+	}
+	p.Send(logMsg(fmt.Sprintf("Saved provenance to %s", path)))
+}
+
+// scaledProgress maps the 0-1 progress of one job onto part of the bar.
+type scaledProgress struct {
+	msgSender
+	offset, scale float64
+}
+
+func (s scaledProgress) Send(msg tea.Msg) {
+	if pm, ok := msg.(progressMsg); ok {
+		msg = progressMsg(s.offset + float64(pm)*s.scale)
+	}
+	s.msgSender.Send(msg)
+}
+
+// outputBaseName is the path of the translated copy of fileName, without
+// extension. prefix is "translated-", or "partial-" for a cancelled run.
+func outputBaseName(fileName, prefix string) string {
+	return filepath.Join(filepath.Dir(fileName), prefix+strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName)))
+}
+
+// outputPlaceholder matches the placeholders of an --output template.
+var outputPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// checkOutputTemplate reports placeholders of an --output template that are
+// not {name}, {target} or {date}.
+func checkOutputTemplate(template string) error {
+	for _, p := range outputPlaceholder.FindAllString(template, -1) {
+		switch p {
+		case "{name}", "{target}", "{date}":
+		default:
+			return fmt.Errorf("unknown placeholder %s in --output; use {name}, {target} or {date}", p)
+		}
+	}
+	return nil
+}
+
+// expandOutput fills in an --output template for fileName and returns the
+// path of the output without extension: the extension follows the format
+// that is written, whatever the template says. A relative template is
+// relative to the folder of fileName.
+func expandOutput(template, fileName string, targets []string, now time.Time) string {
+	if ext := filepath.Ext(template); !strings.Contains(ext, "}") {
+		template = strings.TrimSuffix(template, ext)
+	}
+	name := outputPlaceholder.ReplaceAllStringFunc(template, func(p string) string {
+		switch p {
+		case "{name}":
+			return strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+		case "{target}":
+			return strings.Join(targets, "_")
+		case "{date}":
+			return now.Format("2006-01-02")
+		}
+		return p
+	})
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(fileName), name)
+}
+
+// outputBase is the path of the output of task without extension, from
+// --output if given. prefix is "translated-", "partial-" for a cancelled
+// run, or empty with --in-place; with --output, only "partial-" is added.
+func outputBase(opts options, task *fileTask, prefix string) string {
+	if opts.output == "" {
+		return outputBaseName(task.fileName, prefix)
+	}
+	base := expandOutput(opts.output, task.fileName, task.targetLangs(), time.Now())
+	if prefix == "partial-" {
+		base = filepath.Join(filepath.Dir(base), prefix+filepath.Base(base))
+	}
+	return base
+}
+
+// backupInfix marks the copies --in-place makes of its input.
+const backupInfix = ".backup-"
+
+// backupInput copies fileName to FILE.backup-<time>.ext before --in-place
+// overwrites it, and returns the name of the copy. An existing backup is
+// never overwritten.
+func backupInput(fileName string, now time.Time) (string, error) {
+	ext := filepath.Ext(fileName)
+	backup := strings.TrimSuffix(fileName, ext) + backupInfix + now.Format("20060102-150405") + ext
+	in, err := os.Open(fileName)
+	if err != nil {
+		return "", fmt.Errorf("could not back up %s: %w", fileName, err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("could not back up %s: %w", fileName, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(backup)
+		return "", fmt.Errorf("could not back up %s: %w", fileName, err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(backup)
+		return "", fmt.Errorf("could not back up %s: %w", fileName, err)
+	}
+	return backup, nil
+}
+
+// saveOutput writes the translated workbook to baseName plus the extension
+// of its format. CSV, XML and PO input is written back in its own format.
+// With --csv, each sheet gets its own file once there is more than one.
+func saveOutput(p msgSender, task *fileTask, opts options, baseName string) ([]string, error) {
+	sheet := task.sheets[0]
+	csvOut := csvOutputFormat(opts)
+
+	if !task.failures.empty() {
+		// A save that is tried again must not add the sheet again.
+		if task.errorsSheet == "" {
+			name, err := task.failures.writeSheet(task.f)
+			if err != nil {
+				return nil, fmt.Errorf("Error writing the errors sheet: %v", err)
+			}
+			task.errorsSheet = name
+		}
+		errorsName := task.errorsSheet
+		// Only XLSX output can carry the sheet; the other formats get it
+		// as a CSV file of its own.
+		if task.po != nil || task.simatic != nil || task.csv != nil || task.edits != nil || opts.csvOutput {
+			errorsFileName := baseName + "-errors.csv"
+			format := csvOut
+			if task.csv != nil {
+				format = *task.csv
+			}
+			if err := writeCSV(task.f, errorsName, errorsFileName, format); err != nil {
+				return nil, fmt.Errorf("Error saving the failed rows: %w", err)
+			}
+			p.Send(logMsg(fmt.Sprintf("WARNING: %d rows failed to translate, listed in %s", len(task.failures.rows), errorsFileName)))
+		} else {
+			p.Send(logMsg(fmt.Sprintf("WARNING: %d rows failed to translate, listed on sheet %s", len(task.failures.rows), errorsName)))
+		}
+	}
+
+	if opts.reviewSheet && task.jobs[0].report != nil {
+		// Like the errors sheet, it is written once and goes into a CSV
+		// file of its own for formats without sheets.
+		if task.reviewSheet == "" {
+			name, err := writeReviewSheet(task.f, task.jobs[0].report)
+			if err != nil {
+				return nil, fmt.Errorf("Error writing the review sheet: %v", err)
+			}
+			task.reviewSheet = name
+		}
+		if task.po != nil || task.simatic != nil || task.csv != nil || task.edits != nil || opts.csvOutput {
+			reviewFileName := baseName + "-review.csv"
+			format := csvOut
+			if task.csv != nil {
+				format = *task.csv
+			}
+			if err := writeCSV(task.f, task.reviewSheet, reviewFileName, format); err != nil {
+				return nil, fmt.Errorf("Error saving the review sheet: %w", err)
+			}
+			p.Send(logMsg(fmt.Sprintf("Listed the rows for review in %s", reviewFileName)))
+		}
+	}
+
+	if task.po != nil {
+		newFileName := baseName + filepath.Ext(task.fileName)
+		if err := task.po.write(task.f, sheet, newFileName); err != nil {
+			return nil, fmt.Errorf("Error saving new PO file: %w", err)
+		}
+		return []string{newFileName}, nil
+	}
+
+	if task.simatic != nil {
+		newFileName := baseName + filepath.Ext(task.fileName)
+		added, err := task.simatic.write(task.f, sheet, newFileName)
+		if err != nil {
+			return nil, fmt.Errorf("Error saving new XML file: %w", err)
+		}
+		if added > 0 {
+			p.Send(logMsg(fmt.Sprintf("Added %d items for target languages the XML had no item for", added)))
+		}
+		return []string{newFileName}, nil
+	}
+
+	if task.csv != nil {
+		newFileName := baseName + ".csv"
+		if err := writeCSV(task.f, sheet, newFileName, *task.csv); err != nil {
+			return nil, fmt.Errorf("Error saving new CSV file: %w", err)
+		}
+		return []string{newFileName}, nil
+	}
+
+	if opts.csvOutput {
+		var newFileNames []string
+		for _, sheet := range task.sheets {
+			newFileName := baseName + ".csv"
+			if len(task.sheets) > 1 {
+				newFileName = baseName + "-" + sheet + ".csv"
+			}
+			if err := writeCSV(task.f, sheet, newFileName, csvOut); err != nil {
+				return nil, fmt.Errorf("Error saving new CSV file: %w", err)
+			}
+			newFileNames = append(newFileNames, newFileName)
+		}
+		return newFileNames, nil
+	}
+	// Only cell values change; styles, number formats, panes and column
+	// widths are saved as they were read, which TIA Portal needs to accept
+	// the file again.
+	newFileName := baseName + ".xlsx"
+	if task.edits != nil {
+		// In place, the input is about to be overwritten; its backup is not.
+		src := task.fileName
+		if task.backup != "" {
+			src = task.backup
+		}
+		if err := writeStreamed(src, newFileName, task.edits); err != nil {
+			return nil, fmt.Errorf("Error saving new XLSX file: %w", err)
+		}
+		return []string{newFileName}, nil
+	}
+	// Provenance comments go first: they include the value before the run,
+	// and highlighting does not add a second comment to a cell.
+	if opts.provenanceComments {
+		commented, err := addProvenanceComments(task, reviewModelLabel(opts))
+		if err != nil {
+			return nil, fmt.Errorf("Error adding provenance comments: %v", err)
+		}
+		p.Send(logMsg(fmt.Sprintf("Added provenance comments to %d cells", commented)))
+	}
+	changed, err := task.highlight.apply(task)
+	if err != nil {
+		return nil, fmt.Errorf("Error highlighting changed cells: %v", err)
+	}
+	if changed > 0 {
+		p.Send(logMsg(fmt.Sprintf("Highlighted %d changed cells", changed)))
+	}
+	doubtful, err := markDoubtful(task)
+	if err != nil {
+		return nil, fmt.Errorf("Error marking doubtful cells: %v", err)
+	}
+	if doubtful > 0 {
+		p.Send(logMsg(fmt.Sprintf("Marked %d doubtful cells in orange", doubtful)))
+	}
+	copied, err := task.fallback.mark(task.f)
+	if err != nil {
+		return nil, fmt.Errorf("Error marking untranslated cells: %v", err)
+	}
+	if copied > 0 {
+		p.Send(logMsg(fmt.Sprintf("Marked %d cells holding their source text in red", copied)))
+	}
+	if err := task.f.SaveAs(newFileName); err != nil {
+		return nil, fmt.Errorf("Error saving new XLSX file: %w", err)
+	}
+	return []string{newFileName}, nil
+}
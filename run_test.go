@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestListInputFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.xlsx", "a.xlsx", "old.xls", "translated-a.xlsx", "partial-b.xlsx", "a.xlsx.autosave.xlsx", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	got, err := listInputFiles(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.xlsx", "b.xlsx", "old.xls"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listInputFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestExpandFileArg(t *testing.T) {
+	dir := t.TempDir()
+	exports := filepath.Join(dir, "Project", "exports")
+	if err := os.MkdirAll(exports, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Texts_HMI1.xlsx", "Texts_HMI2.xlsx", "translated-Texts_HMI1.xlsx", "Texts.txt"} {
+		if err := os.WriteFile(filepath.Join(exports, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		arg      string
+		expected []string
+	}{
+		{filepath.Join(exports, "Texts_HMI1.xlsx"), []string{filepath.Join(exports, "Texts_HMI1.xlsx")}},
+		{filepath.Join(exports, "Texts_*"), []string{filepath.Join(exports, "Texts_HMI1.xlsx"), filepath.Join(exports, "Texts_HMI2.xlsx")}},
+		{filepath.Join(dir, "Project", "*", "*.xlsx"), []string{filepath.Join(exports, "Texts_HMI1.xlsx"), filepath.Join(exports, "Texts_HMI2.xlsx")}},
+		{filepath.Join(exports, "*.txt"), nil},
+	}
+	for _, tc := range testCases {
+		got, err := expandFileArg(tc.arg)
+		if tc.expected == nil {
+			if err == nil {
+				t.Errorf("expandFileArg(%q) = %v; expected an error", tc.arg, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("expandFileArg(%q) = %v, %v; expected %v", tc.arg, got, err, tc.expected)
+		}
+	}
+
+	if got, err := listInputFiles(exports); err != nil || len(got) != 2 {
+		t.Errorf("listInputFiles(%q) = %v, %v", exports, got, err)
+	}
+}
+
+func TestExpandOutput(t *testing.T) {
+	dir := filepath.Join("exports", "HMI")
+	input := filepath.Join(dir, "Texts.v2.xlsx")
+	now := time.Date(2026, 10, 16, 15, 30, 0, 0, time.UTC)
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{"{name}_{target}_{date}.xlsx", filepath.Join(dir, "Texts.v2_en-US_fr-FR_2026-10-16")},
+		{"{name}", filepath.Join(dir, "Texts.v2")},
+		{"EN_{name}.csv", filepath.Join(dir, "EN_Texts.v2")},
+		{filepath.Join("..", "out", "{name}.xlsx"), filepath.Join("exports", "out", "Texts.v2")},
+	}
+	for _, tc := range testCases {
+		if got := expandOutput(tc.template, input, []string{"en-US", "fr-FR"}, now); got != tc.expected {
+			t.Errorf("expandOutput(%q) = %q; expected %q", tc.template, got, tc.expected)
+		}
+	}
+
+	if err := checkOutputTemplate("{name}_{target}_{date}.xlsx"); err != nil {
+		t.Error(err)
+	}
+	if err := checkOutputTemplate("{name}_{lang}.xlsx"); err == nil || !strings.Contains(err.Error(), "{lang}") {
+		t.Errorf("unknown placeholder: %v", err)
+	}
+}
+
+func TestSkipPrefixes(t *testing.T) {
+	defer func(prefixes []string) { skipPrefixes = prefixes }(skipPrefixes)
+	skipPrefixes = []string{"EN_", "FR_"}
+	testCases := []struct {
+		name     string
+		expected bool
+	}{
+		{"EN_Texts.xlsx", true},
+		{filepath.Join("exports", "FR_Texts.xlsx"), true},
+		{"partial-Texts.xlsx", true},
+		{"translated-Texts.xlsx", false},
+		{"Texts.xlsx", false},
+	}
+	for _, tc := range testCases {
+		if got := isSidecarFile(tc.name); got != tc.expected {
+			t.Errorf("isSidecarFile(%q) = %v; expected %v", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestRunTasksOutputTemplate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "TextExport.xlsx")
+	writeSheet(t, path, [][]string{{"de-DE", "en-US"}, {"Pumpe", ""}})
+	opts := options{workers: 1, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US", output: "{name}_{target}.xlsx"}
+	task, err := prepareFile(opts, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &runResult{}
+	runTasks(context.Background(), discardSender{}, &upperTranslator{}, []*fileTask{task}, opts, result)
+	if want := filepath.Join(dir, "TextExport_en-US.xlsx"); !reflect.DeepEqual(result.files(), []string{want}) {
+		t.Errorf("saved %v; expected %s", result.files(), want)
+	}
+}
+
+func TestRunTasksSavesEachFile(t *testing.T) {
+	dir := t.TempDir()
+	opts := options{workers: 2, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US"}
+
+	var tasks []*fileTask
+	for _, name := range []string{"one.xlsx", "two.xlsx"} {
+		path := filepath.Join(dir, name)
+		f := excelize.NewFile()
+		f.SetSheetRow("Sheet1", "A1", &[]string{"de-DE", "en-US"})
+		f.SetSheetRow("Sheet1", "A2", &[]string{"Pumpe " + name, ""})
+		if err := f.SaveAs(path); err != nil {
+			t.Fatal(err)
+		}
+		task, err := prepareFile(opts, path, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	result := &runResult{}
+	runTasks(context.Background(), discardSender{}, &upperTranslator{}, tasks, opts, result)
+
+	saved := result.files()
+	if len(saved) != 2 {
+		t.Fatalf("saved %v, want 2 files", saved)
+	}
+	if summary, code := result.summary(); code != exitClean || summary.translated != 2 {
+		t.Errorf("summary %v, exit code %d; expected 2 translated rows and %d", summary, code, exitClean)
+	}
+	for i, name := range []string{"one.xlsx", "two.xlsx"} {
+		want := filepath.Join(dir, "translated-"+name)
+		if saved[i] != want {
+			t.Errorf("saved[%d] = %q, want %q", i, saved[i], want)
+		}
+		f, err := excelize.OpenFile(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := f.GetCellValue("Sheet1", "B2")
+		f.Close()
+		if want := strings.ToUpper("Pumpe " + name); got != want {
+			t.Errorf("%s B2 = %q", name, got)
+		}
+	}
+}
+
+func TestRunTasksInPlace(t *testing.T) {
+	defer func(n int) { streamRows = n }(streamRows)
+	for _, streamed := range []bool{false, true} {
+		streamRows = 100000
+		if streamed {
+			streamRows = 1
+		}
+		dir := t.TempDir()
+		path := filepath.Join(dir, "TextExport.xlsx")
+		writeSheet(t, path, [][]string{{"de-DE", "en-US"}, {"Pumpe", ""}})
+		opts := options{workers: 1, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US", inPlace: true, nonInteractive: true}
+		task, err := prepareFile(opts, path, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (task.edits != nil) != streamed {
+			t.Fatalf("streamed = %v; expected %v", task.edits != nil, streamed)
+		}
+
+		result := &runResult{}
+		runTasks(context.Background(), discardSender{}, &upperTranslator{}, []*fileTask{task}, opts, result)
+		if saved := result.files(); !reflect.DeepEqual(saved, []string{path}) {
+			t.Fatalf("saved %v; expected the input %s", saved, path)
+		}
+		backups, _ := filepath.Glob(filepath.Join(dir, "TextExport.backup-*.xlsx"))
+		if len(backups) != 1 {
+			t.Fatalf("backups %v; expected one", backups)
+		}
+		for file, want := range map[string]string{path: "PUMPE", backups[0]: ""} {
+			f, err := excelize.OpenFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := f.GetCellValue("Sheet1", "B2")
+			f.Close()
+			if got != want {
+				t.Errorf("streamed %v: %s B2 = %q; expected %q", streamed, filepath.Base(file), got, want)
+			}
+		}
+		if !isSidecarFile(backups[0]) {
+			t.Errorf("%s is offered as input", backups[0])
+		}
+	}
+}
+
+func TestPrepareFileMultipleTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.xlsx")
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]string{"de-DE", "en-US", "fr-FR"})
+	f.SetSheetRow("Sheet1", "A2", &[]string{"Pumpe", "", ""})
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{workers: 1, checkpointEvery: 25, sourceCol: "de-DE", targetCol: "en-US,fr-FR"}
+	task, err := prepareFile(opts, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := task.targetLangs(); !reflect.DeepEqual(got, []string{"en-US", "fr-FR"}) {
+		t.Fatalf("targetLangs() = %v", got)
+	}
+
+	result := &runResult{}
+	runTasks(context.Background(), discardSender{}, &upperTranslator{}, []*fileTask{task}, opts, result)
+
+	out, err := excelize.OpenFile(filepath.Join(filepath.Dir(path), "translated-export.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	for _, cell := range []string{"B2", "C2"} {
+		if got, _ := out.GetCellValue("Sheet1", cell); got != "PUMPE" {
+			t.Errorf("%s = %q; expected PUMPE", cell, got)
+		}
+	}
+
+	opts.targetCol = "de-DE"
+	if _, err := prepareFile(opts, path, nil, false); err == nil {
+		t.Errorf("prepareFile should reject the source column as target")
+	}
+}
+
+func TestPrepareFileAllSheets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.xlsx")
+	f := excelize.NewFile()
+	f.SetSheetName("Sheet1", "Alarms")
+	f.SetSheetRow("Alarms", "A1", &[]string{"de-DE", "en-US"})
+	f.SetSheetRow("Alarms", "A2", &[]string{"Störung", ""})
+	f.NewSheet("Text lists")
+	f.SetSheetRow("Text lists", "A1", &[]string{"ID", "en-US", "de-DE"})
+	f.SetSheetRow("Text lists", "A2", &[]string{"1", "", "Ventil"})
+	f.NewSheet("Notes")
+	f.SetSheetRow("Notes", "A1", &[]string{"Remark"})
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{workers: 1, checkpointEvery: 25, sheet: "*", sourceCol: "de-DE", targetCol: "en-US"}
+	task, err := prepareFile(opts, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Notes has no language columns and is skipped.
+	if !reflect.DeepEqual(task.sheets, []string{"Alarms", "Text lists"}) {
+		t.Fatalf("sheets = %v", task.sheets)
+	}
+
+	runTasks(context.Background(), discardSender{}, &upperTranslator{}, []*fileTask{task}, opts, &runResult{})
+
+	out, err := excelize.OpenFile(filepath.Join(filepath.Dir(path), "translated-export.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	for _, c := range []struct{ sheet, cell, want string }{
+		{"Alarms", "B2", "STÖRUNG"},
+		{"Text lists", "B2", "VENTIL"},
+	} {
+		if got, _ := out.GetCellValue(c.sheet, c.cell); got != c.want {
+			t.Errorf("%s!%s = %q; expected %q", c.sheet, c.cell, got, c.want)
+		}
+	}
+
+	opts.sheet = "Missing"
+	if _, err := prepareFile(opts, path, nil, false); err == nil {
+		t.Errorf("prepareFile should reject an unknown sheet")
+	}
+}
+
+// cancellingTranslator cancels the run once it has translated limit texts.
+type cancellingTranslator struct {
+	upperTranslator
+	cancel context.CancelFunc
+	limit  int
+}
+
+func (c *cancellingTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	result, err := c.upperTranslator.Translate(ctx, text, sourceLang, targetLang)
+	c.mu.Lock()
+	if len(c.calls) >= c.limit {
+		c.cancel()
+	}
+	c.mu.Unlock()
+	return result, err
+}
+
+func TestRunTasksCancelSavesPartial(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plant.xlsx")
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]string{"de-DE", "en-US"})
+	for i := 2; i <= 21; i++ {
+		cell, _ := excelize.CoordinatesToCellName(1, i)
+		f.SetCellValue("Sheet1", cell, fmt.Sprintf("Pumpe %c läuft", 'A'+i))
+	}
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	second := filepath.Join(dir, "second.xlsx")
+	if err := f.SaveAs(second); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{workers: 1, checkpointEvery: 1, mode: "full", sourceCol: "de-DE", targetCol: "en-US"}
+	var tasks []*fileTask
+	for _, p := range []string{path, second} {
+		task, err := prepareFile(opts, p, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	translator := &cancellingTranslator{cancel: cancel, limit: 3}
+	result := &runResult{}
+	runTasks(ctx, discardSender{}, translator, tasks, opts, result)
+
+	if len(translator.calls) != 3 {
+		t.Errorf("%d texts sent, expected the run to stop after 3", len(translator.calls))
+	}
+	if _, code := result.summary(); code != exitAborted {
+		t.Errorf("exit code %d; expected %d for a cancelled run", code, exitAborted)
+	}
+	if saved := result.files(); len(saved) != 0 {
+		t.Errorf("saved %v, expected no finished files", saved)
+	}
+	want := filepath.Join(dir, "partial-plant.xlsx")
+	if partial := result.partialFiles(); !reflect.DeepEqual(partial, []string{want}) {
+		t.Fatalf("partial files %v, expected %v", partial, []string{want})
+	}
+	out, err := excelize.OpenFile(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if got, _ := out.GetCellValue("Sheet1", "B2"); got != "PUMPE C LÄUFT" {
+		t.Errorf("B2 = %q, expected the first row to be translated", got)
+	}
+	if got, _ := out.GetCellValue("Sheet1", "B21"); got != "" {
+		t.Errorf("B21 = %q, expected the last row to be left empty", got)
+	}
+	if _, err := os.Stat(checkpointPath(path)); err != nil {
+		t.Errorf("checkpoint should be kept for --resume: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partial-second.xlsx")); err == nil {
+		t.Errorf("second file should not have been started")
+	}
+}
+
+func TestRunTasksExitCode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plant.xlsx")
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]string{"de-DE", "en-US"})
+	f.SetSheetRow("Sheet1", "A2", &[]string{"Pumpe läuft", ""})
+	f.SetSheetRow("Sheet1", "A3", &[]string{"Broken valve", ""})
+	f.SetSheetRow("Sheet1", "A4", &[]string{"##Level##", ""})
+	f.SetSheetRow("Sheet1", "A5", &[]string{"Motor", ""})
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	opts := options{workers: 1, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US"}
+	opts.filter, _ = newRowFilter("2-4", "", "")
+	task, err := prepareFile(opts, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &runResult{}
+	runTasks(context.Background(), discardSender{}, &flakyTranslator{seen: make(map[string]int)}, []*fileTask{task}, opts, result)
+
+	summary, code := result.summary()
+	expected := stats{translated: 1, copied: 1, skipped: 1, errors: 1}
+	if summary != expected || code != exitErrors {
+		t.Errorf("summary %v, exit code %d; expected %v and %d", summary, code, expected, exitErrors)
+	}
+}
+
+// TIA Portal rejects re-imports whose layout was mangled, so the output must
+// keep the input's styles, number formats, panes and column widths.
+func TestSavePreservesLayout(t *testing.T) {
+	for _, highlight := range []bool{false, true} {
+		t.Run(fmt.Sprintf("highlight=%v", highlight), func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "export.xlsx")
+			f := excelize.NewFile()
+			f.SetSheetRow("Sheet1", "A1", &[]string{"de-DE", "en-US", "ID"})
+			f.SetSheetRow("Sheet1", "A2", &[]any{"Motor fault", "", 7})
+			f.SetSheetRow("Sheet1", "A3", &[]any{"Pumpe an", "Pump on", 8})
+			f.SetColWidth("Sheet1", "A", "A", 40)
+			f.SetColWidth("Sheet1", "B", "B", 55)
+			f.SetRowHeight("Sheet1", 1, 30)
+			if err := f.SetPanes("Sheet1", &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+				t.Fatal(err)
+			}
+			header, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+			f.SetCellStyle("Sheet1", "A1", "C1", header)
+			text, _ := f.NewStyle(&excelize.Style{
+				NumFmt:    49,
+				Border:    []excelize.Border{{Type: "bottom", Color: "000000", Style: 1}},
+				Alignment: &excelize.Alignment{WrapText: true},
+			})
+			f.SetCellStyle("Sheet1", "B2", "B3", text)
+			format := "0000"
+			id, _ := f.NewStyle(&excelize.Style{CustomNumFmt: &format})
+			f.SetCellStyle("Sheet1", "C2", "C3", id)
+			if err := f.SaveAs(path); err != nil {
+				t.Fatal(err)
+			}
+
+			opts := options{workers: 2, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US", highlight: highlight}
+			task, err := prepareFile(opts, path, nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := &runResult{}
+			runTasks(context.Background(), discardSender{}, &upperTranslator{}, []*fileTask{task}, opts, result)
+
+			out, err := excelize.OpenFile(filepath.Join(dir, "translated-export.xlsx"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Close()
+
+			if got, _ := out.GetCellValue("Sheet1", "B3"); got != "PUMPE AN" {
+				t.Errorf("B3 = %q, want it translated", got)
+			}
+			for col, want := range map[string]float64{"A": 40, "B": 55} {
+				if got, _ := out.GetColWidth("Sheet1", col); got != want {
+					t.Errorf("column %s width = %v, want %v", col, got, want)
+				}
+			}
+			if got, _ := out.GetRowHeight("Sheet1", 1); got != 30 {
+				t.Errorf("row 1 height = %v, want 30", got)
+			}
+			panes, err := out.GetPanes("Sheet1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !panes.Freeze || panes.YSplit != 1 || panes.TopLeftCell != "A2" {
+				t.Errorf("panes = %+v, want row 1 frozen", panes)
+			}
+
+			style := func(cell string) *excelize.Style {
+				t.Helper()
+				idx, err := out.GetCellStyle("Sheet1", cell)
+				if err != nil {
+					t.Fatal(err)
+				}
+				s, err := out.GetStyle(idx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			}
+			if s := style("A1"); s.Font == nil || !s.Font.Bold {
+				t.Errorf("A1 lost its bold font")
+			}
+			for _, cell := range []string{"B2", "B3"} {
+				s := style(cell)
+				if s.NumFmt != 49 {
+					t.Errorf("%s number format = %d, want 49 (text)", cell, s.NumFmt)
+				}
+				if len(s.Border) != 1 || s.Border[0].Type != "bottom" {
+					t.Errorf("%s border = %+v, want the bottom border", cell, s.Border)
+				}
+				if s.Alignment == nil || !s.Alignment.WrapText {
+					t.Errorf("%s lost its text wrapping", cell)
+				}
+				if filled := len(s.Fill.Color) > 0; filled != highlight {
+					t.Errorf("%s filled = %v, want %v", cell, filled, highlight)
+				}
+			}
+			if s := style("C2"); s.CustomNumFmt == nil || *s.CustomNumFmt != "0000" {
+				t.Errorf("C2 lost its number format: %+v", s)
+			}
+			if got, _ := out.GetCellValue("Sheet1", "C2"); got != "0007" {
+				t.Errorf("C2 = %q, want 0007", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// ///////////////////
+// LOCKED OUTPUT FILES
+// ///////////////////
+
+// An output file that is open in Excel, or held by a sync client such as
+// OneDrive, cannot be overwritten. The translated workbook is still in
+// memory then, so instead of failing the save, the TUI asks what to do:
+// try again, save under another name, or wait until the file is free.
+// Without the TUI nobody can answer, and the output is saved under another
+// name right away.
+
+// maxAlternates is how many other names are tried.
+const maxAlternates = 9
+
+// lockWait is how often a locked file is tried again while waiting.
+var lockWait = 2 * time.Second
+
+// lockChoice is what to do about a locked output file.
+type lockChoice int
+
+const (
+	lockRetry     lockChoice = iota // try once more, e.g. after closing Excel
+	lockAlternate                   // save under another name
+	lockWaitFree                    // try again every few seconds until it works
+)
+
+// lockedFileMsg asks the TUI what to do about a locked output file. The
+// answer goes to reply, which has room for it.
+type lockedFileMsg struct {
+	fileName  string // input file whose output could not be saved
+	err       error
+	alternate string // suffix the output gets with lockAlternate
+	reply     chan<- lockChoice
+}
+
+// isLockedError reports whether err means a file is in use by another
+// program. On Windows that is a sharing or lock violation; elsewhere, and
+// for read-only files, a permission error. The Windows error numbers are
+// others elsewhere, e.g. EPIPE and EDOM on Unix.
+func isLockedError(err error) bool {
+	var errno syscall.Errno
+	if runtime.GOOS == "windows" && errors.As(err, &errno) && (errno == 32 || errno == 33) { // ERROR_SHARING_VIOLATION, ERROR_LOCK_VIOLATION
+		return true
+	}
+	return errors.Is(err, fs.ErrPermission)
+}
+
+// saveLocked saves the output of a task like saveOutput, and handles a
+// locked output file as described above. A run that is stopped while
+// waiting saves under another name, so that nothing is lost.
+func saveLocked(ctx context.Context, p msgSender, task *fileTask, opts options, prefix string) ([]string, error) {
+	ask := !opts.nonInteractive && !opts.noTUI
+	base := outputBase(opts, task, prefix)
+	suffix := ""
+	alternates := 1
+	waiting := false
+	for {
+		newFileNames, err := saveOutput(p, task, opts, base+suffix)
+		if err == nil || !isLockedError(err) {
+			return newFileNames, err
+		}
+		next := fmt.Sprintf(" (%d)", alternates+1)
+
+		choice := lockAlternate
+		switch {
+		case waiting && ctx.Err() == nil:
+			choice = lockWaitFree
+		case ask && ctx.Err() == nil:
+			reply := make(chan lockChoice, 1)
+			p.Send(lockedFileMsg{fileName: task.fileName, err: err, alternate: next, reply: reply})
+			select {
+			case choice = <-reply:
+			case <-ctx.Done():
+			}
+		}
+
+		switch choice {
+		case lockRetry:
+			p.Send(logMsg(fmt.Sprintf("Saving %s again", task.fileName)))
+		case lockWaitFree:
+			if !waiting {
+				p.Send(logMsg(fmt.Sprintf("Waiting for the output of %s to be closed: %v", task.fileName, err)))
+				waiting = true
+			}
+			select {
+			case <-time.After(lockWait):
+			case <-ctx.Done():
+			}
+		default:
+			// Probably not a lock after all, e.g. a read-only folder.
+			if alternates == maxAlternates {
+				return nil, err
+			}
+			p.Send(logMsg(fmt.Sprintf("WARNING: %v; saving under another name", err)))
+			suffix = next
+			alternates++
+			waiting = false
+		}
+	}
+}
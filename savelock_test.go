@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestIsLockedError(t *testing.T) {
+	// Sharing and lock violations on Windows, a broken pipe and EDOM elsewhere.
+	windows := runtime.GOOS == "windows"
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&fs.PathError{Op: "open", Path: "a.xlsx", Err: syscall.Errno(32)}, windows},
+		{fmt.Errorf("Error saving new file: %w", &fs.PathError{Op: "open", Path: "a.xlsx", Err: syscall.Errno(33)}), windows},
+		{fmt.Errorf("Error saving new file: %w", &fs.PathError{Op: "open", Path: "a.xlsx", Err: fs.ErrPermission}), true},
+		{&fs.PathError{Op: "open", Path: "a.xlsx", Err: fs.ErrNotExist}, false},
+		{errors.New("disk full"), false},
+	}
+	for _, tt := range tests {
+		if got := isLockedError(tt.err); got != tt.want {
+			t.Errorf("isLockedError(%v) = %v; expected %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// lockAnswerer answers every question about a locked file with choice,
+// after unlocking the file.
+type lockAnswerer struct {
+	choice lockChoice
+	unlock func()
+	asked  int
+}
+
+func (l *lockAnswerer) Send(msg tea.Msg) {
+	if m, ok := msg.(lockedFileMsg); ok {
+		l.asked++
+		l.unlock()
+		m.reply <- l.choice
+	}
+}
+
+func TestSaveLocked(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("read-only files can be written as root")
+	}
+	tests := []struct {
+		name   string
+		opts   options
+		choice lockChoice
+		want   string
+		asked  int
+	}{
+		{"without the TUI", options{nonInteractive: true}, lockRetry, "translated-TextExport (2).xlsx", 0},
+		{"retry", options{}, lockRetry, "translated-TextExport.xlsx", 1},
+		{"alternate", options{}, lockAlternate, "translated-TextExport (2).xlsx", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "TextExport.xlsx")
+			writeSheet(t, input, [][]string{{"de-DE", "en-US"}, {"Pumpe", "pump"}})
+			task, err := prepareFile(options{sourceCol: "de-DE", targetCol: "en-US", mode: "full"}, input, nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer task.f.Close()
+
+			// A read-only output file stands in for one open in Excel.
+			locked := filepath.Join(dir, "translated-TextExport.xlsx")
+			if err := os.WriteFile(locked, nil, 0o444); err != nil {
+				t.Fatal(err)
+			}
+			p := &lockAnswerer{choice: tt.choice, unlock: func() { os.Chmod(locked, 0o644) }}
+			got, err := saveLocked(context.Background(), p, task, tt.opts, "translated-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || filepath.Base(got[0]) != tt.want {
+				t.Errorf("saved %q; expected %s", got, tt.want)
+			}
+			if p.asked != tt.asked {
+				t.Errorf("asked %d times; expected %d", p.asked, tt.asked)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// QUALITY SCORES
+// ///////////////////
+
+// "translator score" measures machine translations against human ones: a
+// reference column in the same workbook, or the same column of the original
+// export. It computes chrF, which compares character n-grams and suits the
+// short texts of an HMI, and BLEU, which compares words, the way machine
+// translation is usually evaluated. Appended to a history file, the scores
+// show whether a change of prompt or model actually helped.
+
+// chrfOrder and bleuOrder are the longest n-grams counted, and chrfBeta
+// weighs recall over precision, as in the usual chrF settings.
+const (
+	chrfOrder = 6
+	bleuOrder = 4
+	chrfBeta  = 2
+)
+
+// ngramStats counts, per n-gram order, the n-grams of the hypothesis and
+// the reference and those they share.
+type ngramStats struct {
+	hyp, ref, match []int
+}
+
+func newNgramStats(order int) ngramStats {
+	return ngramStats{hyp: make([]int, order), ref: make([]int, order), match: make([]int, order)}
+}
+
+func (s ngramStats) add(other ngramStats) {
+	for n := range s.hyp {
+		s.hyp[n] += other.hyp[n]
+		s.ref[n] += other.ref[n]
+		s.match[n] += other.match[n]
+	}
+}
+
+// countNgrams compares the n-grams of hyp and ref up to order, matches
+// clipped to how often an n-gram occurs in the reference.
+func countNgrams(hyp, ref []string, order int) ngramStats {
+	s := newNgramStats(order)
+	for n := 1; n <= order; n++ {
+		refCounts := make(map[string]int)
+		for i := 0; i+n <= len(ref); i++ {
+			refCounts[strings.Join(ref[i:i+n], "\x00")]++
+			s.ref[n-1]++
+		}
+		for i := 0; i+n <= len(hyp); i++ {
+			gram := strings.Join(hyp[i:i+n], "\x00")
+			if refCounts[gram] > 0 {
+				refCounts[gram]--
+				s.match[n-1]++
+			}
+			s.hyp[n-1]++
+		}
+	}
+	return s
+}
+
+// chrfUnits are the characters of text without whitespace.
+func chrfUnits(text string) []string {
+	var units []string
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			units = append(units, string(r))
+		}
+	}
+	return units
+}
+
+// bleuToken matches the words, numbers and punctuation marks BLEU counts.
+var bleuToken = regexp.MustCompile(`\p{L}+|\p{N}+|[^\s\p{L}\p{N}]`)
+
+// chrf is the F-score of the averaged character n-gram precision and
+// recall, 0-100. Orders that neither text is long enough for are left out.
+func (s ngramStats) chrf() float64 {
+	var precision, recall float64
+	orders := 0
+	for n := range s.hyp {
+		if s.hyp[n] == 0 && s.ref[n] == 0 {
+			continue
+		}
+		orders++
+		if s.hyp[n] > 0 {
+			precision += float64(s.match[n]) / float64(s.hyp[n])
+		}
+		if s.ref[n] > 0 {
+			recall += float64(s.match[n]) / float64(s.ref[n])
+		}
+	}
+	if orders == 0 {
+		return 100 // both empty
+	}
+	precision /= float64(orders)
+	recall /= float64(orders)
+	if precision == 0 && recall == 0 {
+		return 0
+	}
+	beta2 := float64(chrfBeta * chrfBeta)
+	return 100 * (1 + beta2) * precision * recall / (beta2*precision + recall)
+}
+
+// bleu is the geometric mean of the word n-gram precisions times the
+// brevity penalty, 0-100. It is meant for a whole corpus: one missing
+// 4-gram match makes it 0.
+func (s ngramStats) bleu() float64 {
+	var logSum float64
+	for n := range s.hyp {
+		if s.match[n] == 0 {
+			return 0
+		}
+		logSum += math.Log(float64(s.match[n]) / float64(s.hyp[n]))
+	}
+	hypLen, refLen := float64(s.hyp[0]), float64(s.ref[0])
+	penalty := 1.0
+	if hypLen < refLen {
+		penalty = math.Exp(1 - refLen/hypLen)
+	}
+	return 100 * penalty * math.Exp(logSum/float64(len(s.hyp)))
+}
+
+// scoredRow is one translation and its reference.
+type scoredRow struct {
+	row         int // 1-based sheet row
+	source      string
+	translation string
+	reference   string
+	chrf        float64
+}
+
+// qualityScore is the result of scoring a sheet.
+type qualityScore struct {
+	rows []scoredRow
+	chrf float64 // of the whole sheet
+	bleu float64
+}
+
+// scoreRows scores the translations in column tgt of rows against the
+// references in column ref of refRows, row by row. Rows without a
+// reference are left out; a reference without a translation scores 0.
+func scoreRows(rows, refRows [][]string, src, tgt, ref int) qualityScore {
+	cell := func(rows [][]string, i, col int) string {
+		if i < len(rows) && col >= 0 && col < len(rows[i]) {
+			return strings.TrimSpace(rows[i][col])
+		}
+		return ""
+	}
+	var q qualityScore
+	chars, words := newNgramStats(chrfOrder), newNgramStats(bleuOrder)
+	for i := 1; i < max(len(rows), len(refRows)); i++ {
+		reference := cell(refRows, i, ref)
+		if reference == "" {
+			continue
+		}
+		translation := cell(rows, i, tgt)
+		c := countNgrams(chrfUnits(translation), chrfUnits(reference), chrfOrder)
+		chars.add(c)
+		words.add(countNgrams(bleuToken.FindAllString(translation, -1), bleuToken.FindAllString(reference, -1), bleuOrder))
+		q.rows = append(q.rows, scoredRow{row: i + 1, source: cell(rows, i, src), translation: translation, reference: reference, chrf: c.chrf()})
+	}
+	if len(q.rows) > 0 {
+		q.chrf = chars.chrf()
+		q.bleu = words.bleu()
+	}
+	return q
+}
+
+// worst returns the rows with the lowest chrF first.
+func (q qualityScore) worst() []scoredRow {
+	rows := slices.Clone(q.rows)
+	slices.SortStableFunc(rows, func(a, b scoredRow) int {
+		switch {
+		case a.chrf < b.chrf:
+			return -1
+		case a.chrf > b.chrf:
+			return 1
+		}
+		return 0
+	})
+	return rows
+}
+
+// writeScoreReport writes the scores as Markdown, worst rows first.
+func writeScoreReport(w io.Writer, fileName, referenceName, label string, q qualityScore) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Translation Quality\n\n")
+	if label != "" {
+		fmt.Fprintf(&b, "- Run: %s\n", label)
+	}
+	fmt.Fprintf(&b, "- Translations: %s\n- Reference: %s\n- Rows: %d\n- chrF: %.1f\n- BLEU: %.1f\n\n", fileName, referenceName, len(q.rows), q.chrf, q.bleu)
+	fmt.Fprintf(&b, "| Row | chrF | Source | Translation | Reference |\n| ---: | ---: | --- | --- | --- |\n")
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	for _, r := range q.worst() {
+		fmt.Fprintf(&b, "| %d | %.1f | %s | %s | %s |\n", r.row, r.chrf, escape.Replace(r.source), escape.Replace(r.translation), escape.Replace(r.reference))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// scoreHistoryHeader heads a --history file.
+var scoreHistoryHeader = []string{"time", "label", "workbook", "reference", "rows", "chrf", "bleu"}
+
+// appendScoreHistory adds a line to the CSV file at path, creating it with
+// a header if needed.
+func appendScoreHistory(path, fileName, referenceName, label string, q qualityScore, now time.Time) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not write history: %w", err)
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		w.Write(scoreHistoryHeader)
+	}
+	w.Write([]string{now.Format(time.RFC3339), label, fileName, referenceName, strconv.Itoa(len(q.rows)), fmt.Sprintf("%.1f", q.chrf), fmt.Sprintf("%.1f", q.bleu)})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("could not write history: %w", err)
+	}
+	return nil
+}
+
+// runScoreCommand implements "score".
+func runScoreCommand(args []string) error {
+	usage := "usage: score --target-col COL [--reference-col COL | --reference EXPORT.xlsx] [--report FILE.md] [--history FILE.csv] WORKBOOK"
+	fs := flag.NewFlagSet("score", flag.ExitOnError)
+	sheet := fs.String("sheet", "", "Sheet to score (default: first sheet).")
+	sourceCol := fs.String("source-col", "", "Source language column, shown in the report (optional).")
+	targetCol := fs.String("target-col", "", "Column with the machine translations, as a 1-based column number or header name.")
+	referenceCol := fs.String("reference-col", "", "Column of the same workbook with human translations to score against.")
+	referencePath := fs.String("reference", "", "Workbook whose --target-col holds the human translations (default: WORKBOOK without its translated- prefix, if present).")
+	reportPath := fs.String("report", "", "Write a Markdown report with every row, lowest score first.")
+	historyPath := fs.String("history", "", "Append the scores to this CSV file, to follow them across runs.")
+	label := fs.String("label", "", "Name of the run in the report and history, e.g. the model and prompt used.")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *targetCol == "" {
+		return errors.New(usage)
+	}
+	fileName := fs.Arg(0)
+
+	f, _, _, _, err := openInput(options{}, fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sheetName := f.GetSheetName(0)
+	if *sheet != "" {
+		sheetName = *sheet
+	}
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return fmt.Errorf("Error getting rows: %v", err)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("Sheet %q is empty", sheetName)
+	}
+	tgt, err := tiatrans.ResolveColumn(rows[0], *targetCol)
+	if err != nil {
+		return fmt.Errorf("--target-col: %v", err)
+	}
+	src := -1
+	if *sourceCol != "" {
+		if src, err = tiatrans.ResolveColumn(rows[0], *sourceCol); err != nil {
+			return fmt.Errorf("--source-col: %v", err)
+		}
+	}
+
+	refRows, ref, referenceName := rows, -1, ""
+	switch {
+	case *referenceCol != "" && *referencePath != "":
+		return errors.New("--reference-col and --reference cannot be used together")
+	case *referenceCol != "":
+		if ref, err = tiatrans.ResolveColumn(rows[0], *referenceCol); err != nil {
+			return fmt.Errorf("--reference-col: %v", err)
+		}
+		referenceName = fmt.Sprintf("column %s", rows[0][ref])
+	default:
+		if *referencePath == "" {
+			*referencePath = defaultOriginal(fileName)
+		}
+		if *referencePath == "" {
+			return errors.New("no reference: give --reference-col or --reference")
+		}
+		original, _, _, _, err := openInput(options{}, *referencePath)
+		if err != nil {
+			return err
+		}
+		defer original.Close()
+		if refRows, err = original.GetRows(sheetName); err != nil || len(refRows) == 0 {
+			return fmt.Errorf("Sheet %q not found in %s", sheetName, *referencePath)
+		}
+		// The column is found by its header, in case the layouts differ.
+		if ref, err = tiatrans.ResolveColumn(refRows[0], rows[0][tgt]); err != nil {
+			return fmt.Errorf("%s: %v", *referencePath, err)
+		}
+		referenceName = fmt.Sprintf("%s, column %s", *referencePath, refRows[0][ref])
+	}
+
+	q := scoreRows(rows, refRows, src, tgt, ref)
+	if len(q.rows) == 0 {
+		return fmt.Errorf("No references found in %s", referenceName)
+	}
+	fmt.Printf("Scored %d translations in column %s against %s\n", len(q.rows), rows[0][tgt], referenceName)
+	fmt.Printf("chrF: %.1f\nBLEU: %.1f\n", q.chrf, q.bleu)
+	for _, r := range q.worst()[:min(5, len(q.rows))] {
+		fmt.Printf("Row %d (chrF %.1f): %q, reference %q\n", r.row, r.chrf, r.translation, r.reference)
+	}
+
+	if *reportPath != "" {
+		file, err := os.Create(*reportPath)
+		if err != nil {
+			return fmt.Errorf("could not write report: %w", err)
+		}
+		err = writeScoreReport(file, fileName, referenceName, *label, q)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("could not write report: %w", err)
+		}
+		fmt.Printf("Saved report to %s\n", *reportPath)
+	}
+	if *historyPath != "" {
+		if err := appendScoreHistory(*historyPath, fileName, referenceName, *label, q, time.Now()); err != nil {
+			return err
+		}
+		fmt.Printf("Added the scores to %s\n", *historyPath)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScoreMetrics(t *testing.T) {
+	tests := []struct {
+		name       string
+		hyp, ref   string
+		chrf, bleu float64
+	}{
+		{"identical", "Motor is running at full speed", "Motor is running at full speed", 100, 100},
+		{"disjoint", "abc", "xyz", 0, 0},
+		{"empty translation", "", "Motor on", 0, 0},
+		{"too short for 4-grams", "Motor  on", "Motor on", 100, 0},
+		{"partial", "Motor is running", "Motor is running at full speed", 56.4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chars := countNgrams(chrfUnits(tt.hyp), chrfUnits(tt.ref), chrfOrder)
+			if got := chars.chrf(); math.Abs(got-tt.chrf) > 0.05 {
+				t.Errorf("chrF = %.2f, want %.1f", got, tt.chrf)
+			}
+			words := countNgrams(bleuToken.FindAllString(tt.hyp, -1), bleuToken.FindAllString(tt.ref, -1), bleuOrder)
+			if got := words.bleu(); math.Abs(got-tt.bleu) > 0.05 {
+				t.Errorf("BLEU = %.2f, want %.1f", got, tt.bleu)
+			}
+		})
+	}
+}
+
+func TestScoreRows(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US", "en-US reference"},
+		{"Motor läuft", "Motor running", "Motor running"},
+		{"Pumpe aus", "Pump of", "Pump off"},
+		{"Ventil", "", "Valve"},
+		{"Ohne Referenz", "No reference", ""},
+	}
+	q := scoreRows(rows, rows, 0, 1, 2)
+	if len(q.rows) != 3 {
+		t.Fatalf("scored %d rows, want 3", len(q.rows))
+	}
+	worst := q.worst()
+	if worst[0].row != 4 || worst[0].chrf != 0 || worst[2].row != 2 || worst[2].chrf != 100 {
+		t.Errorf("worst = %+v", worst)
+	}
+	if q.chrf <= 0 || q.chrf >= 100 {
+		t.Errorf("sheet chrF = %.1f, want between 0 and 100", q.chrf)
+	}
+
+	var report strings.Builder
+	if err := writeScoreReport(&report, "translated.xlsx", "column en-US reference", "gpt test", q); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"- Run: gpt test", "- Rows: 3", "| 4 | 0.0 | Ventil |  | Valve |"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, report.String())
+		}
+	}
+
+	history := filepath.Join(t.TempDir(), "scores.csv")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for range 2 {
+		if err := appendScoreHistory(history, "translated.xlsx", "reference", "run", q, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(scoreHistoryHeader, ",") || !strings.HasPrefix(lines[1], "2024-05-01T12:00:00Z,run,translated.xlsx,reference,3,") {
+		t.Errorf("history =\n%s", data)
+	}
+}
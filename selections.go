@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ///////////////////
+// LAST SELECTIONS
+// ///////////////////
+
+// The sheets, columns and mode picked for a workbook are remembered in the
+// user's config folder and pre-selected in the forms when the same workbook
+// is translated again. Columns are remembered by header, so they are found
+// again if the next export has more or fewer columns.
+
+// maxSelections is how many workbooks are remembered; the ones translated
+// longest ago are forgotten first.
+const maxSelections = 100
+
+// selection is what was picked for one workbook.
+type selection struct {
+	Sheets  []string `json:"sheets"`
+	Source  string   `json:"source"`  // header of the source column
+	Targets []string `json:"targets"` // headers of the target columns
+	Mode    string   `json:"mode"`
+	Used    string   `json:"used"`
+}
+
+// selectionsPath is the state file in the user's config folder.
+func selectionsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, keychainService, "selections.json"), nil
+}
+
+// selectionKey identifies a workbook by its absolute path.
+func selectionKey(fileName string) string {
+	if abs, err := filepath.Abs(fileName); err == nil {
+		return abs
+	}
+	return fileName
+}
+
+// loadSelections reads the state file. A missing or unreadable one is
+// empty: the forms then start from their usual defaults.
+func loadSelections() map[string]selection {
+	selections := make(map[string]selection)
+	path, err := selectionsPath()
+	if err != nil {
+		return selections
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &selections)
+	}
+	return selections
+}
+
+// lastSelection returns what was picked for a workbook the last time, or
+// nil.
+func lastSelection(fileName string) *selection {
+	if s, ok := loadSelections()[selectionKey(fileName)]; ok {
+		return &s
+	}
+	return nil
+}
+
+// rememberSelection stores the sheets, columns and mode a task was set up
+// with.
+func rememberSelection(task *fileTask) error {
+	path, err := selectionsPath()
+	if err != nil {
+		return err
+	}
+	selections := loadSelections()
+	current := selectionKey(task.fileName)
+	selections[current] = selection{
+		Sheets:  task.sheets,
+		Source:  task.jobs[0].sourceLang,
+		Targets: task.targetLangs(),
+		Mode:    task.jobs[0].mode,
+		Used:    time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(selections) > maxSelections {
+		keys := make([]string, 0, len(selections))
+		for key := range selections {
+			keys = append(keys, key)
+		}
+		// Most recent first; the workbook just set up always stays.
+		slices.SortFunc(keys, func(a, b string) int {
+			switch current {
+			case a:
+				return -1
+			case b:
+				return 1
+			}
+			return strings.Compare(selections[b].Used, selections[a].Used)
+		})
+		for _, key := range keys[maxSelections:] {
+			delete(selections, key)
+		}
+	}
+
+	data, err := json.MarshalIndent(selections, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not write selections: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("could not write selections: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("could not write selections: %w", err)
+	}
+	return nil
+}
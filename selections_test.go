@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRememberSelection(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+
+	path := filepath.Join(dir, "TextExport.xlsx")
+	writeSheet(t, path, [][]string{
+		{"ID", "de-DE", "en-US", "fr-FR"},
+		{"1", "Pumpe läuft", "", ""},
+	})
+	if got := lastSelection(path); got != nil {
+		t.Fatalf("selection %+v before the first run", got)
+	}
+	task, err := prepareFile(options{sourceCol: "de-DE", targetCol: "fr-FR,en-US", mode: "quick"}, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.f.Close()
+	if err := rememberSelection(task); err != nil {
+		t.Fatal(err)
+	}
+
+	got := lastSelection(path)
+	if got == nil {
+		t.Fatal("selection not remembered")
+	}
+	if !slices.Equal(got.Sheets, []string{"Sheet1"}) || got.Source != "de-DE" || !slices.Equal(got.Targets, []string{"fr-FR", "en-US"}) || got.Mode != "quick" {
+		t.Errorf("remembered %+v", got)
+	}
+
+	// Only the most recently used workbooks are kept.
+	for i := range maxSelections {
+		task.fileName = filepath.Join(dir, fmt.Sprintf("export-%d.xlsx", i))
+		if err := rememberSelection(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(loadSelections()); n != maxSelections {
+		t.Errorf("%d workbooks remembered; expected %d", n, maxSelections)
+	}
+	if lastSelection(task.fileName) == nil {
+		t.Error("the last workbook was forgotten")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// SELF-CHECK
+// ///////////////////
+
+// With --self-check, the model rates each of its fresh translations for
+// terminology, completeness and placeholders. Rows that score low on any of
+// them are flagged as doubtful, like rows --review-model is unsure about,
+// and doubtful cells get an orange fill in XLSX output so reviewers know
+// where to start.
+
+// selfCheckMinScore is the lowest rating, out of 5, that is not doubtful.
+const selfCheckMinScore = 4
+
+// doubtfulColor is the fill of doubtful cells: a light orange that stands
+// out from the yellow of --highlight.
+const doubtfulColor = "F8CBAD"
+
+// verifier is optionally implemented by a Translator that can rate a
+// translation.
+type verifier interface {
+	Rate(ctx context.Context, source, translation, sourceLang, targetLang string) (rating, error)
+}
+
+// rating is how a model judges a translation, each from 1 (wrong) to 5
+// (right).
+type rating struct {
+	terminology  int
+	completeness int
+	placeholders int
+	issues       string
+}
+
+// low reports whether any part of r is below selfCheckMinScore.
+func (r rating) low() bool {
+	return min(r.terminology, r.completeness, r.placeholders) < selfCheckMinScore
+}
+
+func (r rating) String() string {
+	s := fmt.Sprintf("terminology %d/5, completeness %d/5, placeholders %d/5", r.terminology, r.completeness, r.placeholders)
+	if r.issues != "" {
+		s += ": " + r.issues
+	}
+	return s
+}
+
+// newVerifier returns the translator of the run as a verifier, for
+// --self-check.
+func newVerifier(translator Translator, provider string) (verifier, error) {
+	v, ok := translator.(verifier)
+	if !ok {
+		return nil, fmt.Errorf("--self-check: %s cannot rate translations, use a chat model provider such as openai or ollama", provider)
+	}
+	return v, nil
+}
+
+// selfCheck has the model rate translation, the translation of text, and
+// flags rj as doubtful if the rating is low. A failed check is flagged too,
+// but does not make the row doubtful.
+func (j *translationJob) selfCheck(ctx context.Context, p msgSender, rj *rowJob, text, translation string) {
+	if j.verifier == nil {
+		return
+	}
+	policy := retryPolicy{retries: j.retries, baseDelay: defaultRetryBaseDelay, maxDelay: defaultRetryMaxDelay, timeout: j.reqTimeout}
+	var r rating
+	err := policy.run(j.withHints(ctx, text), func(ctx context.Context) error {
+		var err error
+		r, err = j.verifier.Rate(ctx, text, translation, j.sourceLang, j.targetLang)
+		return err
+	})
+	if err != nil {
+		p.Send(logMsg(fmt.Sprintf("ERROR: self-check of %q: %v", text, err)))
+		rj.flags = append(rj.flags, fmt.Sprintf("self-check failed: %v", err))
+		return
+	}
+	if r.low() {
+		rj.flags = append(rj.flags, "self-check: "+r.String())
+		rj.doubtful = true
+	}
+}
+
+// markDoubtful gives the target cells of doubtful rows the orange fill, if
+// they still hold the translation that was rated. It runs after the
+// highlighting, whose fill it replaces, and returns how many cells it
+// marked.
+func markDoubtful(task *fileTask) (int, error) {
+	fr := task.jobs[0].report
+	if fr == nil {
+		return 0, nil
+	}
+	targetCols := make(map[string]int)
+	for _, job := range task.jobs {
+		targetCols[job.sheetName+"\x00"+job.targetLang] = job.targetIndex + 1
+	}
+	styles := make(map[int]int)
+	n := 0
+	for _, e := range fr.entries {
+		col, ok := targetCols[e.sheet+"\x00"+e.targetLang]
+		if !e.doubtful || !ok {
+			continue
+		}
+		cell, _ := excelize.CoordinatesToCellName(col, e.row)
+		value, err := task.f.GetCellValue(e.sheet, cell)
+		if err != nil {
+			return n, err
+		}
+		if value != e.translation {
+			continue
+		}
+		if err := fillCell(task.f, e.sheet, cell, doubtfulColor, styles); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Rate asks the model to rate a translation, with the glossary and context
+// of the hints attached to ctx.
+func (t *openaiTranslator) Rate(ctx context.Context, source, translation, sourceLang, targetLang string) (rating, error) {
+	input, err := json.Marshal(map[string]string{"source": source, "translation": translation})
+	if err != nil {
+		return rating{}, err
+	}
+	instructions := fmt.Sprintf("%s You check translations from '%s' to '%s'. The input is a JSON object with a source text and its translation. Rate the translation from 1 (wrong) to 5 (right) on terminology: whether technical terms are the usual ones of the field and of the glossary; completeness: whether everything in the source is translated and nothing is added; and placeholders: whether placeholders, tags and numbers are kept unchanged.%s", t.role(), sourceLang, targetLang, hintInstructions(ctx))
+	resp, err := t.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: t.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: instructions + rateReplyInstructions},
+			{Role: openai.ChatMessageRoleUser, Content: string(input)},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	})
+	if err != nil {
+		return rating{}, t.filtered(err)
+	}
+	t.limiter.observe(resp.Header())
+	countTokens(ctx, resp.Usage.TotalTokens)
+	t.budget.spend(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, 0, 1)
+	if len(resp.Choices) == 0 {
+		return rating{}, fmt.Errorf("%s returned no choices", t.name)
+	}
+	return parseRating(resp.Choices[0].Message.Content)
+}
+
+// rateReplyInstructions asks for the rating as a JSON object.
+const rateReplyInstructions = ` Reply with a JSON object of the form {"terminology": 5, "completeness": 5, "placeholders": 5, "issues": "..."} and nothing else, where issues names in a few words what is wrong, or is "" if nothing.`
+
+type ratingResponse struct {
+	Terminology  *int   `json:"terminology"`
+	Completeness *int   `json:"completeness"`
+	Placeholders *int   `json:"placeholders"`
+	Issues       string `json:"issues"`
+}
+
+// parseRating reads a reply to rateReplyInstructions. Scores are clamped
+// to 1-5; a missing one counts as 1.
+func parseRating(content string) (rating, error) {
+	var parsed ratingResponse
+	if err := json.Unmarshal([]byte(jsonObject(content)), &parsed); err != nil {
+		return rating{}, fmt.Errorf("could not parse rating: %w", err)
+	}
+	score := func(v *int) int {
+		if v == nil {
+			return 1
+		}
+		return min(max(*v, 1), 5)
+	}
+	return rating{
+		terminology:  score(parsed.Terminology),
+		completeness: score(parsed.Completeness),
+		placeholders: score(parsed.Placeholders),
+		issues:       strings.TrimSpace(parsed.Issues),
+	}, nil
+}
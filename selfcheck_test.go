@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// ratingTranslator upper-cases texts and rates translations containing
+// "PUMP" low on terminology.
+type ratingTranslator struct {
+	upperTranslator
+}
+
+func (r *ratingTranslator) Rate(ctx context.Context, source, translation, sourceLang, targetLang string) (rating, error) {
+	if strings.Contains(translation, "PUMP") {
+		return rating{terminology: 2, completeness: 5, placeholders: 5, issues: "pump is a valve here"}, nil
+	}
+	return rating{terminology: 5, completeness: 4, placeholders: 5}, nil
+}
+
+func TestSelfCheck(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor fault", ""},
+		{"Pump running", ""},
+		{"Pump running", ""},
+	}
+	job := newTestJob(t, rows)
+	job.report = &fileReport{}
+	translator := &ratingTranslator{}
+	job.verifier = translator
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	doubtful := make(map[int]bool)
+	for _, e := range job.report.entries {
+		doubtful[e.row] = e.doubtful
+		if e.row == 3 && (len(e.flags) != 1 || e.flags[0] != "self-check: terminology 2/5, completeness 5/5, placeholders 5/5: pump is a valve here") {
+			t.Errorf("flags of row 3 = %q", e.flags)
+		}
+	}
+	if doubtful[2] || !doubtful[3] {
+		t.Errorf("doubtful = %v, want row 3 only", doubtful)
+	}
+
+	// The duplicate in row 4 is not rated again and is not doubtful; row 3
+	// is marked, unless a reviewer has changed it since.
+	task := &fileTask{fileName: "plant.xlsx", f: job.f, jobs: []translationJob{job}}
+	n, err := markDoubtful(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("marked %d cells, want 1", n)
+	}
+	for cell, want := range map[string]bool{"B2": false, "B3": true, "B4": false} {
+		id, _ := job.f.GetCellStyle(job.sheetName, cell)
+		style, err := job.f.GetStyle(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filled := len(style.Fill.Color) == 1 && style.Fill.Color[0] == doubtfulColor
+		if filled != want {
+			t.Errorf("%s filled = %v, want %v", cell, filled, want)
+		}
+	}
+	job.setCell(2, "Valve running")
+	if n, _ := markDoubtful(task); n != 0 {
+		t.Errorf("marked %d cells after the edit, want 0", n)
+	}
+}
+
+func TestParseRating(t *testing.T) {
+	tests := []struct {
+		content string
+		want    rating
+		low     bool
+		wantErr bool
+	}{
+		{`{"terminology": 5, "completeness": 4, "placeholders": 5, "issues": ""}`, rating{5, 4, 5, ""}, false, false},
+		{`{"terminology": 3, "completeness": 5, "placeholders": 5, "issues": " wrong term "}`, rating{3, 5, 5, "wrong term"}, true, false},
+		{`{"terminology": 9, "completeness": 0}`, rating{5, 1, 1, ""}, true, false},
+		{`all good`, rating{}, false, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRating(tt.content)
+		if (err != nil) != tt.wantErr || got != tt.want || err == nil && got.low() != tt.low {
+			t.Errorf("parseRating(%q) = %+v, %v; want %+v", tt.content, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ///////////////////
+// HTTP SERVER
+// ///////////////////
+
+// The serve command offers the translator as a small web page for people
+// who do not use a terminal: upload a workbook, pick the languages, watch
+// the log and download the result. The flags of translate set up the
+// provider and the defaults of every upload. The API behind the page:
+//
+//	POST /jobs                  multipart form: file, source, target, mode; returns {"id": ...}
+//	GET  /jobs/{id}/events      progress as server-sent events: log, progress, done
+//	GET  /jobs/{id}/files/{name} a translated file named in the done event
+//
+// A finished job and its files are deleted after --keep, so a server that
+// runs for months does not fill the disk with workbooks.
+
+const maxUploadSize = 200 << 20
+
+// server runs the uploaded jobs. Each job has a directory of its own under
+// dir for the upload and its outputs.
+type server struct {
+	keep       time.Duration // how long a finished job's files can be downloaded; 0 = until the server stops
+	opts       options
+	translator Translator
+	limiter    *rateLimiter
+	fuzzy      *fuzzyMemory
+	reviewer   reviewer // --review-model; nil = none
+	verifier   verifier // --self-check; nil = none
+	tm         *translationMemory
+	dir        string
+	ctx        context.Context // cancelled when the server shuts down
+
+	mu   sync.Mutex
+	jobs map[string]*serverJob
+	next int
+}
+
+// serverEvent is one server-sent event.
+type serverEvent struct {
+	name string
+	data string
+}
+
+// serverJob is one uploaded file being translated. It receives the
+// progress messages of runTasks and keeps them, so a page that connects late
+// or reconnects still gets the whole log.
+type serverJob struct {
+	id  string
+	dir string
+
+	mu      sync.Mutex
+	events  []serverEvent
+	changed chan struct{} // closed and replaced on every new event
+	done    bool
+	outputs []string // base names of the files in dir to download
+}
+
+func newServerJob(id, dir string) *serverJob {
+	return &serverJob{id: id, dir: dir, changed: make(chan struct{})}
+}
+
+func (j *serverJob) publish(e serverEvent, done bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.events = append(j.events, e)
+	j.done = j.done || done
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// Send turns the progress messages of runTasks into events.
+func (j *serverJob) Send(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case logMsg:
+		j.publish(serverEvent{"log", string(msg)}, false)
+	case progressMsg:
+		j.publish(serverEvent{"progress", strconv.FormatFloat(float64(msg), 'f', 3, 64)}, false)
+	}
+}
+
+// since returns the events from index n on, the channel that is closed on
+// the next one, and whether the job is done.
+func (j *serverJob) since(n int) ([]serverEvent, <-chan struct{}, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return slices.Clone(j.events[n:]), j.changed, j.done
+}
+
+func (j *serverJob) hasOutput(name string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return slices.Contains(j.outputs, name)
+}
+
+// serveDone is the data of the done event.
+type serveDone struct {
+	Status  string   `json:"status"` // as in --json-summary
+	Summary string   `json:"summary"`
+	Files   []string `json:"files"` // download URLs
+}
+
+// runServeCommand starts the web server and runs until interrupted.
+func runServeCommand(args []string) error {
+	addr := flag.String("addr", "localhost:8080", "Address to listen on; use :8080 to accept connections from other machines.")
+	keep := flag.Duration("keep", time.Hour, "How long the results of an upload can be downloaded before they are deleted; 0 keeps them until the server stops.")
+	opts := parseFlags(args)
+	switch {
+	case opts.file != "" || opts.all || len(opts.files) > 0:
+		return errors.New("serve takes its files from the web page, not from --file or --all")
+	case opts.review || opts.overwrite == "ask":
+		return errors.New("--review and --overwrite=ask need the terminal and cannot be used with serve")
+	case opts.reportPath != "" || opts.jsonSummaryPath != "":
+		return errors.New("--report and --json-summary are written per run and cannot be used with serve")
+	case opts.budget != nil:
+		return errors.New("--max-cost and --max-tokens stop a single run and cannot be used with serve")
+	case opts.dryRun:
+		return errors.New("--dry-run cannot be used with serve")
+	case opts.previousPath != "" || opts.hashes:
+		return errors.New("--previous and --hashes belong to a single export and cannot be used with serve")
+	case opts.inPlace:
+		return errors.New("--in-place cannot be used with serve, whose uploads are downloaded again")
+	case opts.projectPath != "":
+		return errors.New("--project keeps the settings of a single file and cannot be used with serve")
+	}
+	opts.nonInteractive = true
+	headless = true
+
+	s := &server{keep: *keep, opts: opts, limiter: newRateLimiter(opts.rpm, opts.tpm), jobs: make(map[string]*serverJob)}
+	translator, embeddings, err := newRunTranslator(opts, s.limiter, false)
+	if err != nil {
+		return err
+	}
+	s.translator = translator
+	if opts.reviewModel != "" {
+		if s.reviewer, err = newReviewer(opts, false); err != nil {
+			return err
+		}
+	}
+	if opts.selfCheck {
+		if s.verifier, err = newVerifier(translator, opts.provider); err != nil {
+			return err
+		}
+	}
+	if opts.tmPath != "" {
+		if s.tm, err = openTM(opts.tmPath); err != nil {
+			return err
+		}
+		defer s.tm.Close()
+	}
+	s.fuzzy = newFuzzyMemory(s.tm, embeddings, opts.fuzzyTM)
+	if s.dir, err = os.MkdirTemp("", "tia-translator-"); err != nil {
+		return err
+	}
+	defer os.RemoveAll(s.dir)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	s.ctx = ctx
+	srv := &http.Server{Addr: *addr, Handler: s.handler()}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	fmt.Printf("Serving the translator on http://%s (Ctrl+C to stop)\n", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handlePage)
+	mux.HandleFunc("POST /jobs", s.handleUpload)
+	mux.HandleFunc("GET /jobs/{id}/events", s.handleEvents)
+	mux.HandleFunc("GET /jobs/{id}/files/{name}", s.handleDownload)
+	return mux
+}
+
+func (s *server) handlePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	servePage.Execute(w, struct{ Source, Target, Mode, Version string }{s.opts.sourceLang, s.opts.targetLang, s.opts.mode, getVersion()})
+}
+
+func (s *server) job(r *http.Request) *serverJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[r.PathValue("id")]
+}
+
+// handleUpload stores the uploaded file, sets it up like translate would and
+// starts translating it.
+func (s *server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("no file uploaded: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	opts := s.opts
+	if v := strings.TrimSpace(r.FormValue("source")); v != "" {
+		opts.sourceLang, opts.sourceCol = v, ""
+	}
+	if v := strings.TrimSpace(r.FormValue("target")); v != "" {
+		opts.targetLang, opts.targetCol = v, ""
+	}
+	if v := r.FormValue("mode"); v != "" {
+		opts.mode = v
+	}
+	if opts.sourceLang == "" && opts.sourceCol == "" {
+		opts.sourceCol = "auto"
+	}
+	switch {
+	case opts.targetLang == "" && opts.targetCol == "":
+		http.Error(w, "no target language given", http.StatusBadRequest)
+		return
+	case opts.mode != "" && opts.mode != "full" && opts.mode != "quick":
+		http.Error(w, fmt.Sprintf("invalid mode %q: must be full or quick", opts.mode), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.next++
+	id := strconv.Itoa(s.next)
+	s.mu.Unlock()
+	dir := filepath.Join(s.dir, id)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	started := false
+	defer func() {
+		if !started {
+			os.RemoveAll(dir)
+		}
+	}()
+	fileName := filepath.Join(dir, filepath.Base(header.Filename))
+	out, err := os.Create(fileName)
+	if err == nil {
+		_, err = io.Copy(out, file)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not store the upload: %v", err), http.StatusBadRequest)
+		return
+	}
+	task, err := prepareFile(opts, fileName, s.tm, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for j := range task.jobs {
+		task.jobs[j].limiter = s.limiter
+		task.jobs[j].fuzzy = s.fuzzy
+		task.jobs[j].reviewer = s.reviewer
+		task.jobs[j].verifier = s.verifier
+	}
+
+	job := newServerJob(id, dir)
+	s.mu.Lock()
+	s.jobs[id] = job
+	s.mu.Unlock()
+	started = true
+	go s.run(job, task, opts)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// run translates task and ends the job's events with a done event.
+func (s *server) run(job *serverJob, task *fileTask, opts options) {
+	result := &runResult{}
+	runTasks(s.ctx, job, s.translator, []*fileTask{task}, opts, result)
+
+	var outputs []string
+	for _, path := range append(result.files(), result.partialFiles()...) {
+		outputs = append(outputs, filepath.Base(path))
+	}
+	job.mu.Lock()
+	job.outputs = outputs
+	job.mu.Unlock()
+
+	summary, code := result.summary()
+	done := serveDone{Status: exitStatuses[code], Summary: summary.String(), Files: []string{}}
+	for _, name := range outputs {
+		done.Files = append(done.Files, fmt.Sprintf("/jobs/%s/files/%s", job.id, url.PathEscape(name)))
+	}
+	data, _ := json.Marshal(done)
+	job.publish(serverEvent{"done", string(data)}, true)
+	if s.keep > 0 {
+		time.AfterFunc(s.keep, func() { s.remove(job) })
+	}
+}
+
+// remove forgets a finished job and deletes its files.
+func (s *server) remove(job *serverJob) {
+	s.mu.Lock()
+	delete(s.jobs, job.id)
+	s.mu.Unlock()
+	if err := os.RemoveAll(job.dir); err != nil {
+		fmt.Fprintf(os.Stderr, "could not delete the files of job %s: %v\n", job.id, err)
+	}
+}
+
+// handleEvents streams the events of a job, from the first one, until it is
+// done or the page goes away.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	job := s.job(r)
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	sent := 0
+	for {
+		events, changed, done := job.since(sent)
+		for _, e := range events {
+			// A data line ends at a newline; log lines have none.
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, strings.ReplaceAll(e.data, "\n", " "))
+		}
+		sent += len(events)
+		flusher.Flush()
+		if done {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	job := s.job(r)
+	name := r.PathValue("name")
+	if job == nil || !job.hasOutput(name) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeFile(w, r, filepath.Join(job.dir, name))
+}
+
+var servePage = template.Must(template.New("serve").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>TIA Text Translator</title>
+<style>
+body { font-family: sans-serif; margin: 2em; max-width: 50em; }
+label { display: block; margin: 0.8em 0 0.2em; }
+input[type=text], select { width: 20em; padding: 4px; }
+button { margin-top: 1em; padding: 6px 16px; }
+progress { width: 100%; margin-top: 1.5em; }
+#log { background: #f6f8fa; border: 1px solid #ccc; padding: 8px; height: 20em; overflow-y: auto; white-space: pre-wrap; font-size: 0.85em; }
+#result a { display: block; margin: 0.3em 0; font-weight: bold; }
+.error { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>TIA Text Translator</h1>
+<p>Version {{.Version}}. Upload a TIA Portal text export and download the translation when it is done.</p>
+<form id="upload">
+<label for="file">Workbook</label>
+<input type="file" id="file" name="file" accept=".xlsx,.csv,.xml,.po" required>
+<label for="source">Source language (empty: detect)</label>
+<input type="text" id="source" name="source" value="{{.Source}}" placeholder="de-DE">
+<label for="target">Target languages, comma-separated</label>
+<input type="text" id="target" name="target" value="{{.Target}}" placeholder="en-US,fr-FR" required>
+<label for="mode">Mode</label>
+<select id="mode" name="mode">
+<option value="full"{{if ne .Mode "quick"}} selected{{end}}>Full: translate every row</option>
+<option value="quick"{{if eq .Mode "quick"}} selected{{end}}>Quick: only fill missing translations</option>
+</select>
+<br><button type="submit">Translate</button>
+</form>
+<progress id="progress" max="1" value="0" hidden></progress>
+<p id="status"></p>
+<div id="result"></div>
+<pre id="log" hidden></pre>
+<script>
+const form = document.getElementById("upload");
+const log = document.getElementById("log");
+const bar = document.getElementById("progress");
+const status = document.getElementById("status");
+const result = document.getElementById("result");
+form.addEventListener("submit", async (e) => {
+	e.preventDefault();
+	form.querySelector("button").disabled = true;
+	status.textContent = "Uploading...";
+	status.className = "";
+	result.textContent = "";
+	log.textContent = "";
+	const resp = await fetch("/jobs", {method: "POST", body: new FormData(form)});
+	if (!resp.ok) {
+		status.textContent = await resp.text();
+		status.className = "error";
+		form.querySelector("button").disabled = false;
+		return;
+	}
+	const {id} = await resp.json();
+	status.textContent = "Translating...";
+	bar.hidden = false;
+	log.hidden = false;
+	const events = new EventSource("/jobs/" + id + "/events");
+	events.addEventListener("log", (e) => {
+		log.textContent += e.data + "\n";
+		log.scrollTop = log.scrollHeight;
+	});
+	events.addEventListener("progress", (e) => { bar.value = parseFloat(e.data); });
+	events.addEventListener("done", (e) => {
+		events.close();
+		const done = JSON.parse(e.data);
+		bar.value = 1;
+		status.textContent = done.summary;
+		status.className = done.status === "clean" ? "" : "error";
+		for (const url of done.files) {
+			const a = document.createElement("a");
+			a.href = url;
+			a.textContent = "Download " + decodeURIComponent(url.split("/").pop());
+			result.appendChild(a);
+		}
+		if (done.files.length === 0) {
+			result.textContent = "No translation was saved; see the log.";
+		}
+		form.querySelector("button").disabled = false;
+	});
+});
+</script>
+</body>
+</html>
+`))
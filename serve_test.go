@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// upload posts a workbook with the form fields to the server.
+func upload(t *testing.T, url string, fields map[string]string) *http.Response {
+	t.Helper()
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]string{"de-DE", "en-US"})
+	f.SetSheetRow("Sheet1", "A2", &[]string{"Pumpe läuft", ""})
+	f.SetSheetRow("Sheet1", "A3", &[]string{"##Tag##", ""})
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	part, _ := mw.CreateFormFile("file", "plant export.xlsx")
+	if err := f.Write(part); err != nil {
+		t.Fatal(err)
+	}
+	mw.Close()
+	resp, err := http.Post(url+"/jobs", mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// waitDone reads the events of a job up to its done event.
+func waitDone(t *testing.T, url, id string) serveDone {
+	t.Helper()
+	resp, err := http.Get(url + "/jobs/" + id + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := make(map[string][]string)
+	var event string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+		} else if data, ok := strings.CutPrefix(line, "data: "); ok {
+			events[event] = append(events[event], data)
+		}
+	}
+	if len(events["log"]) == 0 || len(events["progress"]) == 0 || len(events["done"]) != 1 {
+		t.Fatalf("%d log, %d progress and %d done events", len(events["log"]), len(events["progress"]), len(events["done"]))
+	}
+	var done serveDone
+	if err := json.Unmarshal([]byte(events["done"][0]), &done); err != nil {
+		t.Fatal(err)
+	}
+	return done
+}
+
+func TestServe(t *testing.T) {
+	s := &server{
+		opts:       options{workers: 1, checkpointEvery: 25, nonInteractive: true},
+		translator: &upperTranslator{},
+		dir:        t.TempDir(),
+		ctx:        context.Background(),
+		jobs:       make(map[string]*serverJob),
+	}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(page), `<form id="upload">`) {
+		t.Error("the page has no upload form")
+	}
+
+	resp = upload(t, ts.URL, map[string]string{"source": "de-DE"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("upload without target: status %d; expected 400", resp.StatusCode)
+	}
+
+	resp = upload(t, ts.URL, map[string]string{"source": "de-DE", "target": "en-US", "mode": "full"})
+	var job struct{ ID string }
+	json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || job.ID == "" {
+		t.Fatalf("upload: status %d, job %q", resp.StatusCode, job.ID)
+	}
+
+	done := waitDone(t, ts.URL, job.ID)
+	if done.Status != "clean" || len(done.Files) != 1 || !strings.Contains(done.Summary, "Translated: 1") {
+		t.Fatalf("done = %+v", done)
+	}
+
+	resp, err = http.Get(ts.URL + done.Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("download %s: status %d", done.Files[0], resp.StatusCode)
+	}
+	f, err := excelize.OpenReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.GetCellValue("Sheet1", "B2"); got != "PUMPE LÄUFT" {
+		t.Errorf("B2 = %q; expected the translation", got)
+	}
+
+	resp, err = http.Get(ts.URL + "/jobs/" + job.ID + "/files/plant%20export.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("download of the upload: status %d; expected 404", resp.StatusCode)
+	}
+}
+
+func TestServeDeletesFinishedJobs(t *testing.T) {
+	s := &server{
+		keep:       50 * time.Millisecond,
+		opts:       options{workers: 1, checkpointEvery: 25, nonInteractive: true},
+		translator: &upperTranslator{},
+		dir:        t.TempDir(),
+		ctx:        context.Background(),
+		jobs:       make(map[string]*serverJob),
+	}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	resp := upload(t, ts.URL, map[string]string{"source": "fr-FR", "target": "en-US"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("upload without the source column: status %d; expected 400", resp.StatusCode)
+	}
+	if entries, _ := os.ReadDir(s.dir); len(entries) != 0 {
+		t.Errorf("%d directories left by rejected uploads", len(entries))
+	}
+
+	resp = upload(t, ts.URL, map[string]string{"source": "de-DE", "target": "en-US"})
+	var job struct{ ID string }
+	json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	done := waitDone(t, ts.URL, job.ID)
+	if len(done.Files) != 1 {
+		t.Fatalf("done = %+v", done)
+	}
+	dir := filepath.Join(s.dir, job.ID)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			break
+		}
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("job directory still there after --keep: %v", err)
+	}
+	resp, err := http.Get(ts.URL + done.Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("download after --keep: status %d; expected 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ///////////////////
+// SESSION TERMINOLOGY
+// ///////////////////
+
+// Rows are translated independently, so a term such as "Förderband" can
+// come back as "conveyor" in one row and "belt conveyor" in the next. Each
+// target language of a file keeps the texts translated so far, indexed by
+// their words and word groups; a new text is sent with the earlier texts
+// that share most of them, so the model can keep to the terms it already
+// used. Translations kept from the file, the translation memory or a
+// --previous run seed the index before the first request.
+//
+// With several workers, which rows finish before a request is sent changes
+// from run to run, and so would the prompt; --replay could then not match
+// the recorded requests. With --record or --replay, the translations of a
+// sheet's rows are therefore held back while it is translated in parallel
+// and added in row order once it is done, for the sheets after it.
+
+const (
+	sessionMaxEntries = 5  // most earlier texts sent with a request
+	sessionMaxText    = 80 // longer texts are not kept; short ones show the terms best
+)
+
+// sessionTerms is the rolling terminology of one target language in a file.
+type sessionTerms struct {
+	mu      sync.Mutex
+	lang    string // source language of the texts
+	entries []tmEntry
+	known   map[string]bool  // sources already kept
+	byTerm  map[string][]int // lower-case word group -> entries with it
+	inOrder bool             // --record or --replay: hold and release take effect
+	holding bool             // notes go to held until release
+	held    []heldNote
+}
+
+// heldNote is a translation noted while the session is held.
+type heldNote struct {
+	row                 int
+	source, translation string
+}
+
+func newSessionTerms(sourceLang string) *sessionTerms {
+	return &sessionTerms{lang: sourceLang, known: make(map[string]bool), byTerm: make(map[string][]int)}
+}
+
+// note keeps the translation of source, made for the 0-based row. The
+// first translation of a text stays; later ones are expected to follow it.
+func (s *sessionTerms) note(row int, source, translation string) {
+	if s == nil {
+		return
+	}
+	source, translation = strings.TrimSpace(source), strings.TrimSpace(translation)
+	if source == "" || translation == "" || len([]rune(source)) > sessionMaxText {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holding {
+		s.held = append(s.held, heldNote{row: row, source: source, translation: translation})
+		return
+	}
+	s.add(source, translation)
+}
+
+// hold keeps later notes out of relevant until release. Without inOrder,
+// notes are used as soon as they are made.
+func (s *sessionTerms) hold() {
+	if s == nil || !s.inOrder {
+		return
+	}
+	s.mu.Lock()
+	s.holding = true
+	s.mu.Unlock()
+}
+
+// release adds the notes held since hold in row order, whatever order they
+// were made in.
+func (s *sessionTerms) release() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slices.SortStableFunc(s.held, func(a, b heldNote) int { return cmp.Compare(a.row, b.row) })
+	for _, n := range s.held {
+		s.add(n.source, n.translation)
+	}
+	s.holding, s.held = false, nil
+}
+
+// add indexes a translation; s.mu is held.
+func (s *sessionTerms) add(source, translation string) {
+	if s.known[source] {
+		return
+	}
+	s.known[source] = true
+	s.entries = append(s.entries, tmEntry{source: source, translation: translation})
+	for key := range termNgrams(source, s.lang) {
+		s.byTerm[key] = append(s.byTerm[key], len(s.entries)-1)
+	}
+}
+
+// relevant returns the earlier texts that share the most words and word
+// groups with texts, shortest first among equals, at most
+// sessionMaxEntries of them. The texts themselves are left out.
+func (s *sessionTerms) relevant(texts ...string) []tmEntry {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shared := make(map[int]int)
+	for _, text := range texts {
+		for key := range termNgrams(text, s.lang) {
+			for _, i := range s.byTerm[key] {
+				shared[i]++
+			}
+		}
+	}
+	var found []int
+	for i := range shared {
+		if !slices.ContainsFunc(texts, func(text string) bool { return strings.TrimSpace(text) == s.entries[i].source }) {
+			found = append(found, i)
+		}
+	}
+	slices.SortFunc(found, func(a, b int) int {
+		return cmp.Or(cmp.Compare(shared[b], shared[a]), cmp.Compare(len(s.entries[a].source), len(s.entries[b].source)), cmp.Compare(a, b))
+	})
+	entries := make([]tmEntry, 0, min(len(found), sessionMaxEntries))
+	for _, i := range found[:min(len(found), sessionMaxEntries)] {
+		entries = append(entries, s.entries[i])
+	}
+	return entries
+}
+
+// sessionInstructions shows the model earlier translations of the file.
+func sessionInstructions(entries []tmEntry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(entries))
+	for i, e := range entries {
+		pairs[i] = fmt.Sprintf("%q -> %q", e.source, e.translation)
+	}
+	return fmt.Sprintf(" Earlier texts of this file were translated like this; use the same terms for the same things: %s.", strings.Join(pairs, "; "))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSessionTermsRelevant(t *testing.T) {
+	s := newSessionTerms("de-DE")
+	s.note(1, "Förderband", "Conveyor")
+	s.note(2, "Förderband", "Belt conveyor") // the first translation stays
+	s.note(3, "Förderband Abschnitt A gestoppt", "Conveyor section A stopped")
+	s.note(4, "Pumpe läuft", "Pump running")
+	s.note(5, "Druck der Pumpe zu hoch", "Pump pressure too high")
+	s.note(6, strings.Repeat("Förderband ", 10), "too long to keep")
+
+	got := s.relevant("Förderband Abschnitt B gestoppt")
+	if len(got) != 2 || got[0].source != "Förderband Abschnitt A gestoppt" || got[1].translation != "Conveyor" {
+		t.Errorf("relevant = %v; expected the section text, then Förderband -> Conveyor", got)
+	}
+	if got := s.relevant("Pumpe läuft"); len(got) != 1 || got[0].source != "Druck der Pumpe zu hoch" {
+		t.Errorf("relevant = %v; expected the other pump text but not the text itself", got)
+	}
+	if got := s.relevant("Ventil offen"); len(got) != 0 {
+		t.Errorf("relevant for an unrelated text = %v; expected none", got)
+	}
+	if got := (*sessionTerms)(nil).relevant("Förderband"); got != nil {
+		t.Errorf("relevant without session terms = %v", got)
+	}
+}
+
+// sessionTranslator records the earlier texts sent along with each text.
+type sessionTranslator struct {
+	upperTranslator
+	mu      sync.Mutex
+	session map[string][]tmEntry
+}
+
+func (s *sessionTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	s.mu.Lock()
+	s.session[text] = promptHintsFrom(ctx).session
+	s.mu.Unlock()
+	return s.upperTranslator.Translate(ctx, text, sourceLang, targetLang)
+}
+
+func TestSessionTermsInPrompts(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Förderband", "Conveyor"},
+		{"Förderband läuft", ""},
+		{"Ventil offen", ""},
+		{"Förderband läuft nicht", ""},
+	}
+	job := newTestJob(t, rows)
+	job.mode = "quick"
+	job.workers = 1
+	job.session = newSessionTerms(job.sourceLang)
+	translator := &sessionTranslator{session: make(map[string][]tmEntry)}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	if got := translator.session["Förderband läuft"]; len(got) != 1 || got[0].translation != "Conveyor" {
+		t.Errorf("session = %v; expected the kept translation of Förderband", got)
+	}
+	if got := translator.session["Ventil offen"]; len(got) != 0 {
+		t.Errorf("session for an unrelated text = %v; expected none", got)
+	}
+	got := translator.session["Förderband läuft nicht"]
+	if len(got) != 2 || got[0].translation != "FÖRDERBAND LÄUFT" {
+		t.Errorf("session = %v; expected the translation made earlier in the run first", got)
+	}
+}
+
+func TestSessionTermsHeld(t *testing.T) {
+	s := newSessionTerms("de-DE")
+	s.note(1, "Förderband", "Conveyor")
+	s.hold()
+	s.note(2, "Förderband leer", "Conveyor empty")
+	if got := s.relevant("Förderband steht"); len(got) != 2 {
+		t.Errorf("relevant without a cassette = %v; expected notes to be used at once", got)
+	}
+
+	s = newSessionTerms("de-DE")
+	s.inOrder = true
+	s.note(1, "Förderband", "Conveyor")
+	s.hold()
+	s.note(5, "Förderband läuft", "Conveyor running")
+	s.note(3, "Förderband läuft", "Belt running")
+	if got := s.relevant("Förderband steht"); len(got) != 1 {
+		t.Errorf("relevant while held = %v; expected only the note made before", got)
+	}
+	s.release()
+	got := s.relevant("Förderband steht")
+	if len(got) != 2 || got[1].translation != "Belt running" {
+		t.Errorf("relevant after release = %v; expected the held note of the earlier row to stay", got)
+	}
+}
+
+func TestSessionInstructions(t *testing.T) {
+	if got := sessionInstructions(nil); got != "" {
+		t.Errorf("sessionInstructions(nil) = %q; expected none", got)
+	}
+	got := sessionInstructions([]tmEntry{{source: "Förderband", translation: "Conveyor"}})
+	if !strings.Contains(got, `"Förderband" -> "Conveyor"`) || !strings.Contains(got, "same terms") {
+		t.Errorf("sessionInstructions = %q", got)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// TIA PORTAL XML
+// ///////////////////
+
+// TIA Portal's XML exports (SimaticML) keep every translatable text as a
+// MultilingualText with one MultilingualTextItem per culture:
+//
+//	<MultilingualText ID="3" CompositionName="Comment">
+//	  <ObjectList>
+//	    <MultilingualTextItem ID="4" CompositionName="Items">
+//	      <AttributeList>
+//	        <Culture>de-DE</Culture>
+//	        <Text>Motor</Text>
+//	      </AttributeList>
+//	    </MultilingualTextItem>
+//	    ...
+//
+// The texts are loaded into a sheet with one row per MultilingualText and one
+// column per culture, so the normal pipeline can translate them. Writing back
+// only replaces the <Text> elements that changed and copies every other byte
+// of the original file, so TIA can re-import it. A text translated into a
+// culture it has no item for gets a new item after its last one, written
+// like that one with the next free ID, as TIA exports it.
+
+// simaticIDHeader is the first header of the sheet built from an XML export;
+// detectFileType recognises such sheets by it.
+const simaticIDHeader = "MultilingualText ID"
+
+// simaticText is one MultilingualText and where its items' <Text> elements
+// are in the file.
+type simaticText struct {
+	id          string
+	composition string
+	texts       map[string]string   // culture -> text
+	spans       map[string][2]int64 // culture -> byte range of the <Text> element
+	last        simaticItem         // the last item, copied for a new culture
+}
+
+// simaticItem holds the byte ranges of a MultilingualTextItem, its start tag
+// and its <Culture> and <Text> elements.
+type simaticItem struct {
+	span, tag, culture, text [2]int64
+}
+
+// simaticDoc is a parsed XML export.
+type simaticDoc struct {
+	data     []byte
+	cultures []string
+	texts    []simaticText
+	maxID    uint64 // highest ID attribute; TIA writes them in hex
+}
+
+// simaticIDRegex finds the ID attribute in an item's start tag.
+var simaticIDRegex = regexp.MustCompile(`\bID="[^"]*"`)
+
+// isSimaticFile reports whether fileName should be read as a TIA XML export.
+func isSimaticFile(fileName string) bool {
+	return strings.EqualFold(filepath.Ext(fileName), ".xml")
+}
+
+// parseSimatic finds the multilingual texts in an XML export.
+func parseSimatic(data []byte) (*simaticDoc, error) {
+	doc := &simaticDoc{data: data}
+	d := xml.NewDecoder(bytes.NewReader(data))
+
+	var current *simaticText
+	var culture, text string
+	var item simaticItem
+	var inItem, inCulture, inText, hasText bool
+	for {
+		start := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse XML: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				if id, err := strconv.ParseUint(attr.Value, 16, 64); attr.Name.Local == "ID" && err == nil {
+					doc.maxID = max(doc.maxID, id)
+				}
+			}
+			switch t.Name.Local {
+			case "MultilingualText":
+				current = &simaticText{
+					texts: make(map[string]string),
+					spans: make(map[string][2]int64),
+				}
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "ID":
+						current.id = attr.Value
+					case "CompositionName":
+						current.composition = attr.Value
+					}
+				}
+			case "MultilingualTextItem":
+				inItem = current != nil
+				culture, text, hasText = "", "", false
+				item = simaticItem{span: [2]int64{start, 0}, tag: [2]int64{start, d.InputOffset()}}
+			case "Culture":
+				inCulture = inItem
+				item.culture[0] = start
+			case "Text":
+				if inItem {
+					inText, hasText = true, true
+					item.text[0] = start
+				}
+			}
+		case xml.CharData:
+			switch {
+			case inCulture:
+				culture += string(t)
+			case inText:
+				text += string(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "Culture":
+				if inCulture {
+					inCulture = false
+					item.culture[1] = d.InputOffset()
+				}
+			case "Text":
+				if inText {
+					inText = false
+					item.text[1] = d.InputOffset()
+				}
+			case "MultilingualTextItem":
+				culture = strings.TrimSpace(culture)
+				if inItem && culture != "" && hasText {
+					item.span[1] = d.InputOffset()
+					current.texts[culture] = text
+					current.spans[culture] = item.text
+					current.last = item
+					if !slices.Contains(doc.cultures, culture) {
+						doc.cultures = append(doc.cultures, culture)
+					}
+				}
+				inItem = false
+			case "MultilingualText":
+				if current != nil && len(current.texts) > 0 {
+					doc.texts = append(doc.texts, *current)
+				}
+				current = nil
+			}
+		}
+	}
+	if len(doc.texts) == 0 {
+		return nil, errors.New("no MultilingualText elements found; is this a TIA Portal XML export?")
+	}
+	return doc, nil
+}
+
+// openSimatic reads an XML export into a workbook: the MultilingualText ID
+// and composition name followed by one column per culture.
+func openSimatic(fileName string) (*excelize.File, *simaticDoc, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error opening file: %v", err)
+	}
+	doc, err := parseSimatic(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	header := append([]interface{}{simaticIDHeader, "Composition"}, stringsToValues(doc.cultures)...)
+	f.SetSheetRow(sheet, "A1", &header)
+	for i, t := range doc.texts {
+		row := []interface{}{t.id, t.composition}
+		for _, c := range doc.cultures {
+			row = append(row, t.texts[c])
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	return f, doc, nil
+}
+
+func stringsToValues(s []string) []interface{} {
+	values := make([]interface{}, len(s))
+	for i, v := range s {
+		values[i] = v
+	}
+	return values
+}
+
+// write saves the export with the texts from the sheet. It returns how many
+// items it added for cultures a text had none for.
+func (doc *simaticDoc) write(f *excelize.File, sheetName, newFileName string) (int, error) {
+	type edit struct {
+		span [2]int64 // replaced; an empty span inserts
+		text string
+	}
+	var edits []edit
+	id := doc.maxID
+	added := 0
+	for i, t := range doc.texts {
+		for j, c := range doc.cultures {
+			cell, _ := excelize.CoordinatesToCellName(j+3, i+2)
+			value, err := f.GetCellValue(sheetName, cell)
+			if err != nil {
+				return 0, err
+			}
+			if value == t.texts[c] {
+				continue
+			}
+			if span, ok := t.spans[c]; ok {
+				edits = append(edits, edit{span, textElement(value)})
+				continue
+			}
+			id++
+			at := t.last.span[1]
+			edits = append(edits, edit{[2]int64{at, at}, doc.newItem(t.last, id, c, value)})
+			added++
+		}
+	}
+	sort.SliceStable(edits, func(a, b int) bool { return edits[a].span[0] < edits[b].span[0] })
+
+	var out bytes.Buffer
+	var last int64
+	for _, e := range edits {
+		out.Write(doc.data[last:e.span[0]])
+		out.WriteString(e.text)
+		last = e.span[1]
+	}
+	out.Write(doc.data[last:])
+	return added, os.WriteFile(newFileName, out.Bytes(), 0o644)
+}
+
+// textElement is a <Text> element holding text.
+func textElement(text string) string {
+	var b strings.Builder
+	b.WriteString("<Text>")
+	xml.EscapeText(&b, []byte(text))
+	b.WriteString("</Text>")
+	return b.String()
+}
+
+// newItem copies the item from, with the whitespace before it, as an item
+// with the given ID, culture and text.
+func (doc *simaticDoc) newItem(from simaticItem, id uint64, culture, text string) string {
+	indent := from.span[0]
+	for indent > 0 && strings.ContainsRune(" \t\r\n", rune(doc.data[indent-1])) {
+		indent--
+	}
+	var culturePart bytes.Buffer
+	culturePart.WriteString("<Culture>")
+	xml.EscapeText(&culturePart, []byte(culture))
+	culturePart.WriteString("</Culture>")
+
+	// The parts in file order, with the culture and text swapped in.
+	parts := []struct {
+		span [2]int64
+		with string
+	}{
+		{from.culture, culturePart.String()},
+		{from.text, textElement(text)},
+	}
+	if parts[1].span[0] < parts[0].span[0] {
+		parts[0], parts[1] = parts[1], parts[0]
+	}
+	var b strings.Builder
+	b.Write(doc.data[indent:from.span[0]])
+	b.WriteString(simaticIDRegex.ReplaceAllLiteralString(string(doc.data[from.tag[0]:from.tag[1]]), fmt.Sprintf(`ID="%X"`, id)))
+	last := from.tag[1]
+	for _, part := range parts {
+		b.Write(doc.data[last:part.span[0]])
+		b.WriteString(part.with)
+		last = part.span[1]
+	}
+	b.Write(doc.data[last:from.span[1]])
+	return b.String()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const simaticSample = `<?xml version="1.0" encoding="utf-8"?>
+<Document>
+  <SW.Blocks.FB ID="0">
+    <ObjectList>
+      <MultilingualText ID="1" CompositionName="Comment">
+        <ObjectList>
+          <MultilingualTextItem ID="2" CompositionName="Items">
+            <AttributeList>
+              <Culture>de-DE</Culture>
+              <Text>Motor &amp; Pumpe</Text>
+            </AttributeList>
+          </MultilingualTextItem>
+          <MultilingualTextItem ID="3" CompositionName="Items">
+            <AttributeList>
+              <Culture>en-US</Culture>
+              <Text />
+            </AttributeList>
+          </MultilingualTextItem>
+        </ObjectList>
+      </MultilingualText>
+      <MultilingualText ID="4" CompositionName="Title">
+        <ObjectList>
+          <MultilingualTextItem ID="5" CompositionName="Items">
+            <AttributeList>
+              <Culture>de-DE</Culture>
+              <Text>Ventil</Text>
+            </AttributeList>
+          </MultilingualTextItem>
+        </ObjectList>
+      </MultilingualText>
+    </ObjectList>
+  </SW.Blocks.FB>
+</Document>
+`
+
+func TestSimaticRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "block.xml")
+	if err := os.WriteFile(in, []byte(simaticSample), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, doc, err := openSimatic(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet := f.GetSheetName(0)
+	rows, _ := f.GetRows(sheet)
+	expected := [][]string{
+		{simaticIDHeader, "Composition", "de-DE", "en-US"},
+		{"1", "Comment", "Motor & Pumpe"},
+		{"4", "Title", "Ventil"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("rows = %q; expected %q", rows, expected)
+	}
+	for i := range expected {
+		if strings.Join(rows[i], "|") != strings.Join(expected[i], "|") {
+			t.Errorf("row %d = %q; expected %q", i+1, rows[i], expected[i])
+		}
+	}
+	if detectFileType(rows[0]) != FileTypeTIAXML {
+		t.Errorf("detectFileType = %v; expected TIA Portal XML", detectFileType(rows[0]))
+	}
+
+	f.SetCellValue(sheet, "D2", "Motor & pump")
+	f.SetCellValue(sheet, "D3", "Valve") // no en-US item
+	out := filepath.Join(dir, "out.xml")
+	added, err := doc.write(f, sheet, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 {
+		t.Errorf("added = %d; expected 1", added)
+	}
+
+	got, _ := os.ReadFile(out)
+	want := strings.Replace(simaticSample, "<Text />", "<Text>Motor &amp; pump</Text>", 1)
+	newItem := `
+          <MultilingualTextItem ID="6" CompositionName="Items">
+            <AttributeList>
+              <Culture>en-US</Culture>
+              <Text>Valve</Text>
+            </AttributeList>
+          </MultilingualTextItem>`
+	i := strings.Index(want, "<Text>Ventil</Text>")
+	i += strings.Index(want[i:], "</MultilingualTextItem>") + len("</MultilingualTextItem>")
+	want = want[:i] + newItem + want[i:]
+	if string(got) != want {
+		t.Errorf("output differs from input beyond the translated text and the new item:\n%s", got)
+	}
+	reread, err := parseSimatic(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := reread.texts[1].texts["en-US"]; text != "Valve" {
+		t.Errorf("en-US text of the title = %q; expected the translation in the new item", text)
+	}
+}
+
+func TestSimaticNewItemIDs(t *testing.T) {
+	sample := `<Document><MultilingualText ID="1E"><ObjectList><MultilingualTextItem ID="1F"><AttributeList><Culture>de-DE</Culture><Text>Pumpe</Text></AttributeList></MultilingualTextItem></ObjectList></MultilingualText></Document>`
+	dir := t.TempDir()
+	in := filepath.Join(dir, "list.xml")
+	if err := os.WriteFile(in, []byte(sample), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, doc, err := openSimatic(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cultures other texts of the export have items for.
+	doc.cultures = append(doc.cultures, "fr-FR", "it-IT")
+	sheet := f.GetSheetName(0)
+	f.SetCellValue(sheet, "D2", "Pompe")
+	f.SetCellValue(sheet, "E2", "Pompa")
+	out := filepath.Join(dir, "out.xml")
+	if added, err := doc.write(f, sheet, out); err != nil || added != 2 {
+		t.Fatalf("write = %d, %v; expected 2 items added", added, err)
+	}
+	got, _ := os.ReadFile(out)
+	for _, want := range []string{
+		`<MultilingualTextItem ID="20"><AttributeList><Culture>fr-FR</Culture><Text>Pompe</Text>`,
+		`<MultilingualTextItem ID="21"><AttributeList><Culture>it-IT</Culture><Text>Pompa</Text>`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("output lacks %s:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// STREAMED WORKBOOKS
+// ///////////////////
+
+// streamRows is the number of data rows from which an XLSX workbook is
+// streamed. excelize parses a whole sheet into memory on the first cell
+// written to it, which for a WinCC Unified export of a few hundred thousand
+// rows takes gigabytes. A streamed workbook is never written to: the run
+// collects its values in cellEdits, and the output is copied from the input
+// file with the edited cells swapped in as it goes.
+var streamRows = 100000
+
+// cellEdits are the values a run writes to a streamed workbook. Like the
+// checkpoint it is only used from the goroutine that writes the workbook.
+type cellEdits struct {
+	sheets map[string]map[int]map[int]string // sheet -> 0-based row -> 0-based column -> value
+}
+
+func newCellEdits() *cellEdits {
+	return &cellEdits{sheets: make(map[string]map[int]map[int]string)}
+}
+
+// stream makes the jobs of a task write to edits instead of the workbook.
+// Auto-save and highlighting work on the workbook and are turned off; the
+// checkpoint still allows --resume.
+func (t *fileTask) stream() {
+	t.edits = newCellEdits()
+	t.autosave = nil
+	t.highlight = nil
+	for i := range t.jobs {
+		t.jobs[i].edits = t.edits
+		t.jobs[i].autosave = nil
+	}
+}
+
+// sheetSample is the number of data rows configureSheet reads of a sheet to
+// detect the languages of its columns. The rest of the sheet is read by
+// loadRows once the jobs know their columns and whether they are streamed.
+var sheetSample = 1000
+
+// readSheet reads the header and up to sample data rows of sheet, and
+// counts its rows like GetRows would, header included. Rows are read one by
+// one, so the rest of the sheet is never held in memory.
+func readSheet(f *excelize.File, sheet string, sample int) (rows [][]string, count int, err error) {
+	it, err := f.Rows(sheet)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer it.Close()
+	for n := 1; it.Next(); n++ {
+		row, err := it.Columns()
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(row) > 0 {
+			count = n // trailing empty rows don't count
+		}
+		if n <= sample+1 {
+			rows = append(rows, row)
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, 0, err
+	}
+	return rows[:min(count, len(rows))], count, nil
+}
+
+// readColumns reads every row of sheet like GetRows, but keeps only the
+// cells of cols, the columns the jobs of a streamed sheet look at, and the
+// header. The other cells are left empty, so columns keep their indexes.
+func readColumns(f *excelize.File, sheet string, cols []int) ([][]string, error) {
+	it, err := f.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	width := slices.Max(cols) + 1
+	var rows [][]string
+	count := 0
+	for it.Next() {
+		row, err := it.Columns()
+		if err != nil {
+			return nil, err
+		}
+		if len(row) > 0 {
+			count = len(rows) + 1
+		}
+		if len(rows) == 0 {
+			rows = append(rows, row)
+			continue
+		}
+		kept := make([]string, min(len(row), width))
+		for _, col := range cols {
+			if col < len(kept) {
+				kept[col] = row[col]
+			}
+		}
+		rows = append(rows, kept)
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	return rows[:count], nil
+}
+
+// loadRows reads the rest of the sheets the jobs were set up from a sample
+// of: in full, or for a streamed task only the columns its jobs need.
+func (t *fileTask) loadRows() error {
+	cols := make(map[string][]int) // sheet -> columns its jobs read
+	for _, job := range t.jobs {
+		cols[job.sheetName] = append(cols[job.sheetName], job.sourceIndex, job.targetIndex)
+		if job.contextCol > 0 {
+			cols[job.sheetName] = append(cols[job.sheetName], job.contextCol-1)
+		}
+		if job.previous != nil {
+			cols[job.sheetName] = append(cols[job.sheetName], job.previous.keyCols...)
+		}
+	}
+	loaded := make(map[string][][]string)
+	for i := range t.jobs {
+		job := &t.jobs[i]
+		if len(job.rows) >= job.rowCount {
+			continue
+		}
+		rows, ok := loaded[job.sheetName]
+		if !ok {
+			var err error
+			if t.edits != nil {
+				rows, err = readColumns(t.f, job.sheetName, cols[job.sheetName])
+			} else {
+				rows, err = t.f.GetRows(job.sheetName)
+			}
+			if err != nil {
+				return fmt.Errorf("Error getting rows: %v", err)
+			}
+			loaded[job.sheetName] = rows
+		}
+		job.rows = rows
+	}
+	return nil
+}
+
+func (e *cellEdits) set(sheet string, row, col int, value string) {
+	rows := e.sheets[sheet]
+	if rows == nil {
+		rows = make(map[int]map[int]string)
+		e.sheets[sheet] = rows
+	}
+	if rows[row] == nil {
+		rows[row] = make(map[int]string)
+	}
+	rows[row][col] = value
+}
+
+func (e *cellEdits) get(sheet string, row, col int) (string, bool) {
+	value, ok := e.sheets[sheet][row][col]
+	return value, ok
+}
+
+// writeStreamed copies the XLSX workbook src to dst with edits applied.
+// Everything but the edited cells is copied byte for byte, so styles,
+// panes and column widths stay as TIA Portal wrote them.
+func writeStreamed(src, dst string, edits *cellEdits) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	paths, err := sheetPaths(&zr.Reader)
+	if err != nil {
+		return err
+	}
+	sheetAt := make(map[string]string) // part name -> sheet
+	for sheet := range edits.sheets {
+		p, ok := paths[sheet]
+		if !ok {
+			return fmt.Errorf("sheet %q not found in %s", sheet, src)
+		}
+		sheetAt[p] = sheet
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(out)
+	for _, f := range zr.File {
+		sheet, edited := sheetAt[f.Name]
+		if !edited {
+			if err = zw.Copy(f); err != nil {
+				break
+			}
+			continue
+		}
+		var w io.Writer
+		if w, err = zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: f.Modified}); err != nil {
+			break
+		}
+		var r io.ReadCloser
+		if r, err = f.Open(); err != nil {
+			break
+		}
+		err = patchSheet(w, r, edits.sheets[sheet])
+		r.Close()
+		if err != nil {
+			err = fmt.Errorf("sheet %s: %w", sheet, err)
+			break
+		}
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
+
+// sheetPaths maps the sheet names of a workbook to their parts in the
+// archive, e.g. "Sheet1" to "xl/worksheets/sheet1.xml".
+func sheetPaths(zr *zip.Reader) (map[string]string, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodePart(zr, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if err := decodePart(zr, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string)
+	for _, rel := range rels.Relationships {
+		// Targets are relative to xl/, or absolute within the archive.
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+	paths := make(map[string]string)
+	for _, s := range workbook.Sheets {
+		paths[s.Name] = targets[s.ID]
+	}
+	return paths, nil
+}
+
+func decodePart(zr *zip.Reader, name string, v any) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("not an XLSX workbook: %w", err)
+	}
+	defer f.Close()
+	if err := xml.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("could not read %s: %w", name, err)
+	}
+	return nil
+}
+
+// rawCopier keeps the bytes an xml.Decoder has read from r until they are
+// written on or dropped, so the parts of the input that don't change can be
+// copied as they are.
+type rawCopier struct {
+	r    io.Reader
+	buf  []byte
+	base int64 // input offset of buf[0]
+}
+
+func (c *rawCopier) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.buf = append(c.buf, p[:n]...)
+	return n, err
+}
+
+// copyTo writes the input up to offset off to w.
+func (c *rawCopier) copyTo(w io.Writer, off int64) error {
+	n := off - c.base
+	if _, err := w.Write(c.buf[:n]); err != nil {
+		return err
+	}
+	c.skipTo(off)
+	return nil
+}
+
+// skipTo drops the input up to offset off.
+func (c *rawCopier) skipTo(off int64) {
+	c.buf = c.buf[:copy(c.buf, c.buf[off-c.base:])]
+	c.base = off
+}
+
+// patchSheet copies the worksheet XML from r to w with the cells in rows
+// replaced, or inserted where the row has no such cell yet.
+func patchSheet(w io.Writer, r io.Reader, rows map[int]map[int]string) error {
+	c := &rawCopier{r: r}
+	d := xml.NewDecoder(c)
+	row, col := -1, -1
+	var pending []int // columns of the row still to be written, in order
+	var edits map[int]string
+	var prefix string // namespace prefix of the elements, mostly none
+
+	for {
+		start := d.InputOffset()
+		tok, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				// Everything before the row is done with; writing it on
+				// keeps no more than a row in memory.
+				if err := c.copyTo(w, start); err != nil {
+					return err
+				}
+				row, col = nextIndex(t, "r", row, func(s string) (int, error) { return strconv.Atoi(s) }), -1
+				edits, pending = rows[row], nil
+				for k := range edits {
+					pending = append(pending, k)
+				}
+				slices.Sort(pending)
+				prefix = t.Name.Space
+				// A row without cells has no source text and so no edits;
+				// <row/> has no place for them anyway.
+				if bytes.HasSuffix(c.buf[:d.InputOffset()-c.base], []byte("/>")) {
+					pending = nil
+				}
+			case "c":
+				col = nextIndex(t, "r", col, func(s string) (int, error) {
+					col, _, err := excelize.CellNameToCoordinates(s)
+					return col, err
+				})
+				for len(pending) > 0 && pending[0] <= col {
+					if err := c.copyTo(w, start); err != nil {
+						return err
+					}
+					style := ""
+					if pending[0] == col {
+						// Replace the cell, keeping its style.
+						style = attr(t, "s")
+						if err := skipElement(d); err != nil {
+							return err
+						}
+						c.skipTo(d.InputOffset())
+					}
+					if err := writeCell(w, prefix, row, pending[0], style, edits[pending[0]]); err != nil {
+						return err
+					}
+					pending = pending[1:]
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "row" && len(pending) > 0 {
+				if err := c.copyTo(w, start); err != nil {
+					return err
+				}
+				for _, k := range pending {
+					if err := writeCell(w, prefix, row, k, "", edits[k]); err != nil {
+						return err
+					}
+				}
+				pending = nil
+			}
+		}
+	}
+	_, err := w.Write(c.buf)
+	return err
+}
+
+// nextIndex returns the 0-based index an element's attribute gives, which
+// is 1-based, or the one after prev if it has none.
+func nextIndex(t xml.StartElement, name string, prev int, parse func(string) (int, error)) int {
+	if s := attr(t, name); s != "" {
+		if n, err := parse(s); err == nil {
+			return n - 1
+		}
+	}
+	return prev + 1
+}
+
+func attr(t xml.StartElement, name string) string {
+	for _, a := range t.Attr {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// skipElement reads up to the end of the element just started.
+func skipElement(d *xml.Decoder) error {
+	for depth := 1; depth > 0; {
+		tok, err := d.RawToken()
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return nil
+}
+
+// writeCell writes a cell with value as an inline string, which needs no
+// entry in the shared strings.
+func writeCell(w io.Writer, prefix string, row, col int, style, value string) error {
+	name := func(local string) string {
+		if prefix == "" {
+			return local
+		}
+		return prefix + ":" + local
+	}
+	ref, err := excelize.CoordinatesToCellName(col+1, row+1)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<%s r="%s"`, name("c"), ref)
+	if style != "" {
+		fmt.Fprintf(&b, ` s="%s"`, style)
+	}
+	if value == "" {
+		b.WriteString("/>")
+	} else {
+		fmt.Fprintf(&b, ` t="inlineStr"><%s><%s xml:space="preserve">`, name("is"), name("t"))
+		xml.EscapeText(&b, []byte(value))
+		fmt.Fprintf(&b, `</%s></%s></%s>`, name("t"), name("is"), name("c"))
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
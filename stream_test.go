@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestPatchSheet(t *testing.T) {
+	testCases := []struct {
+		name     string
+		sheet    string
+		rows     map[int]map[int]string
+		expected string
+	}{
+		{
+			"replace keeps the style",
+			`<sheetData><row r="2"><c r="A2" t="s"><v>0</v></c><c r="B2" s="3" t="s"><v>1</v></c></row></sheetData>`,
+			map[int]map[int]string{1: {1: "Pump on"}},
+			`<sheetData><row r="2"><c r="A2" t="s"><v>0</v></c><c r="B2" s="3" t="inlineStr"><is><t xml:space="preserve">Pump on</t></is></c></row></sheetData>`,
+		},
+		{
+			"insert in the middle and at the end",
+			`<sheetData><row r="1"><c r="A1"><v>1</v></c><c r="C1"><v>3</v></c></row></sheetData>`,
+			map[int]map[int]string{0: {1: "B", 3: "D & E"}},
+			`<sheetData><row r="1"><c r="A1"><v>1</v></c><c r="B1" t="inlineStr"><is><t xml:space="preserve">B</t></is></c><c r="C1"><v>3</v></c><c r="D1" t="inlineStr"><is><t xml:space="preserve">D &amp; E</t></is></c></row></sheetData>`,
+		},
+		{
+			"self-closing cell and empty value",
+			`<sheetData><row r="3"><c r="A3" s="1"/><c r="B3" s="2"/></row><row r="4"/></sheetData>`,
+			map[int]map[int]string{2: {0: "", 1: "x"}, 3: {1: "lost"}},
+			`<sheetData><row r="3"><c r="A3" s="1"/><c r="B3" s="2" t="inlineStr"><is><t xml:space="preserve">x</t></is></c></row><row r="4"/></sheetData>`,
+		},
+		{
+			"prefixed elements without references",
+			`<x:sheetData><x:row><x:c><x:v>1</x:v></x:c></x:row><x:row><x:c><x:v>2</x:v></x:c><x:c><x:v>3</x:v></x:c></x:row></x:sheetData>`,
+			map[int]map[int]string{1: {1: "three"}},
+			`<x:sheetData><x:row><x:c><x:v>1</x:v></x:c></x:row><x:row><x:c><x:v>2</x:v></x:c><x:c r="B2" t="inlineStr"><x:is><x:t xml:space="preserve">three</x:t></x:is></x:c></x:row></x:sheetData>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		var out strings.Builder
+		if err := patchSheet(&out, strings.NewReader(tc.sheet), tc.rows); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if out.String() != tc.expected {
+			t.Errorf("%s:\ngot      %s\nexpected %s", tc.name, out.String(), tc.expected)
+		}
+	}
+}
+
+// countingReader and countingWriter count the bytes that pass them.
+type countingReader struct {
+	r       io.Reader
+	n       *int
+	written *int
+	most    int // most bytes read but not yet written
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += n
+	c.most = max(c.most, *c.n-*c.written)
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *int
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	*c.n += len(p)
+	return c.w.Write(p)
+}
+
+func TestPatchSheetBuffersOneRow(t *testing.T) {
+	var sheet strings.Builder
+	sheet.WriteString("<sheetData>")
+	for i := 1; i <= 20000; i++ {
+		fmt.Fprintf(&sheet, `<row r="%d"><c r="A%d" t="inlineStr"><is><t>Motor %d fault</t></is></c></row>`, i, i, i)
+	}
+	sheet.WriteString("</sheetData>")
+
+	var read, written int
+	r := &countingReader{r: strings.NewReader(sheet.String()), n: &read, written: &written}
+	if err := patchSheet(countingWriter{w: io.Discard, n: &written}, r, map[int]map[int]string{1: {1: "Motorstörung"}}); err != nil {
+		t.Fatal(err)
+	}
+	if read != sheet.Len() {
+		t.Fatalf("read %d of %d bytes", read, sheet.Len())
+	}
+	// The decoder reads ahead a few kilobytes; the sheet has over a megabyte.
+	if r.most > 64<<10 {
+		t.Errorf("held up to %d bytes of a %d-byte sheet with a single edit", r.most, sheet.Len())
+	}
+}
+
+func TestRunTasksStreamed(t *testing.T) {
+	defer func(n int) { streamRows = n }(streamRows)
+	streamRows = 3
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "unified.xlsx")
+	f := excelize.NewFile()
+	f.NewSheet("Notes")
+	f.SetCellValue("Notes", "A1", "keep me")
+	f.SetSheetRow("Sheet1", "A1", &[]string{"ID", "de-DE", "en-US"})
+	f.SetSheetRow("Sheet1", "A2", &[]string{"1", "Pumpe läuft", ""})
+	f.SetSheetRow("Sheet1", "A3", &[]string{"2", "Ventil offen", "Valve open"})
+	f.SetSheetRow("Sheet1", "A4", &[]string{"3", "Pumpe läuft"})
+	f.SetColWidth("Sheet1", "C", "C", 42)
+	style, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	f.SetCellStyle("Sheet1", "C2", "C4", style)
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{workers: 2, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US"}
+	task, err := prepareFile(opts, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.edits == nil {
+		t.Fatal("the workbook is not streamed")
+	}
+	result := &runResult{}
+	runTasks(context.Background(), discardSender{}, &upperTranslator{}, []*fileTask{task}, opts, result)
+	if _, code := result.summary(); code != exitClean {
+		t.Fatalf("exit code %d", code)
+	}
+
+	out, err := excelize.OpenFile(filepath.Join(dir, "translated-unified.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	expected := map[string]string{"C2": "PUMPE LÄUFT", "C3": "VENTIL OFFEN", "C4": "PUMPE LÄUFT", "B2": "Pumpe läuft"}
+	for cell, want := range expected {
+		if got, _ := out.GetCellValue("Sheet1", cell); got != want {
+			t.Errorf("%s = %q; expected %q", cell, got, want)
+		}
+	}
+	if got, _ := out.GetCellStyle("Sheet1", "C4"); got != style {
+		t.Errorf("C4 has style %d; expected %d", got, style)
+	}
+	if width, _ := out.GetColWidth("Sheet1", "C"); width != 42 {
+		t.Errorf("column C is %v wide; expected 42", width)
+	}
+	if got, _ := out.GetCellValue("Notes", "A1"); got != "keep me" {
+		t.Errorf("Notes A1 = %q", got)
+	}
+}
+
+func TestPrepareFileStreamedColumns(t *testing.T) {
+	defer func(n, s int) { streamRows, sheetSample = n, s }(streamRows, sheetSample)
+	streamRows, sheetSample = 3, 1
+
+	path := filepath.Join(t.TempDir(), "unified.xlsx")
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]string{"ID", "de-DE", "en-US", "fr-FR", "Comment"})
+	f.SetSheetRow("Sheet1", "A2", &[]string{"1", "Pumpe läuft", "", "Pompe", "note"})
+	f.SetSheetRow("Sheet1", "A3", &[]string{"2", "Ventil offen", "Valve open", "Vanne", "note"})
+	f.SetSheetRow("Sheet1", "A4", &[]string{"3", "Motor", "", "", "note"})
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{workers: 1, checkpointEvery: 25, mode: "full", sourceCol: "de-DE", targetCol: "en-US"}
+	task, err := prepareFile(opts, path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.f.Close()
+	if task.edits == nil {
+		t.Fatal("the workbook is not streamed")
+	}
+	expected := [][]string{
+		{"ID", "de-DE", "en-US", "fr-FR", "Comment"},
+		{"", "Pumpe läuft", ""},
+		{"", "Ventil offen", "Valve open"},
+		{"", "Motor", ""},
+	}
+	if got := task.jobs[0].rows; !reflect.DeepEqual(got, expected) {
+		t.Errorf("rows = %q; expected only the source and target columns %q", got, expected)
+	}
+}
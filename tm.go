@@ -0,0 +1,175 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// ///////////////////
+// TRANSLATION MEMORY
+// ///////////////////
+
+// translationMemory is a SQLite database of every translation made, keyed by
+// source text and language pair, so later runs can reuse them for free.
+type translationMemory struct {
+	db *sql.DB
+}
+
+func openTM(path string) (*translationMemory, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open translation memory: %w", err)
+	}
+	// SQLite allows a single writer; one connection avoids "database is
+	// locked" errors when several workers store results at once.
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS translations (
+		source      TEXT NOT NULL,
+		source_lang TEXT NOT NULL,
+		target_lang TEXT NOT NULL,
+		translation TEXT NOT NULL,
+		updated_at  TEXT NOT NULL,
+		PRIMARY KEY (source, source_lang, target_lang)
+	);
+	CREATE TABLE IF NOT EXISTS embeddings (
+		source   TEXT NOT NULL,
+		embedder TEXT NOT NULL,
+		vector   BLOB NOT NULL,
+		PRIMARY KEY (source, embedder)
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not initialize translation memory: %w", err)
+	}
+	return &translationMemory{db: db}, nil
+}
+
+func (tm *translationMemory) Close() error {
+	return tm.db.Close()
+}
+
+// normalizeLang turns a column header into the language key stored in the
+// memory, dropping the "*" TIA uses to mark the reference language.
+func normalizeLang(header string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(header), "*"))
+}
+
+func (tm *translationMemory) lookup(source, sourceLang, targetLang string) (string, bool, error) {
+	var translation string
+	err := tm.db.QueryRow(
+		`SELECT translation FROM translations WHERE source = ? AND source_lang = ? AND target_lang = ?`,
+		source, normalizeLang(sourceLang), normalizeLang(targetLang),
+	).Scan(&translation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return translation, true, nil
+}
+
+// entries lists stored translations, optionally filtered by language.
+func (tm *translationMemory) entries(sourceLang, targetLang string) ([]tmEntry, error) {
+	query := `SELECT source, source_lang, target_lang, translation, updated_at FROM translations WHERE 1 = 1`
+	var args []any
+	if sourceLang != "" {
+		query += ` AND source_lang = ?`
+		args = append(args, normalizeLang(sourceLang))
+	}
+	if targetLang != "" {
+		query += ` AND target_lang = ?`
+		args = append(args, normalizeLang(targetLang))
+	}
+	rows, err := tm.db.Query(query+` ORDER BY source_lang, target_lang, source`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []tmEntry
+	for rows.Next() {
+		var e tmEntry
+		if err := rows.Scan(&e.source, &e.sourceLang, &e.targetLang, &e.translation, &e.updatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// importEntries stores many entries in one transaction, replacing existing
+// translations of the same source.
+func (tm *translationMemory) importEntries(entries []tmEntry) error {
+	tx, err := tm.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, err := tx.Exec(upsertTranslation, e.source, normalizeLang(e.sourceLang), normalizeLang(e.targetLang), e.translation, e.updatedAt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const upsertTranslation = `INSERT INTO translations (source, source_lang, target_lang, translation, updated_at)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (source, source_lang, target_lang) DO UPDATE SET translation = excluded.translation, updated_at = excluded.updated_at`
+
+func (tm *translationMemory) store(source, sourceLang, targetLang, translation string) error {
+	_, err := tm.db.Exec(upsertTranslation,
+		source, normalizeLang(sourceLang), normalizeLang(targetLang), translation, time.Now().UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// loadEmbeddings returns the cached vectors of an embedder by source text.
+func (tm *translationMemory) loadEmbeddings(embedder string) (map[string][]float32, error) {
+	rows, err := tm.db.Query(`SELECT source, vector FROM embeddings WHERE embedder = ?`, embedder)
+	if err != nil {
+		return nil, fmt.Errorf("could not read embeddings: %w", err)
+	}
+	defer rows.Close()
+	vectors := make(map[string][]float32)
+	for rows.Next() {
+		var source string
+		var blob []byte
+		if err := rows.Scan(&source, &blob); err != nil {
+			return nil, err
+		}
+		v := make([]float32, len(blob)/4)
+		for i := range v {
+			v[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+		}
+		vectors[source] = v
+	}
+	return vectors, rows.Err()
+}
+
+// storeEmbeddings caches the vectors of sources in one transaction.
+func (tm *translationMemory) storeEmbeddings(embedder string, sources []string, vectors [][]float32) error {
+	tx, err := tm.db.Begin()
+	if err != nil {
+		return err
+	}
+	for i, source := range sources {
+		blob := make([]byte, 4*len(vectors[i]))
+		for j, x := range vectors[i] {
+			binary.LittleEndian.PutUint32(blob[4*j:], math.Float32bits(x))
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO embeddings (source, embedder, vector) VALUES (?, ?, ?)`, source, embedder, blob); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTranslationMemory(t *testing.T) {
+	tm, err := openTM(filepath.Join(t.TempDir(), "tm.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tm.Close()
+
+	if err := tm.store("Motor fault", "de-DE*", "en-US", "Motorstörung"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.store("Motor fault", "de-DE", "en-US", "Motor fault"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := tm.lookup("Motor fault", "DE-de", "en-us")
+	if err != nil || !ok || got != "Motor fault" {
+		t.Errorf("lookup = %q, %t, %v; expected %q, true, nil", got, ok, err, "Motor fault")
+	}
+	if _, ok, _ := tm.lookup("Motor fault", "de-DE", "fr-FR"); ok {
+		t.Errorf("lookup for another target language should miss")
+	}
+}
+
+func TestTranslationMemoryInPipeline(t *testing.T) {
+	tm, err := openTM(filepath.Join(t.TempDir(), "tm.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tm.Close()
+	tm.store("Pump running", "de-DE", "en-US", "from memory")
+
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Pump running", ""},
+		{"Valve open", ""},
+	}
+	job := newTestJob(t, rows)
+	job.tm = tm
+	translator := &upperTranslator{}
+
+	iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+	if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != "from memory" {
+		t.Errorf("B2 = %q; expected the memory hit", got)
+	}
+	if len(translator.calls) != 1 || translator.calls[0] != "Valve open" {
+		t.Errorf("translator calls = %v; expected only %q", translator.calls, "Valve open")
+	}
+	if got, ok, _ := tm.lookup("Valve open", "de-DE", "en-US"); !ok || got != "VALVE OPEN" {
+		t.Errorf("new translation not stored: %q, %t", got, ok)
+	}
+}
+
+func TestTranslationMemoryHitChecked(t *testing.T) {
+	tm, err := openTM(filepath.Join(t.TempDir(), "tm.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tm.Close()
+	// Entries with broken placeholders are not stored by a run, but may come
+	// from an older version or an import.
+	tm.store("Motor <tag>1</tag> gestört", "de-DE", "en-US", "Motor fault")
+
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"Motor <tag>1</tag> gestört", ""},
+	}
+	job := newTestJob(t, rows)
+	job.tm = tm
+	job.report = &fileReport{}
+
+	iterateAndTranslate(context.Background(), discardSender{}, &upperTranslator{}, job)
+
+	if len(job.report.entries) != 1 {
+		t.Fatalf("report has %d entries; expected 1", len(job.report.entries))
+	}
+	e := job.report.entries[0]
+	if e.status != rowMemory || !slices.ContainsFunc(e.flags, func(f string) bool { return strings.HasPrefix(f, "placeholders changed") }) {
+		t.Errorf("memory hit: status %v, flags %q; expected a placeholder finding", e.status, e.flags)
+	}
+}
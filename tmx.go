@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ///////////////////
+// TMX IMPORT/EXPORT
+// ///////////////////
+
+// tmEntry is one stored translation.
+type tmEntry struct {
+	source      string
+	sourceLang  string
+	targetLang  string
+	translation string
+	updatedAt   string
+}
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+type tmxDocument struct {
+	XMLName xml.Name  `xml:"tmx"`
+	Version string    `xml:"version,attr"`
+	Header  tmxHeader `xml:"header"`
+	Units   []tmxUnit `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTmf                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tmxUnit struct {
+	ChangeDate string       `xml:"changedate,attr,omitempty"`
+	Variants   []tmxVariant `xml:"tuv"`
+}
+
+type tmxVariant struct {
+	Lang    string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	OldLang string `xml:"lang,attr,omitempty"` // TMX 1.1 style, read only
+	Seg     tmxSeg `xml:"seg"`
+}
+
+func (v tmxVariant) lang() string {
+	if v.Lang != "" {
+		return v.Lang
+	}
+	return v.OldLang
+}
+
+// tmxSeg is segment text. Inline markup from CAT tools (<ph>, <bpt>, ...) is
+// flattened to its text content, which holds the original native codes.
+type tmxSeg struct {
+	Text string `xml:",chardata"`
+}
+
+func (s *tmxSeg) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var b strings.Builder
+	depth := 1
+	for depth > 0 {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			b.Write(t)
+		}
+	}
+	s.Text = b.String()
+	return nil
+}
+
+// canonicalLang formats a stored language key for TMX, e.g. "de-de" as
+// "de-DE".
+func canonicalLang(lang string) string {
+	parts := strings.SplitN(lang, "-", 2)
+	if len(parts) == 2 && len(parts[1]) == 2 {
+		return strings.ToLower(parts[0]) + "-" + strings.ToUpper(parts[1])
+	}
+	return lang
+}
+
+// writeTMX writes entries as a TMX 1.4 document.
+func writeTMX(w io.Writer, entries []tmEntry) error {
+	doc := tmxDocument{
+		Version: "1.4",
+		Header: tmxHeader{
+			CreationTool:        "TIA Text Translator",
+			CreationToolVersion: getVersion(),
+			SegType:             "sentence",
+			OTmf:                "sqlite",
+			AdminLang:           "en-US",
+			SrcLang:             "*all*",
+			DataType:            "plaintext",
+		},
+	}
+	srcLangs := make(map[string]bool)
+	for _, e := range entries {
+		srcLangs[e.sourceLang] = true
+		unit := tmxUnit{Variants: []tmxVariant{
+			{Lang: canonicalLang(e.sourceLang), Seg: tmxSeg{Text: e.source}},
+			{Lang: canonicalLang(e.targetLang), Seg: tmxSeg{Text: e.translation}},
+		}}
+		if t, err := time.Parse(time.RFC3339, e.updatedAt); err == nil {
+			unit.ChangeDate = t.UTC().Format("20060102T150405Z")
+		}
+		doc.Units = append(doc.Units, unit)
+	}
+	if len(srcLangs) == 1 {
+		for lang := range srcLangs {
+			doc.Header.SrcLang = canonicalLang(lang)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// readTMX reads translation pairs from a TMX document. Each unit's source is
+// the variant in the header's source language (or the first variant when the
+// header says "*all*"); every other variant becomes one entry.
+func readTMX(r io.Reader) ([]tmEntry, error) {
+	var doc tmxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("could not parse TMX: %w", err)
+	}
+	var entries []tmEntry
+	for _, unit := range doc.Units {
+		if len(unit.Variants) < 2 {
+			continue
+		}
+		src := 0
+		for i, v := range unit.Variants {
+			if strings.EqualFold(v.lang(), doc.Header.SrcLang) {
+				src = i
+				break
+			}
+		}
+		source := unit.Variants[src]
+		updatedAt := time.Now().UTC().Format(time.RFC3339)
+		if t, err := time.Parse("20060102T150405Z", unit.ChangeDate); err == nil {
+			updatedAt = t.Format(time.RFC3339)
+		}
+		for i, v := range unit.Variants {
+			if i == src || strings.TrimSpace(v.Seg.Text) == "" || strings.TrimSpace(source.Seg.Text) == "" {
+				continue
+			}
+			entries = append(entries, tmEntry{
+				source:      source.Seg.Text,
+				sourceLang:  normalizeLang(source.lang()),
+				targetLang:  normalizeLang(v.lang()),
+				translation: v.Seg.Text,
+				updatedAt:   updatedAt,
+			})
+		}
+	}
+	return entries, nil
+}
+
+// runTMCommand implements "tm export" and "tm import".
+func runTMCommand(args []string) error {
+	usage := "usage: tm export [--tm FILE] [--source LANG] [--target LANG] OUT.tmx | tm import [--tm FILE] IN.tmx"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	fs := flag.NewFlagSet("tm "+args[0], flag.ExitOnError)
+	tmPath := fs.String("tm", "translation-memory.db", "Translation memory file.")
+	sourceLang := fs.String("source", "", "Only export entries with this source language.")
+	targetLang := fs.String("target", "", "Only export entries with this target language.")
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		return errors.New(usage)
+	}
+	path := fs.Arg(0)
+
+	tm, err := openTM(*tmPath)
+	if err != nil {
+		return err
+	}
+	defer tm.Close()
+
+	switch args[0] {
+	case "export":
+		entries, err := tm.entries(*sourceLang, *targetLang)
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := writeTMX(out, entries); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d entries to %s\n", len(entries), path)
+	case "import":
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		entries, err := readTMX(in)
+		if err != nil {
+			return err
+		}
+		if err := tm.importEntries(entries); err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d entries into %s\n", len(entries), *tmPath)
+	default:
+		return errors.New(usage)
+	}
+	return nil
+}
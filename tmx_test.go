@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTMXRoundTrip(t *testing.T) {
+	entries := []tmEntry{
+		{source: "Motorstörung", sourceLang: "de-de", targetLang: "en-us", translation: "Motor fault", updatedAt: "2025-03-01T10:00:00Z"},
+		{source: "Pumpe <an> & aus", sourceLang: "de-de", targetLang: "fr-fr", translation: "Pompe <marche> & arrêt", updatedAt: "2025-03-01T10:00:00Z"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeTMX(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `xml:lang="de-DE"`) {
+		t.Errorf("expected xml:lang attributes in output:\n%s", out)
+	}
+	if !strings.Contains(out, `srclang="de-DE"`) {
+		t.Errorf("expected srclang de-DE in header:\n%s", out)
+	}
+
+	got, err := readTMX(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("read %d entries; expected %d", len(got), len(entries))
+	}
+	for i := range entries {
+		if got[i] != entries[i] {
+			t.Errorf("entry %d = %+v; expected %+v", i, got[i], entries[i])
+		}
+	}
+}
+
+func TestReadTMXInlineMarkup(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+  <header creationtool="Trados" segtype="sentence" o-tmf="x" adminlang="en-US" srclang="en-US" datatype="plaintext"/>
+  <body>
+    <tu>
+      <tuv xml:lang="de-DE"><seg>Ventil <ph>{1}</ph> offen</seg></tuv>
+      <tuv xml:lang="en-US"><seg>Valve <ph>{1}</ph> open</seg></tuv>
+    </tu>
+  </body>
+</tmx>`
+
+	got, err := readTMX(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("read %d entries; expected 1", len(got))
+	}
+	e := got[0]
+	if e.source != "Valve {1} open" || e.sourceLang != "en-us" || e.targetLang != "de-de" || e.translation != "Ventil {1} offen" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
@@ -5,48 +5,6 @@ import (
 	"testing"
 )
 
-func TestIsVisualSeparator(t *testing.T) {
-	testCases := []struct {
-		input    string
-		expected bool
-	}{
-		// Should be true - mostly separators
-		{"---------------------------------------------", true},
-		{"=============================================", true},
-		{"_____________________________________________", true},
-		{"*********************************************", true},
-		{".............................................", true},
-		{"-----", true},
-		{"=====", true},
-		{"_____", true},
-
-		// Should be false - too short
-		{"-", false},
-		{"--", false},
-		{"==", false},
-		{"__", false},
-
-		// Should be false - not mostly separators
-		{"Hello world", false},
-		{"Some-text-with-dashes", false},
-		{"Text with underscores_here", false},
-		{"123-456-789", false},
-		{"A-B-C-D-E", false},
-
-		// Edge cases - mixed but mostly separators
-		{"---------------------------------------------text", true}, // still more than 80% separators
-		{"text---------------------------------------------", true}, // still more than 80% separators
-		{"-----text-----", false},                                   // 10/13 = 0.77 < 0.8
-	}
-
-	for _, tc := range testCases {
-		result := isVisualSeparator(tc.input)
-		if result != tc.expected {
-			t.Errorf("isVisualSeparator(%q) = %t; expected %t", tc.input, result, tc.expected)
-		}
-	}
-}
-
 func TestQuickModeLogic(t *testing.T) {
 	// Test the logic that determines whether to translate in quick mode
 	testCases := []struct {
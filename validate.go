@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ///////////////////
+// RE-IMPORT CHECK
+// ///////////////////
+
+// TIA only imports a workbook laid out exactly like its export, and says so
+// late and vaguely. "validate" checks the translated workbook first: language
+// headers on its own, and with the original export at hand also the column
+// order, the rows and the columns a translation must not touch.
+
+// maxChangedCells caps the changed cells listed per sheet.
+const maxChangedCells = 20
+
+// headerProblems checks the header row of a TIA sheet: every column after the
+// metadata must be a culture code such as "en-US" or a "ref=" column, and
+// only one may be marked as reference language.
+func headerProblems(headers []string, metaCols int) []string {
+	var problems, marked []string
+	languages := 0
+	for i := metadataColumns(headers, FileTypeTIA, metaCols); i < len(headers); i++ {
+		h := strings.TrimSpace(headers[i])
+		switch {
+		case h == "":
+			problems = append(problems, fmt.Sprintf("column %d has no header", i+1))
+		case strings.HasPrefix(strings.ToLower(h), "ref="):
+		case !isCultureCode(h):
+			problems = append(problems, fmt.Sprintf("column %d header %q is not a language code such as en-US", i+1, h))
+		default:
+			languages++
+			if strings.HasSuffix(h, "*") {
+				marked = append(marked, h)
+			}
+		}
+	}
+	if languages == 0 {
+		problems = append(problems, "no column has a language code header")
+	}
+	if len(marked) > 1 {
+		problems = append(problems, fmt.Sprintf("several columns are marked as reference language: %s", strings.Join(marked, ", ")))
+	}
+	return problems
+}
+
+// protectedColumn reports whether column col must be the same as in the
+// export: metadata, Rockwell and TIA references, and the reference language.
+func protectedColumn(headers []string, fileType FileType, metaCols, col int) bool {
+	if col < metadataColumns(headers, fileType, metaCols) {
+		return true
+	}
+	h := strings.TrimSpace(headers[col])
+	return strings.HasPrefix(strings.ToLower(h), "ref=") || strings.HasSuffix(h, "*")
+}
+
+// compareSheet lists how the rows of a translated sheet differ from the
+// export in ways the import cannot cope with.
+func compareSheet(rows, original [][]string, metaCols int) []string {
+	if len(original) == 0 {
+		return nil
+	}
+	var problems []string
+	headers, exported := rows[0], original[0]
+	for i := 0; i < max(len(headers), len(exported)); i++ {
+		var h, e string
+		if i < len(headers) {
+			h = headers[i]
+		}
+		if i < len(exported) {
+			e = exported[i]
+		}
+		if h != e {
+			problems = append(problems, fmt.Sprintf("column %d header is %q, the export had %q", i+1, h, e))
+		}
+	}
+	if len(problems) > 0 {
+		// With the columns moved, comparing cells says nothing more.
+		return problems
+	}
+
+	switch {
+	case len(rows) < len(original):
+		problems = append(problems, fmt.Sprintf("%d rows deleted, the export had %d", len(original)-len(rows), len(original)))
+	case len(rows) > len(original):
+		problems = append(problems, fmt.Sprintf("%d rows added, the export had %d", len(rows)-len(original), len(original)))
+	}
+
+	fileType := detectFileType(exported)
+	changed := 0
+	for r := 1; r < min(len(rows), len(original)); r++ {
+		for c := range exported {
+			if !protectedColumn(exported, fileType, metaCols, c) {
+				continue
+			}
+			var value, want string
+			if c < len(rows[r]) {
+				value = rows[r][c]
+			}
+			if c < len(original[r]) {
+				want = original[r][c]
+			}
+			if value == want {
+				continue
+			}
+			if changed++; changed <= maxChangedCells {
+				problems = append(problems, fmt.Sprintf("row %d column %d (%s) is %q, the export had %q", r+1, c+1, exported[c], value, want))
+			}
+		}
+	}
+	if changed > maxChangedCells {
+		problems = append(problems, fmt.Sprintf("%d more changed cells in columns that must stay as exported", changed-maxChangedCells))
+	}
+	return problems
+}
+
+// validateWorkbook checks every sheet of f, against original if it is not
+// nil. Problems are prefixed with their sheet.
+func validateWorkbook(f, original *excelize.File, metaCols int) ([]string, error) {
+	var problems []string
+	add := func(sheet string, found []string) {
+		for _, p := range found {
+			problems = append(problems, fmt.Sprintf("%s: %s", sheet, p))
+		}
+	}
+
+	sheets := f.GetSheetList()
+	if original != nil {
+		for _, sheet := range original.GetSheetList() {
+			if idx, _ := f.GetSheetIndex(sheet); idx == -1 {
+				add(sheet, []string{"sheet is missing"})
+			}
+		}
+	}
+	for _, sheet := range sheets {
+		rows, err := f.GetRows(sheet)
+		if err != nil {
+			return nil, fmt.Errorf("could not read sheet %s: %w", sheet, err)
+		}
+		if len(rows) == 0 {
+			add(sheet, []string{"sheet is empty"})
+			continue
+		}
+		if isErrorsSheet(rows) {
+			add(sheet, []string{fmt.Sprintf("lists %d rows that failed to translate; fix them and delete the sheet before importing", len(rows)-1)})
+			continue
+		}
+		if isReviewSheet(rows) {
+			add(sheet, []string{"is the review sheet of --review-sheet; delete it before importing"})
+			continue
+		}
+		if detectFileType(rows[0]) == FileTypeTIA {
+			add(sheet, headerProblems(rows[0], metaCols))
+		}
+		if original == nil {
+			continue
+		}
+		if idx, _ := original.GetSheetIndex(sheet); idx == -1 {
+			add(sheet, []string{"sheet is not in the export"})
+			continue
+		}
+		exported, err := original.GetRows(sheet)
+		if err != nil {
+			return nil, fmt.Errorf("could not read sheet %s of the export: %w", sheet, err)
+		}
+		add(sheet, compareSheet(rows, exported, metaCols))
+	}
+	return problems, nil
+}
+
+// defaultOriginal returns the export a translated- workbook was made from, if
+// it is still next to it.
+func defaultOriginal(fileName string) string {
+	base := filepath.Base(fileName)
+	if !strings.HasPrefix(base, "translated-") {
+		return ""
+	}
+	original := filepath.Join(filepath.Dir(fileName), strings.TrimPrefix(base, "translated-"))
+	if _, err := os.Stat(original); err != nil {
+		return ""
+	}
+	return original
+}
+
+// runValidateCommand implements "validate".
+func runValidateCommand(args []string) error {
+	usage := "usage: validate [--original EXPORT.xlsx] [--meta-cols N] WORKBOOK.xlsx"
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	originalPath := fs.String("original", "", "The workbook exported from TIA (default: WORKBOOK without its translated- prefix, if present).")
+	metaCols := fs.Int("meta-cols", -1, "Number of leading metadata columns; -1 detects them.")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New(usage)
+	}
+	fileName := fs.Arg(0)
+	if *originalPath == "" {
+		*originalPath = defaultOriginal(fileName)
+	}
+
+	f, err := excelize.OpenFile(fileName)
+	if err != nil {
+		return fmt.Errorf("Error opening file: %v", err)
+	}
+	defer f.Close()
+	var original *excelize.File
+	if *originalPath != "" {
+		if original, err = excelize.OpenFile(*originalPath); err != nil {
+			return fmt.Errorf("Error opening original: %v", err)
+		}
+		defer original.Close()
+		fmt.Printf("Comparing %s with %s\n", fileName, *originalPath)
+	} else {
+		fmt.Println("No original export given (--original); rows and reference columns are not checked")
+	}
+
+	problems, err := validateWorkbook(f, original, *metaCols)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found; TIA will likely reject the import", len(problems))
+	}
+	fmt.Printf("%s looks ready for import into TIA (%d sheets checked)\n", fileName, len(f.GetSheetList()))
+	return nil
+}
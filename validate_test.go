@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestHeaderProblems(t *testing.T) {
+	testCases := []struct {
+		headers []string
+		want    []string // substrings, one per problem
+	}{
+		{[]string{"Text list", "ID", "Reference", "Comment", "de-DE*", "en-US", "ref=Alarm"}, nil},
+		{[]string{"ID", "de-DE", "English"}, []string{`"English" is not a language code`}},
+		{[]string{"ID", "de-DE", ""}, []string{"column 3 has no header"}},
+		{[]string{"ID", "de-DE*", "en-US*"}, []string{"several columns are marked"}},
+		{[]string{"ID", "German", "English"}, []string{"no column has a language code header"}},
+	}
+
+	for _, tc := range testCases {
+		got := headerProblems(tc.headers, -1)
+		if len(got) != len(tc.want) {
+			t.Errorf("headerProblems(%q) = %q; expected %d problems", tc.headers, got, len(tc.want))
+			continue
+		}
+		for i, want := range tc.want {
+			if !strings.Contains(got[i], want) {
+				t.Errorf("headerProblems(%q)[%d] = %q; expected it to contain %q", tc.headers, i, got[i], want)
+			}
+		}
+	}
+}
+
+func TestCompareSheet(t *testing.T) {
+	original := [][]string{
+		{"Text list", "ID", "Reference", "Comment", "de-DE*", "en-US"},
+		{"Alarms", "1", "", "", "Motorstörung", ""},
+		{"Alarms", "2", "", "", "Pumpe läuft", ""},
+	}
+	clone := func() [][]string {
+		rows := make([][]string, len(original))
+		for i, row := range original {
+			rows[i] = append([]string(nil), row...)
+		}
+		return rows
+	}
+
+	translated := clone()
+	translated[1][5] = "Motor fault"
+	translated[2][5] = "Pump running"
+	if got := compareSheet(translated, original, -1); len(got) != 0 {
+		t.Errorf("translated target column: %q; expected no problems", got)
+	}
+
+	deleted := clone()[:2]
+	if got := compareSheet(deleted, original, -1); len(got) != 1 || !strings.Contains(got[0], "1 rows deleted") {
+		t.Errorf("deleted row: %q", got)
+	}
+
+	changed := clone()
+	changed[2][1] = "3"
+	changed[1][4] = "Motorfehler"
+	got := compareSheet(changed, original, -1)
+	if len(got) != 2 || !strings.Contains(got[0], "row 2 column 5 (de-DE*)") || !strings.Contains(got[1], "row 3 column 2 (ID)") {
+		t.Errorf("changed reference cells: %q", got)
+	}
+
+	moved := clone()
+	moved[0][4], moved[0][5] = moved[0][5], moved[0][4]
+	if got := compareSheet(moved, original, -1); len(got) != 2 || !strings.Contains(got[0], "column 5 header") {
+		t.Errorf("swapped columns: %q", got)
+	}
+}
+
+func TestValidateWorkbook(t *testing.T) {
+	dir := t.TempDir()
+	exportPath := filepath.Join(dir, "export.xlsx")
+	export := excelize.NewFile()
+	export.SetSheetRow("Sheet1", "A1", &[]string{"ID", "de-DE*", "en-US"})
+	export.SetSheetRow("Sheet1", "A2", &[]string{"1", "Ventil", ""})
+	export.NewSheet("Alarms")
+	export.SetSheetRow("Alarms", "A1", &[]string{"ID", "de-DE*", "en-US"})
+	if err := export.SaveAs(exportPath); err != nil {
+		t.Fatal(err)
+	}
+
+	translatedPath := filepath.Join(dir, "translated-export.xlsx")
+	translated := excelize.NewFile()
+	translated.SetSheetRow("Sheet1", "A1", &[]string{"ID", "de-DE*", "en-US"})
+	translated.SetSheetRow("Sheet1", "A2", &[]string{"1", "Ventil", "Valve"})
+	if err := translated.SaveAs(translatedPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := defaultOriginal(translatedPath); got != exportPath {
+		t.Errorf("defaultOriginal() = %q; expected %q", got, exportPath)
+	}
+	if got := defaultOriginal(exportPath); got != "" {
+		t.Errorf("defaultOriginal(%q) = %q; expected none", exportPath, got)
+	}
+	os.Remove(exportPath)
+	if got := defaultOriginal(translatedPath); got != "" {
+		t.Errorf("defaultOriginal() without the export = %q; expected none", got)
+	}
+
+	problems, err := validateWorkbook(translated, export, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0] != "Alarms: sheet is missing" {
+		t.Errorf("validateWorkbook() = %q; expected the missing sheet only", problems)
+	}
+	if problems, _ := validateWorkbook(translated, nil, -1); len(problems) != 0 {
+		t.Errorf("validateWorkbook() without export = %q; expected no problems", problems)
+	}
+}
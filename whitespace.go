@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ///////////////////
+// WHITESPACE
+// ///////////////////
+
+// TIA Portal and WinCC lay out multi-line texts with line breaks, leading
+// spaces and runs of spaces, and a panel shows them as they are. Source
+// texts are trimmed for the translator and the translation memory; the
+// whitespace around them is put back around the translation, line breaks
+// get the style of the source, and a translation that lost line breaks or
+// runs of spaces is flagged.
+
+// padding returns the whitespace raw, a cell as read, starts and ends with.
+func padding(raw string) (lead, trail string) {
+	trimmed := strings.TrimLeftFunc(raw, unicode.IsSpace)
+	lead = raw[:len(raw)-len(trimmed)]
+	if trimmed == "" {
+		return lead, ""
+	}
+	trail = trimmed[len(strings.TrimRightFunc(trimmed, unicode.IsSpace)):]
+	return lead, trail
+}
+
+// restoreLineBreaks trims whitespace the model added around translation and
+// gives its line breaks the style of source: CR LF, as Windows tools write
+// them, or LF.
+func restoreLineBreaks(source, translation string) string {
+	translation = strings.TrimFunc(translation, unicode.IsSpace)
+	if !strings.ContainsAny(source, "\r\n") {
+		return translation
+	}
+	normalized := strings.ReplaceAll(strings.ReplaceAll(translation, "\r\n", "\n"), "\r", "\n")
+	switch {
+	case strings.Contains(source, "\r\n"):
+		return strings.ReplaceAll(normalized, "\n", "\r\n")
+	case strings.Contains(source, "\n"):
+		return normalized
+	}
+	return translation
+}
+
+// spaceRunRegex matches two or more spaces or tabs in a row.
+var spaceRunRegex = regexp.MustCompile(`[ \t]{2,}`)
+
+// whitespaceFlags lists the line breaks and runs of spaces of source that
+// translation does not have the same number of.
+func whitespaceFlags(source, translation string) []string {
+	var flags []string
+	lineBreaks := func(s string) int {
+		return strings.Count(strings.ReplaceAll(s, "\r\n", "\n"), "\n") + strings.Count(strings.ReplaceAll(s, "\r\n", ""), "\r")
+	}
+	if want, got := lineBreaks(source), lineBreaks(translation); want != got {
+		flags = append(flags, fmt.Sprintf("line breaks changed: %d in the source, %d in the translation", want, got))
+	}
+	if want, got := len(spaceRunRegex.FindAllString(source, -1)), len(spaceRunRegex.FindAllString(translation, -1)); want != got {
+		flags = append(flags, fmt.Sprintf("runs of spaces changed: %d in the source, %d in the translation", want, got))
+	}
+	return flags
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPadding(t *testing.T) {
+	tests := []struct{ raw, lead, trail string }{
+		{"Motor", "", ""},
+		{"  Motor on ", "  ", " "},
+		{"\r\nMotor\r\n", "\r\n", "\r\n"},
+		{"   ", "   ", ""},
+	}
+	for _, tt := range tests {
+		if lead, trail := padding(tt.raw); lead != tt.lead || trail != tt.trail {
+			t.Errorf("padding(%q) = %q, %q, want %q, %q", tt.raw, lead, trail, tt.lead, tt.trail)
+		}
+	}
+}
+
+func TestRestoreLineBreaks(t *testing.T) {
+	tests := []struct{ source, translation, want string }{
+		{"Motor\r\nan", "Motor\non", "Motor\r\non"},
+		{"Motor\r\nan", "Motor\r\non", "Motor\r\non"},
+		{"Motor\nan", "Motor\r\non", "Motor\non"},
+		{"Motor an", " Motor on\n", "Motor on"},
+	}
+	for _, tt := range tests {
+		if got := restoreLineBreaks(tt.source, tt.translation); got != tt.want {
+			t.Errorf("restoreLineBreaks(%q, %q) = %q, want %q", tt.source, tt.translation, got, tt.want)
+		}
+	}
+}
+
+func TestWhitespaceFlags(t *testing.T) {
+	tests := []struct {
+		source, translation string
+		want                []string
+	}{
+		{"Motor\r\nan", "Motor\r\non", nil},
+		{"Motor\r\nan", "Motor on", []string{"line breaks changed: 1 in the source, 0 in the translation"}},
+		{"Ist:  10  Soll: 20", "Actual: 10 Set: 20", []string{"runs of spaces changed: 2 in the source, 0 in the translation"}},
+	}
+	for _, tt := range tests {
+		if got := whitespaceFlags(tt.source, tt.translation); strings.Join(got, ";") != strings.Join(tt.want, ";") {
+			t.Errorf("whitespaceFlags(%q, %q) = %q, want %q", tt.source, tt.translation, got, tt.want)
+		}
+	}
+}
+
+// lfTranslator upper-cases like a model that writes LF line breaks and
+// single spaces.
+type lfTranslator struct{}
+
+func (lfTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ToUpper(strings.Join(strings.Fields(text), " ")), nil
+}
+
+func TestWhitespaceKept(t *testing.T) {
+	rows := [][]string{
+		{"de-DE", "en-US"},
+		{"  Motor läuft ", ""},
+		{"Zeile eins\r\nZeile zwei", ""},
+		{"Ist:  10", ""},
+		{"\t#1#\r\n", ""},
+	}
+	job := newTestJob(t, rows)
+	job.report = &fileReport{}
+
+	iterateAndTranslate(context.Background(), discardSender{}, lfTranslator{}, job)
+
+	want := map[string]string{
+		"B2": "  MOTOR LÄUFT ",
+		"B3": "ZEILE EINS ZEILE ZWEI",
+		"B4": "IST: 10",
+		"B5": "\t#1#\r\n", // copied as it is
+	}
+	for cell, w := range want {
+		if got, _ := job.f.GetCellValue(job.sheetName, cell); got != w {
+			t.Errorf("%s = %q, want %q", cell, got, w)
+		}
+	}
+	flags := make(map[int]string)
+	for _, e := range job.report.entries {
+		flags[e.row] = strings.Join(e.flags, ";")
+	}
+	if !strings.Contains(flags[3], "line breaks changed") || !strings.Contains(flags[4], "runs of spaces changed") || flags[2] != "" {
+		t.Errorf("flags = %q", flags)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+// ///////////////////
+// WRONG-LANGUAGE REPLIES
+// ///////////////////
+
+// Asked for a short string, a model sometimes answers in English, or hands
+// the source text back, whatever the target language. Replies are run
+// through the language detection; one that is clearly in another language
+// than the target is asked for once more, and flagged if it still is.
+
+// wrongLanguage returns the name of the language translation is written in
+// if that is clearly not the target language of j, or "" if it is or cannot
+// be told.
+func (j *translationJob) wrongLanguage(translation string) string {
+	got := detectTextLanguage(translation)
+	if !contradicts(headerLanguage(j.targetLang), got) {
+		return ""
+	}
+	return languageNames[got]
+}
+
+// wrongLanguageInstructions insists on the target language after a reply
+// in language, the name of another one.
+func wrongLanguageInstructions(language string) string {
+	if language == "" {
+		return ""
+	}
+	return " A previous reply was in " + language + " instead of the target language. Write the translation in the target language only, even if the text is short or reads like a technical term."
+}
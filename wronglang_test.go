@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// englishTranslator answers in English, and in French once told that it
+// did, unless it is stubborn.
+type englishTranslator struct {
+	stubborn bool
+	requests atomic.Int32
+}
+
+func (e *englishTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	e.requests.Add(1)
+	if promptHintsFrom(ctx).wrongLanguage == "English" && !e.stubborn {
+		return "Défaut pompe, pression haute", nil
+	}
+	return "Pump fault, pressure high", nil
+}
+
+func TestWrongLanguageRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		targetLang string
+		stubborn   bool
+		want       string
+		requests   int32
+		flagged    bool
+	}{
+		{"fixed on retry", "fr-FR", false, "Défaut pompe, pression haute", 2, false},
+		{"still wrong", "fr-FR", true, "Pump fault, pressure high", 2, true},
+		{"right language", "en-US", false, "Pump fault, pressure high", 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := newTestJob(t, [][]string{{"de-DE", tt.targetLang}, {"Pumpenstörung, Druck hoch", ""}})
+			job.targetLang = tt.targetLang
+			job.report = &fileReport{}
+			translator := &englishTranslator{stubborn: tt.stubborn}
+
+			iterateAndTranslate(context.Background(), discardSender{}, translator, job)
+
+			if got, _ := job.f.GetCellValue(job.sheetName, "B2"); got != tt.want {
+				t.Errorf("B2 = %q, want %q", got, tt.want)
+			}
+			if n := translator.requests.Load(); n != tt.requests {
+				t.Errorf("%d requests, want %d", n, tt.requests)
+			}
+			e := job.report.entries[len(job.report.entries)-1]
+			if flagged := strings.Contains(strings.Join(e.flags, ";"), "wrong language: looks like English, not fr-FR"); flagged != tt.flagged {
+				t.Errorf("flags = %q", e.flags)
+			}
+		})
+	}
+}
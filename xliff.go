@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+
+	"tiaprojecttexts_translator_go/pkg/tiatrans"
+)
+
+// ///////////////////
+// XLIFF EXCHANGE
+// ///////////////////
+
+// XLIFF 2.0 lets a translation agency work on the texts in their own tools
+// while the workbook keeps the layout TIA needs. Every row becomes a unit
+// whose id is the 1-based sheet row, so the import can put each translation
+// back where it came from.
+
+type xliffDocument struct {
+	XMLName xml.Name  `xml:"urn:oasis:names:tc:xliff:document:2.0 xliff"`
+	Version string    `xml:"version,attr"`
+	SrcLang string    `xml:"srcLang,attr"`
+	TrgLang string    `xml:"trgLang,attr,omitempty"`
+	File    xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	ID       string      `xml:"id,attr"`
+	Original string      `xml:"original,attr,omitempty"`
+	Notes    []xliffNote `xml:"notes>note,omitempty"`
+	Units    []xliffUnit `xml:"unit"`
+}
+
+// The sheet name is kept in a note; file ids must be NMTOKENs, which sheet
+// names with spaces are not.
+type xliffNote struct {
+	Category string `xml:"category,attr,omitempty"`
+	Text     string `xml:",chardata"`
+}
+
+type xliffUnit struct {
+	ID       string         `xml:"id,attr"`
+	Segments []xliffSegment `xml:"segment"`
+}
+
+// Segment text is read like TMX segments: inline codes are flattened to
+// their text.
+type xliffSegment struct {
+	Source tmxSeg  `xml:"source"`
+	Target *tmxSeg `xml:"target,omitempty"`
+}
+
+// xliffRow is one row exchanged with an XLIFF file.
+type xliffRow struct {
+	row    int // 1-based sheet row
+	source string
+	target string
+}
+
+// xliffLang turns a column header into a language code, dropping the "*"
+// TIA puts on the reference language.
+func xliffLang(header string) string {
+	return strings.TrimSuffix(strings.TrimSpace(header), "*")
+}
+
+// writeXLIFF writes rows as an XLIFF 2.0 document with one unit per row.
+func writeXLIFF(w io.Writer, original, sheet, srcLang, trgLang string, rows []xliffRow) error {
+	doc := xliffDocument{
+		Version: "2.0",
+		SrcLang: srcLang,
+		TrgLang: trgLang,
+		File: xliffFile{
+			ID:       "f1",
+			Original: original,
+			Notes:    []xliffNote{{Category: "sheet", Text: sheet}},
+		},
+	}
+	for _, r := range rows {
+		seg := xliffSegment{Source: tmxSeg{Text: r.source}}
+		if r.target != "" {
+			seg.Target = &tmxSeg{Text: r.target}
+		}
+		doc.File.Units = append(doc.File.Units, xliffUnit{ID: strconv.Itoa(r.row), Segments: []xliffSegment{seg}})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// sheet returns the sheet name recorded by writeXLIFF, if any.
+func (d *xliffDocument) sheet() string {
+	for _, n := range d.File.Notes {
+		if n.Category == "sheet" {
+			return n.Text
+		}
+	}
+	return ""
+}
+
+// readXLIFF reads the rows back. Units without a target, or with an id that
+// is not a row number, are left out; segments of a unit are joined.
+func readXLIFF(r io.Reader) (*xliffDocument, []xliffRow, error) {
+	var doc xliffDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("could not parse XLIFF: %w", err)
+	}
+	if !strings.HasPrefix(doc.Version, "2.") {
+		return nil, nil, fmt.Errorf("unsupported XLIFF version %q, expected 2.0", doc.Version)
+	}
+	var rows []xliffRow
+	for _, unit := range doc.File.Units {
+		row, err := strconv.Atoi(unit.ID)
+		if err != nil || row < 2 {
+			continue
+		}
+		var source, target strings.Builder
+		hasTarget := false
+		for _, seg := range unit.Segments {
+			source.WriteString(seg.Source.Text)
+			if seg.Target != nil {
+				target.WriteString(seg.Target.Text)
+				hasTarget = true
+			}
+		}
+		if !hasTarget {
+			continue
+		}
+		rows = append(rows, xliffRow{row: row, source: source.String(), target: target.String()})
+	}
+	return &doc, rows, nil
+}
+
+// runXLIFFCommand implements "xliff export" and "xliff import".
+func runXLIFFCommand(args []string) error {
+	usage := "usage: xliff export [--sheet NAME] --source-col COL --target-col COL WORKBOOK OUT.xlf | xliff import [--sheet NAME] [--target-col COL] WORKBOOK IN.xlf"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	fs := flag.NewFlagSet("xliff "+args[0], flag.ExitOnError)
+	sheet := fs.String("sheet", "", "Sheet to use (default: first sheet).")
+	sourceCol := fs.String("source-col", "", "Source language column, as a 1-based column number or header name.")
+	targetCol := fs.String("target-col", "", "Target language column, as a 1-based column number or header name.")
+	fs.Parse(args[1:])
+	if fs.NArg() != 2 {
+		return errors.New(usage)
+	}
+	fileName, path := fs.Arg(0), fs.Arg(1)
+
+	var doc *xliffDocument
+	var units []xliffRow
+	if args[0] == "import" {
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		doc, units, err = readXLIFF(in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+		if *sheet == "" {
+			*sheet = doc.sheet()
+		}
+	}
+
+	f, err := excelize.OpenFile(fileName)
+	if err != nil {
+		return fmt.Errorf("Error opening file: %v", err)
+	}
+	defer f.Close()
+	sheetName := f.GetSheetName(0)
+	if *sheet != "" {
+		sheetName = *sheet
+	}
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return fmt.Errorf("Error getting rows: %v", err)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("Sheet %q is empty", sheetName)
+	}
+	headers := rows[0]
+
+	switch args[0] {
+	case "export":
+		if *sourceCol == "" || *targetCol == "" {
+			return errors.New(usage)
+		}
+		src, err := tiatrans.ResolveColumn(headers, *sourceCol)
+		if err != nil {
+			return fmt.Errorf("--source-col: %v", err)
+		}
+		tgt, err := tiatrans.ResolveColumn(headers, *targetCol)
+		if err != nil {
+			return fmt.Errorf("--target-col: %v", err)
+		}
+
+		for i, row := range rows[1:] {
+			if src >= len(row) || strings.TrimSpace(row[src]) == "" {
+				continue
+			}
+			u := xliffRow{row: i + 2, source: row[src]}
+			if tgt < len(row) {
+				u.target = row[tgt]
+			}
+			units = append(units, u)
+		}
+
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := writeXLIFF(out, fileName, sheetName, xliffLang(headers[src]), xliffLang(headers[tgt]), units); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d rows to %s\n", len(units), path)
+
+	case "import":
+		spec := *targetCol
+		if spec == "" {
+			spec = doc.TrgLang
+		}
+		if spec == "" {
+			return fmt.Errorf("XLIFF has no trgLang; pass --target-col")
+		}
+		tgt, err := tiatrans.ResolveColumn(headers, spec)
+		if err != nil {
+			return fmt.Errorf("target column: %v", err)
+		}
+		src, err := tiatrans.ResolveColumn(headers, doc.SrcLang)
+		if err != nil {
+			src = -1 // source text cannot be checked
+		}
+
+		imported, changed := 0, 0
+		for _, u := range units {
+			if u.row > len(rows) {
+				fmt.Fprintf(os.Stderr, "Row %d: not in sheet, skipped\n", u.row)
+				continue
+			}
+			row := rows[u.row-1]
+			if src != -1 && (src >= len(row) || row[src] != u.source) {
+				changed++
+				fmt.Fprintf(os.Stderr, "Row %d: source text changed since export, skipped\n", u.row)
+				continue
+			}
+			cell, _ := excelize.CoordinatesToCellName(tgt+1, u.row)
+			f.SetCellValue(sheetName, cell, u.target)
+			imported++
+		}
+
+		newFileName := outputBaseName(fileName, "translated-") + ".xlsx"
+		if err := f.SaveAs(newFileName); err != nil {
+			return fmt.Errorf("Error saving new XLSX file: %v", err)
+		}
+		fmt.Printf("Imported %d rows into %s\n", imported, newFileName)
+		if changed > 0 {
+			fmt.Printf("%d rows skipped because their source text changed\n", changed)
+		}
+
+	default:
+		return errors.New(usage)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXLIFFRoundTrip(t *testing.T) {
+	rows := []xliffRow{
+		{row: 2, source: "Motorstörung", target: ""},
+		{row: 5, source: "Pumpe <an> & aus", target: "Pump <on> & off"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeXLIFF(&buf, "export.xlsx", "User Texts", "de-DE", "en-US", rows); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{`xmlns="urn:oasis:names:tc:xliff:document:2.0"`, `version="2.0"`, `srcLang="de-DE"`, `trgLang="en-US"`, `<unit id="5">`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in output:\n%s", want, out)
+		}
+	}
+
+	doc, got, err := readXLIFF(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.sheet() != "User Texts" {
+		t.Errorf("sheet = %q; expected User Texts", doc.sheet())
+	}
+	// The untranslated unit has no target and is left out.
+	if expected := rows[1:]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("read %+v; expected %+v", got, expected)
+	}
+}
+
+func TestReadXLIFFInlineCodes(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="de-DE" trgLang="fr-FR">
+  <file id="f1">
+    <unit id="3">
+      <segment><source>Ventil <ph id="1" equiv="{1}"/>offen.</source><target>Vanne ouverte.</target></segment>
+      <segment><source> Bitte prüfen</source><target> Veuillez vérifier</target></segment>
+    </unit>
+    <unit id="note"><segment><source>x</source><target>y</target></segment></unit>
+  </file>
+</xliff>`
+
+	_, got, err := readXLIFF(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []xliffRow{{row: 3, source: "Ventil offen. Bitte prüfen", target: "Vanne ouverte. Veuillez vérifier"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("read %+v; expected %+v", got, expected)
+	}
+}